@@ -0,0 +1,135 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/supremind/rbac/types"
+)
+
+var _ types.AuditSink = (*File)(nil)
+
+// File writes audit events to a file as json lines, and rotates it when it grows too large.
+// Rotated files are named path.1, path.2, ..., path.1 is the most recent one.
+type File struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+
+	f    *os.File
+	size int64
+	sync.Mutex
+}
+
+// NewFile opens or creates the file at path for appending audit events.
+// The file is rotated once it exceeds maxBytes, non-positive maxBytes means never rotate,
+// at most maxBackups rotated files are kept, older ones are removed.
+func NewFile(path string, maxBytes int64, maxBackups int) (*File, error) {
+	s := &File{
+		path:       path,
+		maxBytes:   maxBytes,
+		maxBackups: maxBackups,
+	}
+	if e := s.open(); e != nil {
+		return nil, e
+	}
+	return s, nil
+}
+
+func (s *File) open() error {
+	f, e := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if e != nil {
+		return fmt.Errorf("open audit file: %w", e)
+	}
+	info, e := f.Stat()
+	if e != nil {
+		f.Close()
+		return fmt.Errorf("stat audit file: %w", e)
+	}
+
+	s.f, s.size = f, info.Size()
+	return nil
+}
+
+// Audit implements types.AuditSink
+func (s *File) Audit(event types.AuditEvent) error {
+	line, e := json.Marshal(event)
+	if e != nil {
+		return e
+	}
+	line = append(line, '\n')
+
+	s.Lock()
+	defer s.Unlock()
+
+	if s.f == nil {
+		return os.ErrClosed
+	}
+	// a failed rotation leaves the file reopened, the event is still written to it
+	var rotated error
+	if s.maxBytes > 0 && s.size > 0 && s.size+int64(len(line)) > s.maxBytes {
+		if rotated = s.rotate(); s.f == nil {
+			return rotated
+		}
+	}
+
+	n, e := s.f.Write(line)
+	s.size += int64(n)
+	if e != nil {
+		return e
+	}
+	return rotated
+}
+
+// rotate closes the file, shifts backups, and reopens s.path whether shifting succeeds or not
+func (s *File) rotate() error {
+	e := s.f.Close()
+	s.f = nil
+	if e != nil {
+		e = fmt.Errorf("close audit file: %w", e)
+	} else {
+		e = s.shift()
+	}
+
+	if oe := s.open(); oe != nil {
+		return oe
+	}
+	return e
+}
+
+// shift renames the file and its backups to the next backups, or removes the file if no backup is kept
+func (s *File) shift() error {
+	if s.maxBackups > 0 {
+		os.Remove(s.backup(s.maxBackups))
+		for i := s.maxBackups - 1; i > 0; i-- {
+			if e := os.Rename(s.backup(i), s.backup(i+1)); e != nil && !os.IsNotExist(e) {
+				return fmt.Errorf("rotate audit file: %w", e)
+			}
+		}
+		if e := os.Rename(s.path, s.backup(1)); e != nil {
+			return fmt.Errorf("rotate audit file: %w", e)
+		}
+	} else if e := os.Remove(s.path); e != nil {
+		return fmt.Errorf("rotate audit file: %w", e)
+	}
+	return nil
+}
+
+func (s *File) backup(i int) string {
+	return fmt.Sprintf("%s.%d", s.path, i)
+}
+
+// Close closes the underlying file, events audited after closing are rejected
+func (s *File) Close() error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.f == nil {
+		return nil
+	}
+	e := s.f.Close()
+	s.f = nil
+	return e
+}
@@ -0,0 +1,101 @@
+package audit_test
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/supremind/rbac/audit"
+	. "github.com/supremind/rbac/types"
+)
+
+func TestAudit(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "audit test suit")
+}
+
+var _ = Describe("rotating file sink", func() {
+	var dir string
+	BeforeEach(func() {
+		var e error
+		dir, e = ioutil.TempDir("", "audit")
+		Expect(e).To(Succeed())
+	})
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	lines := func(path string) int {
+		f, e := os.Open(path)
+		Expect(e).To(Succeed())
+		defer f.Close()
+
+		n := 0
+		for s := bufio.NewScanner(f); s.Scan(); n++ {
+		}
+		return n
+	}
+
+	event := AuditEvent{Operation: AuditPermit, Subject: User("alan"), Object: Article("apollo"), Action: Read, After: Read}
+
+	It("should append events to the file", func() {
+		path := filepath.Join(dir, "audit.log")
+		sink, e := NewFile(path, 0, 0)
+		Expect(e).To(Succeed())
+		for i := 0; i < 10; i++ {
+			Expect(sink.Audit(event)).To(Succeed())
+		}
+		Expect(sink.Close()).To(Succeed())
+
+		Expect(lines(path)).To(Equal(10))
+		Expect(filepath.Glob(path + ".*")).To(BeEmpty())
+
+		sink, e = NewFile(path, 0, 0)
+		Expect(e).To(Succeed())
+		Expect(sink.Audit(event)).To(Succeed())
+		Expect(sink.Close()).To(Succeed())
+		Expect(lines(path)).To(Equal(11))
+	})
+
+	It("should rotate and keep limited backups", func() {
+		path := filepath.Join(dir, "audit.log")
+		sink, e := NewFile(path, 1, 2)
+		Expect(e).To(Succeed())
+		for i := 0; i < 5; i++ {
+			Expect(sink.Audit(event)).To(Succeed())
+		}
+		Expect(sink.Close()).To(Succeed())
+
+		Expect(lines(path)).To(Equal(1))
+		Expect(lines(path + ".1")).To(Equal(1))
+		Expect(lines(path + ".2")).To(Equal(1))
+		Expect(path + ".3").NotTo(BeAnExistingFile())
+	})
+
+	It("should keep writing to the file when rotation fails", func() {
+		path := filepath.Join(dir, "audit.log")
+		// a non empty directory in place of the backup can not be renamed over
+		Expect(os.MkdirAll(filepath.Join(path+".1", "busy"), 0755)).To(Succeed())
+
+		sink, e := NewFile(path, 1, 1)
+		Expect(e).To(Succeed())
+		Expect(sink.Audit(event)).To(Succeed())
+		Expect(sink.Audit(event)).NotTo(Succeed())
+		Expect(sink.Audit(event)).NotTo(Succeed())
+		Expect(sink.Close()).To(Succeed())
+
+		Expect(lines(path)).To(Equal(3))
+	})
+
+	It("should reject events after closed", func() {
+		sink, e := NewFile(filepath.Join(dir, "audit.log"), 0, 0)
+		Expect(e).To(Succeed())
+		Expect(sink.Close()).To(Succeed())
+		Expect(sink.Audit(event)).NotTo(Succeed())
+	})
+})
@@ -0,0 +1,31 @@
+// Package audit provides built-in sinks and samplers for rbac audit events
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/supremind/rbac/types"
+)
+
+var _ types.AuditSink = (*JSONLines)(nil)
+
+// JSONLines writes audit events to an io.Writer, one json document a line
+type JSONLines struct {
+	enc *json.Encoder
+	sync.Mutex
+}
+
+// NewJSONLines creates a JSONLines sink writing to w, writes to w are serialized
+func NewJSONLines(w io.Writer) *JSONLines {
+	return &JSONLines{enc: json.NewEncoder(w)}
+}
+
+// Audit implements types.AuditSink
+func (s *JSONLines) Audit(event types.AuditEvent) error {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.enc.Encode(event)
+}
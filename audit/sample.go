@@ -0,0 +1,24 @@
+package audit
+
+import (
+	"math/rand"
+
+	"github.com/supremind/rbac/types"
+)
+
+// AllDecisions chooses every decision to be audited
+func AllDecisions(types.AuditEvent) bool {
+	return true
+}
+
+// Denials chooses denied or failed decisions to be audited
+func Denials(event types.AuditEvent) bool {
+	return !event.Allowed || event.Error != nil
+}
+
+// SampleDecisions chooses decisions to be audited randomly, at the given rate between 0 and 1
+func SampleDecisions(rate float64) func(types.AuditEvent) bool {
+	return func(types.AuditEvent) bool {
+		return rand.Float64() < rate
+	}
+}
@@ -0,0 +1,184 @@
+package rbac_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/supremind/rbac"
+	"github.com/supremind/rbac/audit"
+	"github.com/supremind/rbac/persist/fake"
+	. "github.com/supremind/rbac/types"
+)
+
+type eventsSink struct {
+	events []AuditEvent
+}
+
+func (s *eventsSink) Audit(event AuditEvent) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+var _ = Describe("authorizer with audit sink", func() {
+	newAuthorizer := func(sink AuditSink, decisions func(AuditEvent) bool) Authorizer {
+		authz, e := rbac.New(context.Background(),
+			rbac.WithSubjectPersister(fake.NewGroupingPersister()),
+			rbac.WithObjectPersister(fake.NewGroupingPersister()),
+			rbac.WithPermissionPersister(fake.NewPermissionPersister()),
+			rbac.WithAuditSink(sink, decisions),
+		)
+		Expect(e).To(Succeed())
+		return authz
+	}
+
+	var sink *eventsSink
+	BeforeEach(func() {
+		sink = &eventsSink{}
+	})
+
+	It("should audit every mutation", func() {
+		authz := newAuthorizer(sink, nil)
+
+		Expect(authz.SubjectJoin(User("alan"), Role("editor"))).To(Succeed())
+		Expect(authz.ObjectJoin(Article("apollo"), Category("space"))).To(Succeed())
+		Expect(authz.Permit(Role("editor"), Category("space"), Read)).To(Succeed())
+		Expect(authz.Permit(Role("editor"), Category("space"), Write)).To(Succeed())
+		Expect(authz.Revoke(Role("editor"), Category("space"), Read)).To(Succeed())
+		Expect(authz.SubjectLeave(User("alan"), Role("editor"))).To(Succeed())
+		Expect(authz.RemoveCategory(Category("space"))).To(Succeed())
+
+		ops := make([]AuditOperation, 0, len(sink.events))
+		for _, event := range sink.events {
+			Expect(event.Time).NotTo(BeZero())
+			Expect(event.Error).To(BeNil())
+			ops = append(ops, event.Operation)
+		}
+		Expect(ops).To(Equal([]AuditOperation{
+			AuditSubjectJoin, AuditObjectJoin, AuditPermit, AuditPermit, AuditRevoke, AuditSubjectLeave, AuditRemoveCategory,
+		}))
+
+		Expect(sink.events[0].Entity).To(Equal(User("alan")))
+		Expect(sink.events[0].Group).To(Equal(Role("editor")))
+		Expect(sink.events[0].GroupsBefore).To(BeEmpty())
+		Expect(sink.events[0].GroupsAfter).To(Equal([]Group{Role("editor")}))
+
+		leave := sink.events[5]
+		Expect(leave.GroupsBefore).To(Equal([]Group{Role("editor")}))
+		Expect(leave.GroupsAfter).To(BeEmpty())
+
+		permit := sink.events[3]
+		Expect(permit.Subject).To(Equal(Role("editor")))
+		Expect(permit.Object).To(Equal(Category("space")))
+		Expect(permit.Before).To(Equal(Read))
+		Expect(permit.After).To(Equal(ReadWrite))
+
+		revoke := sink.events[4]
+		Expect(revoke.Before).To(Equal(ReadWrite))
+		Expect(revoke.After).To(Equal(Write))
+	})
+
+	It("should audit permissions revoked by removals", func() {
+		authz := newAuthorizer(sink, nil)
+
+		Expect(authz.SubjectJoin(User("alan"), Role("editor"))).To(Succeed())
+		Expect(authz.Permit(User("alan"), Article("apollo"), ReadWrite)).To(Succeed())
+		Expect(authz.Permit(Role("editor"), Article("apollo"), Read)).To(Succeed())
+		Expect(authz.Permit(Role("editor"), Category("space"), Write)).To(Succeed())
+		sink.events = nil
+
+		Expect(authz.RemoveArticle(Article("apollo"))).To(Succeed())
+		Expect(authz.RemoveRole(Role("editor"))).To(Succeed())
+		Expect(sink.events).To(HaveLen(2))
+
+		article := sink.events[0]
+		Expect(article.Operation).To(Equal(AuditRemoveArticle))
+		Expect(article.Entity).To(Equal(Article("apollo")))
+		Expect(article.GroupsBefore).To(BeNil())
+		Expect(article.Revoked).To(Equal([]AuditRevocation{
+			{Subject: Role("editor"), Object: Article("apollo"), Before: Read, After: None},
+			{Subject: User("alan"), Object: Article("apollo"), Before: ReadWrite, After: None},
+		}))
+
+		role := sink.events[1]
+		Expect(role.Operation).To(Equal(AuditRemoveRole))
+		Expect(role.Group).To(Equal(Role("editor")))
+		Expect(role.Revoked).To(Equal([]AuditRevocation{
+			{Subject: Role("editor"), Object: Category("space"), Before: Write, After: None},
+		}))
+	})
+
+	It("should audit failed mutations with errors", func() {
+		authz := newAuthorizer(sink, nil)
+
+		e := authz.SubjectLeave(User("alan"), Role("editor"))
+		Expect(errors.Is(e, ErrNotFound)).To(BeTrue())
+		Expect(sink.events).To(HaveLen(1))
+		Expect(sink.events[0].Error).To(Equal(e))
+	})
+
+	It("should audit chosen decisions only", func() {
+		authz := newAuthorizer(sink, audit.Denials)
+		Expect(authz.Permit(User("alan"), Article("apollo"), Read)).To(Succeed())
+		sink.events = nil
+
+		Expect(authz.Shall(User("alan"), Article("apollo"), Read)).To(BeTrue())
+		Expect(authz.Shall(User("alan"), Article("apollo"), Write)).To(BeFalse())
+
+		Expect(sink.events).To(HaveLen(1))
+		Expect(sink.events[0].Operation).To(Equal(AuditShall))
+		Expect(sink.events[0].Action).To(Equal(Write))
+		Expect(sink.events[0].Allowed).To(BeFalse())
+	})
+
+	It("should not audit decisions by default", func() {
+		authz := newAuthorizer(sink, nil)
+		Expect(authz.Shall(User("alan"), Article("apollo"), Read)).To(BeFalse())
+		Expect(sink.events).To(BeEmpty())
+	})
+
+	It("should write events as json lines", func() {
+		buf := &bytes.Buffer{}
+		authz := newAuthorizer(audit.NewJSONLines(buf), audit.AllDecisions)
+
+		Expect(authz.Permit(User("alan"), Article("apollo"), Read)).To(Succeed())
+		Expect(authz.Shall(User("alan"), Article("apollo"), Read)).To(BeTrue())
+		Expect(authz.SubjectJoin(User("alan"), Role("editor"))).To(Succeed())
+		Expect(authz.RemoveArticle(Article("apollo"))).To(Succeed())
+
+		dec := json.NewDecoder(buf)
+		var permit, shall, join, remove map[string]interface{}
+		Expect(dec.Decode(&permit)).To(Succeed())
+		Expect(dec.Decode(&shall)).To(Succeed())
+		Expect(dec.Decode(&join)).To(Succeed())
+		Expect(dec.Decode(&remove)).To(Succeed())
+
+		Expect(remove).To(HaveKeyWithValue("operation", "remove_article"))
+		Expect(remove).To(HaveKeyWithValue("revoked", ConsistOf(map[string]interface{}{
+			"subject": User("alan").String(),
+			"object":  Article("apollo").String(),
+			"before":  Read.String(),
+			"after":   "",
+		})))
+		Expect(remove).NotTo(HaveKey("groups_before"))
+
+		Expect(join).To(HaveKeyWithValue("operation", "subject_join"))
+		Expect(join).To(HaveKeyWithValue("groups_before", BeEmpty()))
+		Expect(join).To(HaveKeyWithValue("groups_after", ConsistOf(Role("editor").String())))
+
+		Expect(permit).To(HaveKeyWithValue("operation", "permit"))
+		Expect(permit).To(HaveKeyWithValue("subject", User("alan").String()))
+		Expect(permit).To(HaveKeyWithValue("object", Article("apollo").String()))
+		Expect(permit).To(HaveKeyWithValue("before", ""))
+		Expect(permit).To(HaveKeyWithValue("after", Read.String()))
+		Expect(permit).NotTo(HaveKey("allowed"))
+
+		Expect(shall).To(HaveKeyWithValue("operation", "shall"))
+		Expect(shall).To(HaveKeyWithValue("allowed", true))
+		Expect(shall).NotTo(HaveKey("before"))
+	})
+})
@@ -0,0 +1,95 @@
+package rbac_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/supremind/rbac"
+	"github.com/supremind/rbac/persist/fake"
+	. "github.com/supremind/rbac/types"
+)
+
+var _ = Describe("batch checks", func() {
+	for _, engine := range []struct {
+		name string
+		opts []rbac.AuthorizerOption
+	}{
+		{name: "synced"},
+		{name: "copy on write", opts: []rbac.AuthorizerOption{rbac.WithCopyOnWrite()}},
+	} {
+		engine := engine
+
+		Context(engine.name, func() {
+			var authz Authorizer
+
+			BeforeEach(func() {
+				opts := append([]rbac.AuthorizerOption{
+					rbac.WithSubjectPersister(fake.NewGroupingPersister()),
+					rbac.WithObjectPersister(fake.NewGroupingPersister()),
+					rbac.WithPermissionPersister(fake.NewPermissionPersister()),
+					rbac.WithPresetPolices(func(_ Authorizer, sub Subject, _ Object, act Action) bool {
+						return sub == User("root") && act == Read
+					}),
+				}, engine.opts...)
+				var e error
+				authz, e = rbac.New(context.Background(), opts...)
+				Expect(e).To(Succeed())
+
+				Expect(authz.SubjectJoin(User("alan"), Role("editor"))).To(Succeed())
+				Expect(authz.SubjectJoin(Role("editor"), Role("reader"))).To(Succeed())
+				Expect(authz.ObjectJoin(Article("apollo"), Category("history"))).To(Succeed())
+				Expect(authz.Permit(Role("reader"), Category("history"), Read)).To(Succeed())
+				Expect(authz.Permit(Role("editor"), Article("gemini"), Read|Write)).To(Succeed())
+				Expect(authz.Permit(User("alan"), Article("mercury"), Write)).To(Succeed())
+			})
+
+			checks := []ObjectAction{
+				{Object: Article("apollo"), Action: Read},
+				{Object: Article("apollo"), Action: Write},
+				{Object: Article("gemini"), Action: Read | Write},
+				{Object: Article("mercury"), Action: Write},
+				{Object: Article("mercury"), Action: Read},
+				{Object: Category("history"), Action: Read},
+				{Object: Article("skylab"), Action: Read},
+			}
+
+			It("should decide the same as checking one by one", func() {
+				for _, sub := range []Subject{User("alan"), User("bob"), User("root"), Role("editor"), Role("reader")} {
+					results, e := authz.(BatchChecker).ShallMany(sub, checks)
+					Expect(e).To(Succeed())
+					Expect(results).To(HaveLen(len(checks)))
+
+					for i, check := range checks {
+						allowed, e := authz.Shall(sub, check.Object, check.Action)
+						Expect(e).To(Succeed())
+						Expect(results[i]).To(Equal(allowed), "%s %s %s", sub, check.Object, check.Action)
+					}
+				}
+			})
+
+			It("should filter allowed objects in order", func() {
+				objs := []Object{Article("skylab"), Article("gemini"), Article("apollo"), Article("mercury"), Category("history")}
+
+				allowed, e := authz.(BatchChecker).FilterAllowed(User("alan"), Read, objs)
+				Expect(e).To(Succeed())
+				Expect(allowed).To(Equal([]Object{Article("gemini"), Article("apollo"), Category("history")}))
+
+				allowed, e = authz.(BatchChecker).FilterAllowed(User("root"), Read, objs)
+				Expect(e).To(Succeed())
+				Expect(allowed).To(Equal(objs))
+
+				allowed, e = authz.(BatchChecker).FilterAllowed(User("bob"), Write, objs)
+				Expect(e).To(Succeed())
+				Expect(allowed).To(BeEmpty())
+			})
+
+			It("should check empty batches", func() {
+				results, e := authz.(BatchChecker).ShallMany(User("alan"), nil)
+				Expect(e).To(Succeed())
+				Expect(results).To(BeEmpty())
+			})
+		})
+	}
+})
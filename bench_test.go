@@ -0,0 +1,481 @@
+package rbac_test
+
+import (
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/supremind/rbac"
+	"github.com/supremind/rbac/persist/fake"
+	"github.com/supremind/rbac/types"
+)
+
+const (
+	benchUsers      = 1000
+	benchRoles      = 50
+	benchArticles   = 1000
+	benchCategories = 20
+)
+
+var benchEngines = []struct {
+	name string
+	opts []rbac.AuthorizerOption
+}{
+	{name: "synced"},
+	{name: "copy on write", opts: []rbac.AuthorizerOption{rbac.WithCopyOnWrite()}},
+	{name: "compact", opts: []rbac.AuthorizerOption{rbac.WithCompactPolices()}},
+	{name: "sharded", opts: []rbac.AuthorizerOption{rbac.WithShards(16)}},
+}
+
+func newBenchAuthorizer(b *testing.B, opts ...rbac.AuthorizerOption) types.Authorizer {
+	opts = append([]rbac.AuthorizerOption{
+		rbac.WithSubjectPersister(fake.NewGroupingPersister()),
+		rbac.WithObjectPersister(fake.NewGroupingPersister()),
+		rbac.WithPermissionPersister(fake.NewPermissionPersister()),
+		rbac.WithLogger(logr.Discard()),
+	}, opts...)
+
+	authz, e := rbac.New(context.Background(), opts...)
+	if e != nil {
+		b.Fatal(e)
+	}
+
+	for i := 0; i < benchUsers; i++ {
+		for j := 0; j < 3; j++ {
+			if e := authz.SubjectJoin(benchUser(i), benchRole((i+j*17)%benchRoles)); e != nil {
+				b.Fatal(e)
+			}
+		}
+	}
+	for i := 0; i < benchArticles; i++ {
+		for j := 0; j < 2; j++ {
+			if e := authz.ObjectJoin(benchArticle(i), benchCategory((i+j*7)%benchCategories)); e != nil {
+				b.Fatal(e)
+			}
+		}
+	}
+	for i := 0; i < benchRoles; i++ {
+		if e := authz.Permit(benchRole(i), benchCategory(i%benchCategories), types.Read); e != nil {
+			b.Fatal(e)
+		}
+	}
+
+	return authz
+}
+
+func benchUser(i int) types.User         { return types.User(strconv.Itoa(i)) }
+func benchRole(i int) types.Role         { return types.Role(strconv.Itoa(i)) }
+func benchArticle(i int) types.Article   { return types.Article(strconv.Itoa(i)) }
+func benchCategory(i int) types.Category { return types.Category(strconv.Itoa(i)) }
+
+func BenchmarkShall(b *testing.B) {
+	for _, engine := range benchEngines {
+		b.Run(engine.name, func(b *testing.B) {
+			authz := newBenchAuthorizer(b, engine.opts...)
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				r := rand.New(rand.NewSource(rand.Int63()))
+				for pb.Next() {
+					if _, e := authz.Shall(benchUser(r.Intn(benchUsers)), benchArticle(r.Intn(benchArticles)), types.Read); e != nil {
+						b.Fatal(e)
+					}
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkShallWhileWriting(b *testing.B) {
+	for _, engine := range benchEngines {
+		b.Run(engine.name, func(b *testing.B) {
+			authz := newBenchAuthorizer(b, engine.opts...)
+
+			var stop int32
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				tick := time.NewTicker(100 * time.Microsecond)
+				defer tick.Stop()
+
+				r := rand.New(rand.NewSource(2))
+				for range tick.C {
+					if atomic.LoadInt32(&stop) != 0 {
+						return
+					}
+					sub, obj := benchUser(r.Intn(benchUsers)), benchArticle(r.Intn(benchArticles))
+					if e := authz.Permit(sub, obj, types.Write); e != nil {
+						b.Error(e)
+						return
+					}
+					if e := authz.Revoke(sub, obj, types.Write); e != nil {
+						b.Error(e)
+						return
+					}
+				}
+			}()
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				r := rand.New(rand.NewSource(rand.Int63()))
+				for pb.Next() {
+					if _, e := authz.Shall(benchUser(r.Intn(benchUsers)), benchArticle(r.Intn(benchArticles)), types.Read); e != nil {
+						b.Fatal(e)
+					}
+				}
+			})
+
+			b.StopTimer()
+			atomic.StoreInt32(&stop, 1)
+			<-done
+		})
+	}
+}
+
+func BenchmarkShallMany(b *testing.B) {
+	const batch = 100
+
+	for _, engine := range benchEngines {
+		b.Run(engine.name, func(b *testing.B) {
+			authz := newBenchAuthorizer(b, engine.opts...).(types.BatchChecker)
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				r := rand.New(rand.NewSource(rand.Int63()))
+				checks := make([]types.ObjectAction, batch)
+				for pb.Next() {
+					for i := range checks {
+						checks[i] = types.ObjectAction{Object: benchArticle(r.Intn(benchArticles)), Action: types.Read}
+					}
+					if _, e := authz.ShallMany(benchUser(r.Intn(benchUsers)), checks); e != nil {
+						b.Fatal(e)
+					}
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkShallHot checks a hot set of subjects and objects, as served by caches
+func BenchmarkShallHot(b *testing.B) {
+	const hotUsers, hotArticles = benchUsers / 10, benchArticles / 10
+
+	for _, engine := range []struct {
+		name string
+		opts []rbac.AuthorizerOption
+	}{
+		{name: "synced"},
+		{name: "decision cache", opts: []rbac.AuthorizerOption{rbac.WithDecisionCache(hotUsers * hotArticles)}},
+	} {
+		b.Run(engine.name, func(b *testing.B) {
+			authz := newBenchAuthorizer(b, engine.opts...)
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				r := rand.New(rand.NewSource(rand.Int63()))
+				for pb.Next() {
+					if _, e := authz.Shall(benchUser(r.Intn(hotUsers)), benchArticle(r.Intn(hotArticles)), types.Read); e != nil {
+						b.Fatal(e)
+					}
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkShallFanOut compares evaluating on the fly with the effective index, as users join more roles,
+// and articles join more categories. Denied checks are measured, which walk through all pairs of them on the fly.
+// Users are materialized before the warm index is measured, while the cold index holds one user only,
+// so that every check materializes its user: the index pays off when users are checked more times
+// than the cold cost divided by the on the fly cost, before they are evicted.
+func BenchmarkShallFanOut(b *testing.B) {
+	const groups = 128
+
+	for _, fanOut := range []int{1, 2, 4, 8, 16, 32} {
+		for _, engine := range []struct {
+			name string
+			opts []rbac.AuthorizerOption
+			warm bool
+		}{
+			{name: "on the fly"},
+			{name: "warm index", opts: []rbac.AuthorizerOption{rbac.WithEffectiveIndex(benchUsers * benchArticles)}, warm: true},
+			{name: "cold index", opts: []rbac.AuthorizerOption{rbac.WithEffectiveIndex(benchArticles)}},
+		} {
+			b.Run(strconv.Itoa(fanOut)+"/"+engine.name, func(b *testing.B) {
+				authz, e := rbac.New(context.Background(), append([]rbac.AuthorizerOption{
+					rbac.WithSubjectPersister(fake.NewGroupingPersister()),
+					rbac.WithObjectPersister(fake.NewGroupingPersister()),
+					rbac.WithPermissionPersister(fake.NewPermissionPersister()),
+					rbac.WithLogger(logr.Discard()),
+				}, engine.opts...)...)
+				if e != nil {
+					b.Fatal(e)
+				}
+
+				r := rand.New(rand.NewSource(1))
+				for i := 0; i < benchUsers; i++ {
+					for _, j := range r.Perm(groups)[:fanOut] {
+						if e := authz.SubjectJoin(benchUser(i), benchRole(j)); e != nil {
+							b.Fatal(e)
+						}
+					}
+				}
+				for i := 0; i < benchArticles; i++ {
+					for _, j := range r.Perm(groups)[:fanOut] {
+						if e := authz.ObjectJoin(benchArticle(i), benchCategory(j)); e != nil {
+							b.Fatal(e)
+						}
+					}
+				}
+				for i := 0; i < groups; i++ {
+					if e := authz.Permit(benchRole(i), benchCategory(i), types.Read); e != nil {
+						b.Fatal(e)
+					}
+				}
+				if engine.warm {
+					for i := 0; i < benchUsers; i++ {
+						if _, e := authz.Shall(benchUser(i), benchArticle(0), types.Write); e != nil {
+							b.Fatal(e)
+						}
+					}
+				}
+				b.ResetTimer()
+
+				for i := 0; i < b.N; i++ {
+					if _, e := authz.Shall(benchUser(r.Intn(benchUsers)), benchArticle(r.Intn(benchArticles)), types.Write); e != nil {
+						b.Fatal(e)
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkMemory reports heap taken by polices of about 1M users and 256K articles, loaded from persisters,
+// every user is in 3 roles, every article is in 2 categories, and every role is permitted on a category
+func BenchmarkMemory(b *testing.B) {
+	const (
+		users    = 1 << 20
+		articles = 1 << 18
+	)
+
+	sp, op, pp := fake.NewGroupingPersister(), fake.NewGroupingPersister(), fake.NewPermissionPersister()
+	for i := 0; i < users; i++ {
+		for j := 0; j < 3; j++ {
+			if e := sp.Insert(benchUser(i), benchRole((i+j*17)%benchRoles)); e != nil {
+				b.Fatal(e)
+			}
+		}
+	}
+	for i := 0; i < articles; i++ {
+		for j := 0; j < 2; j++ {
+			if e := op.Insert(benchArticle(i), benchCategory((i+j*7)%benchCategories)); e != nil {
+				b.Fatal(e)
+			}
+		}
+	}
+	for i := 0; i < benchRoles; i++ {
+		if e := pp.Insert(benchRole(i), benchCategory(i%benchCategories), types.Read); e != nil {
+			b.Fatal(e)
+		}
+	}
+
+	heapInUse := func() uint64 {
+		runtime.GC()
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		return stats.HeapInuse
+	}
+
+	for _, engine := range []struct {
+		name string
+		opts []rbac.AuthorizerOption
+	}{
+		{name: "fat"},
+		{name: "compact", opts: []rbac.AuthorizerOption{rbac.WithCompactPolices()}},
+	} {
+		b.Run(engine.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ctx, cancel := context.WithCancel(context.Background())
+				before := heapInUse()
+				authz, e := rbac.New(ctx, append([]rbac.AuthorizerOption{
+					rbac.WithSubjectPersister(sp),
+					rbac.WithObjectPersister(op),
+					rbac.WithPermissionPersister(pp),
+					rbac.WithLogger(logr.Discard()),
+				}, engine.opts...)...)
+				if e != nil {
+					b.Fatal(e)
+				}
+				after := heapInUse()
+				runtime.KeepAlive(authz)
+				cancel()
+
+				b.ReportMetric(float64(after-before)/(1<<20), "MB")
+				b.ReportMetric(float64(after-before)/(users+articles), "B/entity")
+			}
+		})
+	}
+}
+
+// slowGroupingPersister takes latency for every write, as round-trips to a remote storage do
+type slowGroupingPersister struct {
+	types.GroupingPersister
+	latency time.Duration
+}
+
+func (p slowGroupingPersister) Insert(ent types.Entity, group types.Group) error {
+	time.Sleep(p.latency)
+	return p.GroupingPersister.Insert(ent, group)
+}
+
+// BenchmarkJoinContention joins different users to roles from many goroutines,
+// writes to persisters take no time, or some latency like round-trips to a remote storage
+func BenchmarkJoinContention(b *testing.B) {
+	for _, latency := range []time.Duration{0, 100 * time.Microsecond} {
+		for _, engine := range []struct {
+			name string
+			opts []rbac.AuthorizerOption
+		}{
+			{name: "synced"},
+			{name: "8 shards", opts: []rbac.AuthorizerOption{rbac.WithShards(8)}},
+			{name: "64 shards", opts: []rbac.AuthorizerOption{rbac.WithShards(64)}},
+		} {
+			b.Run(latency.String()+"/"+engine.name, func(b *testing.B) {
+				authz, e := rbac.New(context.Background(), append([]rbac.AuthorizerOption{
+					rbac.WithSubjectPersister(slowGroupingPersister{fake.NewGroupingPersister(), latency}),
+					rbac.WithObjectPersister(fake.NewGroupingPersister()),
+					rbac.WithPermissionPersister(fake.NewPermissionPersister()),
+					rbac.WithLogger(logr.Discard()),
+				}, engine.opts...)...)
+				if e != nil {
+					b.Fatal(e)
+				}
+				var users int64
+				b.SetParallelism(64)
+				b.ResetTimer()
+
+				b.RunParallel(func(pb *testing.PB) {
+					for pb.Next() {
+						i := int(atomic.AddInt64(&users, 1))
+						if e := authz.SubjectJoin(benchUser(i), benchRole(i%benchRoles)); e != nil {
+							b.Fatal(e)
+						}
+					}
+				})
+			})
+		}
+	}
+}
+
+// BenchmarkStartup starts authorizers with polices of about 128K users and 32K articles,
+// by listing them from persisters, or by loading a snapshot file and resuming watching changes after it
+func BenchmarkStartup(b *testing.B) {
+	const (
+		users    = 1 << 17
+		articles = 1 << 15
+	)
+
+	sp, op, pp := fake.NewGroupingPersister(), fake.NewGroupingPersister(), fake.NewPermissionPersister()
+	for i := 0; i < users; i++ {
+		for j := 0; j < 3; j++ {
+			if e := sp.Insert(benchUser(i), benchRole((i+j*17)%benchRoles)); e != nil {
+				b.Fatal(e)
+			}
+		}
+	}
+	// roles form a binary tree
+	for i := 1; i < benchRoles; i++ {
+		if e := sp.Insert(benchRole(i), benchRole(i/2)); e != nil {
+			b.Fatal(e)
+		}
+	}
+	for i := 0; i < articles; i++ {
+		for j := 0; j < 2; j++ {
+			if e := op.Insert(benchArticle(i), benchCategory((i+j*7)%benchCategories)); e != nil {
+				b.Fatal(e)
+			}
+		}
+	}
+	for i := 0; i < benchRoles; i++ {
+		if e := pp.Insert(benchRole(i), benchCategory(i%benchCategories), types.Read); e != nil {
+			b.Fatal(e)
+		}
+	}
+
+	dir, e := ioutil.TempDir("", "rbac-bench")
+	if e != nil {
+		b.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "snapshot")
+
+	start := func(opts ...rbac.AuthorizerOption) context.CancelFunc {
+		ctx, cancel := context.WithCancel(context.Background())
+		_, e := rbac.New(ctx, append([]rbac.AuthorizerOption{
+			rbac.WithSubjectPersister(sp),
+			rbac.WithObjectPersister(op),
+			rbac.WithPermissionPersister(pp),
+			rbac.WithLogger(logr.Discard()),
+		}, opts...)...)
+		if e != nil {
+			b.Fatal(e)
+		}
+		return cancel
+	}
+
+	// save the snapshot once, it is saved when the authorizer is done
+	start(rbac.WithSnapshotFile(path, 0))()
+	for {
+		if _, e := os.Stat(path); e == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// authorizers are done after timing, since snapshots are saved then
+	b.Run("list", func(b *testing.B) {
+		cancels := make([]context.CancelFunc, 0, b.N)
+		for i := 0; i < b.N; i++ {
+			cancels = append(cancels, start())
+		}
+		b.StopTimer()
+		for _, cancel := range cancels {
+			cancel()
+		}
+	})
+
+	b.Run("snapshot", func(b *testing.B) {
+		// every authorizer has its own copy of the snapshot, so that the snapshot stays the same
+		raw, e := ioutil.ReadFile(path)
+		if e != nil {
+			b.Fatal(e)
+		}
+		copies := make([]string, b.N)
+		for i := range copies {
+			copies[i] = filepath.Join(dir, strconv.Itoa(i))
+			if e := ioutil.WriteFile(copies[i], raw, 0644); e != nil {
+				b.Fatal(e)
+			}
+		}
+		b.ReportMetric(float64(len(raw))/(1<<20), "MB")
+
+		cancels := make([]context.CancelFunc, 0, b.N)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			cancels = append(cancels, start(rbac.WithSnapshotFile(copies[i], 0)))
+		}
+		b.StopTimer()
+		for _, cancel := range cancels {
+			cancel()
+		}
+	})
+}
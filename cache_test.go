@@ -0,0 +1,135 @@
+package rbac_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/supremind/rbac"
+	"github.com/supremind/rbac/persist/fake"
+	. "github.com/supremind/rbac/types"
+)
+
+// remoteGroupingPersister pushes changes made by other replicas
+type remoteGroupingPersister struct {
+	GroupingPersister
+	changes chan GroupingPolicyChange
+}
+
+func (p remoteGroupingPersister) Watch(context.Context) (<-chan GroupingPolicyChange, error) {
+	return p.changes, nil
+}
+
+// remotePermissionPersister pushes changes made by other replicas
+type remotePermissionPersister struct {
+	PermissionPersister
+	changes chan PermissionPolicyChange
+}
+
+func (p remotePermissionPersister) Watch(context.Context) (<-chan PermissionPolicyChange, error) {
+	return p.changes, nil
+}
+
+var _ = Describe("authorizer with decision cache", func() {
+	var (
+		ctx      context.Context
+		cancel   context.CancelFunc
+		subjects chan GroupingPolicyChange
+		objects  chan GroupingPolicyChange
+		perms    chan PermissionPolicyChange
+		authz    Authorizer
+	)
+
+	stats := func() DecisionCacheStats {
+		s, e := authz.(DecisionCacher).DecisionCacheStats()
+		Expect(e).To(Succeed())
+		return s
+	}
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		subjects = make(chan GroupingPolicyChange)
+		objects = make(chan GroupingPolicyChange)
+		perms = make(chan PermissionPolicyChange)
+
+		var e error
+		authz, e = rbac.New(ctx,
+			rbac.WithSubjectPersister(remoteGroupingPersister{fake.NewGroupingPersister(), subjects}),
+			rbac.WithObjectPersister(remoteGroupingPersister{fake.NewGroupingPersister(), objects}),
+			rbac.WithPermissionPersister(remotePermissionPersister{fake.NewPermissionPersister(), perms}),
+			rbac.WithDecisionCache(100),
+		)
+		Expect(e).To(Succeed())
+
+		Expect(authz.SubjectJoin(User("alan"), Role("editor"))).To(Succeed())
+		Expect(authz.SubjectJoin(User("bob"), Role("reader"))).To(Succeed())
+		Expect(authz.ObjectJoin(Article("apollo"), Category("history"))).To(Succeed())
+		Expect(authz.ObjectJoin(Article("gemini"), Category("science"))).To(Succeed())
+		Expect(authz.Permit(Role("editor"), Category("history"), Read|Write)).To(Succeed())
+		Expect(authz.Permit(Role("reader"), Category("science"), Read)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	It("should serve decisions from the cache", func() {
+		Expect(authz.Shall(User("alan"), Article("apollo"), Write)).To(BeTrue())
+		Expect(authz.Shall(User("alan"), Article("apollo"), Write)).To(BeTrue())
+		Expect(authz.PermittedActions(User("bob"), Article("gemini"))).To(Equal(Read))
+		Expect(authz.PermittedActions(User("bob"), Article("gemini"))).To(Equal(Read))
+
+		Expect(stats()).To(Equal(DecisionCacheStats{Size: 2, Hits: 2, Misses: 2}))
+	})
+
+	It("should drop decisions depending on local changes only", func() {
+		Expect(authz.Shall(User("alan"), Article("apollo"), Read)).To(BeTrue())
+		Expect(authz.Shall(User("bob"), Article("gemini"), Read)).To(BeTrue())
+
+		Expect(authz.Revoke(Role("editor"), Category("history"), Read)).To(Succeed())
+		Expect(stats().Invalidations).To(BeEquivalentTo(1))
+		Expect(authz.Shall(User("alan"), Article("apollo"), Read)).To(BeFalse())
+		Expect(authz.Shall(User("bob"), Article("gemini"), Read)).To(BeTrue())
+
+		Expect(authz.SubjectLeave(User("bob"), Role("reader"))).To(Succeed())
+		Expect(authz.Shall(User("bob"), Article("gemini"), Read)).To(BeFalse())
+
+		Expect(authz.ObjectJoin(Article("apollo"), Category("science"))).To(Succeed())
+		Expect(authz.SubjectJoin(User("alan"), Role("reader"))).To(Succeed())
+		Expect(authz.Shall(User("alan"), Article("apollo"), Read)).To(BeTrue())
+
+		Expect(authz.RemoveRole(Role("reader"))).To(Succeed())
+		Expect(authz.Shall(User("alan"), Article("apollo"), Read)).To(BeFalse())
+		Expect(authz.Shall(User("alan"), Article("apollo"), Write)).To(BeTrue())
+	})
+
+	It("should drop decisions depending on changes of other replicas", func() {
+		Expect(authz.Shall(User("alan"), Article("apollo"), Write)).To(BeTrue())
+		perms <- PermissionPolicyChange{
+			PermissionPolicy: PermissionPolicy{Subject: Role("editor"), Object: Category("history"), Action: Read},
+			Method:           PersistUpdate,
+		}
+		Eventually(func() (bool, error) { return authz.Shall(User("alan"), Article("apollo"), Write) }).Should(BeFalse())
+
+		Expect(authz.Shall(User("carl"), Article("gemini"), Read)).To(BeFalse())
+		subjects <- GroupingPolicyChange{GroupingPolicy: GroupingPolicy{Entity: User("carl"), Group: Role("reader")}, Method: PersistInsert}
+		Eventually(func() (bool, error) { return authz.Shall(User("carl"), Article("gemini"), Read) }).Should(BeTrue())
+
+		Expect(authz.Shall(User("carl"), Article("skylab"), Read)).To(BeFalse())
+		objects <- GroupingPolicyChange{GroupingPolicy: GroupingPolicy{Entity: Article("skylab"), Group: Category("science")}, Method: PersistInsert}
+		Eventually(func() (bool, error) { return authz.Shall(User("carl"), Article("skylab"), Read) }).Should(BeTrue())
+	})
+
+	It("should decide the same as without the cache", func() {
+		expectSameDecisions(ctx, authz)
+		Expect(stats().Hits).NotTo(BeZero())
+	})
+
+	It("should report no cache if decisions are not cached", func() {
+		plain, e := rbac.New(ctx, rbac.WithPermissionPersister(fake.NewPermissionPersister()))
+		Expect(e).To(Succeed())
+		_, e = plain.(DecisionCacher).DecisionCacheStats()
+		Expect(e).To(MatchError(ErrNoDecisionCache))
+	})
+})
@@ -0,0 +1,89 @@
+// Package casbin converts between Casbin RBAC polices in CSV and polices of this library,
+// so that services migrating from Casbin could run the two side by side.
+//
+// Polices are lines like:
+//
+//	p, editor, news, read
+//	g, alice, editor
+//	g2, apollo, news
+//
+// p lines are permissions, g lines are subject groupings and g2 lines are object groupings.
+// Casbin names are untyped, names in the group column of g lines are read as roles, and other subjects as users,
+// names in the group column of g2 lines are read as categories, and other objects as articles.
+// Names in the serialized form of entities, like role:editor, are read as they are.
+// Actions are parsed by types.ParseAction, unless they are mapped by WithActions.
+//
+// Constructs beyond the RBAC model, like domains, deny effects, wildcards and patterns, are reported as unsupported.
+package casbin
+
+import (
+	"fmt"
+
+	"github.com/supremind/rbac/types"
+)
+
+// Option configures conversions
+type Option func(*converter)
+
+// WithActions maps Casbin action names to actions, both ways.
+// For example, mapping GET to types.Read, and * to types.AllActions.
+// Names not mapped are parsed by types.ParseAction on reading.
+func WithActions(names map[string]types.Action) Option {
+	return func(c *converter) {
+		for name, act := range names {
+			c.actions[name] = act
+			if _, ok := c.names[act]; !ok || name < c.names[act] {
+				c.names[act] = name // the same name is chosen for the same action every time
+			}
+		}
+	}
+}
+
+// WithBareNames writes entities by names, like editor instead of role:editor.
+// Bare names are what Casbin polices usually use, but types of entities are inferred when they are read back,
+// so roles without members would be read as users, and categories without members would be read as articles.
+func WithBareNames() Option {
+	return func(c *converter) {
+		c.bare = true
+	}
+}
+
+type converter struct {
+	actions map[string]types.Action
+	names   map[types.Action]string
+	bare    bool
+}
+
+func newConverter(opts ...Option) *converter {
+	c := &converter{
+		actions: make(map[string]types.Action),
+		names:   make(map[types.Action]string),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Report tells constructs skipped in conversions
+type Report struct {
+	Unsupported []Unsupported
+}
+
+// Unsupported is a construct could not be converted
+type Unsupported struct {
+	Line   int // line number from 1, 0 if it is not about a single line
+	Text   string
+	Reason string
+}
+
+func (u Unsupported) String() string {
+	if u.Line == 0 {
+		return fmt.Sprintf("%s: %s", u.Text, u.Reason)
+	}
+	return fmt.Sprintf("line %d: %s: %s", u.Line, u.Text, u.Reason)
+}
+
+func (r *Report) unsupported(line int, text, reason string, args ...interface{}) {
+	r.Unsupported = append(r.Unsupported, Unsupported{Line: line, Text: text, Reason: fmt.Sprintf(reason, args...)})
+}
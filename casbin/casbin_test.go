@@ -0,0 +1,133 @@
+package casbin_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/supremind/rbac/casbin"
+	. "github.com/supremind/rbac/types"
+)
+
+func TestCasbin(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "casbin test suit")
+}
+
+const polices = `
+# polices migrated from casbin
+p, editor, news, read
+p, editor, news, write
+p, alice, apollo, GET
+p, bob, data, read, deny
+p, bob, /data/*, read
+p, bob, data, fly
+p, alice, news, read, tenant1
+g, alice, writer
+g, writer, editor
+g, bob, editor, tenant1
+g2, apollo, news
+g3, x, y
+`
+
+var _ = Describe("casbin polices", func() {
+	It("should read RBAC polices, and report unsupported ones", func() {
+		ps, report, e := casbin.Read(strings.NewReader(polices), casbin.WithActions(map[string]Action{"GET": Read}))
+		Expect(e).To(Succeed())
+
+		Expect(ps.Subjects).To(ConsistOf(
+			GroupingPolicy{Entity: User("alice"), Group: Role("writer")},
+			GroupingPolicy{Entity: Role("writer"), Group: Role("editor")},
+		))
+		Expect(ps.Objects).To(ConsistOf(GroupingPolicy{Entity: Article("apollo"), Group: Category("news")}))
+		Expect(ps.Permissions).To(ConsistOf(
+			PermissionPolicy{Subject: Role("editor"), Object: Category("news"), Action: ReadWrite},
+			PermissionPolicy{Subject: User("alice"), Object: Article("apollo"), Action: Read},
+		))
+
+		lines := make([]int, 0, len(report.Unsupported))
+		for _, u := range report.Unsupported {
+			lines = append(lines, u.Line)
+		}
+		Expect(lines).To(Equal([]int{6, 7, 8, 9, 12, 14}))
+		Expect(report.Unsupported[0].String()).To(Equal("line 6: p, bob, data, read, deny: deny effects are not supported"))
+	})
+
+	It("should write polices, and read them back", func() {
+		ps := Policies{
+			Subjects:    []GroupingPolicy{{Entity: User("alice"), Group: Role("editor")}},
+			Objects:     []GroupingPolicy{{Entity: Article("apollo"), Group: Category("news")}},
+			Permissions: []PermissionPolicy{{Subject: Role("editor"), Object: Category("news"), Action: ReadWriteExec}},
+		}
+
+		var out bytes.Buffer
+		report, e := casbin.Write(&out, ps, casbin.WithActions(map[string]Action{"*": ReadWriteExec}))
+		Expect(e).To(Succeed())
+		Expect(report.Unsupported).To(BeEmpty())
+		Expect(out.String()).To(Equal("p, role:editor, cat:news, *\ng, user:alice, role:editor\ng2, art:apollo, cat:news\n"))
+
+		out.Reset()
+		_, e = casbin.Write(&out, ps, casbin.WithBareNames())
+		Expect(e).To(Succeed())
+		Expect(out.String()).To(Equal(`p, editor, news, exec
+p, editor, news, read
+p, editor, news, write
+g, alice, editor
+g2, apollo, news
+`))
+
+		read, report, e := casbin.Read(&out)
+		Expect(e).To(Succeed())
+		Expect(report.Unsupported).To(BeEmpty())
+		Expect(read).To(Equal(ps))
+	})
+
+	It("should report clashing bare names", func() {
+		report, e := casbin.Write(&bytes.Buffer{}, Policies{
+			Permissions: []PermissionPolicy{{Subject: User("news"), Object: Category("news"), Action: Read}},
+		}, casbin.WithBareNames())
+		Expect(e).To(Succeed())
+		Expect(report.Unsupported).To(HaveLen(1))
+	})
+
+	It("should check models", func() {
+		report, e := casbin.CheckModel(strings.NewReader(`
+[request_definition]
+r = sub, obj, act
+
+[policy_definition]
+p = sub, obj, act
+
+[role_definition]
+g = _, _
+g2 = _, _
+
+[policy_effect]
+e = some(where (p.eft == allow))
+
+[matchers]
+m = g(r.sub, p.sub) && g2(r.obj, p.obj) && r.act == p.act
+`))
+		Expect(e).To(Succeed())
+		Expect(report.Unsupported).To(BeEmpty())
+
+		report, e = casbin.CheckModel(strings.NewReader(`
+[request_definition]
+r = sub, dom, obj, act
+
+[role_definition]
+g = _, _, _
+
+[policy_effect]
+e = !some(where (p.eft == deny))
+
+[matchers]
+m = g(r.sub, p.sub) && keyMatch(r.obj, p.obj) && r.act == p.act
+`))
+		Expect(e).To(Succeed())
+		Expect(report.Unsupported).To(HaveLen(4))
+	})
+})
@@ -0,0 +1,106 @@
+package casbin
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// CheckModel reads a Casbin model conf from r, and reports what are beyond the RBAC model of this library.
+// A supported model is like:
+//
+//	[request_definition]
+//	r = sub, obj, act
+//
+//	[policy_definition]
+//	p = sub, obj, act
+//
+//	[role_definition]
+//	g = _, _
+//	g2 = _, _
+//
+//	[policy_effect]
+//	e = some(where (p.eft == allow))
+//
+//	[matchers]
+//	m = g(r.sub, p.sub) && g2(r.obj, p.obj) && r.act == p.act
+//
+// Errors are returned only if r could not be read.
+func CheckModel(r io.Reader) (*Report, error) {
+	report := &Report{}
+	section := ""
+
+	scanner := bufio.NewScanner(r)
+	for number := 1; scanner.Scan(); number++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]") {
+			section = text[1 : len(text)-1]
+			continue
+		}
+
+		kv := strings.SplitN(text, "=", 2)
+		if len(kv) != 2 {
+			report.unsupported(number, text, "expecting key = value")
+			continue
+		}
+		key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+
+		switch section {
+		case "request_definition", "policy_definition":
+			checkDefinition(report, number, text, section, key, value)
+		case "role_definition":
+			if key != "g" && key != "g2" {
+				report.unsupported(number, text, "role definition %s is not supported, expecting g or g2", key)
+			} else if len(splitFields(value)) != 2 {
+				report.unsupported(number, text, "domains are not supported, expecting %s = _, _", key)
+			}
+		case "policy_effect":
+			if strings.Join(strings.Fields(value), "") != "some(where(p.eft==allow))" {
+				report.unsupported(number, text, "effects other than some(where (p.eft == allow)) are not supported")
+			}
+		case "matchers":
+			for _, fn := range functionPattern.FindAllStringSubmatch(value, -1) {
+				if fn[1] != "g" && fn[1] != "g2" {
+					report.unsupported(number, text, "function %s is not supported, only g and g2 are", fn[1])
+				}
+			}
+		default:
+			report.unsupported(number, text, "section %s is not supported", section)
+		}
+	}
+
+	return report, scanner.Err()
+}
+
+var functionPattern = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_]*)\s*\(`)
+
+func checkDefinition(report *Report, number int, text, section, key, value string) {
+	want := "r"
+	if section == "policy_definition" {
+		want = "p"
+	}
+	if key != want {
+		report.unsupported(number, text, "definition %s is not supported, expecting %s", key, want)
+		return
+	}
+
+	fields := splitFields(value)
+	if len(fields) == 4 && section == "policy_definition" && fields[3] == "eft" {
+		fields = fields[:3]
+	}
+	if strings.Join(fields, ", ") != "sub, obj, act" {
+		report.unsupported(number, text, "expecting %s = sub, obj, act", key)
+	}
+}
+
+func splitFields(value string) []string {
+	fields := strings.Split(value, ",")
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	return fields
+}
@@ -0,0 +1,302 @@
+package casbin
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/supremind/rbac/types"
+)
+
+// line of a policy file
+type line struct {
+	number int
+	text   string
+	fields []string
+}
+
+// Read reads Casbin polices in CSV from r, constructs could not be converted are skipped and reported.
+// Errors are returned only if r could not be read.
+func Read(r io.Reader, opts ...Option) (types.Policies, *Report, error) {
+	c := newConverter(opts...)
+	report := &Report{}
+
+	lines, e := readLines(r)
+	if e != nil {
+		return types.Policies{}, nil, e
+	}
+
+	// names in the group column of g and g2 lines are roles and categories
+	roles := make(map[string]struct{})
+	categories := make(map[string]struct{})
+	for _, l := range lines {
+		if len(l.fields) != 3 {
+			continue
+		}
+		switch l.fields[0] {
+		case "g":
+			roles[l.fields[2]] = struct{}{}
+		case "g2":
+			categories[l.fields[2]] = struct{}{}
+		}
+	}
+
+	var ps types.Policies
+	seen := make(map[types.GroupingPolicy]struct{})
+	perms := make(map[types.PermissionPolicy]int) // subject and object, without action, to index in ps.Permissions
+
+	for _, l := range lines {
+		switch ptype := l.fields[0]; ptype {
+		case "p":
+			if len(l.fields) == 5 && l.fields[4] == "allow" {
+				l.fields = l.fields[:4]
+			}
+			if len(l.fields) == 5 && l.fields[4] == "deny" {
+				report.unsupported(l.number, l.text, "deny effects are not supported")
+				continue
+			}
+			if len(l.fields) != 4 {
+				report.unsupported(l.number, l.text, "expecting p, sub, obj, act, domains and extra fields are not supported")
+				continue
+			}
+
+			sub, e := c.subject(l.fields[1], roles)
+			if e != nil {
+				report.unsupported(l.number, l.text, "%s", e)
+				continue
+			}
+			obj, e := c.object(l.fields[2], categories)
+			if e != nil {
+				report.unsupported(l.number, l.text, "%s", e)
+				continue
+			}
+			act, e := c.action(l.fields[3])
+			if e != nil {
+				report.unsupported(l.number, l.text, "%s", e)
+				continue
+			}
+
+			key := types.PermissionPolicy{Subject: sub, Object: obj}
+			if i, ok := perms[key]; ok {
+				ps.Permissions[i].Action |= act
+				continue
+			}
+			perms[key] = len(ps.Permissions)
+			ps.Permissions = append(ps.Permissions, types.PermissionPolicy{Subject: sub, Object: obj, Action: act})
+
+		case "g", "g2":
+			if len(l.fields) != 3 {
+				report.unsupported(l.number, l.text, "expecting %s, member, group, domains and extra fields are not supported", ptype)
+				continue
+			}
+
+			var policy types.GroupingPolicy
+			if ptype == "g" {
+				sub, e := c.subject(l.fields[1], roles)
+				if e != nil {
+					report.unsupported(l.number, l.text, "%s", e)
+					continue
+				}
+				role, e := c.subject(l.fields[2], roles)
+				if e != nil {
+					report.unsupported(l.number, l.text, "%s", e)
+					continue
+				}
+				if _, ok := role.(types.Role); !ok {
+					report.unsupported(l.number, l.text, "%s is not a role", l.fields[2])
+					continue
+				}
+				policy = types.GroupingPolicy{Entity: sub, Group: role.(types.Role)}
+			} else {
+				obj, e := c.object(l.fields[1], categories)
+				if e != nil {
+					report.unsupported(l.number, l.text, "%s", e)
+					continue
+				}
+				cat, e := c.object(l.fields[2], categories)
+				if e != nil {
+					report.unsupported(l.number, l.text, "%s", e)
+					continue
+				}
+				if _, ok := cat.(types.Category); !ok {
+					report.unsupported(l.number, l.text, "%s is not a category", l.fields[2])
+					continue
+				}
+				policy = types.GroupingPolicy{Entity: obj, Group: cat.(types.Category)}
+			}
+
+			if _, ok := seen[policy]; ok {
+				continue
+			}
+			seen[policy] = struct{}{}
+			if ptype == "g" {
+				ps.Subjects = append(ps.Subjects, policy)
+			} else {
+				ps.Objects = append(ps.Objects, policy)
+			}
+
+		default:
+			report.unsupported(l.number, l.text, "policy type %s is not supported, expecting p, g or g2", ptype)
+		}
+	}
+
+	return ps, report, nil
+}
+
+func readLines(r io.Reader) ([]line, error) {
+	var lines []line
+	scanner := bufio.NewScanner(r)
+	for number := 1; scanner.Scan(); number++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		cr := csv.NewReader(strings.NewReader(text))
+		cr.TrimLeadingSpace = true
+		fields, e := cr.Read()
+		if e != nil {
+			return nil, fmt.Errorf("line %d: %w", number, e)
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		lines = append(lines, line{number: number, text: text, fields: fields})
+	}
+	return lines, scanner.Err()
+}
+
+// subject parses name as a role if it is one of roles, or as a user otherwise
+func (c *converter) subject(name string, roles map[string]struct{}) (types.Subject, error) {
+	if e := checkName(name); e != nil {
+		return nil, e
+	}
+	if ent, e := types.ParseEntity(name); e == nil {
+		sub, ok := ent.(types.Subject)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a subject", name)
+		}
+		return sub, nil
+	}
+	if _, ok := roles[name]; ok {
+		return types.Role(name), nil
+	}
+	return types.User(name), nil
+}
+
+// object parses name as a category if it is one of categories, or as an article otherwise
+func (c *converter) object(name string, categories map[string]struct{}) (types.Object, error) {
+	if e := checkName(name); e != nil {
+		return nil, e
+	}
+	if ent, e := types.ParseEntity(name); e == nil {
+		obj, ok := ent.(types.Object)
+		if !ok {
+			return nil, fmt.Errorf("%s is not an object", name)
+		}
+		return obj, nil
+	}
+	if _, ok := categories[name]; ok {
+		return types.Category(name), nil
+	}
+	return types.Article(name), nil
+}
+
+func checkName(name string) error {
+	if name == "" {
+		return fmt.Errorf("empty name")
+	}
+	if strings.ContainsAny(name, "*()") {
+		return fmt.Errorf("patterns like %s are not supported", name)
+	}
+	return nil
+}
+
+func (c *converter) action(name string) (types.Action, error) {
+	if act, ok := c.actions[name]; ok {
+		return act, nil
+	}
+	if strings.ContainsAny(name, "*()") {
+		return 0, fmt.Errorf("patterns like %s are not supported, map them by WithActions", name)
+	}
+	act, e := types.ParseAction(name)
+	if e != nil {
+		return 0, fmt.Errorf("%w, map it by WithActions", e)
+	}
+	return act, nil
+}
+
+// Write writes polices to w as Casbin polices in CSV, p lines first, then g and g2 lines, each sorted.
+// A p line is written for each single action, unless the union of actions is mapped to a name by WithActions.
+// Bare names of different entities clashing with each other, and actions without names, are reported.
+func Write(w io.Writer, ps types.Policies, opts ...Option) (*Report, error) {
+	c := newConverter(opts...)
+	report := &Report{}
+	named := make(map[string]types.Entity)
+
+	name := func(ent types.Entity) string {
+		if !c.bare {
+			return ent.String()
+		}
+		n := strings.SplitN(ent.String(), ":", 2)[1]
+		if other, ok := named[n]; ok && other != ent {
+			report.unsupported(0, n, "both %s and %s are named %s", other, ent, n)
+		}
+		named[n] = ent
+		return n
+	}
+
+	var pLines, gLines, g2Lines []string
+	for _, policy := range ps.Permissions {
+		sub, obj := name(policy.Subject), name(policy.Object)
+		for _, act := range c.splitAction(policy.Action) {
+			an, ok := c.names[act]
+			if !ok {
+				an = act.String()
+				if _, e := types.ParseAction(an); e != nil {
+					report.unsupported(0, an, "action of %s on %s has no name", sub, obj)
+					continue
+				}
+			}
+			pLines = append(pLines, csvLine("p", sub, obj, an))
+		}
+	}
+	for _, policy := range ps.Subjects {
+		gLines = append(gLines, csvLine("g", name(policy.Entity), name(policy.Group)))
+	}
+	for _, policy := range ps.Objects {
+		g2Lines = append(g2Lines, csvLine("g2", name(policy.Entity), name(policy.Group)))
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, lines := range [][]string{pLines, gLines, g2Lines} {
+		sort.Strings(lines)
+		for _, l := range lines {
+			fmt.Fprintln(bw, l)
+		}
+	}
+	return report, bw.Flush()
+}
+
+// splitAction returns act itself if it is named, or single actions in it
+func (c *converter) splitAction(act types.Action) []types.Action {
+	if _, ok := c.names[act]; ok {
+		return []types.Action{act}
+	}
+	return act.Split()
+}
+
+// csvLine joins fields like Casbin does, fields are quoted only if necessary
+func csvLine(fields ...string) string {
+	quoted := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if strings.ContainsAny(f, ",\"\n") || strings.TrimSpace(f) != f {
+			f = `"` + strings.Replace(f, `"`, `""`, -1) + `"`
+		}
+		quoted = append(quoted, f)
+	}
+	return strings.Join(quoted, ", ")
+}
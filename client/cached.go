@@ -0,0 +1,458 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/supremind/rbac"
+	"github.com/supremind/rbac/diff"
+	"github.com/supremind/rbac/internal/wire"
+	"github.com/supremind/rbac/policy"
+	"github.com/supremind/rbac/types"
+)
+
+var (
+	_ types.Authorizer   = (*Cached)(nil)
+	_ types.PolicyLister = (*Cached)(nil)
+	_ types.BatchChecker = (*Cached)(nil)
+)
+
+// retry intervals of watching again after the change feed is broken
+const (
+	minRetryInterval = 100 * time.Millisecond
+	maxRetryInterval = 10 * time.Second
+)
+
+// Cached is a types.Authorizer mirroring polices of a server, which must be created with a server.Feed.
+// Reads are served by a local replica, and writes are forwarded to the server,
+// they are seen by reads after the server pushes them back, like changes made by others.
+type Cached struct {
+	remote   *Client
+	local    types.Authorizer
+	subjects chan types.GroupingPolicyChange
+	objects  chan types.GroupingPolicyChange
+	perms    chan types.PermissionPolicyChange
+	log      logr.Logger
+}
+
+// NewCached creates a Cached of the server at base, it starts with a snapshot of all polices,
+// and follows changes of them until ctx is done.
+// If the change feed is broken, it watches again, and catches up with the new snapshot.
+func NewCached(ctx context.Context, base string, opts ...Option) (*Cached, error) {
+	c := &Cached{
+		remote:   New(base, opts...),
+		subjects: make(chan types.GroupingPolicyChange),
+		objects:  make(chan types.GroupingPolicyChange),
+		perms:    make(chan types.PermissionPolicyChange),
+	}
+	c.log = c.remote.log
+
+	snapshot, s, e := c.remote.watch(ctx)
+	if e != nil {
+		return nil, e
+	}
+	ps, e := snapshot.Policies()
+	if e != nil {
+		s.close()
+		return nil, e
+	}
+
+	c.local, e = rbac.New(ctx,
+		rbac.WithSubjectPersister(&replicaGrouping{polices: ps.Subjects, changes: c.subjects}),
+		rbac.WithObjectPersister(&replicaGrouping{polices: ps.Objects, changes: c.objects}),
+		rbac.WithPermissionPersister(&replicaPermission{polices: ps.Permissions, changes: c.perms}),
+		rbac.WithLogger(c.log.WithName("replica")),
+	)
+	if e != nil {
+		s.close()
+		return nil, e
+	}
+
+	go c.follow(ctx, s)
+	return c, nil
+}
+
+// follow changes pushed by the server, until ctx is done
+func (c *Cached) follow(ctx context.Context, s *stream) {
+	for {
+		e := c.consume(ctx, s)
+		s.close()
+		if ctx.Err() != nil {
+			return
+		}
+		c.log.Error(e, "change feed is broken, watch again")
+
+		if s = c.rewatch(ctx); s == nil {
+			return
+		}
+	}
+}
+
+func (c *Cached) consume(ctx context.Context, s *stream) error {
+	for {
+		change, e := s.next()
+		if e != nil {
+			return e
+		}
+		if e := c.apply(ctx, change); e != nil {
+			return e
+		}
+	}
+}
+
+// rewatch watches again until succeeded, and catches up with the new snapshot, it returns nil if ctx is done
+func (c *Cached) rewatch(ctx context.Context) *stream {
+	interval := minRetryInterval
+	for {
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return nil
+		}
+
+		snapshot, s, e := c.remote.watch(ctx)
+		if e == nil {
+			if e = c.catchUp(ctx, snapshot); e != nil {
+				s.close()
+			}
+		}
+		if e == nil {
+			return s
+		}
+
+		c.log.Error(e, "watch again", "retry in", interval)
+		if interval *= 2; interval > maxRetryInterval {
+			interval = maxRetryInterval
+		}
+	}
+}
+
+// catchUp changes the replica to be the same as snapshot
+func (c *Cached) catchUp(ctx context.Context, snapshot *policy.Document) error {
+	want, e := snapshot.Policies()
+	if e != nil {
+		return e
+	}
+	have, e := c.Policies()
+	if e != nil {
+		return e
+	}
+	d := diff.Of(have, want)
+	c.log.V(4).Info("catch up with the snapshot", "subjects", len(d.Subjects.Added)+len(d.Subjects.Removed),
+		"objects", len(d.Objects.Added)+len(d.Objects.Removed), "permissions", len(d.Permissions))
+
+	for _, m := range []struct {
+		changes     chan types.GroupingPolicyChange
+		memberships diff.Memberships
+	}{{c.subjects, d.Subjects}, {c.objects, d.Objects}} {
+		for _, gp := range m.memberships.Removed {
+			if e := sendGrouping(ctx, m.changes, types.GroupingPolicyChange{GroupingPolicy: gp, Method: types.PersistDelete}); e != nil {
+				return e
+			}
+		}
+		for _, gp := range m.memberships.Added {
+			if e := sendGrouping(ctx, m.changes, types.GroupingPolicyChange{GroupingPolicy: gp, Method: types.PersistInsert}); e != nil {
+				return e
+			}
+		}
+	}
+
+	for _, p := range d.Permissions {
+		change := types.PermissionPolicyChange{
+			PermissionPolicy: types.PermissionPolicy{Subject: p.Subject, Object: p.Object, Action: p.After},
+			Method:           types.PersistUpdate,
+		}
+		if p.After == types.None {
+			change.Method = types.PersistDelete
+		}
+		if e := sendPermission(ctx, c.perms, change); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// apply a change pushed by the server to the replica
+func (c *Cached) apply(ctx context.Context, change *wire.Change) error {
+	method := types.PersistMethod(change.Method)
+
+	switch change.Kind {
+	case wire.KindSubject, wire.KindObject:
+		ent, e := types.ParseEntity(change.Entity)
+		if e != nil {
+			return fmt.Errorf("%w: %s", e, change.Entity)
+		}
+		group, e := types.ParseGroup(change.Group)
+		if e != nil {
+			return fmt.Errorf("%w: %s", e, change.Group)
+		}
+		changes := c.subjects
+		if change.Kind == wire.KindObject {
+			changes = c.objects
+		}
+		return sendGrouping(ctx, changes, types.GroupingPolicyChange{
+			GroupingPolicy: types.GroupingPolicy{Entity: ent, Group: group},
+			Method:         method,
+		})
+
+	case wire.KindPermission:
+		sub, e := types.ParseSubject(change.Subject)
+		if e != nil {
+			return fmt.Errorf("%w: %s", e, change.Subject)
+		}
+		obj, e := types.ParseObject(change.Object)
+		if e != nil {
+			return fmt.Errorf("%w: %s", e, change.Object)
+		}
+		act, e := wire.ParseAction(change.Action)
+		if e != nil {
+			return e
+		}
+		return sendPermission(ctx, c.perms, types.PermissionPolicyChange{
+			PermissionPolicy: types.PermissionPolicy{Subject: sub, Object: obj, Action: act},
+			Method:           method,
+		})
+	}
+
+	return fmt.Errorf("%w: %s changes", types.ErrUnsupportedChange, change.Kind)
+}
+
+func sendGrouping(ctx context.Context, changes chan<- types.GroupingPolicyChange, change types.GroupingPolicyChange) error {
+	select {
+	case changes <- change:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func sendPermission(ctx context.Context, changes chan<- types.PermissionPolicyChange, change types.PermissionPolicyChange) error {
+	select {
+	case changes <- change:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SubjectJoin joins a user or a sub role to a role
+func (c *Cached) SubjectJoin(sub types.Subject, role types.Role) error {
+	return c.remote.SubjectJoin(sub, role)
+}
+
+// SubjectLeave removes a user or a sub role from a role
+func (c *Cached) SubjectLeave(sub types.Subject, role types.Role) error {
+	return c.remote.SubjectLeave(sub, role)
+}
+
+// RemoveUser removes a user and all policies about it
+func (c *Cached) RemoveUser(user types.User) error {
+	return c.remote.RemoveUser(user)
+}
+
+// RemoveRole removes a role and all policies about it
+func (c *Cached) RemoveRole(role types.Role) error {
+	return c.remote.RemoveRole(role)
+}
+
+// Subjects returns the GroupingReader interface for subjects
+func (c *Cached) Subjects() types.GroupingReader {
+	return c.local.Subjects()
+}
+
+// ObjectJoin joins an article or a sub category to a category
+func (c *Cached) ObjectJoin(obj types.Object, cat types.Category) error {
+	return c.remote.ObjectJoin(obj, cat)
+}
+
+// ObjectLeave removes an article or a sub category from a category
+func (c *Cached) ObjectLeave(obj types.Object, cat types.Category) error {
+	return c.remote.ObjectLeave(obj, cat)
+}
+
+// RemoveArticle removes an article and all polices about it
+func (c *Cached) RemoveArticle(art types.Article) error {
+	return c.remote.RemoveArticle(art)
+}
+
+// RemoveCategory removes a category and all polices about it
+func (c *Cached) RemoveCategory(cat types.Category) error {
+	return c.remote.RemoveCategory(cat)
+}
+
+// Objects returns the GroupingReader interface for objects
+func (c *Cached) Objects() types.GroupingReader {
+	return c.local.Objects()
+}
+
+// Permit subject to perform action on object
+func (c *Cached) Permit(sub types.Subject, obj types.Object, act types.Action) error {
+	return c.remote.Permit(sub, obj, act)
+}
+
+// Revoke permission for subject to perform action on object
+func (c *Cached) Revoke(sub types.Subject, obj types.Object, act types.Action) error {
+	return c.remote.Revoke(sub, obj, act)
+}
+
+// Shall subject perform action on object
+func (c *Cached) Shall(sub types.Subject, obj types.Object, act types.Action) (bool, error) {
+	return c.local.Shall(sub, obj, act)
+}
+
+// ShallMany tells if subject shall perform actions on objects, results are in the order of checks
+func (c *Cached) ShallMany(sub types.Subject, checks []types.ObjectAction) ([]bool, error) {
+	return c.local.(types.BatchChecker).ShallMany(sub, checks)
+}
+
+// FilterAllowed returns objects subject shall perform action on, in their given order
+func (c *Cached) FilterAllowed(sub types.Subject, act types.Action, objs []types.Object) ([]types.Object, error) {
+	return c.local.(types.BatchChecker).FilterAllowed(sub, act, objs)
+}
+
+// PermissionsOn object for all subjects
+func (c *Cached) PermissionsOn(obj types.Object) (map[types.Subject]types.Action, error) {
+	return c.local.PermissionsOn(obj)
+}
+
+// PermissionsFor subject on all objects
+func (c *Cached) PermissionsFor(sub types.Subject) (map[types.Object]types.Action, error) {
+	return c.local.PermissionsFor(sub)
+}
+
+// PermittedActions for subject on object
+func (c *Cached) PermittedActions(sub types.Subject, obj types.Object) (types.Action, error) {
+	return c.local.PermittedActions(sub, obj)
+}
+
+// Policies lists all polices assigned directly, as they are in the replica
+func (c *Cached) Policies() (types.Policies, error) {
+	return c.local.(types.PolicyLister).Policies()
+}
+
+// stream of events pushed by the server
+type stream struct {
+	body   io.ReadCloser
+	dec    *json.Decoder
+	idle   *time.Timer
+	cancel context.CancelFunc
+}
+
+// idleTimeout is how long a stream could be quiet, before it is thought to be broken
+const idleTimeout = 3 * wire.Heartbeat
+
+// watch returns the snapshot of polices, and the stream of changes following it
+func (c *Client) watch(ctx context.Context) (*policy.Document, *stream, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	req, e := http.NewRequestWithContext(ctx, http.MethodGet, c.base+"/v1/watch", nil)
+	if e != nil {
+		cancel()
+		return nil, nil, e
+	}
+
+	hc := *c.hc
+	hc.Timeout = 0 // streams are checked by heartbeats
+	resp, e := hc.Do(req)
+	if e != nil {
+		cancel()
+		return nil, nil, e
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer cancel()
+		defer resp.Body.Close()
+		return nil, nil, errorOf(resp)
+	}
+
+	s := &stream{body: resp.Body, dec: json.NewDecoder(resp.Body), idle: time.AfterFunc(idleTimeout, cancel), cancel: cancel}
+	var event wire.Event
+	if e := s.read(&event); e != nil {
+		s.close()
+		return nil, nil, e
+	}
+	if event.Snapshot == nil {
+		s.close()
+		return nil, nil, errors.New("change feed does not start with a snapshot")
+	}
+	return event.Snapshot, s, nil
+}
+
+func (s *stream) read(event *wire.Event) error {
+	if e := s.dec.Decode(event); e != nil {
+		return e
+	}
+	s.idle.Reset(idleTimeout)
+	return nil
+}
+
+// next returns the next change, skipping heartbeats
+func (s *stream) next() (*wire.Change, error) {
+	for {
+		var event wire.Event
+		if e := s.read(&event); e != nil {
+			return nil, e
+		}
+		if event.Change != nil {
+			return event.Change, nil
+		}
+	}
+}
+
+func (s *stream) close() {
+	s.idle.Stop()
+	s.cancel()
+	s.body.Close()
+}
+
+// replicaGrouping is the read-only persister of replicas, listing the snapshot, and watching changes pushed
+type replicaGrouping struct {
+	polices []types.GroupingPolicy
+	changes chan types.GroupingPolicyChange
+}
+
+func (g *replicaGrouping) Insert(types.Entity, types.Group) error {
+	return types.ErrReadOnly
+}
+
+func (g *replicaGrouping) Remove(types.Entity, types.Group) error {
+	return types.ErrReadOnly
+}
+
+func (g *replicaGrouping) List() ([]types.GroupingPolicy, error) {
+	return g.polices, nil
+}
+
+func (g *replicaGrouping) Watch(context.Context) (<-chan types.GroupingPolicyChange, error) {
+	return g.changes, nil
+}
+
+// replicaPermission is the read-only persister of replicas, listing the snapshot, and watching changes pushed
+type replicaPermission struct {
+	polices []types.PermissionPolicy
+	changes chan types.PermissionPolicyChange
+}
+
+func (p *replicaPermission) Insert(types.Subject, types.Object, types.Action) error {
+	return types.ErrReadOnly
+}
+
+func (p *replicaPermission) Update(types.Subject, types.Object, types.Action) error {
+	return types.ErrReadOnly
+}
+
+func (p *replicaPermission) Remove(types.Subject, types.Object) error {
+	return types.ErrReadOnly
+}
+
+func (p *replicaPermission) List() ([]types.PermissionPolicy, error) {
+	return p.polices, nil
+}
+
+func (p *replicaPermission) Watch(context.Context) (<-chan types.PermissionPolicyChange, error) {
+	return p.changes, nil
+}
@@ -0,0 +1,403 @@
+// Package client implements types.Authorizer with a remote authorization server, see package server,
+// so that callers could swap local and remote authorizers.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/supremind/rbac/internal/wire"
+	"github.com/supremind/rbac/policy"
+	"github.com/supremind/rbac/types"
+)
+
+var (
+	_ types.Authorizer   = (*Client)(nil)
+	_ types.PolicyLister = (*Client)(nil)
+	_ types.BatchChecker = (*Client)(nil)
+)
+
+// DefaultTimeout of requests
+const DefaultTimeout = 5 * time.Second
+
+// Option configures clients
+type Option func(*Client)
+
+// WithHTTPClient sets the http client sending requests, a client with DefaultTimeout is used by default
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.hc = hc
+	}
+}
+
+// WithLogger sets the logger of cached clients, see NewCached
+func WithLogger(l logr.Logger) Option {
+	return func(c *Client) {
+		c.log = l
+	}
+}
+
+// Client is a types.Authorizer served by a remote server
+type Client struct {
+	base string
+	hc   *http.Client
+	log  logr.Logger
+}
+
+// New creates a Client of the server at base, like http://rbac.example.com
+func New(base string, opts ...Option) *Client {
+	c := &Client{
+		base: strings.TrimSuffix(base, "/"),
+		hc:   &http.Client{Timeout: DefaultTimeout},
+		log:  logr.Discard(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Error is returned by servers, it unwraps to the same error as local authorizers return, like types.ErrNotFound
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return wire.ErrorOf(e.Code)
+}
+
+// do sends a request, and decodes the response into out if it is not nil
+func (c *Client) do(method, path string, query url.Values, in, out interface{}) error {
+	u := c.base + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if in != nil {
+		content, e := json.Marshal(in)
+		if e != nil {
+			return e
+		}
+		body = bytes.NewReader(content)
+	}
+
+	req, e := http.NewRequestWithContext(context.Background(), method, u, body)
+	if e != nil {
+		return e
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, e := c.hc.Do(req)
+	if e != nil {
+		return e
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return errorOf(resp)
+	}
+
+	if out == nil {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// errorOf reads the error from a failed response
+func errorOf(resp *http.Response) error {
+	werr := wire.Error{}
+	content, _ := ioutil.ReadAll(resp.Body)
+	if e := json.Unmarshal(content, &werr); e != nil || werr.Code == "" {
+		return &Error{Status: resp.StatusCode, Code: "unknown", Message: fmt.Sprintf("%s %s: %s", resp.Request.Method, resp.Request.URL.Path, resp.Status)}
+	}
+	return &Error{Status: resp.StatusCode, Code: werr.Code, Message: werr.Message}
+}
+
+// SubjectJoin joins a user or a sub role to a role
+func (c *Client) SubjectJoin(sub types.Subject, role types.Role) error {
+	return c.do(http.MethodPost, "/v1/subjects/join", nil, wire.Grouping{Entity: sub.String(), Group: role.String()}, nil)
+}
+
+// SubjectLeave removes a user or a sub role from a role
+func (c *Client) SubjectLeave(sub types.Subject, role types.Role) error {
+	return c.do(http.MethodPost, "/v1/subjects/leave", nil, wire.Grouping{Entity: sub.String(), Group: role.String()}, nil)
+}
+
+// RemoveUser removes a user and all policies about it
+func (c *Client) RemoveUser(user types.User) error {
+	return c.removeEntity(user)
+}
+
+// RemoveRole removes a role and all policies about it
+func (c *Client) RemoveRole(role types.Role) error {
+	return c.removeEntity(role)
+}
+
+// Subjects returns the GroupingReader interface for subjects
+func (c *Client) Subjects() types.GroupingReader {
+	return &groupingReader{c: c, prefix: "/v1/subjects/"}
+}
+
+// ObjectJoin joins an article or a sub category to a category
+func (c *Client) ObjectJoin(obj types.Object, cat types.Category) error {
+	return c.do(http.MethodPost, "/v1/objects/join", nil, wire.Grouping{Entity: obj.String(), Group: cat.String()}, nil)
+}
+
+// ObjectLeave removes an article or a sub category from a category
+func (c *Client) ObjectLeave(obj types.Object, cat types.Category) error {
+	return c.do(http.MethodPost, "/v1/objects/leave", nil, wire.Grouping{Entity: obj.String(), Group: cat.String()}, nil)
+}
+
+// RemoveArticle removes an article and all polices about it
+func (c *Client) RemoveArticle(art types.Article) error {
+	return c.removeEntity(art)
+}
+
+// RemoveCategory removes a category and all polices about it
+func (c *Client) RemoveCategory(cat types.Category) error {
+	return c.removeEntity(cat)
+}
+
+// Objects returns the GroupingReader interface for objects
+func (c *Client) Objects() types.GroupingReader {
+	return &groupingReader{c: c, prefix: "/v1/objects/"}
+}
+
+func (c *Client) removeEntity(ent types.Entity) error {
+	return c.do(http.MethodDelete, "/v1/entities/"+url.PathEscape(ent.String()), nil, nil, nil)
+}
+
+// Permit subject to perform action on object
+func (c *Client) Permit(sub types.Subject, obj types.Object, act types.Action) error {
+	return c.do(http.MethodPost, "/v1/permissions/permit", nil, permission(sub, obj, act), nil)
+}
+
+// Revoke permission for subject to perform action on object
+func (c *Client) Revoke(sub types.Subject, obj types.Object, act types.Action) error {
+	return c.do(http.MethodPost, "/v1/permissions/revoke", nil, permission(sub, obj, act), nil)
+}
+
+// Shall subject perform action on object
+func (c *Client) Shall(sub types.Subject, obj types.Object, act types.Action) (bool, error) {
+	var d wire.Decision
+	if e := c.do(http.MethodPost, "/v1/check", nil, permission(sub, obj, act), &d); e != nil {
+		return false, e
+	}
+	return d.Allowed, nil
+}
+
+// Check is a permission to be checked in a batch
+type Check struct {
+	Subject types.Subject
+	Object  types.Object
+	Action  types.Action
+}
+
+// ShallBatch checks permissions in one request, results are in the same order as checks.
+// The first error of checks is returned, if any of them fails.
+func (c *Client) ShallBatch(checks []Check) ([]bool, error) {
+	b := wire.Batch{Checks: make([]wire.Permission, 0, len(checks))}
+	for _, check := range checks {
+		b.Checks = append(b.Checks, permission(check.Subject, check.Object, check.Action))
+	}
+
+	var result wire.BatchResult
+	if e := c.do(http.MethodPost, "/v1/check/batch", nil, b, &result); e != nil {
+		return nil, e
+	}
+	if len(result.Results) != len(checks) {
+		return nil, fmt.Errorf("%d results for %d checks", len(result.Results), len(checks))
+	}
+
+	allowed := make([]bool, len(checks))
+	for i, d := range result.Results {
+		if d.Error != nil {
+			return nil, &Error{Status: http.StatusOK, Code: d.Error.Code, Message: d.Error.Message}
+		}
+		allowed[i] = d.Allowed
+	}
+	return allowed, nil
+}
+
+// ShallMany tells if subject shall perform actions on objects, in one batch request
+func (c *Client) ShallMany(sub types.Subject, checks []types.ObjectAction) ([]bool, error) {
+	batch := make([]Check, len(checks))
+	for i, check := range checks {
+		batch[i] = Check{Subject: sub, Object: check.Object, Action: check.Action}
+	}
+	return c.ShallBatch(batch)
+}
+
+// FilterAllowed returns objects subject shall perform action on, in their given order, in one batch request
+func (c *Client) FilterAllowed(sub types.Subject, act types.Action, objs []types.Object) ([]types.Object, error) {
+	return filterAllowed(c, sub, act, objs)
+}
+
+// filterAllowed filters objects by checking them in a batch
+func filterAllowed(b types.BatchChecker, sub types.Subject, act types.Action, objs []types.Object) ([]types.Object, error) {
+	checks := make([]types.ObjectAction, len(objs))
+	for i, obj := range objs {
+		checks[i] = types.ObjectAction{Object: obj, Action: act}
+	}
+	results, e := b.ShallMany(sub, checks)
+	if e != nil {
+		return nil, e
+	}
+
+	allowed := make([]types.Object, 0, len(objs))
+	for i, obj := range objs {
+		if results[i] {
+			allowed = append(allowed, obj)
+		}
+	}
+	return allowed, nil
+}
+
+// PermissionsOn object for all subjects
+func (c *Client) PermissionsOn(obj types.Object) (map[types.Subject]types.Action, error) {
+	var result map[string]string
+	if e := c.do(http.MethodGet, "/v1/permissions/on", url.Values{"object": {obj.String()}}, nil, &result); e != nil {
+		return nil, e
+	}
+
+	perms := make(map[types.Subject]types.Action, len(result))
+	for s, a := range result {
+		sub, e := types.ParseSubject(s)
+		if e != nil {
+			return nil, fmt.Errorf("%w: %s", e, s)
+		}
+		act, e := wire.ParseAction(a)
+		if e != nil {
+			return nil, e
+		}
+		perms[sub] = act
+	}
+	return perms, nil
+}
+
+// PermissionsFor subject on all objects
+func (c *Client) PermissionsFor(sub types.Subject) (map[types.Object]types.Action, error) {
+	var result map[string]string
+	if e := c.do(http.MethodGet, "/v1/permissions/for", url.Values{"subject": {sub.String()}}, nil, &result); e != nil {
+		return nil, e
+	}
+
+	perms := make(map[types.Object]types.Action, len(result))
+	for o, a := range result {
+		obj, e := types.ParseObject(o)
+		if e != nil {
+			return nil, fmt.Errorf("%w: %s", e, o)
+		}
+		act, e := wire.ParseAction(a)
+		if e != nil {
+			return nil, e
+		}
+		perms[obj] = act
+	}
+	return perms, nil
+}
+
+// PermittedActions for subject on object
+func (c *Client) PermittedActions(sub types.Subject, obj types.Object) (types.Action, error) {
+	var result wire.Actions
+	q := url.Values{"subject": {sub.String()}, "object": {obj.String()}}
+	if e := c.do(http.MethodGet, "/v1/permissions/permitted", q, nil, &result); e != nil {
+		return 0, e
+	}
+	return wire.ParseAction(result.Action)
+}
+
+// Policies lists all polices assigned directly
+func (c *Client) Policies() (types.Policies, error) {
+	var doc policy.Document
+	if e := c.do(http.MethodGet, "/v1/policies", nil, nil, &doc); e != nil {
+		return types.Policies{}, e
+	}
+	return doc.Policies()
+}
+
+func permission(sub types.Subject, obj types.Object, act types.Action) wire.Permission {
+	return wire.Permission{Subject: sub.String(), Object: obj.String(), Action: act.String()}
+}
+
+// groupingReader reads subject or object groupings from the server
+type groupingReader struct {
+	c      *Client
+	prefix string
+}
+
+func (g *groupingReader) IsIn(member types.Member, group types.Group) (bool, error) {
+	var result wire.Bool
+	q := url.Values{"member": {member.String()}, "group": {group.String()}}
+	if e := g.c.do(http.MethodGet, g.prefix+"is-in", q, nil, &result); e != nil {
+		return false, e
+	}
+	return result.Result, nil
+}
+
+func (g *groupingReader) AllGroups() (map[types.Group]struct{}, error) {
+	return g.groups("groups", nil)
+}
+
+func (g *groupingReader) AllMembers() (map[types.Member]struct{}, error) {
+	return g.members("members", nil)
+}
+
+func (g *groupingReader) MembersIn(group types.Group) (map[types.Member]struct{}, error) {
+	return g.members("members-in", url.Values{"group": {group.String()}})
+}
+
+func (g *groupingReader) GroupsOf(ent types.Entity) (map[types.Group]struct{}, error) {
+	return g.groups("groups-of", url.Values{"entity": {ent.String()}})
+}
+
+func (g *groupingReader) groups(path string, q url.Values) (map[types.Group]struct{}, error) {
+	var names []string
+	if e := g.c.do(http.MethodGet, g.prefix+path, q, nil, &names); e != nil {
+		return nil, e
+	}
+	groups := make(map[types.Group]struct{}, len(names))
+	for _, name := range names {
+		group, e := types.ParseGroup(name)
+		if e != nil {
+			return nil, fmt.Errorf("%w: %s", e, name)
+		}
+		groups[group] = struct{}{}
+	}
+	return groups, nil
+}
+
+func (g *groupingReader) members(path string, q url.Values) (map[types.Member]struct{}, error) {
+	var names []string
+	if e := g.c.do(http.MethodGet, g.prefix+path, q, nil, &names); e != nil {
+		return nil, e
+	}
+	members := make(map[types.Member]struct{}, len(names))
+	for _, name := range names {
+		member, e := types.ParseMember(name)
+		if e != nil {
+			return nil, fmt.Errorf("%w: %s", e, name)
+		}
+		members[member] = struct{}{}
+	}
+	return members, nil
+}
@@ -0,0 +1,210 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/supremind/rbac"
+	"github.com/supremind/rbac/client"
+	"github.com/supremind/rbac/persist/fake"
+	"github.com/supremind/rbac/server"
+	. "github.com/supremind/rbac/types"
+)
+
+func TestClient(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "client test suit")
+}
+
+var _ = Describe("remote authorizer", func() {
+	var (
+		local  Authorizer
+		remote *client.Client
+		ts     *httptest.Server
+	)
+
+	BeforeEach(func() {
+		var e error
+		local, e = rbac.New(context.Background(),
+			rbac.WithSubjectPersister(fake.NewGroupingPersister()),
+			rbac.WithObjectPersister(fake.NewGroupingPersister()),
+			rbac.WithPermissionPersister(fake.NewPermissionPersister()),
+		)
+		Expect(e).To(Succeed())
+		ts = httptest.NewServer(server.New(local))
+		remote = client.New(ts.URL, client.WithHTTPClient(ts.Client()))
+
+		Expect(remote.SubjectJoin(User("alan"), Role("editor"))).To(Succeed())
+		Expect(remote.ObjectJoin(Article("apollo"), Category("news"))).To(Succeed())
+		Expect(remote.Permit(Role("editor"), Category("news"), Read)).To(Succeed())
+		Expect(remote.Permit(User("alan"), Article("apollo"), Write)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		ts.Close()
+	})
+
+	It("should make the same decisions as the local one", func() {
+		for _, act := range []Action{Read, Write, Exec, Read | Write, Read | Exec} {
+			want, e := local.Shall(User("alan"), Article("apollo"), act)
+			Expect(e).To(Succeed())
+			Expect(remote.Shall(User("alan"), Article("apollo"), act)).To(Equal(want))
+		}
+		Expect(remote.PermittedActions(User("alan"), Article("apollo"))).To(Equal(Read | Write))
+		Expect(remote.PermittedActions(User("bob"), Article("apollo"))).To(Equal(None))
+	})
+
+	It("should check permissions in batches", func() {
+		Expect(remote.ShallBatch([]client.Check{
+			{Subject: User("alan"), Object: Article("apollo"), Action: Read},
+			{Subject: User("alan"), Object: Article("apollo"), Action: Exec},
+			{Subject: Role("editor"), Object: Category("news"), Action: Read},
+		})).To(Equal([]bool{true, false, true}))
+	})
+
+	It("should check permissions of a subject in batches", func() {
+		Expect(remote.ShallMany(User("alan"), []ObjectAction{
+			{Object: Article("apollo"), Action: Read},
+			{Object: Article("apollo"), Action: Exec},
+		})).To(Equal([]bool{true, false}))
+		Expect(remote.FilterAllowed(User("alan"), Read, []Object{Article("gemini"), Article("apollo")})).
+			To(Equal([]Object{Article("apollo")}))
+	})
+
+	It("should list groupings", func() {
+		Expect(remote.Subjects().IsIn(User("alan"), Role("editor"))).To(BeTrue())
+		Expect(remote.Subjects().GroupsOf(User("alan"))).To(Equal(map[Group]struct{}{Role("editor"): {}}))
+		Expect(remote.Subjects().MembersIn(Role("editor"))).To(Equal(map[Member]struct{}{User("alan"): {}}))
+		Expect(remote.Objects().AllGroups()).To(Equal(map[Group]struct{}{Category("news"): {}}))
+		Expect(remote.Objects().AllMembers()).To(Equal(map[Member]struct{}{Article("apollo"): {}}))
+	})
+
+	It("should list permissions and polices", func() {
+		Expect(remote.PermissionsOn(Category("news"))).To(Equal(map[Subject]Action{Role("editor"): Read}))
+		want, e := local.PermissionsFor(User("alan"))
+		Expect(e).To(Succeed())
+		Expect(remote.PermissionsFor(User("alan"))).To(Equal(want))
+
+		ps, e := local.(PolicyLister).Policies()
+		Expect(e).To(Succeed())
+		got, e := remote.Policies()
+		Expect(e).To(Succeed())
+		Expect(got.Subjects).To(ConsistOf(ps.Subjects))
+		Expect(got.Objects).To(ConsistOf(ps.Objects))
+		Expect(got.Permissions).To(ConsistOf(ps.Permissions))
+	})
+
+	It("should mutate polices", func() {
+		Expect(remote.Revoke(Role("editor"), Category("news"), Read)).To(Succeed())
+		Expect(local.Shall(User("alan"), Article("apollo"), Read)).To(BeFalse())
+
+		Expect(remote.SubjectLeave(User("alan"), Role("editor"))).To(Succeed())
+		Expect(local.Subjects().IsIn(User("alan"), Role("editor"))).To(BeFalse())
+
+		Expect(remote.RemoveArticle(Article("apollo"))).To(Succeed())
+		Expect(local.PermittedActions(User("alan"), Article("apollo"))).To(Equal(None))
+	})
+
+	It("should return the same errors as the local one", func() {
+		e := remote.SubjectJoin(User("alan"), Role("editor"))
+		Expect(errors.Is(e, ErrAlreadyExists)).To(BeTrue())
+		var ce *client.Error
+		Expect(errors.As(e, &ce)).To(BeTrue())
+		Expect(ce.Status).To(Equal(409))
+
+		Expect(errors.Is(remote.SubjectLeave(User("bob"), Role("editor")), ErrNotFound)).To(BeTrue())
+		Expect(errors.Is(remote.ObjectLeave(Article("apollo"), Category("sports")), ErrNotFound)).To(BeTrue())
+
+		_, e = remote.ShallBatch([]client.Check{{Subject: User("alan"), Object: Article("apollo"), Action: Action(1 << 10)}})
+		Expect(errors.Is(e, ErrUnknownAction)).To(BeTrue())
+	})
+})
+
+var _ = Describe("cached authorizer", func() {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+		origin Authorizer
+		cached *client.Cached
+		ts     *httptest.Server
+	)
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		feed := server.NewFeed()
+		var e error
+		origin, e = rbac.New(ctx,
+			rbac.WithSubjectPersisterContext(feed.Subjects(fake.NewGroupingPersister())),
+			rbac.WithObjectPersisterContext(feed.Objects(fake.NewGroupingPersister())),
+			rbac.WithPermissionPersisterContext(feed.Permissions(fake.NewPermissionPersister())),
+		)
+		Expect(e).To(Succeed())
+		Expect(origin.SubjectJoin(User("alan"), Role("editor"))).To(Succeed())
+		Expect(origin.ObjectJoin(Article("apollo"), Category("news"))).To(Succeed())
+		Expect(origin.Permit(Role("editor"), Category("news"), Read)).To(Succeed())
+
+		ts = httptest.NewServer(server.New(origin, server.WithFeed(feed)))
+		cached, e = client.NewCached(ctx, ts.URL, client.WithHTTPClient(ts.Client()))
+		Expect(e).To(Succeed())
+	})
+
+	AfterEach(func() {
+		cancel()
+		ts.Close()
+	})
+
+	shall := func(sub Subject, obj Object, act Action) func() bool {
+		return func() bool {
+			ok, e := cached.Shall(sub, obj, act)
+			Expect(e).To(Succeed())
+			return ok
+		}
+	}
+
+	It("should start with the snapshot", func() {
+		Expect(cached.Shall(User("alan"), Article("apollo"), Read)).To(BeTrue())
+		Expect(cached.Subjects().IsIn(User("alan"), Role("editor"))).To(BeTrue())
+		want, e := origin.(PolicyLister).Policies()
+		Expect(e).To(Succeed())
+		Expect(cached.Policies()).To(Equal(want))
+	})
+
+	It("should follow changes made on the server", func() {
+		Expect(origin.Permit(User("bob"), Article("apollo"), Write)).To(Succeed())
+		Eventually(shall(User("bob"), Article("apollo"), Write)).Should(BeTrue())
+
+		Expect(origin.SubjectLeave(User("alan"), Role("editor"))).To(Succeed())
+		Eventually(shall(User("alan"), Article("apollo"), Read)).Should(BeFalse())
+	})
+
+	It("should forward writes to the server", func() {
+		Expect(cached.SubjectJoin(User("bob"), Role("editor"))).To(Succeed())
+		Expect(origin.Subjects().IsIn(User("bob"), Role("editor"))).To(BeTrue())
+		Eventually(shall(User("bob"), Article("apollo"), Read)).Should(BeTrue())
+
+		Expect(cached.Revoke(Role("editor"), Category("news"), Read)).To(Succeed())
+		Eventually(shall(User("bob"), Article("apollo"), Read)).Should(BeFalse())
+
+		Expect(errors.Is(cached.SubjectJoin(User("alan"), Role("editor")), ErrAlreadyExists)).To(BeTrue())
+	})
+
+	It("should catch up after watching again", func() {
+		ts.CloseClientConnections()
+		Expect(origin.Permit(User("bob"), Category("news"), Exec)).To(Succeed())
+		Expect(origin.RemoveArticle(Article("apollo"))).To(Succeed())
+
+		Eventually(shall(User("bob"), Category("news"), Exec)).Should(BeTrue())
+		Eventually(func() (Policies, error) { return cached.Policies() }).Should(Equal(mustPolicies(origin)))
+	})
+})
+
+func mustPolicies(authz Authorizer) Policies {
+	ps, e := authz.(PolicyLister).Policies()
+	Expect(e).To(Succeed())
+	return ps
+}
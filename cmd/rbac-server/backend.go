@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/globalsign/mgo"
+	"github.com/go-logr/logr"
+	"github.com/supremind/rbac/persist/file"
+	mgopersist "github.com/supremind/rbac/persist/mgo"
+	"github.com/supremind/rbac/types"
+)
+
+// backend is the set of persisters rbac-server serves
+type backend struct {
+	subject    types.GroupingPersister
+	object     types.GroupingPersister
+	permission types.PermissionPersister
+	close      func()
+}
+
+// backendConfig tells where polices are persisted
+type backendConfig struct {
+	kind string // file or mgo
+
+	// for file persisters
+	dir string
+
+	// for mgo persisters
+	mongoURL          string
+	subjectCollection string
+	objectCollection  string
+	permCollection    string
+}
+
+var errUnsupportedPersister = errors.New("unsupported persister")
+
+func openBackend(cfg backendConfig, l logr.Logger) (*backend, error) {
+	switch cfg.kind {
+	case "file":
+		return openFileBackend(cfg, l)
+	case "mgo":
+		return openMgoBackend(cfg, l)
+	}
+	return nil, fmt.Errorf("%w: %q, expecting file or mgo", errUnsupportedPersister, cfg.kind)
+}
+
+func openFileBackend(cfg backendConfig, l logr.Logger) (*backend, error) {
+	if e := os.MkdirAll(cfg.dir, 0755); e != nil {
+		return nil, e
+	}
+
+	sp, e := file.NewGrouping(filepath.Join(cfg.dir, "subject.json"), file.WithLogger(l.WithName("subject")))
+	if e != nil {
+		return nil, e
+	}
+	op, e := file.NewGrouping(filepath.Join(cfg.dir, "object.json"), file.WithLogger(l.WithName("object")))
+	if e != nil {
+		return nil, e
+	}
+	pp, e := file.NewPermission(filepath.Join(cfg.dir, "permission.json"), file.WithLogger(l.WithName("permission")))
+	if e != nil {
+		return nil, e
+	}
+
+	return &backend{subject: sp, object: op, permission: pp, close: func() {}}, nil
+}
+
+func openMgoBackend(cfg backendConfig, l logr.Logger) (*backend, error) {
+	if cfg.mongoURL == "" {
+		return nil, errors.New("mongodb url is required by mgo persister")
+	}
+	ss, e := mgo.Dial(cfg.mongoURL)
+	if e != nil {
+		return nil, fmt.Errorf("connect to mongodb: %w", e)
+	}
+	db := ss.DB("") // database named in the url
+
+	sp, e := mgopersist.NewGrouping(db.C(cfg.subjectCollection), mgopersist.WithLogger(l.WithName("subject")))
+	if e != nil {
+		ss.Close()
+		return nil, e
+	}
+	op, e := mgopersist.NewGrouping(db.C(cfg.objectCollection), mgopersist.WithLogger(l.WithName("object")))
+	if e != nil {
+		ss.Close()
+		return nil, e
+	}
+	pp, e := mgopersist.NewPermission(db.C(cfg.permCollection), mgopersist.WithLogger(l.WithName("permission")))
+	if e != nil {
+		ss.Close()
+		return nil, e
+	}
+
+	return &backend{subject: sp, object: op, permission: pp, close: ss.Close}, nil
+}
@@ -0,0 +1,18 @@
+module github.com/supremind/rbac/cmd/rbac-server
+
+go 1.14
+
+replace (
+	github.com/supremind/rbac => ../..
+	github.com/supremind/rbac/persist/mgo => ../../persist/mgo
+)
+
+require (
+	github.com/globalsign/mgo v0.0.0-20181015135952-eeefdecb41b8
+	github.com/go-logr/logr v1.0.0
+	github.com/go-logr/stdr v1.0.0
+	github.com/onsi/ginkgo v1.16.4
+	github.com/onsi/gomega v1.14.0
+	github.com/supremind/rbac v0.5.0
+	github.com/supremind/rbac/persist/mgo v0.0.0-00010101000000-000000000000
+)
@@ -0,0 +1,132 @@
+// Command rbac-server serves an Authorizer over HTTP/JSON, see package server for endpoints,
+// and package client for the Go client.
+//
+// Polices are kept by persisters, replicas watching the same persisters make the same decisions.
+//
+//	rbac-server -listen :8080 -persister file -dir ./polices
+//	rbac-server -listen :8080 -persister mgo -mongo mongodb://localhost:27017/app
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/go-logr/stdr"
+	"github.com/supremind/rbac"
+	"github.com/supremind/rbac/server"
+)
+
+// shutdownTimeout is how long in-flight requests are waited for on shutdown
+const shutdownTimeout = 10 * time.Second
+
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		cancel()
+	}()
+
+	if e := run(ctx, os.Args[1:], os.Stderr, nil); e != nil {
+		if !errors.Is(e, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "rbac-server:", e)
+		}
+		os.Exit(2)
+	}
+}
+
+// run serves until ctx is done, ready is called with the listening address if it is not nil
+func run(ctx context.Context, args []string, stderr io.Writer, ready func(addr string)) error {
+	var (
+		cfg       backendConfig
+		listen    string
+		verbosity int
+	)
+
+	fs := flag.NewFlagSet("rbac-server", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&listen, "listen", envOr("RBAC_SERVER_LISTEN", ":8080"), "address to listen on")
+	fs.StringVar(&cfg.kind, "persister", envOr("RBAC_SERVER_PERSISTER", "file"), "persister of polices: file or mgo")
+	fs.StringVar(&cfg.dir, "dir", envOr("RBAC_SERVER_DIR", "."), "directory of policy files, for file persister")
+	fs.StringVar(&cfg.mongoURL, "mongo", os.Getenv("RBAC_SERVER_MONGO"), "mongodb url with database name, for mgo persister")
+	fs.StringVar(&cfg.subjectCollection, "subject-collection", "subject", "collection of subject groupings, for mgo persister")
+	fs.StringVar(&cfg.objectCollection, "object-collection", "object", "collection of object groupings, for mgo persister")
+	fs.StringVar(&cfg.permCollection, "permission-collection", "permission", "collection of permissions, for mgo persister")
+	fs.IntVar(&verbosity, "v", 0, "log verbosity")
+	if e := fs.Parse(args); e != nil {
+		return e
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("unexpected arguments %v, see rbac-server -h", fs.Args())
+	}
+
+	l := stdr.New(log.New(stderr, "", log.LstdFlags))
+	if verbosity != 0 {
+		stdr.SetVerbosity(verbosity) // stdr keeps verbosity globally, which is 0 by default
+	}
+
+	b, e := openBackend(cfg, l.WithName("persister"))
+	if e != nil {
+		return e
+	}
+	defer b.close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	feed := server.NewFeed()
+	authz, e := rbac.New(ctx,
+		rbac.WithSubjectPersisterContext(feed.Subjects(b.subject)),
+		rbac.WithObjectPersisterContext(feed.Objects(b.object)),
+		rbac.WithPermissionPersisterContext(feed.Permissions(b.permission)),
+		rbac.WithLogger(l.WithName("rbac")),
+	)
+	if e != nil {
+		return e
+	}
+
+	ln, e := net.Listen("tcp", listen)
+	if e != nil {
+		return e
+	}
+	srv := &http.Server{Handler: server.New(authz, server.WithFeed(feed), server.WithLogger(l.WithName("server")))}
+	srv.RegisterOnShutdown(feed.Close) // watchers would block shutting down otherwise
+
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.Serve(ln)
+	}()
+	l.Info("serving", "address", ln.Addr().String(), "persister", cfg.kind)
+	if ready != nil {
+		ready(ln.Addr().String())
+	}
+
+	select {
+	case e := <-served:
+		return e
+	case <-ctx.Done():
+	}
+
+	l.Info("shutting down")
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+	return srv.Shutdown(shutdownCtx)
+}
+
+func envOr(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/supremind/rbac/client"
+	. "github.com/supremind/rbac/types"
+)
+
+func TestRbacServer(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "rbac-server test suit")
+}
+
+var _ = Describe("rbac-server", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var e error
+		dir, e = ioutil.TempDir("", "rbac-server-")
+		Expect(e).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("should serve polices persisted in files until canceled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		addrs := make(chan string, 1)
+		done := make(chan error, 1)
+		go func() {
+			done <- run(ctx, []string{"-listen", "127.0.0.1:0", "-dir", dir}, ioutil.Discard, func(addr string) { addrs <- addr })
+		}()
+
+		var addr string
+		Eventually(addrs).Should(Receive(&addr))
+		c := client.New("http://" + addr)
+		Expect(c.SubjectJoin(User("alan"), Role("editor"))).To(Succeed())
+		Expect(c.Permit(Role("editor"), Article("apollo"), Read)).To(Succeed())
+		Expect(c.Shall(User("alan"), Article("apollo"), Read)).To(BeTrue())
+		Expect(c.Shall(User("alan"), Article("apollo"), Write)).To(BeFalse())
+
+		watching, stopWatching := context.WithCancel(context.Background())
+		defer stopWatching()
+		cached, e := client.NewCached(watching, "http://"+addr)
+		Expect(e).To(Succeed())
+		Expect(cached.Shall(User("alan"), Article("apollo"), Read)).To(BeTrue())
+
+		cancel()
+		Eventually(done).Should(Receive(BeNil()))
+		Expect(dir + "/permission.json").To(BeAnExistingFile())
+	})
+
+	It("should reject unknown persisters", func() {
+		Expect(run(context.Background(), []string{"-persister", "sql"}, ioutil.Discard, nil)).To(MatchError(errUnsupportedPersister))
+	})
+})
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/supremind/rbac/policy"
+	"github.com/supremind/rbac/types"
+)
+
+// session is what a command works with
+type session struct {
+	authz   types.Authorizer
+	backend *backend
+	out     printer
+	dryRun  bool
+	ledger  string // path of the ledger file
+	config  backendConfig
+}
+
+type command struct {
+	name  string
+	usage string
+	args  int
+	run   func(s *session, args []string) error
+}
+
+var commands = []command{
+	{name: "join", usage: "join <subject|object> <role|category>", args: 2, run: join},
+	{name: "leave", usage: "leave <subject|object> <role|category>", args: 2, run: leave},
+	{name: "permit", usage: "permit <subject> <object> <action>", args: 3, run: permit},
+	{name: "revoke", usage: "revoke <subject> <object> <action>", args: 3, run: revoke},
+	{name: "remove-user", usage: "remove-user <user>", args: 1, run: removeUser},
+	{name: "remove-role", usage: "remove-role <role>", args: 1, run: removeRole},
+	{name: "remove-article", usage: "remove-article <article>", args: 1, run: removeArticle},
+	{name: "remove-category", usage: "remove-category <category>", args: 1, run: removeCategory},
+	{name: "list", usage: "list <roles|users|categories|articles>", args: 1, run: list},
+	{name: "members-of", usage: "members-of <role|category>", args: 1, run: membersOf},
+	{name: "groups-of", usage: "groups-of <subject|object>", args: 1, run: groupsOf},
+	{name: "check", usage: "check <subject> <object> <action>", args: 3, run: check},
+	{name: "explain", usage: "explain <subject> <object> <action>", args: 3, run: explainCmd},
+	{name: "export", usage: "export", args: 0, run: export},
+	{name: "import", usage: "import <merge|replace> <file>", args: 2, run: importCmd},
+	{name: "reconcile", usage: "reconcile <manifest>", args: 1, run: reconcile},
+	{name: "diff", usage: "diff <from> <to>, sources are live, dir:<path>, mongo:<url>, or document files", args: 2, run: diffCmd},
+}
+
+func lookupCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+func join(s *session, args []string) error {
+	group, e := types.ParseGroup(args[1])
+	if e != nil {
+		return fmt.Errorf("%w: %s", e, args[1])
+	}
+
+	switch group := group.(type) {
+	case types.Role:
+		sub, e := types.ParseSubject(args[0])
+		if e != nil {
+			return fmt.Errorf("%w: %s", e, args[0])
+		}
+		return s.authz.SubjectJoin(sub, group)
+	case types.Category:
+		obj, e := types.ParseObject(args[0])
+		if e != nil {
+			return fmt.Errorf("%w: %s", e, args[0])
+		}
+		return s.authz.ObjectJoin(obj, group)
+	}
+	return fmt.Errorf("%w: %s", types.ErrInvalidEntity, args[1])
+}
+
+func leave(s *session, args []string) error {
+	group, e := types.ParseGroup(args[1])
+	if e != nil {
+		return fmt.Errorf("%w: %s", e, args[1])
+	}
+
+	switch group := group.(type) {
+	case types.Role:
+		sub, e := types.ParseSubject(args[0])
+		if e != nil {
+			return fmt.Errorf("%w: %s", e, args[0])
+		}
+		return s.authz.SubjectLeave(sub, group)
+	case types.Category:
+		obj, e := types.ParseObject(args[0])
+		if e != nil {
+			return fmt.Errorf("%w: %s", e, args[0])
+		}
+		return s.authz.ObjectLeave(obj, group)
+	}
+	return fmt.Errorf("%w: %s", types.ErrInvalidEntity, args[1])
+}
+
+// parsePermission parses arguments like "<subject> <object> <action>"
+func parsePermission(args []string) (types.Subject, types.Object, types.Action, error) {
+	sub, e := types.ParseSubject(args[0])
+	if e != nil {
+		return nil, nil, 0, fmt.Errorf("%w: %s", e, args[0])
+	}
+	obj, e := types.ParseObject(args[1])
+	if e != nil {
+		return nil, nil, 0, fmt.Errorf("%w: %s", e, args[1])
+	}
+	act, e := types.ParseAction(args[2])
+	if e != nil {
+		return nil, nil, 0, e
+	}
+	return sub, obj, act, nil
+}
+
+func permit(s *session, args []string) error {
+	sub, obj, act, e := parsePermission(args)
+	if e != nil {
+		return e
+	}
+	return s.authz.Permit(sub, obj, act)
+}
+
+func revoke(s *session, args []string) error {
+	sub, obj, act, e := parsePermission(args)
+	if e != nil {
+		return e
+	}
+	return s.authz.Revoke(sub, obj, act)
+}
+
+func removeUser(s *session, args []string) error {
+	user, ok := parseEntity(args[0]).(types.User)
+	if !ok {
+		return fmt.Errorf("%w: expecting user:<name>, got %s", types.ErrInvalidEntity, args[0])
+	}
+	return s.authz.RemoveUser(user)
+}
+
+func removeRole(s *session, args []string) error {
+	role, ok := parseEntity(args[0]).(types.Role)
+	if !ok {
+		return fmt.Errorf("%w: expecting role:<name>, got %s", types.ErrInvalidEntity, args[0])
+	}
+	return s.authz.RemoveRole(role)
+}
+
+func removeArticle(s *session, args []string) error {
+	art, ok := parseEntity(args[0]).(types.Article)
+	if !ok {
+		return fmt.Errorf("%w: expecting art:<name>, got %s", types.ErrInvalidEntity, args[0])
+	}
+	return s.authz.RemoveArticle(art)
+}
+
+func removeCategory(s *session, args []string) error {
+	cat, ok := parseEntity(args[0]).(types.Category)
+	if !ok {
+		return fmt.Errorf("%w: expecting cat:<name>, got %s", types.ErrInvalidEntity, args[0])
+	}
+	return s.authz.RemoveCategory(cat)
+}
+
+// parseEntity parses s, or returns nil if it is invalid
+func parseEntity(s string) types.Entity {
+	ent, _ := types.ParseEntity(s)
+	return ent
+}
+
+func list(s *session, args []string) error {
+	var (
+		header string
+		ents   []types.Entity
+	)
+
+	switch args[0] {
+	case "roles":
+		groups, e := s.authz.Subjects().AllGroups()
+		if e != nil {
+			return e
+		}
+		header, ents = "ROLE", groupEntities(groups)
+	case "users":
+		members, e := s.authz.Subjects().AllMembers()
+		if e != nil {
+			return e
+		}
+		header, ents = "USER", memberEntities(members)
+	case "categories":
+		groups, e := s.authz.Objects().AllGroups()
+		if e != nil {
+			return e
+		}
+		header, ents = "CATEGORY", groupEntities(groups)
+	case "articles":
+		members, e := s.authz.Objects().AllMembers()
+		if e != nil {
+			return e
+		}
+		header, ents = "ARTICLE", memberEntities(members)
+	default:
+		return fmt.Errorf("cannot list %q, expecting roles, users, categories, or articles", args[0])
+	}
+
+	return s.out.entities(header, ents)
+}
+
+func membersOf(s *session, args []string) error {
+	group, e := types.ParseGroup(args[0])
+	if e != nil {
+		return fmt.Errorf("%w: %s", e, args[0])
+	}
+
+	reader := s.authz.Subjects()
+	if _, ok := group.(types.Category); ok {
+		reader = s.authz.Objects()
+	}
+	members, e := reader.MembersIn(group)
+	if e != nil {
+		return e
+	}
+	return s.out.entities("MEMBER", memberEntities(members))
+}
+
+func groupsOf(s *session, args []string) error {
+	ent, e := types.ParseEntity(args[0])
+	if e != nil {
+		return fmt.Errorf("%w: %s", e, args[0])
+	}
+
+	reader := s.authz.Subjects()
+	if _, ok := ent.(types.Object); ok {
+		reader = s.authz.Objects()
+	}
+	groups, e := reader.GroupsOf(ent)
+	if e != nil {
+		return e
+	}
+	return s.out.entities("GROUP", groupEntities(groups))
+}
+
+func check(s *session, args []string) error {
+	sub, obj, act, e := parsePermission(args)
+	if e != nil {
+		return e
+	}
+	allowed, e := s.authz.Shall(sub, obj, act)
+	if e != nil {
+		return e
+	}
+	return s.out.decision(decision{Subject: sub.String(), Object: obj.String(), Action: act.String(), Allowed: allowed})
+}
+
+func explainCmd(s *session, args []string) error {
+	sub, obj, act, e := parsePermission(args)
+	if e != nil {
+		return e
+	}
+
+	subjects, e := s.backend.subject.List()
+	if e != nil {
+		return e
+	}
+	objects, e := s.backend.object.List()
+	if e != nil {
+		return e
+	}
+	perms, e := s.backend.permission.List()
+	if e != nil {
+		return e
+	}
+
+	return s.out.explanation(explain(sub, obj, act, subjects, objects, perms))
+}
+
+// export writes polices in YAML, or in JSON with -o json
+func export(s *session, args []string) error {
+	format := policy.YAML
+	if s.out.json {
+		format = policy.JSON
+	}
+	return policy.Export(s.out.w, s.authz, format)
+}
+
+func importCmd(s *session, args []string) error {
+	var mode policy.Mode
+	switch args[0] {
+	case "merge":
+		mode = policy.Merge
+	case "replace":
+		mode = policy.Replace
+	default:
+		return fmt.Errorf("unknown import mode %q, expecting merge or replace", args[0])
+	}
+	if s.dryRun {
+		mode |= policy.DryRun
+	}
+
+	f, e := os.Open(args[1])
+	if e != nil {
+		return e
+	}
+	defer f.Close()
+
+	report, e := policy.Import(f, s.authz, mode)
+	if e != nil {
+		return e
+	}
+	return s.out.report(report)
+}
+
+// reconcile converges polices to the manifest, polices owned by it are recorded in the ledger
+func reconcile(s *session, args []string) error {
+	f, e := os.Open(args[0])
+	if e != nil {
+		return e
+	}
+	defer f.Close()
+
+	m, e := policy.DecodeManifest(f)
+	if e != nil {
+		return e
+	}
+
+	r := policy.NewReconciler(s.authz, policy.NewFileLedger(s.ledger))
+	plan, e := r.Reconcile(m)
+	if e != nil {
+		return e
+	}
+	if s.dryRun {
+		return s.out.report(plan.Report())
+	}
+
+	report, e := r.Apply(plan)
+	if e != nil {
+		return e
+	}
+	return s.out.report(report)
+}
+
+func groupEntities(groups map[types.Group]struct{}) []types.Entity {
+	ents := make([]types.Entity, 0, len(groups))
+	for group := range groups {
+		ents = append(ents, group)
+	}
+	return ents
+}
+
+func memberEntities(members map[types.Member]struct{}) []types.Entity {
+	ents := make([]types.Entity, 0, len(members))
+	for member := range members {
+		ents = append(ents, member)
+	}
+	return ents
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/supremind/rbac/diff"
+	"github.com/supremind/rbac/types"
+)
+
+func diffCmd(s *session, args []string) error {
+	from, closeFrom, e := openSource(s, args[0])
+	if e != nil {
+		return e
+	}
+	defer closeFrom()
+	to, closeTo, e := openSource(s, args[1])
+	if e != nil {
+		return e
+	}
+	defer closeTo()
+
+	d, e := diff.Compare(from, to)
+	if e != nil {
+		return e
+	}
+	return s.out.diff(d)
+}
+
+// openSource opens polices to diff:
+// live for the configured persisters, dir:<path> for file persisters in the directory,
+// mongo:<url> for mgo persisters with configured collections, or the path of a document file
+func openSource(s *session, source string) (types.PolicyLister, func(), error) {
+	var cfg backendConfig
+	switch {
+	case source == "live":
+		return diff.FromAuthorizer(s.authz), func() {}, nil
+	case strings.HasPrefix(source, "dir:"):
+		cfg = s.config
+		cfg.kind, cfg.dir = "file", strings.TrimPrefix(source, "dir:")
+		if _, e := os.Stat(cfg.dir); e != nil {
+			return nil, nil, e
+		}
+	case strings.HasPrefix(source, "mongo:"):
+		cfg = s.config
+		cfg.kind, cfg.mongoURL = "mgo", strings.TrimPrefix(source, "mongo:")
+	default:
+		return diff.FromFile(source), func() {}, nil
+	}
+
+	b, e := openBackend(cfg, logr.Discard())
+	if e != nil {
+		return nil, nil, e
+	}
+	return diff.FromPersisters(b.subject, b.object, b.permission), b.close, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/supremind/rbac/types"
+)
+
+// explanation tells why a subject shall or shall not perform an action on an object
+type explanation struct {
+	Subject string  `json:"subject"`
+	Object  string  `json:"object"`
+	Action  string  `json:"action"`
+	Allowed bool    `json:"allowed"`
+	Grants  []grant `json:"grants"`
+	Missing string  `json:"missing,omitempty"` // actions granted by no policy
+}
+
+// grant is a permission policy contributing to the decision,
+// and how the subject and the object reach the policy through groupings
+type grant struct {
+	Subject     string   `json:"subject"`
+	Object      string   `json:"object"`
+	Action      string   `json:"action"`
+	SubjectPath []string `json:"subject_path"`
+	ObjectPath  []string `json:"object_path"`
+}
+
+// explain finds permission polices granting any of act to sub on obj, directly or through roles and categories
+func explain(sub types.Subject, obj types.Object, act types.Action, subjects, objects []types.GroupingPolicy, perms []types.PermissionPolicy) explanation {
+	subPaths := pathsFrom(sub, subjects)
+	objPaths := pathsFrom(obj, objects)
+
+	exp := explanation{
+		Subject: sub.String(),
+		Object:  obj.String(),
+		Action:  act.String(),
+		Grants:  make([]grant, 0),
+	}
+
+	var granted types.Action
+	for _, perm := range perms {
+		subPath, ok := subPaths[perm.Subject]
+		if !ok {
+			continue
+		}
+		objPath, ok := objPaths[perm.Object]
+		if !ok {
+			continue
+		}
+		if perm.Action&act == 0 {
+			continue
+		}
+
+		granted |= perm.Action
+		exp.Grants = append(exp.Grants, grant{
+			Subject:     perm.Subject.String(),
+			Object:      perm.Object.String(),
+			Action:      perm.Action.String(),
+			SubjectPath: subPath,
+			ObjectPath:  objPath,
+		})
+	}
+
+	// the most direct grants go first
+	sort.Slice(exp.Grants, func(i, j int) bool {
+		a, b := exp.Grants[i], exp.Grants[j]
+		if da, db := len(a.SubjectPath)+len(a.ObjectPath), len(b.SubjectPath)+len(b.ObjectPath); da != db {
+			return da < db
+		}
+		if a.Subject != b.Subject {
+			return a.Subject < b.Subject
+		}
+		return a.Object < b.Object
+	})
+
+	exp.Allowed = granted.Includes(act)
+	if missing := act.Difference(granted); missing != 0 {
+		exp.Missing = missing.String()
+	}
+	return exp
+}
+
+// pathsFrom finds the shortest path from ent to every group it belongs to, including ent itself
+func pathsFrom(ent types.Entity, polices []types.GroupingPolicy) map[types.Entity][]string {
+	groups := make(map[types.Entity][]types.Group, len(polices))
+	for _, policy := range polices {
+		groups[policy.Entity] = append(groups[policy.Entity], policy.Group)
+	}
+
+	paths := map[types.Entity][]string{ent: {ent.String()}}
+	queue := []types.Entity{ent}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, group := range groups[cur] {
+			if _, seen := paths[group]; seen {
+				continue
+			}
+			path := make([]string, len(paths[cur]), len(paths[cur])+1)
+			copy(path, paths[cur])
+			paths[group] = append(path, group.String())
+			queue = append(queue, group)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,133 @@
+// Command rbacctl administers rbac polices kept by persisters.
+//
+// It loads all polices from the configured persisters, and changes them through an Authorizer,
+// so that replicas watching the same persisters coordinate the changes as if they were made by one of them.
+//
+//	rbacctl -persister file -dir ./polices permit role:editor cat:news read
+//	rbacctl -persister mgo -mongo mongodb://localhost:27017/app -o json explain user:alan art:apollo read
+//	rbacctl -persister sql -sql-driver postgres -sql-dsn postgres://localhost/app list roles
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/go-logr/stdr"
+	"github.com/supremind/rbac"
+)
+
+func main() {
+	if e := run(context.Background(), os.Args[1:], os.Stdout, os.Stderr); e != nil {
+		if !errors.Is(e, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "rbacctl:", e)
+		}
+		os.Exit(2)
+	}
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	var (
+		cfg       backendConfig
+		format    string
+		verbosity int
+		dryRun    bool
+		ledger    string
+	)
+
+	fs := flag.NewFlagSet("rbacctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&cfg.kind, "persister", envOr("RBACCTL_PERSISTER", "file"), "persister of polices: file, mgo or sql")
+	fs.StringVar(&cfg.dir, "dir", envOr("RBACCTL_DIR", "."), "directory of policy files, for file persister")
+	fs.StringVar(&cfg.mongoURL, "mongo", os.Getenv("RBACCTL_MONGO"), "mongodb url with database name, for mgo persister")
+	fs.StringVar(&cfg.subjectCollection, "subject-collection", "subject", "collection of subject groupings, for mgo persister")
+	fs.StringVar(&cfg.objectCollection, "object-collection", "object", "collection of object groupings, for mgo persister")
+	fs.StringVar(&cfg.permCollection, "permission-collection", "permission", "collection of permissions, for mgo persister")
+	fs.StringVar(&cfg.sqlDriver, "sql-driver", envOr("RBACCTL_SQL_DRIVER", "sqlite3"), "database driver: sqlite3, mysql or postgres, for sql persister")
+	fs.StringVar(&cfg.sqlDSN, "sql-dsn", os.Getenv("RBACCTL_SQL_DSN"), "data source name of the database, for sql persister")
+	fs.StringVar(&cfg.subjectTable, "subject-table", "rbac_subject", "table of subject groupings, for sql persister")
+	fs.StringVar(&cfg.objectTable, "object-table", "rbac_object", "table of object groupings, for sql persister")
+	fs.StringVar(&cfg.permissionTable, "permission-table", "rbac_permission", "table of permissions, for sql persister")
+	fs.StringVar(&format, "o", "table", "output format: table or json")
+	fs.IntVar(&verbosity, "v", 0, "log verbosity")
+	fs.BoolVar(&dryRun, "dry-run", false, "report what would be changed by import or reconcile, without changing anything")
+	fs.StringVar(&ledger, "ledger", os.Getenv("RBACCTL_LEDGER"), "file of polices owned by manifests, for reconcile (default ledger.json in -dir)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: rbacctl [flags] <command> [args]")
+		fmt.Fprintln(fs.Output(), "\ncommands:")
+		for _, cmd := range commands {
+			fmt.Fprintf(fs.Output(), "  %s\n", cmd.usage)
+		}
+		fmt.Fprintln(fs.Output(), "\nflags:")
+		fs.PrintDefaults()
+	}
+
+	if e := fs.Parse(args); e != nil {
+		return e
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if format != "table" && format != "json" {
+		return fmt.Errorf("unknown output format %q, expecting table or json", format)
+	}
+
+	cmd, ok := lookupCommand(fs.Arg(0))
+	if !ok {
+		return fmt.Errorf("unknown command %q, see rbacctl -h", fs.Arg(0))
+	}
+	cmdArgs := fs.Args()[1:]
+	if len(cmdArgs) != cmd.args {
+		return fmt.Errorf("usage: rbacctl %s", cmd.usage)
+	}
+
+	l := stdr.New(log.New(stderr, "", log.LstdFlags))
+	if verbosity != 0 {
+		stdr.SetVerbosity(verbosity) // stdr keeps verbosity globally, which is 0 by default
+	}
+
+	b, e := openBackend(cfg, l.WithName("persister"))
+	if e != nil {
+		return e
+	}
+	defer b.close()
+
+	if ledger == "" {
+		ledger = filepath.Join(cfg.dir, "ledger.json")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	authz, e := rbac.New(ctx,
+		rbac.WithSubjectPersister(b.subject),
+		rbac.WithObjectPersister(b.object),
+		rbac.WithPermissionPersister(b.permission),
+		rbac.WithLogger(l.WithName("rbac")),
+	)
+	if e != nil {
+		return e
+	}
+
+	return cmd.run(&session{
+		authz:   authz,
+		backend: b,
+		out:     printer{w: stdout, json: format == "json"},
+		dryRun:  dryRun,
+		ledger:  ledger,
+		config:  cfg,
+	}, cmdArgs)
+}
+
+func envOr(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return def
+}
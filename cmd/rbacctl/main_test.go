@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestRbacctl(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "rbacctl test suit")
+}
+
+var _ = Describe("rbacctl", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var e error
+		dir, e = ioutil.TempDir("", "rbacctl-")
+		Expect(e).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	rbacctl := func(args ...string) (string, error) {
+		var stdout, stderr bytes.Buffer
+		e := run(context.Background(), append([]string{"-dir", dir}, args...), &stdout, &stderr)
+		return stdout.String(), e
+	}
+	must := func(args ...string) string {
+		out, e := rbacctl(args...)
+		Expect(e).To(Succeed(), "rbacctl %v", args)
+		return out
+	}
+
+	BeforeEach(func() {
+		must("join", "user:alan", "role:writer")
+		must("join", "role:writer", "role:editor")
+		must("join", "art:apollo", "cat:news")
+		must("permit", "role:editor", "cat:news", "read")
+		must("permit", "user:alan", "art:apollo", "write")
+	})
+
+	It("should keep polices across runs", func() {
+		Expect(must("list", "roles")).To(Equal("ROLE\nrole:editor\nrole:writer\n"))
+		Expect(must("-o", "json", "groups-of", "art:apollo")).To(MatchJSON(`["cat:news"]`))
+		Expect(must("-o", "json", "members-of", "role:editor")).To(MatchJSON(`["user:alan"]`))
+
+		Expect(must("-o", "json", "check", "user:alan", "art:apollo", "read")).To(MatchJSON(
+			`{"subject": "user:alan", "object": "art:apollo", "action": "read", "allowed": true}`,
+		))
+
+		must("leave", "role:writer", "role:editor")
+		Expect(must("-o", "json", "check", "user:alan", "art:apollo", "read")).To(MatchJSON(
+			`{"subject": "user:alan", "object": "art:apollo", "action": "read", "allowed": false}`,
+		))
+	})
+
+	It("should explain decisions with paths through groupings", func() {
+		var exp explanation
+		Expect(json.Unmarshal([]byte(must("-o", "json", "explain", "user:alan", "art:apollo", "read|write|exec")), &exp)).To(Succeed())
+
+		Expect(exp.Allowed).To(BeFalse())
+		Expect(exp.Missing).To(Equal("exec"))
+		Expect(exp.Grants).To(Equal([]grant{
+			{
+				Subject: "user:alan", Object: "art:apollo", Action: "write",
+				SubjectPath: []string{"user:alan"},
+				ObjectPath:  []string{"art:apollo"},
+			},
+			{
+				Subject: "role:editor", Object: "cat:news", Action: "read",
+				SubjectPath: []string{"user:alan", "role:writer", "role:editor"},
+				ObjectPath:  []string{"art:apollo", "cat:news"},
+			},
+		}))
+	})
+
+	It("should export and import polices", func() {
+		exported := must("export")
+		Expect(exported).To(ContainSubstring("version: 1"))
+
+		doc := dir + "/polices.yaml"
+		Expect(ioutil.WriteFile(doc, []byte(exported), 0644)).To(Succeed())
+
+		must("leave", "role:writer", "role:editor")
+		must("permit", "user:edison", "art:apollo", "exec")
+
+		Expect(must("-dry-run", "import", "replace", doc)).To(HavePrefix("dry run, nothing is changed\n"))
+		Expect(must("check", "user:edison", "art:apollo", "exec")).To(ContainSubstring("true"))
+
+		var report struct {
+			Subjects struct {
+				Joined []map[string]string `json:"joined"`
+			} `json:"subjects"`
+			Permissions []map[string]interface{} `json:"permissions"`
+		}
+		Expect(json.Unmarshal([]byte(must("-o", "json", "import", "replace", doc)), &report)).To(Succeed())
+		Expect(report.Subjects.Joined).To(Equal([]map[string]string{{"entity": "role:writer", "group": "role:editor"}}))
+		Expect(report.Permissions).To(HaveLen(1))
+
+		Expect(must("export")).To(MatchYAML(exported))
+		Expect(must("import", "merge", doc)).To(Equal("no change\n"))
+	})
+
+	It("should reconcile polices to manifests", func() {
+		manifest := filepath.Join(dir, "manifest.yaml")
+		Expect(ioutil.WriteFile(manifest, []byte(`
+version: 1
+owner: platform
+subjects:
+- entity: user:edison
+  group: role:editor
+`), 0644)).To(Succeed())
+
+		Expect(must("-dry-run", "reconcile", manifest)).To(HavePrefix("dry run, nothing is changed\n"))
+		Expect(must("-o", "json", "members-of", "role:editor")).To(MatchJSON(`["user:alan"]`))
+
+		must("reconcile", manifest)
+		Expect(must("-o", "json", "members-of", "role:editor")).To(MatchJSON(`["user:alan", "user:edison"]`))
+		Expect(must("reconcile", manifest)).To(Equal("no change\n"))
+
+		Expect(ioutil.WriteFile(manifest, []byte("version: 1\nowner: platform\n"), 0644)).To(Succeed())
+		must("reconcile", manifest)
+		Expect(must("-o", "json", "members-of", "role:editor")).To(MatchJSON(`["user:alan"]`))
+	})
+
+	It("should diff polices between sources", func() {
+		exported := filepath.Join(dir, "polices.yaml")
+		Expect(ioutil.WriteFile(exported, []byte(must("export")), 0644)).To(Succeed())
+		Expect(must("diff", "live", exported)).To(Equal("no change\n"))
+
+		staging, e := ioutil.TempDir("", "rbacctl-")
+		Expect(e).To(Succeed())
+		defer os.RemoveAll(staging)
+		inStaging := func(args ...string) {
+			Expect(run(context.Background(), append([]string{"-dir", staging}, args...), ioutil.Discard, ioutil.Discard)).To(Succeed())
+		}
+		inStaging("import", "merge", exported)
+		inStaging("permit", "role:editor", "cat:news", "write")
+		inStaging("leave", "user:alan", "role:writer")
+
+		Expect(must("diff", "live", "dir:"+staging)).To(Equal(`subjects:
+- user:alan in role:writer
+permissions:
+~ role:editor on cat:news: read -> write|read (+write)
+`))
+		Expect(must("-o", "json", "diff", "dir:"+staging, exported)).To(MatchJSON(`{
+			"subjects": {"added": [{"entity": "user:alan", "group": "role:writer"}], "removed": []},
+			"objects": {"added": [], "removed": []},
+			"permissions": [{"subject": "role:editor", "object": "cat:news", "before": "write|read", "after": "read", "narrowed": "write"}]
+		}`))
+
+		_, e = rbacctl("diff", "live", "dir:"+filepath.Join(dir, "not-exists"))
+		Expect(e).To(HaveOccurred())
+	})
+
+	It("should reject invalid arguments", func() {
+		_, e := rbacctl("permit", "alan", "art:apollo", "read")
+		Expect(e).To(HaveOccurred())
+		_, e = rbacctl("permit", "user:alan", "art:apollo", "fly")
+		Expect(e).To(HaveOccurred())
+		_, e = rbacctl("remove-user", "role:writer")
+		Expect(e).To(HaveOccurred())
+		_, e = rbacctl("-persister", "etcd", "list", "roles")
+		Expect(e).To(MatchError(errUnsupportedPersister))
+		_, e = rbacctl("-persister", "sql", "-sql-driver", "oracle", "-sql-dsn", "db", "list", "roles")
+		Expect(e).To(HaveOccurred())
+	})
+
+	It("should keep polices in sql databases", func() {
+		sql := []string{"-persister", "sql", "-sql-driver", "sqlite3", "-sql-dsn", filepath.Join(dir, "rbac.db")}
+		must(append(sql, "join", "user:alan", "role:writer")...)
+		must(append(sql, "join", "role:writer", "role:editor")...)
+		must(append(sql, "permit", "role:editor", "cat:news", "read")...)
+		must(append(sql, "join", "art:apollo", "cat:news")...)
+
+		Expect(must(append(sql, "list", "roles")...)).To(Equal("ROLE\nrole:editor\nrole:writer\n"))
+		Expect(must(append(sql, "-o", "json", "check", "user:alan", "art:apollo", "read")...)).To(MatchJSON(
+			`{"subject": "user:alan", "object": "art:apollo", "action": "read", "allowed": true}`,
+		))
+
+		must(append(sql, "remove-role", "role:editor")...)
+		Expect(must(append(sql, "-o", "json", "check", "user:alan", "art:apollo", "read")...)).To(MatchJSON(
+			`{"subject": "user:alan", "object": "art:apollo", "action": "read", "allowed": false}`,
+		))
+	})
+})
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/supremind/rbac/diff"
+	"github.com/supremind/rbac/policy"
+	"github.com/supremind/rbac/types"
+)
+
+// printer writes results as aligned tables, or as JSON documents
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+func (p printer) encode(v interface{}) error {
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func (p printer) table(header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// entities prints serialized entities in order, under header
+func (p printer) entities(header string, ents []types.Entity) error {
+	names := make([]string, 0, len(ents))
+	for _, ent := range ents {
+		names = append(names, ent.String())
+	}
+	sort.Strings(names)
+
+	if p.json {
+		return p.encode(names)
+	}
+	rows := make([][]string, 0, len(names))
+	for _, name := range names {
+		rows = append(rows, []string{name})
+	}
+	return p.table([]string{header}, rows)
+}
+
+type decision struct {
+	Subject string `json:"subject"`
+	Object  string `json:"object"`
+	Action  string `json:"action"`
+	Allowed bool   `json:"allowed"`
+}
+
+func (p printer) decision(d decision) error {
+	if p.json {
+		return p.encode(d)
+	}
+	return p.table([]string{"SUBJECT", "OBJECT", "ACTION", "ALLOWED"}, [][]string{
+		{d.Subject, d.Object, d.Action, fmt.Sprint(d.Allowed)},
+	})
+}
+
+func (p printer) explanation(exp explanation) error {
+	if p.json {
+		return p.encode(exp)
+	}
+
+	if e := p.decision(decision{Subject: exp.Subject, Object: exp.Object, Action: exp.Action, Allowed: exp.Allowed}); e != nil {
+		return e
+	}
+	fmt.Fprintln(p.w)
+
+	if len(exp.Grants) == 0 {
+		fmt.Fprintln(p.w, "no policy grants any of the actions")
+	} else {
+		rows := make([][]string, 0, len(exp.Grants))
+		for _, g := range exp.Grants {
+			rows = append(rows, []string{
+				g.Subject, g.Object, g.Action,
+				strings.Join(g.SubjectPath, " -> "),
+				strings.Join(g.ObjectPath, " -> "),
+			})
+		}
+		if e := p.table([]string{"GRANTED TO", "ON", "ACTION", "SUBJECT PATH", "OBJECT PATH"}, rows); e != nil {
+			return e
+		}
+	}
+
+	if exp.Missing != "" {
+		fmt.Fprintf(p.w, "missing actions: %s\n", exp.Missing)
+	}
+	return nil
+}
+
+func (p printer) report(r *policy.Report) error {
+	if p.json {
+		return p.encode(r)
+	}
+
+	if r.DryRun {
+		fmt.Fprintln(p.w, "dry run, nothing is changed")
+	}
+	if r.Empty() {
+		fmt.Fprintln(p.w, "no change")
+		return nil
+	}
+
+	rows := make([][]string, 0)
+	for _, changes := range []policy.GroupingChanges{r.Subjects, r.Objects} {
+		for _, g := range changes.Joined {
+			rows = append(rows, []string{"join", g.Entity + " in " + g.Group, "", ""})
+		}
+		for _, g := range changes.Left {
+			rows = append(rows, []string{"leave", g.Entity + " in " + g.Group, "", ""})
+		}
+	}
+	for _, perm := range r.Permissions {
+		rows = append(rows, []string{"permission", perm.Subject + " on " + perm.Object, actions(perm.Before), actions(perm.After)})
+	}
+	return p.table([]string{"CHANGE", "POLICY", "BEFORE", "AFTER"}, rows)
+}
+
+func (p printer) diff(d *diff.Diff) error {
+	if p.json {
+		return p.encode(d)
+	}
+	if d.Empty() {
+		fmt.Fprintln(p.w, "no change")
+		return nil
+	}
+	return d.Render(p.w)
+}
+
+func actions(names []string) string {
+	if len(names) == 0 {
+		return "-"
+	}
+	return strings.Join(names, "|")
+}
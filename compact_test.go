@@ -0,0 +1,61 @@
+package rbac_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/supremind/rbac"
+	"github.com/supremind/rbac/persist/fake"
+	. "github.com/supremind/rbac/types"
+)
+
+var _ = Describe("authorizer with compact polices", func() {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+		authz  Authorizer
+	)
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		var e error
+		authz, e = rbac.New(ctx,
+			rbac.WithSubjectPersister(fake.NewGroupingPersister()),
+			rbac.WithObjectPersister(fake.NewGroupingPersister()),
+			rbac.WithPermissionPersister(fake.NewPermissionPersister()),
+			rbac.WithCompactPolices(),
+		)
+		Expect(e).To(Succeed())
+
+		Expect(authz.SubjectJoin(User("alan"), Role("editor"))).To(Succeed())
+		Expect(authz.SubjectJoin(Role("editor"), Role("reader"))).To(Succeed())
+		Expect(authz.ObjectJoin(Article("apollo"), Category("history"))).To(Succeed())
+		Expect(authz.Permit(Role("reader"), Category("history"), Read)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	It("should forget removed entities", func() {
+		Expect(authz.Shall(User("alan"), Article("apollo"), Read)).To(BeTrue())
+
+		Expect(authz.RemoveRole(Role("editor"))).To(Succeed())
+		Expect(authz.Shall(User("alan"), Article("apollo"), Read)).To(BeFalse())
+		Expect(authz.Subjects().GroupsOf(User("alan"))).To(BeEmpty())
+		Expect(authz.Subjects().AllGroups()).To(HaveKey(Role("reader")))
+		Expect(authz.Subjects().AllGroups()).NotTo(HaveKey(Role("editor")))
+
+		Expect(authz.SubjectJoin(User("bob"), Role("reader"))).To(Succeed())
+		Expect(authz.Shall(User("bob"), Article("apollo"), Read)).To(BeTrue())
+		Expect(authz.Revoke(Role("reader"), Category("history"), Read)).To(Succeed())
+		Expect(authz.PermissionsFor(Role("reader"))).To(BeEmpty())
+		Expect(authz.Shall(User("bob"), Article("apollo"), Read)).To(BeFalse())
+	})
+
+	It("should decide the same as fat polices", func() {
+		expectSameDecisions(ctx, authz)
+	})
+})
@@ -0,0 +1,107 @@
+package rbac_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/supremind/rbac"
+	"github.com/supremind/rbac/persist/fake"
+	. "github.com/supremind/rbac/types"
+)
+
+// blockingGroupingPersister never finishes inserting, until the context is done
+type blockingGroupingPersister struct {
+	GroupingPersister
+}
+
+func (p blockingGroupingPersister) InsertContext(ctx context.Context, ent Entity, group Group) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (p blockingGroupingPersister) RemoveContext(ctx context.Context, ent Entity, group Group) error {
+	return p.Remove(ent, group)
+}
+
+func (p blockingGroupingPersister) ListContext(ctx context.Context) ([]GroupingPolicy, error) {
+	return p.List()
+}
+
+var _ = Describe("authorizer with context", func() {
+	It("should cancel writes to context-aware persisters", func() {
+		authz, e := rbac.NewContext(context.Background(),
+			rbac.WithSubjectPersisterContext(blockingGroupingPersister{fake.NewGroupingPersister()}),
+			rbac.WithPermissionPersister(fake.NewPermissionPersister()),
+		)
+		Expect(e).To(Succeed())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		e = authz.SubjectJoinContext(ctx, User("alan"), Role("editor"))
+		Expect(errors.Is(e, context.DeadlineExceeded)).To(BeTrue())
+		Expect(authz.Subjects().IsIn(User("alan"), Role("editor"))).To(BeFalse())
+	})
+
+	It("should not write with canceled contexts to plain persisters", func() {
+		authz, e := rbac.NewContext(context.Background(),
+			rbac.WithSubjectPersister(fake.NewGroupingPersister()),
+			rbac.WithPermissionPersister(fake.NewPermissionPersister()),
+		)
+		Expect(e).To(Succeed())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		e = authz.PermitContext(ctx, User("alan"), Article("apollo"), Read)
+		Expect(errors.Is(e, context.Canceled)).To(BeTrue())
+		Expect(authz.ShallContext(context.Background(), User("alan"), Article("apollo"), Read)).To(BeFalse())
+
+		Expect(authz.PermitContext(context.Background(), User("alan"), Article("apollo"), Read)).To(Succeed())
+		Expect(authz.ShallContext(context.Background(), User("alan"), Article("apollo"), Read)).To(BeTrue())
+	})
+
+	It("should refuse nil persisters", func() {
+		_, e := rbac.New(context.Background(), rbac.WithPermissionPersister(nil))
+		Expect(e).To(MatchError("empty permission persister"))
+
+		_, e = rbac.New(context.Background(),
+			rbac.WithSubjectPersister(nil),
+			rbac.WithObjectPersister(nil),
+			rbac.WithPermissionPersister(fake.NewPermissionPersister()),
+		)
+		Expect(e).To(Succeed())
+	})
+
+	It("should serve both plain and context-aware methods", func() {
+		authz, e := rbac.New(context.Background(),
+			rbac.WithSubjectPersister(fake.NewGroupingPersister()),
+			rbac.WithPermissionPersister(fake.NewPermissionPersister()),
+		)
+		Expect(e).To(Succeed())
+
+		Expect(authz.(AuthorizerContext).SubjectJoinContext(context.Background(), User("alan"), Role("editor"))).To(Succeed())
+		Expect(authz.Permit(Role("editor"), Article("apollo"), Read)).To(Succeed())
+		Expect(authz.Shall(User("alan"), Article("apollo"), Read)).To(BeTrue())
+	})
+
+	It("should record actors from contexts in audit events", func() {
+		sink := &eventsSink{}
+		authz, e := rbac.NewContext(context.Background(),
+			rbac.WithSubjectPersister(fake.NewGroupingPersister()),
+			rbac.WithPermissionPersister(fake.NewPermissionPersister()),
+			rbac.WithAuditSink(sink, nil),
+		)
+		Expect(e).To(Succeed())
+
+		ctx := WithActor(context.Background(), "admin")
+		Expect(authz.SubjectJoinContext(ctx, User("alan"), Role("editor"))).To(Succeed())
+		Expect(authz.PermitContext(context.Background(), Role("editor"), Article("apollo"), Read)).To(Succeed())
+
+		Expect(sink.events).To(HaveLen(2))
+		Expect(sink.events[0].Actor).To(Equal("admin"))
+		Expect(sink.events[1].Actor).To(BeEmpty())
+	})
+})
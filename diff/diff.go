@@ -0,0 +1,192 @@
+// Package diff compares polices of two states, like polices in staging and production,
+// polices kept by two sets of persisters, exported documents, or live authorizers.
+package diff
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/supremind/rbac/types"
+)
+
+// Diff is the changes from one state of polices to another
+type Diff struct {
+	Subjects    Memberships
+	Objects     Memberships
+	Permissions []Permission
+}
+
+// Memberships are grouping polices added or removed
+type Memberships struct {
+	Added   []types.GroupingPolicy
+	Removed []types.GroupingPolicy
+}
+
+// Permission is the change of actions directly permitted to Subject on Object
+type Permission struct {
+	Subject types.Subject
+	Object  types.Object
+
+	// Before and After are actions permitted, None if nothing is permitted
+	Before types.Action
+	After  types.Action
+}
+
+// Widened returns actions permitted after but not before
+func (p Permission) Widened() types.Action {
+	return p.After.Difference(p.Before)
+}
+
+// Narrowed returns actions permitted before but not after
+func (p Permission) Narrowed() types.Action {
+	return p.Before.Difference(p.After)
+}
+
+// Empty tells if the two states are the same
+func (d *Diff) Empty() bool {
+	return len(d.Subjects.Added) == 0 && len(d.Subjects.Removed) == 0 &&
+		len(d.Objects.Added) == 0 && len(d.Objects.Removed) == 0 &&
+		len(d.Permissions) == 0
+}
+
+// Compare reads polices from the two sources, and returns changes from one to another
+func Compare(from, to types.PolicyLister) (*Diff, error) {
+	a, e := from.Policies()
+	if e != nil {
+		return nil, e
+	}
+	b, e := to.Policies()
+	if e != nil {
+		return nil, e
+	}
+	return Of(a, b), nil
+}
+
+// Of returns changes from polices a to b, changes are sorted by the serialized entities
+func Of(a, b types.Policies) *Diff {
+	d := &Diff{}
+	d.Subjects = memberships(a.Subjects, b.Subjects)
+	d.Objects = memberships(a.Objects, b.Objects)
+
+	before, after := actionsByKey(a.Permissions), actionsByKey(b.Permissions)
+	for key, act := range before {
+		if act != after[key] {
+			d.Permissions = append(d.Permissions, Permission{Subject: key.sub, Object: key.obj, Before: act, After: after[key]})
+		}
+	}
+	for key, act := range after {
+		if _, ok := before[key]; !ok && act != types.None {
+			d.Permissions = append(d.Permissions, Permission{Subject: key.sub, Object: key.obj, After: act})
+		}
+	}
+	sort.Slice(d.Permissions, func(i, j int) bool {
+		a, b := d.Permissions[i], d.Permissions[j]
+		if a.Subject.String() != b.Subject.String() {
+			return a.Subject.String() < b.Subject.String()
+		}
+		return a.Object.String() < b.Object.String()
+	})
+
+	return d
+}
+
+func memberships(a, b []types.GroupingPolicy) Memberships {
+	return Memberships{Added: subtract(b, a), Removed: subtract(a, b)}
+}
+
+// subtract returns polices in a but not b, sorted
+func subtract(a, b []types.GroupingPolicy) []types.GroupingPolicy {
+	exclude := make(map[types.GroupingPolicy]struct{}, len(b))
+	for _, policy := range b {
+		exclude[policy] = struct{}{}
+	}
+
+	var polices []types.GroupingPolicy
+	for _, policy := range a {
+		if _, ok := exclude[policy]; ok {
+			continue
+		}
+		exclude[policy] = struct{}{} // skip duplicated ones
+		polices = append(polices, policy)
+	}
+	sort.Slice(polices, func(i, j int) bool {
+		if polices[i].Entity.String() != polices[j].Entity.String() {
+			return polices[i].Entity.String() < polices[j].Entity.String()
+		}
+		return polices[i].Group.String() < polices[j].Group.String()
+	})
+	return polices
+}
+
+type permissionKey struct {
+	sub types.Subject
+	obj types.Object
+}
+
+func actionsByKey(polices []types.PermissionPolicy) map[permissionKey]types.Action {
+	acts := make(map[permissionKey]types.Action, len(polices))
+	for _, policy := range polices {
+		acts[permissionKey{sub: policy.Subject, obj: policy.Object}] |= policy.Action
+	}
+	return acts
+}
+
+type grouping struct {
+	Entity string `json:"entity"`
+	Group  string `json:"group"`
+}
+
+// MarshalJSON encodes entities in their serialized form
+func (m Memberships) MarshalJSON() ([]byte, error) {
+	encode := func(polices []types.GroupingPolicy) []grouping {
+		gs := make([]grouping, 0, len(polices))
+		for _, policy := range polices {
+			gs = append(gs, grouping{Entity: policy.Entity.String(), Group: policy.Group.String()})
+		}
+		return gs
+	}
+
+	return json.Marshal(struct {
+		Added   []grouping `json:"added"`
+		Removed []grouping `json:"removed"`
+	}{
+		Added:   encode(m.Added),
+		Removed: encode(m.Removed),
+	})
+}
+
+// MarshalJSON encodes entities in their serialized form, and actions by names
+func (p Permission) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Subject  string `json:"subject"`
+		Object   string `json:"object"`
+		Before   string `json:"before"`
+		After    string `json:"after"`
+		Widened  string `json:"widened,omitempty"`
+		Narrowed string `json:"narrowed,omitempty"`
+	}{
+		Subject:  p.Subject.String(),
+		Object:   p.Object.String(),
+		Before:   p.Before.String(),
+		After:    p.After.String(),
+		Widened:  p.Widened().String(),
+		Narrowed: p.Narrowed().String(),
+	})
+}
+
+// MarshalJSON encodes the diff with lower cased keys, and empty lists instead of nulls
+func (d *Diff) MarshalJSON() ([]byte, error) {
+	perms := d.Permissions
+	if perms == nil {
+		perms = []Permission{}
+	}
+	return json.Marshal(struct {
+		Subjects    Memberships  `json:"subjects"`
+		Objects     Memberships  `json:"objects"`
+		Permissions []Permission `json:"permissions"`
+	}{
+		Subjects:    d.Subjects,
+		Objects:     d.Objects,
+		Permissions: perms,
+	})
+}
@@ -0,0 +1,134 @@
+package diff_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/supremind/rbac"
+	"github.com/supremind/rbac/diff"
+	"github.com/supremind/rbac/persist/fake"
+	"github.com/supremind/rbac/policy"
+	. "github.com/supremind/rbac/types"
+)
+
+func TestDiff(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "diff test suit")
+}
+
+const staging = `
+version: 1
+subjects:
+- entity: user:alan
+  group: role:editor
+- entity: user:edison
+  group: role:editor
+objects:
+- entity: art:apollo
+  group: cat:news
+permissions:
+- subject: role:editor
+  object: cat:news
+  actions: [read, write]
+- subject: user:alan
+  object: art:apollo
+  actions: [exec]
+`
+
+var _ = Describe("policy diff", func() {
+	var (
+		sp, op GroupingPersister
+		pp     PermissionPersister
+	)
+
+	BeforeEach(func() {
+		sp, op, pp = fake.NewGroupingPersister(), fake.NewGroupingPersister(), fake.NewPermissionPersister()
+		Expect(sp.Insert(User("alan"), Role("editor"))).To(Succeed())
+		Expect(sp.Insert(User("bob"), Role("editor"))).To(Succeed())
+		Expect(op.Insert(Article("apollo"), Category("news"))).To(Succeed())
+		Expect(pp.Insert(Role("editor"), Category("news"), Read|Exec)).To(Succeed())
+		Expect(pp.Insert(User("bob"), Article("apollo"), Read)).To(Succeed())
+	})
+
+	It("should diff persisters against documents", func() {
+		d, e := diff.Compare(diff.FromPersisters(sp, op, pp), diff.FromReader(strings.NewReader(staging)))
+		Expect(e).To(Succeed())
+
+		Expect(d.Subjects.Added).To(Equal([]GroupingPolicy{{Entity: User("edison"), Group: Role("editor")}}))
+		Expect(d.Subjects.Removed).To(Equal([]GroupingPolicy{{Entity: User("bob"), Group: Role("editor")}}))
+		Expect(d.Objects.Added).To(BeEmpty())
+		Expect(d.Objects.Removed).To(BeEmpty())
+		Expect(d.Permissions).To(Equal([]diff.Permission{
+			{Subject: Role("editor"), Object: Category("news"), Before: Read | Exec, After: ReadWrite},
+			{Subject: User("alan"), Object: Article("apollo"), After: Exec},
+			{Subject: User("bob"), Object: Article("apollo"), Before: Read},
+		}))
+		Expect(d.Permissions[0].Widened()).To(Equal(Write))
+		Expect(d.Permissions[0].Narrowed()).To(Equal(Exec))
+
+		var out bytes.Buffer
+		Expect(d.Render(&out)).To(Succeed())
+		Expect(out.String()).To(Equal(`subjects:
++ user:edison in role:editor
+- user:bob in role:editor
+permissions:
+~ role:editor on cat:news: exec|read -> write|read (+write -exec)
++ user:alan on art:apollo: exec
+- user:bob on art:apollo: read
+`))
+
+		content, e := json.Marshal(d)
+		Expect(e).To(Succeed())
+		Expect(content).To(MatchJSON(`{
+			"subjects": {
+				"added": [{"entity": "user:edison", "group": "role:editor"}],
+				"removed": [{"entity": "user:bob", "group": "role:editor"}]
+			},
+			"objects": {"added": [], "removed": []},
+			"permissions": [
+				{"subject": "role:editor", "object": "cat:news", "before": "exec|read", "after": "write|read", "widened": "write", "narrowed": "exec"},
+				{"subject": "user:alan", "object": "art:apollo", "before": "", "after": "exec", "widened": "exec"},
+				{"subject": "user:bob", "object": "art:apollo", "before": "read", "after": "", "narrowed": "read"}
+			]
+		}`))
+	})
+
+	It("should find nothing changed between a live authorizer and its persisters", func() {
+		authz, e := rbac.New(context.Background(),
+			rbac.WithSubjectPersister(sp),
+			rbac.WithObjectPersister(op),
+			rbac.WithPermissionPersister(pp),
+		)
+		Expect(e).To(Succeed())
+
+		d, e := diff.Compare(diff.FromAuthorizer(authz), diff.FromPersisters(sp, op, pp))
+		Expect(e).To(Succeed())
+		Expect(d.Empty()).To(BeTrue())
+
+		var out bytes.Buffer
+		Expect(d.Render(&out)).To(Succeed())
+		Expect(out.String()).To(BeEmpty())
+
+		By("exported documents are the same as well")
+		var exported bytes.Buffer
+		Expect(policy.Export(&exported, authz, policy.YAML)).To(Succeed())
+		doc, e := policy.Decode(&exported)
+		Expect(e).To(Succeed())
+		d, e = diff.Compare(diff.FromDocument(doc), authz.(PolicyLister))
+		Expect(e).To(Succeed())
+		Expect(d.Empty()).To(BeTrue())
+	})
+
+	It("should fail with sources failed", func() {
+		_, e := diff.Compare(diff.FromFile("not-exists.yaml"), diff.FromPersisters(sp, op, pp))
+		Expect(e).To(HaveOccurred())
+		_, e = diff.Compare(diff.FromPersisters(sp, op, pp), diff.FromReader(strings.NewReader("version: 2\n")))
+		Expect(e).To(MatchError(policy.ErrUnsupportedVersion))
+	})
+})
@@ -0,0 +1,68 @@
+package diff
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// Render writes the diff in a human readable form, like:
+//
+//	subjects:
+//	+ user:alan in role:editor
+//	- user:bob in role:editor
+//	permissions:
+//	~ role:editor on cat:news: read -> read|write (+write)
+//	+ user:alan on art:apollo: write
+//
+// sections without changes are omitted, and nothing is written if the diff is empty
+func (d *Diff) Render(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	renderMemberships(bw, "subjects", d.Subjects)
+	renderMemberships(bw, "objects", d.Objects)
+
+	if len(d.Permissions) > 0 {
+		fmt.Fprintln(bw, "permissions:")
+	}
+	for _, p := range d.Permissions {
+		switch {
+		case p.Before == 0:
+			fmt.Fprintf(bw, "+ %s on %s: %s\n", p.Subject, p.Object, p.After)
+		case p.After == 0:
+			fmt.Fprintf(bw, "- %s on %s: %s\n", p.Subject, p.Object, p.Before)
+		default:
+			fmt.Fprintf(bw, "~ %s on %s: %s -> %s (%s)\n", p.Subject, p.Object, p.Before, p.After, changedActions(p))
+		}
+	}
+
+	return bw.Flush()
+}
+
+func renderMemberships(w io.Writer, name string, m Memberships) {
+	if len(m.Added) == 0 && len(m.Removed) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%s:\n", name)
+	for _, policy := range m.Added {
+		fmt.Fprintf(w, "+ %s in %s\n", policy.Entity, policy.Group)
+	}
+	for _, policy := range m.Removed {
+		fmt.Fprintf(w, "- %s in %s\n", policy.Entity, policy.Group)
+	}
+}
+
+// changedActions tells actions widened and narrowed, like +write -exec
+func changedActions(p Permission) string {
+	var s string
+	if widened := p.Widened(); widened != 0 {
+		s = "+" + widened.String()
+	}
+	if narrowed := p.Narrowed(); narrowed != 0 {
+		if s != "" {
+			s += " "
+		}
+		s += "-" + narrowed.String()
+	}
+	return s
+}
@@ -0,0 +1,79 @@
+package diff
+
+import (
+	"io"
+	"os"
+
+	"github.com/supremind/rbac/policy"
+	"github.com/supremind/rbac/types"
+)
+
+type policyListerFunc func() (types.Policies, error)
+
+func (f policyListerFunc) Policies() (types.Policies, error) {
+	return f()
+}
+
+// FromPersisters lists polices kept by the persisters, object could be nil if object grouping is not used
+func FromPersisters(subject, object types.GroupingPersister, permission types.PermissionPersister) types.PolicyLister {
+	return policyListerFunc(func() (types.Policies, error) {
+		var ps types.Policies
+		var e error
+
+		if subject != nil {
+			if ps.Subjects, e = subject.List(); e != nil {
+				return types.Policies{}, e
+			}
+		}
+		if object != nil {
+			if ps.Objects, e = object.List(); e != nil {
+				return types.Policies{}, e
+			}
+		}
+		if permission != nil {
+			if ps.Permissions, e = permission.List(); e != nil {
+				return types.Policies{}, e
+			}
+		}
+		return ps, nil
+	})
+}
+
+// FromAuthorizer lists polices in a live authorizer, it should be a types.PolicyLister, like the Authorizers created by rbac.New
+func FromAuthorizer(authz types.Authorizer) types.PolicyLister {
+	return policyListerFunc(func() (types.Policies, error) {
+		l, ok := authz.(types.PolicyLister)
+		if !ok {
+			return types.Policies{}, types.ErrNoPolicyList
+		}
+		return l.Policies()
+	})
+}
+
+// FromDocument lists polices in a document, like the ones exported by policy.Export
+func FromDocument(doc *policy.Document) types.PolicyLister {
+	return policyListerFunc(doc.Policies)
+}
+
+// FromReader lists polices in a document read from r
+func FromReader(r io.Reader) types.PolicyLister {
+	return policyListerFunc(func() (types.Policies, error) {
+		doc, e := policy.Decode(r)
+		if e != nil {
+			return types.Policies{}, e
+		}
+		return doc.Policies()
+	})
+}
+
+// FromFile lists polices in the document file at path
+func FromFile(path string) types.PolicyLister {
+	return policyListerFunc(func() (types.Policies, error) {
+		f, e := os.Open(path)
+		if e != nil {
+			return types.Policies{}, e
+		}
+		defer f.Close()
+		return FromReader(f).Policies()
+	})
+}
@@ -0,0 +1,81 @@
+package rbac_test
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/supremind/rbac"
+	"github.com/supremind/rbac/persist/fake"
+	. "github.com/supremind/rbac/types"
+)
+
+// expectSameDecisions changes polices of authz randomly, and expects it decides the same as a plain authorizer
+func expectSameDecisions(ctx context.Context, authz Authorizer) {
+	plain, e := rbac.New(ctx,
+		rbac.WithSubjectPersister(fake.NewGroupingPersister()),
+		rbac.WithObjectPersister(fake.NewGroupingPersister()),
+		rbac.WithPermissionPersister(fake.NewPermissionPersister()),
+	)
+	Expect(e).To(Succeed())
+	ps, e := authz.(PolicyLister).Policies()
+	Expect(e).To(Succeed())
+	for _, gp := range ps.Subjects {
+		Expect(plain.SubjectJoin(gp.Entity.(Subject), gp.Group.(Role))).To(Succeed())
+	}
+	for _, gp := range ps.Objects {
+		Expect(plain.ObjectJoin(gp.Entity.(Object), gp.Group.(Category))).To(Succeed())
+	}
+	for _, pp := range ps.Permissions {
+		Expect(plain.Permit(pp.Subject, pp.Object, pp.Action)).To(Succeed())
+	}
+
+	// groupings are kept acyclic: members never join groups they could be joined by
+	users := []Subject{User("alan"), User("bob"), User("carl"), Role("editor"), Role("reader")}
+	members := []Subject{User("alan"), User("bob"), User("carl"), Role("editor")}
+	roles := []Role{Role("reader"), Role("writer")}
+	arts := []Object{Article("apollo"), Article("gemini"), Article("skylab"), Category("history"), Category("science")}
+	entries := []Object{Article("apollo"), Article("gemini"), Article("skylab"), Category("history")}
+	cats := []Category{Category("science"), Category("space")}
+	acts := []Action{Read, Write, Exec, Read | Write}
+
+	r := rand.New(rand.NewSource(GinkgoRandomSeed()))
+	both := func(op func(Authorizer) error) {
+		e1, e2 := op(authz), op(plain)
+		Expect(errors.Is(e1, ErrAlreadyExists) || errors.Is(e1, ErrNotFound)).To(Equal(errors.Is(e2, ErrAlreadyExists) || errors.Is(e2, ErrNotFound)))
+	}
+	for i := 0; i < 500; i++ {
+		sub, obj, act := users[r.Intn(len(users))], arts[r.Intn(len(arts))], acts[r.Intn(len(acts))]
+		member, role := members[r.Intn(len(members))], roles[r.Intn(len(roles))]
+		entry, cat := entries[r.Intn(len(entries))], cats[r.Intn(len(cats))]
+
+		switch r.Intn(8) {
+		case 0:
+			both(func(a Authorizer) error { return a.SubjectJoin(member, role) })
+		case 1:
+			both(func(a Authorizer) error { return a.SubjectLeave(member, role) })
+		case 2:
+			both(func(a Authorizer) error { return a.ObjectJoin(entry, cat) })
+		case 3:
+			both(func(a Authorizer) error { return a.ObjectLeave(entry, cat) })
+		case 4:
+			both(func(a Authorizer) error { return a.Permit(sub, obj, act) })
+		case 5:
+			both(func(a Authorizer) error { return a.Revoke(sub, obj, act) })
+		case 6:
+			both(func(a Authorizer) error { return a.RemoveRole(role) })
+		}
+
+		for _, sub := range users {
+			for _, obj := range arts {
+				want, e := plain.PermittedActions(sub, obj)
+				Expect(e).To(Succeed())
+				Expect(authz.PermittedActions(sub, obj)).To(Equal(want), "%s on %s", sub, obj)
+				Expect(authz.Shall(sub, obj, act)).To(Equal(want.Includes(act)), "%s %s on %s", sub, act, obj)
+			}
+		}
+	}
+}
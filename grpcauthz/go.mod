@@ -0,0 +1,11 @@
+module github.com/supremind/rbac/grpcauthz
+
+go 1.14
+
+require (
+	github.com/onsi/ginkgo v1.16.4
+	github.com/onsi/gomega v1.14.0
+	github.com/supremind/rbac v0.5.0
+	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013
+	google.golang.org/grpc v1.40.0
+)
@@ -0,0 +1,272 @@
+// Package grpcauthz provides gRPC server interceptors authorizing calls by an Authorizer.
+//
+// Methods are mapped to objects and actions by Rules, keyed by full method names, like /pkg.Service/Method,
+// or /pkg.Service/* for all methods of a service:
+//
+//	ic := grpcauthz.New(authz, subjectFromMetadata, grpcauthz.Rules{
+//		"/news.Articles/Get":       {Action: types.Read, ObjectOf: articleOf},
+//		"/news.Articles/Publish":   {Action: types.Exec, ObjectOf: articleOf},
+//		"/news.Articles/*":         {Action: types.Write, Object: types.Category("news")},
+//		"/grpc.health.v1.Health/*": {Public: true},
+//	})
+//	srv := grpc.NewServer(grpc.UnaryInterceptor(ic.Unary()), grpc.StreamInterceptor(ic.Stream()))
+//
+// Calls to methods without rules are denied.
+// Objects could be read from proto options of methods as well, by a custom Resolver.
+package grpcauthz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/supremind/rbac/types"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ErrNoRule is returned when there is no rule for a method
+var ErrNoRule = errors.New("no rule for method")
+
+// Domain of error details in PermissionDenied statuses
+const Domain = "rbac"
+
+// SubjectFunc extracts the subject from incoming metadata, like the user authenticated by a token
+type SubjectFunc func(ctx context.Context, md metadata.MD) (types.Subject, error)
+
+// Rule tells the object and the action of a method
+type Rule struct {
+	// Public methods are not authorized
+	Public bool
+
+	// Action performed by calling the method
+	Action types.Action
+
+	// Object accessed by calling the method, it is used if ObjectOf is nil
+	Object types.Object
+
+	// ObjectOf returns the object accessed from the request message,
+	// for streaming methods, the first message received is used, and calls are authorized at that time
+	ObjectOf func(req interface{}) (types.Object, error)
+}
+
+// Resolver returns the rule of a method
+type Resolver interface {
+	Resolve(fullMethod string) (Rule, error)
+}
+
+// Rules is a Resolver keyed by full method names, /pkg.Service/* matches all methods of the service
+type Rules map[string]Rule
+
+// Resolve returns the rule of the method, or of its service
+func (rs Rules) Resolve(fullMethod string) (Rule, error) {
+	if rule, ok := rs[fullMethod]; ok {
+		return rule, nil
+	}
+	if i := strings.LastIndexByte(fullMethod, '/'); i >= 0 {
+		if rule, ok := rs[fullMethod[:i+1]+"*"]; ok {
+			return rule, nil
+		}
+	}
+	return Rule{}, fmt.Errorf("%w: %s", ErrNoRule, fullMethod)
+}
+
+// Decision is made for a call
+type Decision struct {
+	Subject types.Subject
+	Object  types.Object
+	Action  types.Action
+	Allowed bool
+}
+
+type decisionKey struct{}
+
+// DecisionFrom returns the decision put into the context by interceptors
+func DecisionFrom(ctx context.Context) (Decision, bool) {
+	d, ok := ctx.Value(decisionKey{}).(Decision)
+	return d, ok
+}
+
+// Option configures interceptors
+type Option func(*Interceptor)
+
+// WithExplanation adds actions permitted and missing to details of PermissionDenied statuses.
+// It tells clients about polices, so it is better not to be used for public services.
+func WithExplanation() Option {
+	return func(i *Interceptor) {
+		i.explain = true
+	}
+}
+
+// Interceptor authorizes calls before invoking handlers
+type Interceptor struct {
+	authz    types.Authorizer
+	subject  SubjectFunc
+	resolver Resolver
+	explain  bool
+}
+
+// New creates an Interceptor.
+// If authz implements types.PermissionContext, like the Authorizers created by rbac.New,
+// decisions are made with call contexts, carrying the subject as the actor.
+func New(authz types.Authorizer, subject SubjectFunc, resolver Resolver, opts ...Option) *Interceptor {
+	i := &Interceptor{authz: authz, subject: subject, resolver: resolver}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// Unary returns the unary server interceptor
+func (i *Interceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		rule, e := i.resolver.Resolve(info.FullMethod)
+		if e != nil {
+			return nil, status.Error(codes.PermissionDenied, e.Error())
+		}
+		if rule.Public {
+			return handler(ctx, req)
+		}
+
+		ctx, e = i.authorize(ctx, rule, req)
+		if e != nil {
+			return nil, e
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Stream returns the stream server interceptor
+func (i *Interceptor) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		rule, e := i.resolver.Resolve(info.FullMethod)
+		if e != nil {
+			return status.Error(codes.PermissionDenied, e.Error())
+		}
+		if rule.Public {
+			return handler(srv, ss)
+		}
+
+		if rule.ObjectOf != nil {
+			return handler(srv, &authorizingStream{ServerStream: ss, i: i, rule: rule, ctx: ss.Context()})
+		}
+
+		ctx, e := i.authorize(ss.Context(), rule, nil)
+		if e != nil {
+			return e
+		}
+		return handler(srv, &authorizingStream{ServerStream: ss, ctx: ctx, authorized: true})
+	}
+}
+
+// authorize returns the context carrying the decision, or a status error if the call should not be invoked
+func (i *Interceptor) authorize(ctx context.Context, rule Rule, req interface{}) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	sub, e := i.subject(ctx, md)
+	if e != nil {
+		return nil, status.Error(codes.Unauthenticated, e.Error())
+	}
+
+	obj := rule.Object
+	if rule.ObjectOf != nil {
+		if obj, e = rule.ObjectOf(req); e != nil {
+			return nil, status.Error(codes.InvalidArgument, e.Error())
+		}
+	}
+	if obj == nil {
+		return nil, status.Error(codes.Internal, "no object in the rule")
+	}
+
+	ctx = types.WithActor(ctx, sub.String())
+	allowed, e := i.shall(ctx, sub, obj, rule.Action)
+	if e != nil {
+		return nil, status.Error(codes.Internal, e.Error())
+	}
+	d := Decision{Subject: sub, Object: obj, Action: rule.Action, Allowed: allowed}
+	if !allowed {
+		return nil, i.deny(ctx, d)
+	}
+	return context.WithValue(ctx, decisionKey{}, d), nil
+}
+
+func (i *Interceptor) shall(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) (bool, error) {
+	if pc, ok := i.authz.(types.PermissionContext); ok {
+		return pc.ShallContext(ctx, sub, obj, act)
+	}
+	return i.authz.Shall(sub, obj, act)
+}
+
+func (i *Interceptor) permitted(ctx context.Context, sub types.Subject, obj types.Object) (types.Action, error) {
+	if pc, ok := i.authz.(types.PermissionContext); ok {
+		return pc.PermittedActionsContext(ctx, sub, obj)
+	}
+	return i.authz.PermittedActions(sub, obj)
+}
+
+// deny returns a PermissionDenied status, with the decision in an ErrorInfo detail
+func (i *Interceptor) deny(ctx context.Context, d Decision) error {
+	info := &errdetails.ErrorInfo{
+		Reason: "PERMISSION_DENIED",
+		Domain: Domain,
+		Metadata: map[string]string{
+			"subject": d.Subject.String(),
+			"object":  d.Object.String(),
+			"action":  d.Action.String(),
+		},
+	}
+	if i.explain {
+		permitted, e := i.permitted(ctx, d.Subject, d.Object)
+		if e != nil {
+			return status.Error(codes.Internal, e.Error())
+		}
+		info.Metadata["permitted"] = permitted.String()
+		info.Metadata["missing"] = d.Action.Difference(permitted).String()
+	}
+
+	st := status.Newf(codes.PermissionDenied, "%s shall not %s %s", d.Subject, d.Action, d.Object)
+	if withDetails, e := st.WithDetails(info); e == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// authorizingStream carries the decision in its context,
+// and authorizes the call on the first message received if objects are read from requests
+type authorizingStream struct {
+	grpc.ServerStream
+	i          *Interceptor
+	rule       Rule
+	ctx        context.Context
+	authorized bool
+}
+
+func (s *authorizingStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *authorizingStream) RecvMsg(m interface{}) error {
+	if e := s.ServerStream.RecvMsg(m); e != nil {
+		return e
+	}
+	if s.authorized {
+		return nil
+	}
+
+	ctx, e := s.i.authorize(s.ctx, s.rule, m)
+	if e != nil {
+		return e
+	}
+	s.ctx, s.authorized = ctx, true
+	return nil
+}
+
+func (s *authorizingStream) SendMsg(m interface{}) error {
+	if !s.authorized {
+		return status.Error(codes.PermissionDenied, "no message is received to be authorized")
+	}
+	return s.ServerStream.SendMsg(m)
+}
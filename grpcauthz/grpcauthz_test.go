@@ -0,0 +1,152 @@
+package grpcauthz_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/supremind/rbac"
+	"github.com/supremind/rbac/grpcauthz"
+	"github.com/supremind/rbac/persist/fake"
+	. "github.com/supremind/rbac/types"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestGRPCAuthz(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "grpcauthz test suit")
+}
+
+func subjectFromMetadata(ctx context.Context, md metadata.MD) (Subject, error) {
+	users := md.Get("x-user")
+	if len(users) == 0 {
+		return nil, errors.New("not logged in")
+	}
+	return User(users[0]), nil
+}
+
+func serviceOf(req interface{}) (Object, error) {
+	return Category(req.(*healthpb.HealthCheckRequest).Service), nil
+}
+
+var _ = Describe("grpc interceptors", func() {
+	var (
+		srv    *grpc.Server
+		conn   *grpc.ClientConn
+		client healthpb.HealthClient
+		cancel context.CancelFunc
+	)
+
+	start := func(rules grpcauthz.Rules, opts ...grpcauthz.Option) {
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+
+		authz, e := rbac.New(ctx,
+			rbac.WithSubjectPersister(fake.NewGroupingPersister()),
+			rbac.WithObjectPersister(fake.NewGroupingPersister()),
+			rbac.WithPermissionPersister(fake.NewPermissionPersister()),
+		)
+		Expect(e).To(Succeed())
+		Expect(authz.SubjectJoin(User("alan"), Role("ops"))).To(Succeed())
+		Expect(authz.Permit(Role("ops"), Category("news"), Read)).To(Succeed())
+
+		ic := grpcauthz.New(authz, subjectFromMetadata, rules, opts...)
+		srv = grpc.NewServer(grpc.UnaryInterceptor(ic.Unary()), grpc.StreamInterceptor(ic.Stream()))
+		hs := health.NewServer()
+		hs.SetServingStatus("news", healthpb.HealthCheckResponse_SERVING)
+		hs.SetServingStatus("sports", healthpb.HealthCheckResponse_SERVING)
+		healthpb.RegisterHealthServer(srv, hs)
+
+		lis := bufconn.Listen(1 << 20)
+		go srv.Serve(lis)
+
+		conn, e = grpc.DialContext(ctx, "bufnet",
+			grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+			grpc.WithInsecure(),
+		)
+		Expect(e).To(Succeed())
+		client = healthpb.NewHealthClient(conn)
+	}
+
+	AfterEach(func() {
+		conn.Close()
+		srv.Stop()
+		cancel()
+	})
+
+	as := func(user string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "x-user", user)
+	}
+
+	It("should authorize unary calls", func() {
+		start(grpcauthz.Rules{
+			"/grpc.health.v1.Health/Check": {Action: Read, ObjectOf: serviceOf},
+		}, grpcauthz.WithExplanation())
+
+		resp, e := client.Check(as("alan"), &healthpb.HealthCheckRequest{Service: "news"})
+		Expect(e).To(Succeed())
+		Expect(resp.Status).To(Equal(healthpb.HealthCheckResponse_SERVING))
+
+		_, e = client.Check(as("alan"), &healthpb.HealthCheckRequest{Service: "sports"})
+		st := status.Convert(e)
+		Expect(st.Code()).To(Equal(codes.PermissionDenied))
+		Expect(st.Details()).To(HaveLen(1))
+		info := st.Details()[0].(*errdetails.ErrorInfo)
+		Expect(info.Domain).To(Equal(grpcauthz.Domain))
+		Expect(info.Metadata).To(Equal(map[string]string{
+			"subject": "user:alan", "object": "cat:sports", "action": "read", "permitted": "", "missing": "read",
+		}))
+
+		_, e = client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "news"})
+		Expect(status.Code(e)).To(Equal(codes.Unauthenticated))
+	})
+
+	It("should authorize streaming calls on the first message", func() {
+		start(grpcauthz.Rules{
+			"/grpc.health.v1.Health/*": {Action: Read, ObjectOf: serviceOf},
+		})
+
+		stream, e := client.Watch(as("alan"), &healthpb.HealthCheckRequest{Service: "news"})
+		Expect(e).To(Succeed())
+		resp, e := stream.Recv()
+		Expect(e).To(Succeed())
+		Expect(resp.Status).To(Equal(healthpb.HealthCheckResponse_SERVING))
+
+		stream, e = client.Watch(as("edison"), &healthpb.HealthCheckRequest{Service: "news"})
+		Expect(e).To(Succeed())
+		_, e = stream.Recv()
+		Expect(status.Code(e)).To(Equal(codes.PermissionDenied))
+	})
+
+	It("should authorize streaming calls with static objects before invoking handlers", func() {
+		start(grpcauthz.Rules{
+			"/grpc.health.v1.Health/Watch": {Action: Write, Object: Category("news")},
+			"/grpc.health.v1.Health/Check": {Public: true},
+		})
+
+		stream, e := client.Watch(as("alan"), &healthpb.HealthCheckRequest{Service: "news"})
+		Expect(e).To(Succeed())
+		_, e = stream.Recv()
+		Expect(status.Code(e)).To(Equal(codes.PermissionDenied))
+
+		_, e = client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "sports"})
+		Expect(e).To(Succeed())
+	})
+
+	It("should deny methods without rules", func() {
+		start(grpcauthz.Rules{})
+		_, e := client.Check(as("alan"), &healthpb.HealthCheckRequest{Service: "news"})
+		Expect(status.Code(e)).To(Equal(codes.PermissionDenied))
+	})
+})
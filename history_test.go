@@ -0,0 +1,99 @@
+package rbac_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/supremind/rbac"
+	"github.com/supremind/rbac/persist/fake"
+	. "github.com/supremind/rbac/types"
+)
+
+var _ = Describe("authorizer with history", func() {
+	newAuthorizer := func(opts ...rbac.AuthorizerOption) Authorizer {
+		authz, e := rbac.New(context.Background(), append([]rbac.AuthorizerOption{
+			rbac.WithSubjectPersister(fake.NewGroupingPersister()),
+			rbac.WithObjectPersister(fake.NewGroupingPersister()),
+			rbac.WithPermissionPersister(fake.NewPermissionPersister()),
+		}, opts...)...)
+		Expect(e).To(Succeed())
+		return authz
+	}
+
+	// tick makes sure changes are recorded at distinct times
+	tick := func() time.Time {
+		time.Sleep(time.Millisecond)
+		defer time.Sleep(time.Millisecond)
+		return time.Now()
+	}
+
+	var (
+		authz                    Authorizer
+		created, granted, denied time.Time
+	)
+
+	BeforeEach(func() {
+		authz = newAuthorizer(rbac.WithHistory(time.Hour, 0))
+		created = tick()
+
+		Expect(authz.SubjectJoin(User("alan"), Role("editor"))).To(Succeed())
+		Expect(authz.ObjectJoin(Article("apollo"), Category("space"))).To(Succeed())
+		Expect(authz.Permit(Role("editor"), Category("space"), ReadWrite)).To(Succeed())
+		granted = tick()
+
+		Expect(authz.SubjectLeave(User("alan"), Role("editor"))).To(Succeed())
+		denied = tick()
+	})
+
+	shallAt := func(t time.Time, sub Subject, obj Object, act Action) bool {
+		snapshot, e := authz.(Historian).SnapshotAt(t)
+		Expect(e).To(Succeed())
+		ok, e := snapshot.Shall(sub, obj, act)
+		Expect(e).To(Succeed())
+		return ok
+	}
+
+	It("should decide with polices at the given time", func() {
+		Expect(shallAt(created, User("alan"), Article("apollo"), Write)).To(BeFalse())
+		Expect(shallAt(granted, User("alan"), Article("apollo"), Write)).To(BeTrue())
+		Expect(shallAt(denied, User("alan"), Article("apollo"), Write)).To(BeFalse())
+		Expect(authz.Shall(User("alan"), Article("apollo"), Write)).To(BeFalse())
+	})
+
+	It("should be read only", func() {
+		snapshot, e := authz.(Historian).SnapshotAt(granted)
+		Expect(e).To(Succeed())
+		Expect(snapshot.Permit(User("alan"), Article("apollo"), Exec)).To(MatchError(ErrReadOnly))
+		Expect(snapshot.SubjectJoin(User("alan"), Role("admin"))).To(MatchError(ErrReadOnly))
+		Expect(snapshot.Subjects().IsIn(User("alan"), Role("editor"))).To(BeTrue())
+	})
+
+	It("should not know what happened before it was created", func() {
+		_, e := authz.(Historian).SnapshotAt(created.Add(-time.Hour))
+		Expect(e).To(MatchError(ErrNoHistory))
+	})
+
+	It("should drop versions beyond limit", func() {
+		authz := newAuthorizer(rbac.WithHistory(0, 2))
+		created := tick()
+		Expect(authz.Permit(User("alan"), Article("apollo"), Read)).To(Succeed())
+		Expect(authz.Permit(User("alan"), Article("apollo"), Write)).To(Succeed())
+		Expect(authz.Permit(User("alan"), Article("apollo"), Exec)).To(Succeed())
+		now := tick()
+
+		_, e := authz.(Historian).SnapshotAt(created)
+		Expect(e).To(MatchError(ErrNoHistory))
+
+		snapshot, e := authz.(Historian).SnapshotAt(now)
+		Expect(e).To(Succeed())
+		Expect(snapshot.PermittedActions(User("alan"), Article("apollo"))).To(Equal(ReadWriteExec))
+	})
+
+	It("should not keep history by default", func() {
+		_, e := newAuthorizer().(Historian).SnapshotAt(time.Now())
+		Expect(e).To(MatchError(ErrNoHistory))
+	})
+})
@@ -0,0 +1,181 @@
+// Package httpauthz provides net/http middleware authorizing requests by an Authorizer.
+//
+// Subjects, objects and actions are extracted from requests by functions, like:
+//
+//	routes, _ := httpauthz.NewRoutes(
+//		httpauthz.Route{Pattern: "/articles/{id}", Object: "art:{id}"},
+//		httpauthz.Route{Methods: []string{"POST"}, Pattern: "/articles/{id}/publish", Object: "art:{id}", Action: types.Exec},
+//	)
+//	mw := httpauthz.New(authz, subjectFromToken, routes.Object, routes.Action, httpauthz.WithExplanation())
+//	http.ListenAndServe(":8080", mw.Handler(mux))
+//
+// Requests are passed to the next handler only if the subject shall perform the action on the object,
+// and the decision is put into the request context, as well as the subject as the actor, see types.WithActor.
+package httpauthz
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/supremind/rbac/types"
+)
+
+// SubjectFunc extracts the subject from a request, like the user authenticated by a token
+type SubjectFunc func(*http.Request) (types.Subject, error)
+
+// ObjectFunc extracts the object to be accessed from a request
+type ObjectFunc func(*http.Request) (types.Object, error)
+
+// ActionFunc extracts the action to be performed from a request
+type ActionFunc func(*http.Request) (types.Action, error)
+
+// ErrorHandler writes responses for failed requests, status is the suggested status code:
+// 401 if the subject could not be extracted, 404 if no route matches, 400 for other extractor errors,
+// and 500 if the authorizer fails
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, status int, err error)
+
+// Decision is made for a request
+type Decision struct {
+	Subject types.Subject
+	Object  types.Object
+	Action  types.Action
+	Allowed bool
+}
+
+type decisionKey struct{}
+
+// DecisionFrom returns the decision put into the request context by the middleware
+func DecisionFrom(ctx context.Context) (Decision, bool) {
+	d, ok := ctx.Value(decisionKey{}).(Decision)
+	return d, ok
+}
+
+// Option configures the middleware
+type Option func(*Middleware)
+
+// WithExplanation explains denied requests in response bodies, with actions permitted and missing.
+// It tells clients about polices, so it is better not to be used for public services.
+func WithExplanation() Option {
+	return func(m *Middleware) {
+		m.explain = true
+	}
+}
+
+// WithErrorHandler sets the handler for requests failed to be authorized, it is http.Error by default
+func WithErrorHandler(h ErrorHandler) Option {
+	return func(m *Middleware) {
+		m.onError = h
+	}
+}
+
+// Middleware authorizes requests before passing them to the next handler
+type Middleware struct {
+	authz   types.Authorizer
+	subject SubjectFunc
+	object  ObjectFunc
+	action  ActionFunc
+	explain bool
+	onError ErrorHandler
+}
+
+// New creates a Middleware.
+// If authz implements types.PermissionContext, like the Authorizers created by rbac.New,
+// decisions are made with request contexts, carrying the subject as the actor.
+func New(authz types.Authorizer, subject SubjectFunc, object ObjectFunc, action ActionFunc, opts ...Option) *Middleware {
+	m := &Middleware{
+		authz:   authz,
+		subject: subject,
+		object:  object,
+		action:  action,
+		onError: func(w http.ResponseWriter, r *http.Request, status int, err error) {
+			http.Error(w, err.Error(), status)
+		},
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Handler wraps next, which is called only for requests allowed
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sub, e := m.subject(r)
+		if e != nil {
+			m.onError(w, r, http.StatusUnauthorized, e)
+			return
+		}
+		obj, e := m.object(r)
+		if e != nil {
+			m.onError(w, r, statusOf(e), e)
+			return
+		}
+		act, e := m.action(r)
+		if e != nil {
+			m.onError(w, r, statusOf(e), e)
+			return
+		}
+
+		ctx := types.WithActor(r.Context(), sub.String())
+		allowed, e := m.shall(ctx, sub, obj, act)
+		if e != nil {
+			m.onError(w, r, http.StatusInternalServerError, e)
+			return
+		}
+
+		d := Decision{Subject: sub, Object: obj, Action: act, Allowed: allowed}
+		if !allowed {
+			m.deny(w, r.WithContext(ctx), d)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, decisionKey{}, d)))
+	})
+}
+
+func (m *Middleware) shall(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) (bool, error) {
+	if pc, ok := m.authz.(types.PermissionContext); ok {
+		return pc.ShallContext(ctx, sub, obj, act)
+	}
+	return m.authz.Shall(sub, obj, act)
+}
+
+func (m *Middleware) permitted(ctx context.Context, sub types.Subject, obj types.Object) (types.Action, error) {
+	if pc, ok := m.authz.(types.PermissionContext); ok {
+		return pc.PermittedActionsContext(ctx, sub, obj)
+	}
+	return m.authz.PermittedActions(sub, obj)
+}
+
+// explanation of a denied request
+type explanation struct {
+	Subject   string `json:"subject"`
+	Object    string `json:"object"`
+	Action    string `json:"action"`
+	Allowed   bool   `json:"allowed"`
+	Permitted string `json:"permitted"`
+	Missing   string `json:"missing"`
+}
+
+func (m *Middleware) deny(w http.ResponseWriter, r *http.Request, d Decision) {
+	if !m.explain {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	permitted, e := m.permitted(r.Context(), d.Subject, d.Object)
+	if e != nil {
+		m.onError(w, r, http.StatusInternalServerError, e)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(explanation{
+		Subject:   d.Subject.String(),
+		Object:    d.Object.String(),
+		Action:    d.Action.String(),
+		Permitted: permitted.String(),
+		Missing:   d.Action.Difference(permitted).String(),
+	})
+}
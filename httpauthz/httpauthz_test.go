@@ -0,0 +1,140 @@
+package httpauthz_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/supremind/rbac"
+	"github.com/supremind/rbac/httpauthz"
+	"github.com/supremind/rbac/persist/fake"
+	. "github.com/supremind/rbac/types"
+)
+
+func TestHTTPAuthz(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "httpauthz test suit")
+}
+
+func subjectFromHeader(r *http.Request) (Subject, error) {
+	name := r.Header.Get("X-User")
+	if name == "" {
+		return nil, errors.New("not logged in")
+	}
+	return User(name), nil
+}
+
+var _ = Describe("http middleware", func() {
+	var (
+		authz   Authorizer
+		routes  *httpauthz.Routes
+		handler http.Handler
+	)
+
+	BeforeEach(func() {
+		var e error
+		authz, e = rbac.New(context.Background(),
+			rbac.WithSubjectPersister(fake.NewGroupingPersister()),
+			rbac.WithObjectPersister(fake.NewGroupingPersister()),
+			rbac.WithPermissionPersister(fake.NewPermissionPersister()),
+		)
+		Expect(e).To(Succeed())
+		Expect(authz.SubjectJoin(User("alan"), Role("editor"))).To(Succeed())
+		Expect(authz.ObjectJoin(Article("apollo"), Category("news"))).To(Succeed())
+		Expect(authz.Permit(Role("editor"), Category("news"), Read)).To(Succeed())
+		Expect(authz.Permit(User("alan"), Article("apollo"), Exec)).To(Succeed())
+
+		routes, e = httpauthz.NewRoutes(
+			httpauthz.Route{Methods: []string{"post"}, Pattern: "/articles/{id}/publish", Object: "art:{id}", Action: Exec},
+			httpauthz.Route{Pattern: "/articles/{id}", Object: "art:{id}"},
+			httpauthz.Route{Pattern: "/categories/{name}/*", Object: "cat:{name}"},
+		)
+		Expect(e).To(Succeed())
+
+		mw := httpauthz.New(authz, subjectFromHeader, routes.Object, routes.Action, httpauthz.WithExplanation())
+		handler = mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			d, ok := httpauthz.DecisionFrom(r.Context())
+			Expect(ok).To(BeTrue())
+			Expect(d.Allowed).To(BeTrue())
+			Expect(ActorFrom(r.Context())).To(Equal(d.Subject.String()))
+			w.Write([]byte(d.Object.String() + " " + d.Action.String()))
+		}))
+	})
+
+	serve := func(method, path, user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if user != "" {
+			req.Header.Set("X-User", user)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	It("should pass allowed requests with decisions", func() {
+		rec := serve("GET", "/articles/apollo", "alan")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(Equal("art:apollo read"))
+
+		rec = serve("POST", "/articles/apollo/publish", "alan")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(Equal("art:apollo exec"))
+
+		rec = serve("GET", "/categories/news/a/b", "alan")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(Equal("cat:news read"))
+	})
+
+	It("should reject denied requests with explanations", func() {
+		rec := serve("PUT", "/articles/apollo", "alan")
+		Expect(rec.Code).To(Equal(http.StatusForbidden))
+		Expect(rec.Body.String()).To(MatchJSON(`{
+			"subject": "user:alan", "object": "art:apollo", "action": "write",
+			"allowed": false, "permitted": "exec|read", "missing": "write"
+		}`))
+
+		Expect(serve("GET", "/articles/apollo", "edison").Code).To(Equal(http.StatusForbidden))
+	})
+
+	It("should reject requests failed to be extracted", func() {
+		Expect(serve("GET", "/articles/apollo", "").Code).To(Equal(http.StatusUnauthorized))
+		Expect(serve("GET", "/users/alan", "alan").Code).To(Equal(http.StatusNotFound))
+		Expect(serve("GET", "/articles/apollo/publish", "alan").Code).To(Equal(http.StatusNotFound))
+		Expect(serve("GET", "/articles//", "alan").Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("should deny without explanations by default", func() {
+		var failed int
+		mw := httpauthz.New(authz, subjectFromHeader, routes.Object, httpauthz.MethodAction,
+			httpauthz.WithErrorHandler(func(w http.ResponseWriter, r *http.Request, status int, err error) {
+				failed = status
+				w.WriteHeader(status)
+			}),
+		)
+		handler = mw.Handler(http.NotFoundHandler())
+
+		rec := serve("DELETE", "/articles/apollo", "alan")
+		Expect(rec.Code).To(Equal(http.StatusForbidden))
+		Expect(rec.Body.String()).To(Equal("Forbidden\n"))
+
+		serve("GET", "/articles/apollo", "")
+		Expect(failed).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("should validate routes", func() {
+		for _, r := range []httpauthz.Route{
+			{Pattern: "articles/{id}", Object: "art:{id}"},
+			{Pattern: "/articles/{id}", Object: "art:{name}"},
+			{Pattern: "/articles/{id}", Object: "role:{id}"},
+			{Pattern: "/articles/{id}", Object: "art:{id"},
+		} {
+			_, e := httpauthz.NewRoutes(r)
+			Expect(e).To(MatchError(httpauthz.ErrInvalidRoute), r.Pattern+" "+r.Object)
+		}
+	})
+})
@@ -0,0 +1,202 @@
+package httpauthz
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/supremind/rbac/types"
+)
+
+// errors about routes
+var (
+	ErrNoRoute      = errors.New("no route matches")
+	ErrInvalidRoute = errors.New("invalid route")
+)
+
+// Route maps requests to an object and an action
+type Route struct {
+	// Methods matched, all methods are matched if it is empty
+	Methods []string
+
+	// Pattern of paths matched, like /articles/{id}/comments,
+	// a segment in braces matches any single segment, and a trailing /* matches all the rest
+	Pattern string
+
+	// Object is the template of the object accessed, like art:{id}, names in braces are replaced by segments matched
+	Object string
+
+	// Action performed, it is MethodAction of the request if not set
+	Action types.Action
+}
+
+// MethodAction maps GET, HEAD and OPTIONS requests to types.Read, and others to types.Write
+func MethodAction(r *http.Request) (types.Action, error) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return types.Read, nil
+	}
+	return types.Write, nil
+}
+
+// Routes is a table of routes, the first route matching a request is used
+type Routes struct {
+	routes []route
+}
+
+type route struct {
+	Route
+	methods  map[string]struct{}
+	segments []string
+	rest     bool // the pattern ends with /*
+}
+
+// NewRoutes creates a table of routes, routes are validated
+func NewRoutes(routes ...Route) (*Routes, error) {
+	rs := &Routes{routes: make([]route, 0, len(routes))}
+
+	for _, r := range routes {
+		if !strings.HasPrefix(r.Pattern, "/") {
+			return nil, fmt.Errorf("%w: pattern %q should start with /", ErrInvalidRoute, r.Pattern)
+		}
+
+		rt := route{Route: r}
+		if len(r.Methods) > 0 {
+			rt.methods = make(map[string]struct{}, len(r.Methods))
+			for _, method := range r.Methods {
+				rt.methods[strings.ToUpper(method)] = struct{}{}
+			}
+		}
+
+		pattern := strings.TrimPrefix(r.Pattern, "/")
+		if pattern == "*" || strings.HasSuffix(pattern, "/*") {
+			rt.rest = true
+			pattern = strings.TrimSuffix(strings.TrimSuffix(pattern, "*"), "/")
+		}
+		if pattern != "" {
+			rt.segments = strings.Split(pattern, "/")
+		}
+
+		// every name in the object template should be defined in the pattern, and it should be an object
+		params := make(map[string]string)
+		for _, seg := range rt.segments {
+			if name, ok := paramName(seg); ok {
+				params[name] = name
+			}
+		}
+		obj, e := expand(r.Object, params)
+		if e != nil {
+			return nil, fmt.Errorf("%w: %s: %s", ErrInvalidRoute, r.Pattern, e)
+		}
+		if _, e := types.ParseObject(obj); e != nil {
+			return nil, fmt.Errorf("%w: %s: %s: %s", ErrInvalidRoute, r.Pattern, e, r.Object)
+		}
+
+		rs.routes = append(rs.routes, rt)
+	}
+
+	return rs, nil
+}
+
+// Object returns the object of the route matching the request, it is an ObjectFunc
+func (rs *Routes) Object(r *http.Request) (types.Object, error) {
+	rt, params, e := rs.match(r)
+	if e != nil {
+		return nil, e
+	}
+	obj, e := expand(rt.Object, params)
+	if e != nil {
+		return nil, e
+	}
+	return types.ParseObject(obj)
+}
+
+// Action returns the action of the route matching the request, it is an ActionFunc
+func (rs *Routes) Action(r *http.Request) (types.Action, error) {
+	rt, _, e := rs.match(r)
+	if e != nil {
+		return 0, e
+	}
+	if rt.Action != 0 {
+		return rt.Action, nil
+	}
+	return MethodAction(r)
+}
+
+func (rs *Routes) match(r *http.Request) (*route, map[string]string, error) {
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(path) == 1 && path[0] == "" {
+		path = nil
+	}
+
+	for i := range rs.routes {
+		rt := &rs.routes[i]
+		if rt.methods != nil {
+			if _, ok := rt.methods[r.Method]; !ok {
+				continue
+			}
+		}
+		if params, ok := rt.matchPath(path); ok {
+			return rt, params, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("%w: %s %s", ErrNoRoute, r.Method, r.URL.Path)
+}
+
+func (rt *route) matchPath(path []string) (map[string]string, bool) {
+	if len(path) < len(rt.segments) || (!rt.rest && len(path) != len(rt.segments)) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for i, seg := range rt.segments {
+		if name, ok := paramName(seg); ok {
+			if path[i] == "" {
+				return nil, false
+			}
+			params[name] = path[i]
+		} else if seg != path[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+func paramName(seg string) (string, bool) {
+	if len(seg) > 2 && strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+		return seg[1 : len(seg)-1], true
+	}
+	return "", false
+}
+
+// expand replaces names in braces by params
+func expand(template string, params map[string]string) (string, error) {
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(template, '{')
+		if start < 0 {
+			b.WriteString(template)
+			return b.String(), nil
+		}
+		end := strings.IndexByte(template[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unclosed brace in %s", template)
+		}
+		name := template[start+1 : start+end]
+		value, ok := params[name]
+		if !ok {
+			return "", fmt.Errorf("%s is not defined in the pattern", name)
+		}
+		b.WriteString(template[:start])
+		b.WriteString(value)
+		template = template[start+end+1:]
+	}
+}
+
+func statusOf(e error) int {
+	if errors.Is(e, ErrNoRoute) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,91 @@
+package rbac_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/supremind/rbac"
+	"github.com/supremind/rbac/persist/fake"
+	. "github.com/supremind/rbac/types"
+)
+
+var _ = Describe("authorizer with effective index", func() {
+	for _, engine := range []struct {
+		name string
+		opts []rbac.AuthorizerOption
+	}{
+		{name: "synced", opts: []rbac.AuthorizerOption{rbac.WithEffectiveIndex(100)}},
+		{name: "copy on write", opts: []rbac.AuthorizerOption{rbac.WithEffectiveIndex(100), rbac.WithCopyOnWrite()}},
+		{name: "decision cache", opts: []rbac.AuthorizerOption{rbac.WithEffectiveIndex(100), rbac.WithDecisionCache(100)}},
+		{name: "small budget", opts: []rbac.AuthorizerOption{rbac.WithEffectiveIndex(2)}},
+	} {
+		engine := engine
+
+		Context(engine.name, func() {
+			var (
+				ctx      context.Context
+				cancel   context.CancelFunc
+				subjects chan GroupingPolicyChange
+				objects  chan GroupingPolicyChange
+				perms    chan PermissionPolicyChange
+				authz    Authorizer
+			)
+
+			BeforeEach(func() {
+				ctx, cancel = context.WithCancel(context.Background())
+				subjects = make(chan GroupingPolicyChange)
+				objects = make(chan GroupingPolicyChange)
+				perms = make(chan PermissionPolicyChange)
+
+				var e error
+				authz, e = rbac.New(ctx, append([]rbac.AuthorizerOption{
+					rbac.WithSubjectPersister(remoteGroupingPersister{fake.NewGroupingPersister(), subjects}),
+					rbac.WithObjectPersister(remoteGroupingPersister{fake.NewGroupingPersister(), objects}),
+					rbac.WithPermissionPersister(remotePermissionPersister{fake.NewPermissionPersister(), perms}),
+				}, engine.opts...)...)
+				Expect(e).To(Succeed())
+
+				Expect(authz.SubjectJoin(User("alan"), Role("editor"))).To(Succeed())
+				Expect(authz.SubjectJoin(Role("editor"), Role("reader"))).To(Succeed())
+				Expect(authz.ObjectJoin(Article("apollo"), Category("history"))).To(Succeed())
+				Expect(authz.ObjectJoin(Category("history"), Category("science"))).To(Succeed())
+				Expect(authz.Permit(Role("reader"), Category("science"), Read)).To(Succeed())
+				Expect(authz.Permit(Role("editor"), Article("gemini"), Write)).To(Succeed())
+			})
+
+			AfterEach(func() {
+				cancel()
+			})
+
+			It("should decide through roles and categories", func() {
+				Expect(authz.PermittedActions(User("alan"), Article("apollo"))).To(Equal(Read))
+				Expect(authz.PermittedActions(User("alan"), Article("gemini"))).To(Equal(Write))
+				Expect(authz.Shall(User("alan"), Article("apollo"), Read|Write)).To(BeFalse())
+				Expect(authz.Shall(User("bob"), Article("apollo"), Read)).To(BeFalse())
+				Expect(authz.Shall(Role("editor"), Article("apollo"), Read)).To(BeTrue())
+				Expect(authz.Shall(User("alan"), Category("history"), Read)).To(BeTrue())
+			})
+
+			It("should follow changes of other replicas", func() {
+				Expect(authz.Shall(User("alan"), Article("apollo"), Read)).To(BeTrue())
+				objects <- GroupingPolicyChange{GroupingPolicy: GroupingPolicy{Entity: Category("history"), Group: Category("science")}, Method: PersistDelete}
+				Eventually(func() (bool, error) { return authz.Shall(User("alan"), Article("apollo"), Read) }).Should(BeFalse())
+
+				subjects <- GroupingPolicyChange{GroupingPolicy: GroupingPolicy{Entity: User("bob"), Group: Role("editor")}, Method: PersistInsert}
+				Eventually(func() (bool, error) { return authz.Shall(User("bob"), Article("gemini"), Write) }).Should(BeTrue())
+
+				perms <- PermissionPolicyChange{
+					PermissionPolicy: PermissionPolicy{Subject: Role("reader"), Object: Category("history"), Action: Exec},
+					Method:           PersistInsert,
+				}
+				Eventually(func() (Action, error) { return authz.PermittedActions(User("bob"), Article("apollo")) }).Should(Equal(Exec))
+			})
+
+			It("should decide the same as without the index", func() {
+				expectSameDecisions(ctx, authz)
+			})
+		})
+	}
+})
@@ -0,0 +1,301 @@
+package authorizer
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/supremind/rbac/types"
+)
+
+var _ types.AuthorizerContext = (*auditedAuthorizer)(nil)
+var _ types.AuthorizerContext = (*auditedWriter)(nil)
+
+// auditor sends audit events to the sink
+type auditor struct {
+	sink types.AuditSink
+	l    logr.Logger
+}
+
+func (a auditor) record(ctx context.Context, event types.AuditEvent) {
+	event.Time = time.Now()
+	event.Actor = types.ActorFrom(ctx)
+
+	if e := a.sink.Audit(event); e != nil {
+		a.l.Error(e, "send audit event", "operation", event.Operation)
+	}
+}
+
+// auditedAuthorizer sends audit events of sampled decisions to the sink
+type auditedAuthorizer struct {
+	types.AuthorizerContext
+	auditor
+	sample func(types.AuditEvent) bool
+}
+
+func newAuditedAuthorizer(authz types.AuthorizerContext, sink types.AuditSink, sample func(types.AuditEvent) bool, l logr.Logger) *auditedAuthorizer {
+	return &auditedAuthorizer{
+		AuthorizerContext: authz,
+		auditor:           auditor{sink: sink, l: l},
+		sample:            sample,
+	}
+}
+
+// auditedWriter sends audit events of all mutations to the sink,
+// it is wrapped by the writer lock, so that states before and after changes are read along with them
+type auditedWriter struct {
+	types.AuthorizerContext
+	auditor
+	sg, og types.Grouping   // groupings, to tell groups of entities before and after changes
+	p      types.Permission // direct permissions, to tell actions before and after changes
+}
+
+func newAuditedWriter(authz types.AuthorizerContext, sg, og types.Grouping, p types.Permission, sink types.AuditSink, l logr.Logger) *auditedWriter {
+	return &auditedWriter{
+		AuthorizerContext: authz,
+		auditor:           auditor{sink: sink, l: l},
+		sg:                sg,
+		og:                og,
+		p:                 p,
+	}
+}
+
+func (a *auditedWriter) directActions(sub types.Subject, obj types.Object) types.Action {
+	act, e := a.p.PermittedActions(sub, obj)
+	if e != nil {
+		a.l.Error(e, "get permitted actions", "subject", sub, "object", obj)
+	}
+	return act
+}
+
+// groupsOf returns groups the entity belongs to in grouping g, ordered by names
+func (a *auditedWriter) groupsOf(g types.Grouping, ent types.Entity) []types.Group {
+	if g == nil {
+		return nil
+	}
+	groups, e := g.GroupsOf(ent)
+	if e != nil {
+		a.l.Error(e, "get groups", "entity", ent)
+	}
+
+	sorted := make([]types.Group, 0, len(groups))
+	for group := range groups {
+		sorted = append(sorted, group)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].String() < sorted[j].String() })
+	return sorted
+}
+
+// changeGrouping records the grouping change of ent made by change, with groups ent belongs to before and after it
+func (a *auditedWriter) changeGrouping(ctx context.Context, g types.Grouping, ent types.Entity, event types.AuditEvent, change func() error) error {
+	event.GroupsBefore = a.groupsOf(g, ent)
+	event.Error = change()
+	event.GroupsAfter = a.groupsOf(g, ent)
+
+	a.record(ctx, event)
+	return event.Error
+}
+
+// directPermissions returns permissions directly permitted to ent if it is a subject, or on ent if it is an object,
+// ordered by subjects and objects, with actions as the ones before revoked
+func (a *auditedWriter) directPermissions(ent types.Entity) []types.AuditRevocation {
+	var revoked []types.AuditRevocation
+	switch ent := ent.(type) {
+	case types.Subject:
+		perms, e := a.p.PermissionsFor(ent)
+		if e != nil {
+			a.l.Error(e, "get permissions", "subject", ent)
+		}
+		for obj, act := range perms {
+			revoked = append(revoked, types.AuditRevocation{Subject: ent, Object: obj, Before: act})
+		}
+	case types.Object:
+		perms, e := a.p.PermissionsOn(ent)
+		if e != nil {
+			a.l.Error(e, "get permissions", "object", ent)
+		}
+		for sub, act := range perms {
+			revoked = append(revoked, types.AuditRevocation{Subject: sub, Object: ent, Before: act})
+		}
+	}
+
+	sort.Slice(revoked, func(i, j int) bool {
+		if x, y := revoked[i].Subject.String(), revoked[j].Subject.String(); x != y {
+			return x < y
+		}
+		return revoked[i].Object.String() < revoked[j].Object.String()
+	})
+	return revoked
+}
+
+// remove records the removal of ent made by change, with permissions revoked by it,
+// and groups ent belongs to before and after it if g is not nil
+func (a *auditedWriter) remove(ctx context.Context, g types.Grouping, ent types.Entity, event types.AuditEvent, change func() error) error {
+	event.Revoked = a.directPermissions(ent)
+	event.GroupsBefore = a.groupsOf(g, ent)
+	event.Error = change()
+	event.GroupsAfter = a.groupsOf(g, ent)
+	for i, revoked := range event.Revoked {
+		event.Revoked[i].After = a.directActions(revoked.Subject, revoked.Object)
+	}
+
+	a.record(ctx, event)
+	return event.Error
+}
+
+// changePermission records the permission change made by change, with actions directly permitted before and after it
+func (a *auditedWriter) changePermission(ctx context.Context, event types.AuditEvent, change func() error) error {
+	event.Before = a.directActions(event.Subject, event.Object)
+	event.Error = change()
+	event.After = a.directActions(event.Subject, event.Object)
+
+	a.record(ctx, event)
+	return event.Error
+}
+
+// SubjectJoinContext joins a user or a sub role to a role
+func (a *auditedWriter) SubjectJoinContext(ctx context.Context, sub types.Subject, role types.Role) error {
+	return a.changeGrouping(ctx, a.sg, sub, types.AuditEvent{Operation: types.AuditSubjectJoin, Entity: sub, Group: role}, func() error {
+		return a.AuthorizerContext.SubjectJoinContext(ctx, sub, role)
+	})
+}
+
+// SubjectLeaveContext removes a user or a sub role from a role
+func (a *auditedWriter) SubjectLeaveContext(ctx context.Context, sub types.Subject, role types.Role) error {
+	return a.changeGrouping(ctx, a.sg, sub, types.AuditEvent{Operation: types.AuditSubjectLeave, Entity: sub, Group: role}, func() error {
+		return a.AuthorizerContext.SubjectLeaveContext(ctx, sub, role)
+	})
+}
+
+// RemoveUserContext removes a user and all policies about it
+func (a *auditedWriter) RemoveUserContext(ctx context.Context, user types.User) error {
+	return a.remove(ctx, a.sg, user, types.AuditEvent{Operation: types.AuditRemoveUser, Entity: user}, func() error {
+		return a.AuthorizerContext.RemoveUserContext(ctx, user)
+	})
+}
+
+// RemoveRoleContext removes a role and all policies about it
+func (a *auditedWriter) RemoveRoleContext(ctx context.Context, role types.Role) error {
+	return a.remove(ctx, a.sg, role, types.AuditEvent{Operation: types.AuditRemoveRole, Group: role}, func() error {
+		return a.AuthorizerContext.RemoveRoleContext(ctx, role)
+	})
+}
+
+// ObjectJoinContext joins an article or a sub category to a category
+func (a *auditedWriter) ObjectJoinContext(ctx context.Context, obj types.Object, cat types.Category) error {
+	return a.changeGrouping(ctx, a.og, obj, types.AuditEvent{Operation: types.AuditObjectJoin, Entity: obj, Group: cat}, func() error {
+		return a.AuthorizerContext.ObjectJoinContext(ctx, obj, cat)
+	})
+}
+
+// ObjectLeaveContext removes an article or a sub category from a category
+func (a *auditedWriter) ObjectLeaveContext(ctx context.Context, obj types.Object, cat types.Category) error {
+	return a.changeGrouping(ctx, a.og, obj, types.AuditEvent{Operation: types.AuditObjectLeave, Entity: obj, Group: cat}, func() error {
+		return a.AuthorizerContext.ObjectLeaveContext(ctx, obj, cat)
+	})
+}
+
+// RemoveArticleContext removes an article and all polices about it
+func (a *auditedWriter) RemoveArticleContext(ctx context.Context, art types.Article) error {
+	return a.remove(ctx, nil, art, types.AuditEvent{Operation: types.AuditRemoveArticle, Entity: art}, func() error {
+		return a.AuthorizerContext.RemoveArticleContext(ctx, art)
+	})
+}
+
+// RemoveCategoryContext removes a category and all polices about it
+func (a *auditedWriter) RemoveCategoryContext(ctx context.Context, cat types.Category) error {
+	return a.remove(ctx, nil, cat, types.AuditEvent{Operation: types.AuditRemoveCategory, Group: cat}, func() error {
+		return a.AuthorizerContext.RemoveCategoryContext(ctx, cat)
+	})
+}
+
+// PermitContext permits subject to perform action on object
+func (a *auditedWriter) PermitContext(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) error {
+	return a.changePermission(ctx, types.AuditEvent{Operation: types.AuditPermit, Subject: sub, Object: obj, Action: act}, func() error {
+		return a.AuthorizerContext.PermitContext(ctx, sub, obj, act)
+	})
+}
+
+// RevokeContext revokes permission for subject to perform action on object
+func (a *auditedWriter) RevokeContext(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) error {
+	return a.changePermission(ctx, types.AuditEvent{Operation: types.AuditRevoke, Subject: sub, Object: obj, Action: act}, func() error {
+		return a.AuthorizerContext.RevokeContext(ctx, sub, obj, act)
+	})
+}
+
+// shallMany checks permissions in a batch
+func (a *auditedWriter) shallMany(ctx context.Context, sub types.Subject, checks []types.ObjectAction) ([]bool, error) {
+	return shallManyOf(ctx, a.AuthorizerContext, sub, checks)
+}
+
+// policies lists all polices assigned directly
+func (a *auditedWriter) policies() (types.Policies, error) {
+	return policiesOf(a.AuthorizerContext)
+}
+
+// snapshotAt returns a read-only authorizer, which decides with polices as they were at the given time
+func (a *auditedWriter) snapshotAt(t time.Time) (types.AuthorizerContext, error) {
+	return snapshotOf(a.AuthorizerContext, t)
+}
+
+// decisionCacheStats returns statistics of the decision cache
+func (a *auditedWriter) decisionCacheStats() (types.DecisionCacheStats, error) {
+	return decisionCacheStatsOf(a.AuthorizerContext)
+}
+
+// ShallContext tells if subject shall perform action on object
+func (a *auditedAuthorizer) ShallContext(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) (bool, error) {
+	allowed, e := a.AuthorizerContext.ShallContext(ctx, sub, obj, act)
+
+	event := types.AuditEvent{
+		Operation: types.AuditShall,
+		Subject:   sub,
+		Object:    obj,
+		Action:    act,
+		Allowed:   allowed,
+		Error:     e,
+	}
+	if a.sample(event) {
+		a.record(ctx, event)
+	}
+
+	return allowed, e
+}
+
+// shallMany checks permissions in a batch, each decision is sampled as if it is checked alone
+func (a *auditedAuthorizer) shallMany(ctx context.Context, sub types.Subject, checks []types.ObjectAction) ([]bool, error) {
+	results, e := shallManyOf(ctx, a.AuthorizerContext, sub, checks)
+
+	for i, check := range checks {
+		event := types.AuditEvent{
+			Operation: types.AuditShall,
+			Subject:   sub,
+			Object:    check.Object,
+			Action:    check.Action,
+			Allowed:   e == nil && results[i],
+			Error:     e,
+		}
+		if a.sample(event) {
+			a.record(ctx, event)
+		}
+	}
+
+	return results, e
+}
+
+// policies lists all polices assigned directly
+func (a *auditedAuthorizer) policies() (types.Policies, error) {
+	return policiesOf(a.AuthorizerContext)
+}
+
+// snapshotAt returns a read-only authorizer, which decides with polices as they were at the given time,
+// decisions made by it are not audited
+func (a *auditedAuthorizer) snapshotAt(t time.Time) (types.AuthorizerContext, error) {
+	return snapshotOf(a.AuthorizerContext, t)
+}
+
+// decisionCacheStats returns statistics of the decision cache
+func (a *auditedAuthorizer) decisionCacheStats() (types.DecisionCacheStats, error) {
+	return decisionCacheStatsOf(a.AuthorizerContext)
+}
@@ -1,64 +1,148 @@
 package authorizer
 
 import (
+	"context"
+
 	"github.com/go-logr/logr"
+	"github.com/supremind/rbac/internal/decision"
+	"github.com/supremind/rbac/internal/effective"
+	"github.com/supremind/rbac/trace"
 	"github.com/supremind/rbac/types"
 )
 
+var _ types.AuthorizerContext = (*authorizer)(nil)
+
 type authorizer struct {
-	sg types.Grouping
-	og types.Grouping
-	p  types.Permission
-	l  logr.Logger
+	sg     types.Grouping
+	og     types.Grouping
+	p      types.Permission
+	l      logr.Logger
+	tracer types.Tracer
+}
+
+// Config controls optional features of the authorizer
+type Config struct {
+	// Presets are polices checked before all others
+	Presets []types.PresetPolicy
+
+	// LockFree leaves reads unlocked,
+	// given groupings and permission must be safe to read concurrently with writes, without locking
+	LockFree bool
+
+	// Shards lets members join and leave groups in parallel if positive and not LockFree,
+	// given groupings must be safe to write members concurrently, like sharded ones
+	Shards int
+
+	// AuditSink receives audit events of policy mutations, and decisions chosen by AuditDecision
+	AuditSink     types.AuditSink
+	AuditDecision func(types.AuditEvent) bool
+
+	// Metrics measures decisions if set
+	Metrics types.Metrics
+
+	// Tracer starts spans around operations if set
+	Tracer types.Tracer
+
+	// Index materializes effective actions of users on articles if set,
+	// it must be told about changes by groupings and permission, before Decisions
+	Index *effective.Index
+
+	// Decisions caches decisions if set, it must be told about changes by groupings and permission
+	Decisions *decision.Cache
+}
+
+// Authorizer could be used with or without contexts
+type Authorizer interface {
+	types.Authorizer
+	types.AuthorizerContext
 }
 
 // New creates an authorizer
-func New(sg, og types.Grouping, p types.Permission, l logr.Logger, presets ...types.PresetPolicy) types.Authorizer {
-	var a types.Authorizer
+func New(sg, og types.Grouping, p types.Permission, l logr.Logger, cfg Config) Authorizer {
+	tracer := cfg.Tracer
+	if tracer == nil {
+		tracer = trace.Nop
+	}
+
+	var a types.AuthorizerContext
 	a = &authorizer{
-		sg: sg,
-		og: og,
-		p:  p,
-		l:  l,
+		sg:     sg,
+		og:     og,
+		p:      p,
+		l:      l,
+		tracer: tracer,
+	}
+
+	if cfg.Index != nil {
+		cfg.Index.Bind(sg, og, p)
+		a = newIndexedAuthorizer(a, cfg.Index)
+	}
+
+	if cfg.Decisions != nil {
+		a = newCachedAuthorizer(a, cfg.Decisions)
+	}
+
+	// mutations are audited within the writer lock, along with states before and after them
+	if cfg.AuditSink != nil {
+		a = newAuditedWriter(a, sg, og, p, cfg.AuditSink, l.WithName("audit"))
+	}
+
+	switch {
+	case cfg.LockFree:
+		a = newLockFreeAuthorizer(a)
+	case cfg.Shards > 0:
+		a = newShardedAuthorizer(a, cfg.Shards)
+	default:
+		a = newSyncedAuthorizer(a)
+	}
+	a = newWithPresetPolices(a, cfg.Presets...)
+
+	if cfg.Metrics != nil {
+		a = newMeasuredAuthorizer(a, cfg.Metrics)
+	}
+
+	if cfg.AuditSink != nil && cfg.AuditDecision != nil {
+		a = newAuditedAuthorizer(a, cfg.AuditSink, cfg.AuditDecision, l.WithName("audit"))
 	}
 
-	a = newSyncedAuthorizer(a)
-	a = newWithPresetPolices(a, presets...)
+	if cfg.Tracer != nil {
+		a = newTracedAuthorizer(a, cfg.Tracer)
+	}
 
-	return a
+	return newBackgroundAuthorizer(a)
 }
 
-// SubjectJoin joins a user or a sub role to a role
-func (a *authorizer) SubjectJoin(sub types.Subject, role types.Role) error {
+// SubjectJoinContext joins a user or a sub role to a role
+func (a *authorizer) SubjectJoinContext(ctx context.Context, sub types.Subject, role types.Role) error {
 	a.l.V(4).Info("subject join", "subject", sub, "role", role)
 
 	if a.sg == nil {
 		return types.ErrNoSubjectGrouping
 	}
 
-	return a.sg.Join(sub, role)
+	return groupingWriter(a.sg).JoinContext(ctx, sub, role)
 }
 
-// SubjectLeave removes a user or a sub role from a role
-func (a *authorizer) SubjectLeave(sub types.Subject, role types.Role) error {
+// SubjectLeaveContext removes a user or a sub role from a role
+func (a *authorizer) SubjectLeaveContext(ctx context.Context, sub types.Subject, role types.Role) error {
 	a.l.V(4).Info("subject leave", "subject", sub, "role", role)
 
 	if a.sg == nil {
 		return types.ErrNoSubjectGrouping
 	}
 
-	return a.sg.Leave(sub, role)
+	return groupingWriter(a.sg).LeaveContext(ctx, sub, role)
 }
 
-// RemoveUser removes a user and all policies about it
-func (a *authorizer) RemoveUser(user types.User) error {
+// RemoveUserContext removes a user and all policies about it
+func (a *authorizer) RemoveUserContext(ctx context.Context, user types.User) error {
 	a.l.V(4).Info("remove user", "user", user)
 
 	if a.sg == nil {
 		return types.ErrNoSubjectGrouping
 	}
 
-	if e := a.sg.RemoveMember(user); e != nil {
+	if e := groupingWriter(a.sg).RemoveMemberContext(ctx, user); e != nil {
 		return e
 	}
 
@@ -67,7 +151,7 @@ func (a *authorizer) RemoveUser(user types.User) error {
 		return e
 	}
 	for obj, act := range perms {
-		if e := a.p.Revoke(user, obj, act); e != nil {
+		if e := permissionWriter(a.p).RevokeContext(ctx, user, obj, act); e != nil {
 			return e
 		}
 	}
@@ -75,15 +159,15 @@ func (a *authorizer) RemoveUser(user types.User) error {
 	return nil
 }
 
-// RemoveRole removes a role and all policies about it
-func (a *authorizer) RemoveRole(role types.Role) error {
+// RemoveRoleContext removes a role and all policies about it
+func (a *authorizer) RemoveRoleContext(ctx context.Context, role types.Role) error {
 	a.l.V(4).Info("remove role", "role", role)
 
 	if a.sg == nil {
 		return types.ErrNoSubjectGrouping
 	}
 
-	if e := a.sg.RemoveGroup(role); e != nil {
+	if e := groupingWriter(a.sg).RemoveGroupContext(ctx, role); e != nil {
 		return e
 	}
 
@@ -92,7 +176,7 @@ func (a *authorizer) RemoveRole(role types.Role) error {
 		return e
 	}
 	for obj, act := range perms {
-		if e := a.p.Revoke(role, obj, act); e != nil {
+		if e := permissionWriter(a.p).RevokeContext(ctx, role, obj, act); e != nil {
 			return e
 		}
 	}
@@ -105,30 +189,30 @@ func (a *authorizer) Subjects() types.GroupingReader {
 	return a.sg
 }
 
-// ObjectJoin joins an article or a sub category to a category
-func (a *authorizer) ObjectJoin(obj types.Object, cat types.Category) error {
+// ObjectJoinContext joins an article or a sub category to a category
+func (a *authorizer) ObjectJoinContext(ctx context.Context, obj types.Object, cat types.Category) error {
 	a.l.V(4).Info("object join", "object", obj, "category", cat)
 
 	if a.og == nil {
 		return types.ErrNoObjectGrouping
 	}
 
-	return a.og.Join(obj, cat)
+	return groupingWriter(a.og).JoinContext(ctx, obj, cat)
 }
 
-// ObjectLeave removes an article or a sub category from a category
-func (a *authorizer) ObjectLeave(obj types.Object, cat types.Category) error {
+// ObjectLeaveContext removes an article or a sub category from a category
+func (a *authorizer) ObjectLeaveContext(ctx context.Context, obj types.Object, cat types.Category) error {
 	a.l.V(4).Info("object leave", "object", obj, "category", "cat")
 
 	if a.og == nil {
 		return types.ErrNoObjectGrouping
 	}
 
-	return a.og.Leave(obj, cat)
+	return groupingWriter(a.og).LeaveContext(ctx, obj, cat)
 }
 
-// RemoveArticle removes an article and all polices about it
-func (a *authorizer) RemoveArticle(art types.Article) error {
+// RemoveArticleContext removes an article and all polices about it
+func (a *authorizer) RemoveArticleContext(ctx context.Context, art types.Article) error {
 	a.l.V(4).Info("remove article", "article", art)
 
 	if a.og == nil {
@@ -140,7 +224,7 @@ func (a *authorizer) RemoveArticle(art types.Article) error {
 		return e
 	}
 	for sub, act := range perms {
-		if e := a.p.Revoke(sub, art, act); e != nil {
+		if e := permissionWriter(a.p).RevokeContext(ctx, sub, art, act); e != nil {
 			return e
 		}
 	}
@@ -148,8 +232,8 @@ func (a *authorizer) RemoveArticle(art types.Article) error {
 	return nil
 }
 
-// RemoveCategory removes a category and all polices about it
-func (a *authorizer) RemoveCategory(cat types.Category) error {
+// RemoveCategoryContext removes a category and all polices about it
+func (a *authorizer) RemoveCategoryContext(ctx context.Context, cat types.Category) error {
 	a.l.V(4).Info("remove category", "category", cat)
 
 	if a.og == nil {
@@ -161,7 +245,7 @@ func (a *authorizer) RemoveCategory(cat types.Category) error {
 		return e
 	}
 	for sub, act := range perms {
-		if e := a.p.Revoke(sub, cat, act); e != nil {
+		if e := permissionWriter(a.p).RevokeContext(ctx, sub, cat, act); e != nil {
 			return e
 		}
 	}
@@ -174,24 +258,30 @@ func (a *authorizer) Objects() types.GroupingReader {
 	return a.og
 }
 
-// Permit subject to perform action on object
-func (a *authorizer) Permit(sub types.Subject, obj types.Object, act types.Action) error {
+// PermitContext permits subject to perform action on object
+func (a *authorizer) PermitContext(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) error {
 	a.l.V(4).Info("permit", "subject", sub, "object", obj, "action", act)
 
-	return a.p.Permit(sub, obj, act)
+	return permissionWriter(a.p).PermitContext(ctx, sub, obj, act)
 }
 
-// Revoke permission for subject to perform action on object
-func (a *authorizer) Revoke(sub types.Subject, obj types.Object, act types.Action) error {
+// RevokeContext revokes permission for subject to perform action on object
+func (a *authorizer) RevokeContext(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) error {
 	a.l.V(4).Info("revoke", "subject", sub, "object", obj, "action", act)
 
-	return a.p.Revoke(sub, obj, act)
+	return permissionWriter(a.p).RevokeContext(ctx, sub, obj, act)
 }
 
-// Shall subject perform action on object
-func (a *authorizer) Shall(sub types.Subject, obj types.Object, act types.Action) (bool, error) {
+// ShallContext tells if subject shall perform action on object
+func (a *authorizer) ShallContext(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) (bool, error) {
 	a.l.V(6).Info("shall", "subject", sub, "object", obj, "action", act)
 
+	f := a.frozen()
+	return f.shall(ctx, sub, obj, act, f.newRoleSet(ctx, sub))
+}
+
+// shall tells if subject shall perform action on object, roles of subject are expanded by rs when needed
+func (a *authorizer) shall(ctx context.Context, sub types.Subject, obj types.Object, act types.Action, rs *roleSet) (bool, error) {
 	allowed, e := a.p.PermittedActions(sub, obj)
 	if e != nil {
 		return false, e
@@ -204,7 +294,7 @@ func (a *authorizer) Shall(sub types.Subject, obj types.Object, act types.Action
 
 	var roles map[types.Group]struct{}
 	if a.sg != nil {
-		roles, e = a.sg.GroupsOf(sub)
+		roles, e = rs.get()
 		if e != nil {
 			return false, e
 		}
@@ -223,7 +313,7 @@ func (a *authorizer) Shall(sub types.Subject, obj types.Object, act types.Action
 
 	var cats map[types.Group]struct{}
 	if a.og != nil {
-		cats, e = a.og.GroupsOf(obj)
+		cats, e = a.groupsOf(ctx, a.og, "object", obj)
 		if e != nil {
 			return false, e
 		}
@@ -260,15 +350,16 @@ func (a *authorizer) Shall(sub types.Subject, obj types.Object, act types.Action
 	return false, nil
 }
 
-// PermissionsOn object for all subjects
-func (a *authorizer) PermissionsOn(obj types.Object) (map[types.Subject]types.Action, error) {
+// PermissionsOnContext returns permissions on object for all subjects
+func (a *authorizer) PermissionsOnContext(ctx context.Context, obj types.Object) (map[types.Subject]types.Action, error) {
+	a = a.frozen()
 	perms, e := a.p.PermissionsOn(obj)
 	if e != nil {
 		return nil, e
 	}
 
 	if a.og != nil {
-		cats, e := a.og.GroupsOf(obj)
+		cats, e := a.groupsOf(ctx, a.og, "object", obj)
 		if e != nil {
 			return nil, e
 		}
@@ -291,15 +382,16 @@ func (a *authorizer) PermissionsOn(obj types.Object) (map[types.Subject]types.Ac
 	return perms, nil
 }
 
-// PermissionsFor subject on all objects
-func (a *authorizer) PermissionsFor(sub types.Subject) (map[types.Object]types.Action, error) {
+// PermissionsForContext returns permissions for subject on all objects
+func (a *authorizer) PermissionsForContext(ctx context.Context, sub types.Subject) (map[types.Object]types.Action, error) {
+	a = a.frozen()
 	perms, e := a.p.PermissionsFor(sub)
 	if e != nil {
 		return nil, e
 	}
 
 	if a.sg != nil {
-		roles, e := a.sg.GroupsOf(sub)
+		roles, e := a.groupsOf(ctx, a.sg, "subject", sub)
 		if e != nil {
 			return nil, e
 		}
@@ -322,8 +414,9 @@ func (a *authorizer) PermissionsFor(sub types.Subject) (map[types.Object]types.A
 	return perms, nil
 }
 
-// PermittedActions for subject on object
-func (a *authorizer) PermittedActions(sub types.Subject, obj types.Object) (types.Action, error) {
+// PermittedActionsContext returns permitted actions for subject on object
+func (a *authorizer) PermittedActionsContext(ctx context.Context, sub types.Subject, obj types.Object) (types.Action, error) {
+	a = a.frozen()
 	var act types.Action
 
 	allowed, e := a.p.PermittedActions(sub, obj)
@@ -337,7 +430,7 @@ func (a *authorizer) PermittedActions(sub types.Subject, obj types.Object) (type
 
 	var roles map[types.Group]struct{}
 	if a.sg != nil {
-		roles, e = a.sg.GroupsOf(sub)
+		roles, e = a.groupsOf(ctx, a.sg, "subject", sub)
 		if e != nil {
 			return 0, e
 		}
@@ -356,7 +449,7 @@ func (a *authorizer) PermittedActions(sub types.Subject, obj types.Object) (type
 
 	var cats map[types.Group]struct{}
 	if a.og != nil {
-		cats, e = a.og.GroupsOf(obj)
+		cats, e = a.groupsOf(ctx, a.og, "object", obj)
 		if e != nil {
 			return 0, e
 		}
@@ -392,3 +485,16 @@ func (a *authorizer) PermittedActions(sub types.Subject, obj types.Object) (type
 
 	return act, nil
 }
+
+// groupsOf expands all groups the entity belongs to in grouping g, as a traced operation
+func (a *authorizer) groupsOf(ctx context.Context, g types.Grouping, component string, ent types.Entity) (map[types.Group]struct{}, error) {
+	_, span := a.tracer.Start(ctx, "rbac.GroupsOf",
+		types.Attr(trace.AttrComponent, component),
+		types.Attr(trace.AttrEntity, ent.String()),
+	)
+	groups, e := g.GroupsOf(ent)
+	span.SetAttributes(types.Attr(trace.AttrGroups, len(groups)))
+	span.End(e)
+
+	return groups, e
+}
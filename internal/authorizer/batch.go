@@ -0,0 +1,71 @@
+package authorizer
+
+import (
+	"context"
+
+	"github.com/supremind/rbac/types"
+)
+
+// batchChecker is an authorizer could check permissions of a subject in batches
+type batchChecker interface {
+	// shallMany tells if subject shall perform actions on objects, results are in the order of checks
+	shallMany(ctx context.Context, sub types.Subject, checks []types.ObjectAction) ([]bool, error)
+}
+
+// shallManyOf checks in a batch if authz supports it, or one by one otherwise
+func shallManyOf(ctx context.Context, authz types.AuthorizerContext, sub types.Subject, checks []types.ObjectAction) ([]bool, error) {
+	if b, ok := authz.(batchChecker); ok {
+		return b.shallMany(ctx, sub, checks)
+	}
+
+	results := make([]bool, len(checks))
+	for i, check := range checks {
+		allowed, e := authz.ShallContext(ctx, sub, check.Object, check.Action)
+		if e != nil {
+			return nil, e
+		}
+		results[i] = allowed
+	}
+	return results, nil
+}
+
+// roleSet expands roles of a subject once, when they are needed for the first time
+type roleSet struct {
+	a        *authorizer
+	ctx      context.Context
+	sub      types.Subject
+	roles    map[types.Group]struct{}
+	expanded bool
+}
+
+func (a *authorizer) newRoleSet(ctx context.Context, sub types.Subject) *roleSet {
+	return &roleSet{a: a, ctx: ctx, sub: sub}
+}
+
+func (rs *roleSet) get() (map[types.Group]struct{}, error) {
+	if rs.expanded {
+		return rs.roles, nil
+	}
+	roles, e := rs.a.groupsOf(rs.ctx, rs.a.sg, "subject", rs.sub)
+	if e != nil {
+		return nil, e
+	}
+	rs.roles, rs.expanded = roles, true
+	return roles, nil
+}
+
+func (a *authorizer) shallMany(ctx context.Context, sub types.Subject, checks []types.ObjectAction) ([]bool, error) {
+	a.l.V(6).Info("shall many", "subject", sub, "checks", len(checks))
+
+	a = a.frozen()
+	rs := a.newRoleSet(ctx, sub)
+	results := make([]bool, len(checks))
+	for i, check := range checks {
+		allowed, e := a.shall(ctx, sub, check.Object, check.Action, rs)
+		if e != nil {
+			return nil, e
+		}
+		results[i] = allowed
+	}
+	return results, nil
+}
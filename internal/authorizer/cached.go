@@ -0,0 +1,169 @@
+package authorizer
+
+import (
+	"context"
+	"time"
+
+	"github.com/supremind/rbac/internal/decision"
+	"github.com/supremind/rbac/types"
+)
+
+var _ types.AuthorizerContext = (*cachedAuthorizer)(nil)
+
+// cachedAuthorizer serves decisions from the cache, and caches decisions made by the inner authorizer,
+// cached decisions are dropped by groupings and permission when polices they depend on change.
+// It reads groupings as the inner authorizer does, so it must be locked the same way.
+type cachedAuthorizer struct {
+	types.AuthorizerContext
+	cache *decision.Cache
+}
+
+func newCachedAuthorizer(authz types.AuthorizerContext, cache *decision.Cache) *cachedAuthorizer {
+	return &cachedAuthorizer{
+		AuthorizerContext: authz,
+		cache:             cache,
+	}
+}
+
+// decisionCacher is an authorizer caching its decisions
+type decisionCacher interface {
+	// decisionCacheStats returns statistics of the decision cache
+	decisionCacheStats() (types.DecisionCacheStats, error)
+}
+
+// decisionCacheStatsOf returns statistics of the decision cache of authz, if it caches decisions
+func decisionCacheStatsOf(authz types.AuthorizerContext) (types.DecisionCacheStats, error) {
+	if c, ok := authz.(decisionCacher); ok {
+		return c.decisionCacheStats()
+	}
+	return types.DecisionCacheStats{}, types.ErrNoDecisionCache
+}
+
+// ShallContext tells if subject shall perform action on object
+func (a *cachedAuthorizer) ShallContext(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) (bool, error) {
+	if result, ok := a.cache.Get(sub, obj, act); ok {
+		return result.Includes(act), nil
+	}
+
+	epoch := a.cache.Epoch()
+	allowed, e := a.AuthorizerContext.ShallContext(ctx, sub, obj, act)
+	if e != nil {
+		return false, e
+	}
+
+	result := types.None
+	if allowed {
+		result = act
+	}
+	a.put(epoch, sub, obj, act, result)
+	return allowed, nil
+}
+
+// PermittedActionsContext returns permitted actions for subject on object
+func (a *cachedAuthorizer) PermittedActionsContext(ctx context.Context, sub types.Subject, obj types.Object) (types.Action, error) {
+	if result, ok := a.cache.Get(sub, obj, types.None); ok {
+		return result, nil
+	}
+
+	epoch := a.cache.Epoch()
+	act, e := a.AuthorizerContext.PermittedActionsContext(ctx, sub, obj)
+	if e != nil {
+		return types.None, e
+	}
+
+	a.put(epoch, sub, obj, types.None, act)
+	return act, nil
+}
+
+// shallMany serves checks from the cache, and the rest of them in a batch
+func (a *cachedAuthorizer) shallMany(ctx context.Context, sub types.Subject, checks []types.ObjectAction) ([]bool, error) {
+	results := make([]bool, len(checks))
+	var rest []types.ObjectAction
+	var restIndex []int
+	for i, check := range checks {
+		if result, ok := a.cache.Get(sub, check.Object, check.Action); ok {
+			results[i] = result.Includes(check.Action)
+			continue
+		}
+		rest = append(rest, check)
+		restIndex = append(restIndex, i)
+	}
+	if len(rest) == 0 {
+		return results, nil
+	}
+
+	epoch := a.cache.Epoch()
+	restResults, e := shallManyOf(ctx, a.AuthorizerContext, sub, rest)
+	if e != nil {
+		return nil, e
+	}
+
+	roles, e := a.groupsOf(a.Subjects(), sub)
+	if e != nil {
+		return results, nil
+	}
+	for i, allowed := range restResults {
+		results[restIndex[i]] = allowed
+
+		check := rest[i]
+		cats, e := a.groupsOf(a.Objects(), check.Object)
+		if e != nil {
+			continue
+		}
+		result := types.None
+		if allowed {
+			result = check.Action
+		}
+		a.cache.Put(epoch, sub, check.Object, check.Action, result, depsOf(sub, roles, check.Object, cats))
+	}
+	return results, nil
+}
+
+// put a decision to the cache, if groups it depends on are known
+func (a *cachedAuthorizer) put(epoch uint64, sub types.Subject, obj types.Object, act, result types.Action) {
+	roles, e := a.groupsOf(a.Subjects(), sub)
+	if e != nil {
+		return
+	}
+	cats, e := a.groupsOf(a.Objects(), obj)
+	if e != nil {
+		return
+	}
+	a.cache.Put(epoch, sub, obj, act, result, depsOf(sub, roles, obj, cats))
+}
+
+// groupsOf returns groups the entity belongs to in g, if g is used
+func (a *cachedAuthorizer) groupsOf(g types.GroupingReader, ent types.Entity) (map[types.Group]struct{}, error) {
+	if g == nil {
+		return nil, nil
+	}
+	return g.GroupsOf(ent)
+}
+
+// depsOf lists entities a decision depends on
+func depsOf(sub types.Subject, roles map[types.Group]struct{}, obj types.Object, cats map[types.Group]struct{}) []types.Entity {
+	deps := make([]types.Entity, 0, 2+len(roles)+len(cats))
+	deps = append(deps, sub, obj)
+	for role := range roles {
+		deps = append(deps, role)
+	}
+	for cat := range cats {
+		deps = append(deps, cat)
+	}
+	return deps
+}
+
+func (a *cachedAuthorizer) decisionCacheStats() (types.DecisionCacheStats, error) {
+	return a.cache.Stats(), nil
+}
+
+// policies lists all polices assigned directly
+func (a *cachedAuthorizer) policies() (types.Policies, error) {
+	return policiesOf(a.AuthorizerContext)
+}
+
+// snapshotAt returns a read-only authorizer, which decides with polices as they were at the given time,
+// decisions made by it are not cached
+func (a *cachedAuthorizer) snapshotAt(t time.Time) (types.AuthorizerContext, error) {
+	return snapshotOf(a.AuthorizerContext, t)
+}
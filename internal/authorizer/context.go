@@ -0,0 +1,207 @@
+package authorizer
+
+import (
+	"context"
+	"time"
+
+	"github.com/supremind/rbac/types"
+)
+
+var (
+	_ Authorizer                = (*backgroundAuthorizer)(nil)
+	_ types.Historian           = (*backgroundAuthorizer)(nil)
+	_ types.PolicyLister        = (*backgroundAuthorizer)(nil)
+	_ types.BatchChecker        = (*backgroundAuthorizer)(nil)
+	_ types.BatchCheckerContext = (*backgroundAuthorizer)(nil)
+	_ types.DecisionCacher      = (*backgroundAuthorizer)(nil)
+)
+
+// backgroundAuthorizer serves types.Authorizer with background contexts,
+// and types.AuthorizerContext as it is
+type backgroundAuthorizer struct {
+	types.AuthorizerContext
+}
+
+func newBackgroundAuthorizer(authz types.AuthorizerContext) *backgroundAuthorizer {
+	return &backgroundAuthorizer{AuthorizerContext: authz}
+}
+
+// SubjectJoin joins a user or a sub role to a role
+func (a *backgroundAuthorizer) SubjectJoin(sub types.Subject, role types.Role) error {
+	return a.SubjectJoinContext(context.Background(), sub, role)
+}
+
+// SubjectLeave removes a user or a sub role from a role
+func (a *backgroundAuthorizer) SubjectLeave(sub types.Subject, role types.Role) error {
+	return a.SubjectLeaveContext(context.Background(), sub, role)
+}
+
+// RemoveUser removes a user and all policies about it
+func (a *backgroundAuthorizer) RemoveUser(user types.User) error {
+	return a.RemoveUserContext(context.Background(), user)
+}
+
+// RemoveRole removes a role and all policies about it
+func (a *backgroundAuthorizer) RemoveRole(role types.Role) error {
+	return a.RemoveRoleContext(context.Background(), role)
+}
+
+// ObjectJoin joins an article or a sub category to a category
+func (a *backgroundAuthorizer) ObjectJoin(obj types.Object, cat types.Category) error {
+	return a.ObjectJoinContext(context.Background(), obj, cat)
+}
+
+// ObjectLeave removes an article or a sub category from a category
+func (a *backgroundAuthorizer) ObjectLeave(obj types.Object, cat types.Category) error {
+	return a.ObjectLeaveContext(context.Background(), obj, cat)
+}
+
+// RemoveArticle removes an article and all polices about it
+func (a *backgroundAuthorizer) RemoveArticle(art types.Article) error {
+	return a.RemoveArticleContext(context.Background(), art)
+}
+
+// RemoveCategory removes a category and all polices about it
+func (a *backgroundAuthorizer) RemoveCategory(cat types.Category) error {
+	return a.RemoveCategoryContext(context.Background(), cat)
+}
+
+// Permit subject to perform action on object
+func (a *backgroundAuthorizer) Permit(sub types.Subject, obj types.Object, act types.Action) error {
+	return a.PermitContext(context.Background(), sub, obj, act)
+}
+
+// Revoke permission for subject to perform action on object
+func (a *backgroundAuthorizer) Revoke(sub types.Subject, obj types.Object, act types.Action) error {
+	return a.RevokeContext(context.Background(), sub, obj, act)
+}
+
+// Shall subject perform action on object
+func (a *backgroundAuthorizer) Shall(sub types.Subject, obj types.Object, act types.Action) (bool, error) {
+	return a.ShallContext(context.Background(), sub, obj, act)
+}
+
+// PermissionsOn object for all subjects
+func (a *backgroundAuthorizer) PermissionsOn(obj types.Object) (map[types.Subject]types.Action, error) {
+	return a.PermissionsOnContext(context.Background(), obj)
+}
+
+// PermissionsFor subject on all objects
+func (a *backgroundAuthorizer) PermissionsFor(sub types.Subject) (map[types.Object]types.Action, error) {
+	return a.PermissionsForContext(context.Background(), sub)
+}
+
+// PermittedActions for subject on object
+func (a *backgroundAuthorizer) PermittedActions(sub types.Subject, obj types.Object) (types.Action, error) {
+	return a.PermittedActionsContext(context.Background(), sub, obj)
+}
+
+// ShallMany tells if subject shall perform actions on objects, results are in the order of checks
+func (a *backgroundAuthorizer) ShallMany(sub types.Subject, checks []types.ObjectAction) ([]bool, error) {
+	return a.ShallManyContext(context.Background(), sub, checks)
+}
+
+// ShallManyContext tells if subject shall perform actions on objects, results are in the order of checks
+func (a *backgroundAuthorizer) ShallManyContext(ctx context.Context, sub types.Subject, checks []types.ObjectAction) ([]bool, error) {
+	return shallManyOf(ctx, a.AuthorizerContext, sub, checks)
+}
+
+// FilterAllowed returns objects subject shall perform action on, in their given order
+func (a *backgroundAuthorizer) FilterAllowed(sub types.Subject, act types.Action, objs []types.Object) ([]types.Object, error) {
+	return a.FilterAllowedContext(context.Background(), sub, act, objs)
+}
+
+// FilterAllowedContext returns objects subject shall perform action on, in their given order
+func (a *backgroundAuthorizer) FilterAllowedContext(ctx context.Context, sub types.Subject, act types.Action, objs []types.Object) ([]types.Object, error) {
+	checks := make([]types.ObjectAction, len(objs))
+	for i, obj := range objs {
+		checks[i] = types.ObjectAction{Object: obj, Action: act}
+	}
+	results, e := shallManyOf(ctx, a.AuthorizerContext, sub, checks)
+	if e != nil {
+		return nil, e
+	}
+
+	allowed := make([]types.Object, 0, len(objs))
+	for i, obj := range objs {
+		if results[i] {
+			allowed = append(allowed, obj)
+		}
+	}
+	return allowed, nil
+}
+
+// SnapshotAt returns a read-only authorizer, which decides with polices as they were at the given time
+func (a *backgroundAuthorizer) SnapshotAt(t time.Time) (types.Authorizer, error) {
+	at, e := snapshotOf(a.AuthorizerContext, t)
+	if e != nil {
+		return nil, e
+	}
+	return newBackgroundAuthorizer(at), nil
+}
+
+// Policies lists all polices assigned directly
+func (a *backgroundAuthorizer) Policies() (types.Policies, error) {
+	return policiesOf(a.AuthorizerContext)
+}
+
+// DecisionCacheStats returns statistics of the decision cache, if decisions are cached
+func (a *backgroundAuthorizer) DecisionCacheStats() (types.DecisionCacheStats, error) {
+	return decisionCacheStatsOf(a.AuthorizerContext)
+}
+
+// permissionWriterContext is the context-aware writer of permissions
+type permissionWriterContext interface {
+	PermitContext(context.Context, types.Subject, types.Object, types.Action) error
+	RevokeContext(context.Context, types.Subject, types.Object, types.Action) error
+}
+
+// groupingWriter returns the context-aware writer of the grouping,
+// contexts are ignored if the grouping does not support them
+func groupingWriter(g types.Grouping) types.GroupingWriterContext {
+	if w, ok := g.(types.GroupingWriterContext); ok {
+		return w
+	}
+	return groupingWithoutContext{g}
+}
+
+type groupingWithoutContext struct {
+	g types.GroupingWriter
+}
+
+func (w groupingWithoutContext) JoinContext(_ context.Context, ent types.Entity, group types.Group) error {
+	return w.g.Join(ent, group)
+}
+
+func (w groupingWithoutContext) LeaveContext(_ context.Context, ent types.Entity, group types.Group) error {
+	return w.g.Leave(ent, group)
+}
+
+func (w groupingWithoutContext) RemoveGroupContext(_ context.Context, group types.Group) error {
+	return w.g.RemoveGroup(group)
+}
+
+func (w groupingWithoutContext) RemoveMemberContext(_ context.Context, member types.Member) error {
+	return w.g.RemoveMember(member)
+}
+
+// permissionWriter returns the context-aware writer of the permission,
+// contexts are ignored if the permission does not support them
+func permissionWriter(p types.Permission) permissionWriterContext {
+	if w, ok := p.(permissionWriterContext); ok {
+		return w
+	}
+	return permissionWithoutContext{p}
+}
+
+type permissionWithoutContext struct {
+	p types.Permission
+}
+
+func (w permissionWithoutContext) PermitContext(_ context.Context, sub types.Subject, obj types.Object, act types.Action) error {
+	return w.p.Permit(sub, obj, act)
+}
+
+func (w permissionWithoutContext) RevokeContext(_ context.Context, sub types.Subject, obj types.Object, act types.Action) error {
+	return w.p.Revoke(sub, obj, act)
+}
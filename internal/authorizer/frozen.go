@@ -0,0 +1,39 @@
+package authorizer
+
+import (
+	"github.com/supremind/rbac/types"
+)
+
+// freezableGrouping is a grouping keeps its states in immutable snapshots
+type freezableGrouping interface {
+	Frozen() types.Grouping
+}
+
+// freezablePermission is a permission keeps its states in immutable snapshots
+type freezablePermission interface {
+	Frozen() types.Permission
+}
+
+// frozen returns an authorizer reading the current snapshots of groupings and permission,
+// which are loaded once, so that its decisions are made with the same polices.
+// Groupings and permission without snapshots are read as they are.
+func (a *authorizer) frozen() *authorizer {
+	sg, sok := a.sg.(freezableGrouping)
+	og, ook := a.og.(freezableGrouping)
+	p, pok := a.p.(freezablePermission)
+	if !sok && !ook && !pok {
+		return a
+	}
+
+	f := *a
+	if sok {
+		f.sg = sg.Frozen()
+	}
+	if ook {
+		f.og = og.Frozen()
+	}
+	if pok {
+		f.p = p.Frozen()
+	}
+	return &f
+}
@@ -0,0 +1,72 @@
+package authorizer
+
+import (
+	"time"
+
+	"github.com/supremind/rbac/types"
+)
+
+// historicalGrouping is a grouping keeps its history
+type historicalGrouping interface {
+	At(time.Time) (types.Grouping, error)
+}
+
+// historicalPermission is a permission keeps its history
+type historicalPermission interface {
+	At(time.Time) (types.Permission, error)
+}
+
+// historian is an authorizer knows how polices were in the past
+type historian interface {
+	// snapshotAt returns a read-only authorizer, which decides with polices as they were at the given time
+	snapshotAt(time.Time) (types.AuthorizerContext, error)
+}
+
+func (a *authorizer) snapshotAt(t time.Time) (types.AuthorizerContext, error) {
+	a.l.V(4).Info("snapshot at", "time", t)
+
+	at := &authorizer{l: a.l.WithName("snapshot"), tracer: a.tracer}
+
+	if a.sg != nil {
+		h, ok := a.sg.(historicalGrouping)
+		if !ok {
+			return nil, types.ErrNoHistory
+		}
+		sg, e := h.At(t)
+		if e != nil {
+			return nil, e
+		}
+		at.sg = sg
+	}
+
+	if a.og != nil {
+		h, ok := a.og.(historicalGrouping)
+		if !ok {
+			return nil, types.ErrNoHistory
+		}
+		og, e := h.At(t)
+		if e != nil {
+			return nil, e
+		}
+		at.og = og
+	}
+
+	h, ok := a.p.(historicalPermission)
+	if !ok {
+		return nil, types.ErrNoHistory
+	}
+	p, e := h.At(t)
+	if e != nil {
+		return nil, e
+	}
+	at.p = p
+
+	return at, nil
+}
+
+func snapshotOf(authz types.AuthorizerContext, t time.Time) (types.AuthorizerContext, error) {
+	if h, ok := authz.(historian); ok {
+		return h.snapshotAt(t)
+	}
+	return nil, types.ErrNoHistory
+}
@@ -0,0 +1,103 @@
+package authorizer
+
+import (
+	"context"
+	"time"
+
+	"github.com/supremind/rbac/internal/effective"
+	"github.com/supremind/rbac/types"
+)
+
+var _ types.AuthorizerContext = (*indexedAuthorizer)(nil)
+
+// indexedAuthorizer looks up effective actions of users on articles in the index,
+// other subjects and objects, and users out of the budget of the index, are decided by the inner authorizer
+type indexedAuthorizer struct {
+	types.AuthorizerContext
+	index *effective.Index
+}
+
+func newIndexedAuthorizer(authz types.AuthorizerContext, index *effective.Index) *indexedAuthorizer {
+	return &indexedAuthorizer{
+		AuthorizerContext: authz,
+		index:             index,
+	}
+}
+
+// ShallContext tells if subject shall perform action on object
+func (a *indexedAuthorizer) ShallContext(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) (bool, error) {
+	allowed, ok, e := a.lookup(sub, obj)
+	if e != nil {
+		return false, e
+	}
+	if ok {
+		return allowed.Includes(act), nil
+	}
+
+	return a.AuthorizerContext.ShallContext(ctx, sub, obj, act)
+}
+
+// PermittedActionsContext returns permitted actions for subject on object
+func (a *indexedAuthorizer) PermittedActionsContext(ctx context.Context, sub types.Subject, obj types.Object) (types.Action, error) {
+	allowed, ok, e := a.lookup(sub, obj)
+	if e != nil {
+		return types.None, e
+	}
+	if ok {
+		return allowed, nil
+	}
+
+	return a.AuthorizerContext.PermittedActionsContext(ctx, sub, obj)
+}
+
+// shallMany looks up checks on articles in the index, and decides the rest of them in a batch
+func (a *indexedAuthorizer) shallMany(ctx context.Context, sub types.Subject, checks []types.ObjectAction) ([]bool, error) {
+	results := make([]bool, len(checks))
+	var rest []types.ObjectAction
+	var restIndex []int
+	for i, check := range checks {
+		allowed, ok, e := a.lookup(sub, check.Object)
+		if e != nil {
+			return nil, e
+		}
+		if ok {
+			results[i] = allowed.Includes(check.Action)
+			continue
+		}
+		rest = append(rest, check)
+		restIndex = append(restIndex, i)
+	}
+	if len(rest) == 0 {
+		return results, nil
+	}
+
+	restResults, e := shallManyOf(ctx, a.AuthorizerContext, sub, rest)
+	if e != nil {
+		return nil, e
+	}
+	for i, allowed := range restResults {
+		results[restIndex[i]] = allowed
+	}
+	return results, nil
+}
+
+// lookup effective actions in the index, ok is false if they are not indexed
+func (a *indexedAuthorizer) lookup(sub types.Subject, obj types.Object) (act types.Action, ok bool, err error) {
+	user, isUser := sub.(types.User)
+	art, isArticle := obj.(types.Article)
+	if !isUser || !isArticle {
+		return types.None, false, nil
+	}
+	return a.index.PermittedActions(user, art)
+}
+
+// policies lists all polices assigned directly
+func (a *indexedAuthorizer) policies() (types.Policies, error) {
+	return policiesOf(a.AuthorizerContext)
+}
+
+// snapshotAt returns a read-only authorizer, which decides with polices as they were at the given time,
+// without the index
+func (a *indexedAuthorizer) snapshotAt(t time.Time) (types.AuthorizerContext, error) {
+	return snapshotOf(a.AuthorizerContext, t)
+}
@@ -0,0 +1,127 @@
+package authorizer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/supremind/rbac/types"
+)
+
+var _ types.AuthorizerContext = (*lockFreeAuthorizer)(nil)
+
+// lockFreeAuthorizer serializes writes to the given authorizer, while leaves all reads unlocked,
+// it works with groupings and permissions could be read without locking, like copy-on-write ones
+type lockFreeAuthorizer struct {
+	sync.Mutex
+	types.AuthorizerContext
+}
+
+func newLockFreeAuthorizer(authz types.AuthorizerContext) *lockFreeAuthorizer {
+	return &lockFreeAuthorizer{AuthorizerContext: authz}
+}
+
+// SubjectJoinContext joins a user or a sub role to a role
+func (authz *lockFreeAuthorizer) SubjectJoinContext(ctx context.Context, sub types.Subject, role types.Role) error {
+	authz.Lock()
+	defer authz.Unlock()
+
+	return authz.AuthorizerContext.SubjectJoinContext(ctx, sub, role)
+}
+
+// SubjectLeaveContext removes a user or a sub role from a role
+func (authz *lockFreeAuthorizer) SubjectLeaveContext(ctx context.Context, sub types.Subject, role types.Role) error {
+	authz.Lock()
+	defer authz.Unlock()
+
+	return authz.AuthorizerContext.SubjectLeaveContext(ctx, sub, role)
+}
+
+// RemoveUserContext removes a user and all policies about it
+func (authz *lockFreeAuthorizer) RemoveUserContext(ctx context.Context, user types.User) error {
+	authz.Lock()
+	defer authz.Unlock()
+
+	return authz.AuthorizerContext.RemoveUserContext(ctx, user)
+}
+
+// RemoveRoleContext removes a role and all policies about it
+func (authz *lockFreeAuthorizer) RemoveRoleContext(ctx context.Context, role types.Role) error {
+	authz.Lock()
+	defer authz.Unlock()
+
+	return authz.AuthorizerContext.RemoveRoleContext(ctx, role)
+}
+
+// ObjectJoinContext joins an article or a sub category to a category
+func (authz *lockFreeAuthorizer) ObjectJoinContext(ctx context.Context, obj types.Object, cat types.Category) error {
+	authz.Lock()
+	defer authz.Unlock()
+
+	return authz.AuthorizerContext.ObjectJoinContext(ctx, obj, cat)
+}
+
+// ObjectLeaveContext removes an article or a sub category from a category
+func (authz *lockFreeAuthorizer) ObjectLeaveContext(ctx context.Context, obj types.Object, cat types.Category) error {
+	authz.Lock()
+	defer authz.Unlock()
+
+	return authz.AuthorizerContext.ObjectLeaveContext(ctx, obj, cat)
+}
+
+// RemoveArticleContext removes an article and all polices about it
+func (authz *lockFreeAuthorizer) RemoveArticleContext(ctx context.Context, art types.Article) error {
+	authz.Lock()
+	defer authz.Unlock()
+
+	return authz.AuthorizerContext.RemoveArticleContext(ctx, art)
+}
+
+// RemoveCategoryContext removes a category and all polices about it
+func (authz *lockFreeAuthorizer) RemoveCategoryContext(ctx context.Context, cat types.Category) error {
+	authz.Lock()
+	defer authz.Unlock()
+
+	return authz.AuthorizerContext.RemoveCategoryContext(ctx, cat)
+}
+
+// PermitContext permits subject to perform action on object
+func (authz *lockFreeAuthorizer) PermitContext(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) error {
+	authz.Lock()
+	defer authz.Unlock()
+
+	return authz.AuthorizerContext.PermitContext(ctx, sub, obj, act)
+}
+
+// RevokeContext revokes permission for subject to perform action on object
+func (authz *lockFreeAuthorizer) RevokeContext(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) error {
+	authz.Lock()
+	defer authz.Unlock()
+
+	return authz.AuthorizerContext.RevokeContext(ctx, sub, obj, act)
+}
+
+// shallMany checks permissions in a batch without locking,
+// all decisions in the batch are made with the snapshots of polices loaded once
+func (authz *lockFreeAuthorizer) shallMany(ctx context.Context, sub types.Subject, checks []types.ObjectAction) ([]bool, error) {
+	return shallManyOf(ctx, authz.AuthorizerContext, sub, checks)
+}
+
+// policies lists all polices assigned directly, writers are blocked meanwhile,
+// so that polices of groupings and permission are consistent with each other
+func (authz *lockFreeAuthorizer) policies() (types.Policies, error) {
+	authz.Lock()
+	defer authz.Unlock()
+
+	return policiesOf(authz.AuthorizerContext)
+}
+
+// snapshotAt returns a read-only authorizer, which decides with polices as they were at the given time
+func (authz *lockFreeAuthorizer) snapshotAt(t time.Time) (types.AuthorizerContext, error) {
+	return snapshotOf(authz.AuthorizerContext, t)
+}
+
+// decisionCacheStats returns statistics of the decision cache
+func (authz *lockFreeAuthorizer) decisionCacheStats() (types.DecisionCacheStats, error) {
+	return decisionCacheStatsOf(authz.AuthorizerContext)
+}
@@ -0,0 +1,64 @@
+package authorizer
+
+import (
+	"context"
+	"time"
+
+	"github.com/supremind/rbac/types"
+)
+
+var _ types.AuthorizerContext = (*measuredAuthorizer)(nil)
+
+// measuredAuthorizer reports decisions and how long they took to metrics
+type measuredAuthorizer struct {
+	types.AuthorizerContext
+	metrics types.Metrics
+}
+
+func newMeasuredAuthorizer(authz types.AuthorizerContext, m types.Metrics) *measuredAuthorizer {
+	return &measuredAuthorizer{
+		AuthorizerContext: authz,
+		metrics:           m,
+	}
+}
+
+// ShallContext tells if subject shall perform action on object
+func (a *measuredAuthorizer) ShallContext(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) (bool, error) {
+	start := time.Now()
+	allowed, e := a.AuthorizerContext.ShallContext(ctx, sub, obj, act)
+	a.metrics.ObserveDecision(act, allowed, e, time.Since(start))
+
+	return allowed, e
+}
+
+// shallMany checks permissions in a batch, each decision is measured as taking an even share of the batch
+func (a *measuredAuthorizer) shallMany(ctx context.Context, sub types.Subject, checks []types.ObjectAction) ([]bool, error) {
+	start := time.Now()
+	results, e := shallManyOf(ctx, a.AuthorizerContext, sub, checks)
+	if len(checks) == 0 {
+		return results, e
+	}
+
+	took := time.Since(start) / time.Duration(len(checks))
+	for i, check := range checks {
+		allowed := e == nil && results[i]
+		a.metrics.ObserveDecision(check.Action, allowed, e, took)
+	}
+	return results, e
+}
+
+// policies lists all polices assigned directly
+func (a *measuredAuthorizer) policies() (types.Policies, error) {
+	return policiesOf(a.AuthorizerContext)
+}
+
+// snapshotAt returns a read-only authorizer, which decides with polices as they were at the given time,
+// decisions made by it are not measured
+func (a *measuredAuthorizer) snapshotAt(t time.Time) (types.AuthorizerContext, error) {
+	return snapshotOf(a.AuthorizerContext, t)
+}
+
+// decisionCacheStats returns statistics of the decision cache
+func (a *measuredAuthorizer) decisionCacheStats() (types.DecisionCacheStats, error) {
+	return decisionCacheStatsOf(a.AuthorizerContext)
+}
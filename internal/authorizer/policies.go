@@ -0,0 +1,68 @@
+package authorizer
+
+import (
+	"github.com/supremind/rbac/types"
+)
+
+// groupingLister is a grouping could list its polices
+type groupingLister interface {
+	Policies() ([]types.GroupingPolicy, error)
+}
+
+// permissionLister is a permission could list its polices
+type permissionLister interface {
+	Policies() ([]types.PermissionPolicy, error)
+}
+
+// policyLister is an authorizer could list its polices
+type policyLister interface {
+	// policies lists all polices assigned directly
+	policies() (types.Policies, error)
+}
+
+func (a *authorizer) policies() (types.Policies, error) {
+	var ps types.Policies
+
+	if a.sg != nil {
+		l, ok := a.sg.(groupingLister)
+		if !ok {
+			return types.Policies{}, types.ErrNoPolicyList
+		}
+		subjects, e := l.Policies()
+		if e != nil {
+			return types.Policies{}, e
+		}
+		ps.Subjects = subjects
+	}
+
+	if a.og != nil {
+		l, ok := a.og.(groupingLister)
+		if !ok {
+			return types.Policies{}, types.ErrNoPolicyList
+		}
+		objects, e := l.Policies()
+		if e != nil {
+			return types.Policies{}, e
+		}
+		ps.Objects = objects
+	}
+
+	l, ok := a.p.(permissionLister)
+	if !ok {
+		return types.Policies{}, types.ErrNoPolicyList
+	}
+	perms, e := l.Policies()
+	if e != nil {
+		return types.Policies{}, e
+	}
+	ps.Permissions = perms
+
+	return ps, nil
+}
+
+func policiesOf(authz types.AuthorizerContext) (types.Policies, error) {
+	if l, ok := authz.(policyLister); ok {
+		return l.policies()
+	}
+	return types.Policies{}, types.ErrNoPolicyList
+}
@@ -1,25 +1,87 @@
 package authorizer
 
-import "github.com/supremind/rbac/types"
+import (
+	"context"
+	"time"
+
+	"github.com/supremind/rbac/types"
+)
 
 type authorizerWithPreset struct {
 	presets []types.PresetPolicy
-	types.Authorizer
+	self    types.Authorizer // presets are checked against the authorizer itself
+	types.AuthorizerContext
 }
 
-func newWithPresetPolices(authz types.Authorizer, presets ...types.PresetPolicy) *authorizerWithPreset {
-	return &authorizerWithPreset{
-		presets:    presets,
-		Authorizer: authz,
+func newWithPresetPolices(authz types.AuthorizerContext, presets ...types.PresetPolicy) *authorizerWithPreset {
+	a := &authorizerWithPreset{
+		presets:           presets,
+		AuthorizerContext: authz,
 	}
+	a.self = newBackgroundAuthorizer(a)
+	return a
+}
+
+func (a *authorizerWithPreset) ShallContext(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) (bool, error) {
+	if a.preset(sub, obj, act) {
+		return true, nil
+	}
+
+	return a.AuthorizerContext.ShallContext(ctx, sub, obj, act)
 }
 
-func (a *authorizerWithPreset) Shall(sub types.Subject, obj types.Object, act types.Action) (bool, error) {
+// shallMany checks preset polices first, and the rest of checks in a batch
+func (a *authorizerWithPreset) shallMany(ctx context.Context, sub types.Subject, checks []types.ObjectAction) ([]bool, error) {
+	results := make([]bool, len(checks))
+	var rest []types.ObjectAction
+	var restIndex []int
+	for i, check := range checks {
+		if a.preset(sub, check.Object, check.Action) {
+			results[i] = true
+			continue
+		}
+		rest = append(rest, check)
+		restIndex = append(restIndex, i)
+	}
+	if len(rest) == 0 {
+		return results, nil
+	}
+
+	restResults, e := shallManyOf(ctx, a.AuthorizerContext, sub, rest)
+	if e != nil {
+		return nil, e
+	}
+	for i, allowed := range restResults {
+		results[restIndex[i]] = allowed
+	}
+	return results, nil
+}
+
+func (a *authorizerWithPreset) preset(sub types.Subject, obj types.Object, act types.Action) bool {
 	for _, p := range a.presets {
-		if p(a, sub, obj, act) {
-			return true, nil
+		if p(a.self, sub, obj, act) {
+			return true
 		}
 	}
+	return false
+}
+
+// policies lists all polices assigned directly
+func (a *authorizerWithPreset) policies() (types.Policies, error) {
+	return policiesOf(a.AuthorizerContext)
+}
+
+// snapshotAt returns a read-only authorizer, which decides with polices as they were at the given time,
+// preset polices are applied to it too
+func (a *authorizerWithPreset) snapshotAt(t time.Time) (types.AuthorizerContext, error) {
+	at, e := snapshotOf(a.AuthorizerContext, t)
+	if e != nil {
+		return nil, e
+	}
+	return newWithPresetPolices(at, a.presets...), nil
+}
 
-	return a.Authorizer.Shall(sub, obj, act)
+// decisionCacheStats returns statistics of the decision cache
+func (a *authorizerWithPreset) decisionCacheStats() (types.DecisionCacheStats, error) {
+	return decisionCacheStatsOf(a.AuthorizerContext)
 }
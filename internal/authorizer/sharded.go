@@ -0,0 +1,81 @@
+package authorizer
+
+import (
+	"context"
+	"sync"
+
+	"github.com/supremind/rbac/internal/grouping"
+	"github.com/supremind/rbac/types"
+)
+
+var _ types.AuthorizerContext = (*shardedAuthorizer)(nil)
+
+// shardedAuthorizer lets users and articles join and leave groups in parallel, with the read lock held,
+// they are serialized per member by stripes of locks, which are partitioned the same as sharded groupings.
+// Other writes hold the write lock as syncedAuthorizer does.
+type shardedAuthorizer struct {
+	*syncedAuthorizer
+	stripes []sync.Mutex
+}
+
+func newShardedAuthorizer(authz types.AuthorizerContext, shards int) *shardedAuthorizer {
+	return &shardedAuthorizer{
+		syncedAuthorizer: newSyncedAuthorizer(authz),
+		stripes:          make([]sync.Mutex, shards),
+	}
+}
+
+// lockMember locks the stripe of member with the read lock held, and returns the function to unlock them
+func (authz *shardedAuthorizer) lockMember(member types.Member) func() {
+	stripe := &authz.stripes[grouping.ShardOf(member, len(authz.stripes))]
+	authz.RLock()
+	stripe.Lock()
+	return func() {
+		stripe.Unlock()
+		authz.RUnlock()
+	}
+}
+
+// SubjectJoinContext joins a user or a sub role to a role
+func (authz *shardedAuthorizer) SubjectJoinContext(ctx context.Context, sub types.Subject, role types.Role) error {
+	user, ok := sub.(types.User)
+	if !ok {
+		return authz.syncedAuthorizer.SubjectJoinContext(ctx, sub, role)
+	}
+	defer authz.lockMember(user)()
+
+	return authz.authz.SubjectJoinContext(ctx, sub, role)
+}
+
+// SubjectLeaveContext removes a user or a sub role from a role
+func (authz *shardedAuthorizer) SubjectLeaveContext(ctx context.Context, sub types.Subject, role types.Role) error {
+	user, ok := sub.(types.User)
+	if !ok {
+		return authz.syncedAuthorizer.SubjectLeaveContext(ctx, sub, role)
+	}
+	defer authz.lockMember(user)()
+
+	return authz.authz.SubjectLeaveContext(ctx, sub, role)
+}
+
+// ObjectJoinContext joins an article or a sub category to a category
+func (authz *shardedAuthorizer) ObjectJoinContext(ctx context.Context, obj types.Object, cat types.Category) error {
+	art, ok := obj.(types.Article)
+	if !ok {
+		return authz.syncedAuthorizer.ObjectJoinContext(ctx, obj, cat)
+	}
+	defer authz.lockMember(art)()
+
+	return authz.authz.ObjectJoinContext(ctx, obj, cat)
+}
+
+// ObjectLeaveContext removes an article or a sub category from a category
+func (authz *shardedAuthorizer) ObjectLeaveContext(ctx context.Context, obj types.Object, cat types.Category) error {
+	art, ok := obj.(types.Article)
+	if !ok {
+		return authz.syncedAuthorizer.ObjectLeaveContext(ctx, obj, cat)
+	}
+	defer authz.lockMember(art)()
+
+	return authz.authz.ObjectLeaveContext(ctx, obj, cat)
+}
@@ -1,53 +1,55 @@
 package authorizer
 
 import (
+	"context"
 	"sync"
+	"time"
 
 	"github.com/supremind/rbac/types"
 )
 
-var _ types.Authorizer = (*syncedAuthorizer)(nil)
+var _ types.AuthorizerContext = (*syncedAuthorizer)(nil)
 
 // syncedAuthorizer makes the given authorizer be safe in concurrent usages
 type syncedAuthorizer struct {
 	sync.RWMutex
-	authz types.Authorizer
+	authz types.AuthorizerContext
 }
 
-func newSyncedAuthorizer(authz types.Authorizer) *syncedAuthorizer {
+func newSyncedAuthorizer(authz types.AuthorizerContext) *syncedAuthorizer {
 	return &syncedAuthorizer{authz: authz}
 }
 
-// SubjectJoin joins a user or a sub role to a role
-func (authz *syncedAuthorizer) SubjectJoin(sub types.Subject, role types.Role) error {
+// SubjectJoinContext joins a user or a sub role to a role
+func (authz *syncedAuthorizer) SubjectJoinContext(ctx context.Context, sub types.Subject, role types.Role) error {
 	authz.Lock()
 	defer authz.Unlock()
 
-	return authz.authz.SubjectJoin(sub, role)
+	return authz.authz.SubjectJoinContext(ctx, sub, role)
 }
 
-// SubjectLeave removes a user or a sub role from a role
-func (authz *syncedAuthorizer) SubjectLeave(sub types.Subject, role types.Role) error {
+// SubjectLeaveContext removes a user or a sub role from a role
+func (authz *syncedAuthorizer) SubjectLeaveContext(ctx context.Context, sub types.Subject, role types.Role) error {
 	authz.Lock()
 	defer authz.Unlock()
 
-	return authz.authz.SubjectLeave(sub, role)
+	return authz.authz.SubjectLeaveContext(ctx, sub, role)
 }
 
-// RemoveUser removes a user and all policies about it
-func (authz *syncedAuthorizer) RemoveUser(user types.User) error {
+// RemoveUserContext removes a user and all policies about it
+func (authz *syncedAuthorizer) RemoveUserContext(ctx context.Context, user types.User) error {
 	authz.Lock()
 	defer authz.Unlock()
 
-	return authz.authz.RemoveUser(user)
+	return authz.authz.RemoveUserContext(ctx, user)
 }
 
-// RemoveRole removes a role and all policies about it
-func (authz *syncedAuthorizer) RemoveRole(role types.Role) error {
+// RemoveRoleContext removes a role and all policies about it
+func (authz *syncedAuthorizer) RemoveRoleContext(ctx context.Context, role types.Role) error {
 	authz.Lock()
 	defer authz.Unlock()
 
-	return authz.authz.RemoveRole(role)
+	return authz.authz.RemoveRoleContext(ctx, role)
 }
 
 // Subjects returns the types.GroupingReader interface for subjects
@@ -55,36 +57,36 @@ func (authz *syncedAuthorizer) Subjects() types.GroupingReader {
 	return authz.authz.Subjects()
 }
 
-// ObjectJoin joins an article or a sub category to a category
-func (authz *syncedAuthorizer) ObjectJoin(obj types.Object, cat types.Category) error {
+// ObjectJoinContext joins an article or a sub category to a category
+func (authz *syncedAuthorizer) ObjectJoinContext(ctx context.Context, obj types.Object, cat types.Category) error {
 	authz.Lock()
 	defer authz.Unlock()
 
-	return authz.authz.ObjectJoin(obj, cat)
+	return authz.authz.ObjectJoinContext(ctx, obj, cat)
 }
 
-// ObjectLeave removes an article or a sub category from a category
-func (authz *syncedAuthorizer) ObjectLeave(obj types.Object, cat types.Category) error {
+// ObjectLeaveContext removes an article or a sub category from a category
+func (authz *syncedAuthorizer) ObjectLeaveContext(ctx context.Context, obj types.Object, cat types.Category) error {
 	authz.Lock()
 	defer authz.Unlock()
 
-	return authz.authz.ObjectLeave(obj, cat)
+	return authz.authz.ObjectLeaveContext(ctx, obj, cat)
 }
 
-// RemoveArticle removes an article and all polices about it
-func (authz *syncedAuthorizer) RemoveArticle(art types.Article) error {
+// RemoveArticleContext removes an article and all polices about it
+func (authz *syncedAuthorizer) RemoveArticleContext(ctx context.Context, art types.Article) error {
 	authz.Lock()
 	defer authz.Unlock()
 
-	return authz.authz.RemoveArticle(art)
+	return authz.authz.RemoveArticleContext(ctx, art)
 }
 
-// RemoveCategory removes a category and all polices about it
-func (authz *syncedAuthorizer) RemoveCategory(cat types.Category) error {
+// RemoveCategoryContext removes a category and all polices about it
+func (authz *syncedAuthorizer) RemoveCategoryContext(ctx context.Context, cat types.Category) error {
 	authz.Lock()
 	defer authz.Unlock()
 
-	return authz.authz.RemoveCategory(cat)
+	return authz.authz.RemoveCategoryContext(ctx, cat)
 }
 
 // Objects returns the types.GroupingReader interface for objects
@@ -92,50 +94,76 @@ func (authz *syncedAuthorizer) Objects() types.GroupingReader {
 	return authz.authz.Objects()
 }
 
-// Permit subject to perform action on object
-func (authz *syncedAuthorizer) Permit(sub types.Subject, obj types.Object, act types.Action) error {
+// PermitContext permits subject to perform action on object
+func (authz *syncedAuthorizer) PermitContext(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) error {
 	authz.Lock()
 	defer authz.Unlock()
 
-	return authz.authz.Permit(sub, obj, act)
+	return authz.authz.PermitContext(ctx, sub, obj, act)
 }
 
-// Revoke permission for subject to perform action on object
-func (authz *syncedAuthorizer) Revoke(sub types.Subject, obj types.Object, act types.Action) error {
+// RevokeContext revokes permission for subject to perform action on object
+func (authz *syncedAuthorizer) RevokeContext(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) error {
 	authz.Lock()
 	defer authz.Unlock()
 
-	return authz.authz.Revoke(sub, obj, act)
+	return authz.authz.RevokeContext(ctx, sub, obj, act)
 }
 
-// Shall subject to perform action on object
-func (authz *syncedAuthorizer) Shall(sub types.Subject, obj types.Object, act types.Action) (bool, error) {
+// ShallContext tells if subject shall perform action on object
+func (authz *syncedAuthorizer) ShallContext(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) (bool, error) {
 	authz.RLock()
 	defer authz.RUnlock()
 
-	return authz.authz.Shall(sub, obj, act)
+	return authz.authz.ShallContext(ctx, sub, obj, act)
 }
 
-// PermissionsOn object for all subjects
-func (authz *syncedAuthorizer) PermissionsOn(obj types.Object) (map[types.Subject]types.Action, error) {
+// PermissionsOnContext returns permissions on object for all subjects
+func (authz *syncedAuthorizer) PermissionsOnContext(ctx context.Context, obj types.Object) (map[types.Subject]types.Action, error) {
 	authz.RLock()
 	defer authz.RUnlock()
 
-	return authz.authz.PermissionsOn(obj)
+	return authz.authz.PermissionsOnContext(ctx, obj)
 }
 
-// PermissionsFor subject on all objects
-func (authz *syncedAuthorizer) PermissionsFor(sub types.Subject) (map[types.Object]types.Action, error) {
+// PermissionsForContext returns permissions for subject on all objects
+func (authz *syncedAuthorizer) PermissionsForContext(ctx context.Context, sub types.Subject) (map[types.Object]types.Action, error) {
 	authz.RLock()
 	defer authz.RUnlock()
 
-	return authz.authz.PermissionsFor(sub)
+	return authz.authz.PermissionsForContext(ctx, sub)
 }
 
-// PermittedActions for subject on object
-func (authz *syncedAuthorizer) PermittedActions(sub types.Subject, obj types.Object) (types.Action, error) {
+// PermittedActionsContext returns permitted actions for subject on object
+func (authz *syncedAuthorizer) PermittedActionsContext(ctx context.Context, sub types.Subject, obj types.Object) (types.Action, error) {
 	authz.RLock()
 	defer authz.RUnlock()
 
-	return authz.authz.PermittedActions(sub, obj)
+	return authz.authz.PermittedActionsContext(ctx, sub, obj)
+}
+
+// shallMany checks permissions in a batch, with the read lock held through the batch
+func (authz *syncedAuthorizer) shallMany(ctx context.Context, sub types.Subject, checks []types.ObjectAction) ([]bool, error) {
+	authz.RLock()
+	defer authz.RUnlock()
+
+	return shallManyOf(ctx, authz.authz, sub, checks)
+}
+
+// policies lists all polices assigned directly
+func (authz *syncedAuthorizer) policies() (types.Policies, error) {
+	authz.RLock()
+	defer authz.RUnlock()
+
+	return policiesOf(authz.authz)
+}
+
+// snapshotAt returns a read-only authorizer, which decides with polices as they were at the given time
+func (authz *syncedAuthorizer) snapshotAt(t time.Time) (types.AuthorizerContext, error) {
+	return snapshotOf(authz.authz, t)
+}
+
+// decisionCacheStats returns statistics of the decision cache
+func (authz *syncedAuthorizer) decisionCacheStats() (types.DecisionCacheStats, error) {
+	return decisionCacheStatsOf(authz.authz)
 }
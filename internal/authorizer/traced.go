@@ -0,0 +1,200 @@
+package authorizer
+
+import (
+	"context"
+	"time"
+
+	"github.com/supremind/rbac/trace"
+	"github.com/supremind/rbac/types"
+)
+
+var _ types.AuthorizerContext = (*tracedAuthorizer)(nil)
+
+// tracedAuthorizer starts spans around all operations
+type tracedAuthorizer struct {
+	types.AuthorizerContext
+	tracer types.Tracer
+}
+
+func newTracedAuthorizer(authz types.AuthorizerContext, t types.Tracer) *tracedAuthorizer {
+	return &tracedAuthorizer{
+		AuthorizerContext: authz,
+		tracer:            t,
+	}
+}
+
+func subjectAttrs(sub types.Subject, role types.Role) []types.Attribute {
+	return []types.Attribute{
+		types.Attr(trace.AttrEntity, sub.String()),
+		types.Attr(trace.AttrGroup, role.String()),
+	}
+}
+
+func objectAttrs(obj types.Object, cat types.Category) []types.Attribute {
+	return []types.Attribute{
+		types.Attr(trace.AttrEntity, obj.String()),
+		types.Attr(trace.AttrGroup, cat.String()),
+	}
+}
+
+func permissionAttrs(sub types.Subject, obj types.Object, act types.Action) []types.Attribute {
+	return []types.Attribute{
+		types.Attr(trace.AttrSubject, sub.String()),
+		types.Attr(trace.AttrObject, obj.String()),
+		types.Attr(trace.AttrAction, act.String()),
+	}
+}
+
+// SubjectJoinContext joins a user or a sub role to a role
+func (a *tracedAuthorizer) SubjectJoinContext(ctx context.Context, sub types.Subject, role types.Role) error {
+	ctx, span := a.tracer.Start(ctx, "rbac.SubjectJoin", subjectAttrs(sub, role)...)
+	e := a.AuthorizerContext.SubjectJoinContext(ctx, sub, role)
+	span.End(e)
+	return e
+}
+
+// SubjectLeaveContext removes a user or a sub role from a role
+func (a *tracedAuthorizer) SubjectLeaveContext(ctx context.Context, sub types.Subject, role types.Role) error {
+	ctx, span := a.tracer.Start(ctx, "rbac.SubjectLeave", subjectAttrs(sub, role)...)
+	e := a.AuthorizerContext.SubjectLeaveContext(ctx, sub, role)
+	span.End(e)
+	return e
+}
+
+// RemoveUserContext removes a user and all policies about it
+func (a *tracedAuthorizer) RemoveUserContext(ctx context.Context, user types.User) error {
+	ctx, span := a.tracer.Start(ctx, "rbac.RemoveUser", types.Attr(trace.AttrEntity, user.String()))
+	e := a.AuthorizerContext.RemoveUserContext(ctx, user)
+	span.End(e)
+	return e
+}
+
+// RemoveRoleContext removes a role and all policies about it
+func (a *tracedAuthorizer) RemoveRoleContext(ctx context.Context, role types.Role) error {
+	ctx, span := a.tracer.Start(ctx, "rbac.RemoveRole", types.Attr(trace.AttrGroup, role.String()))
+	e := a.AuthorizerContext.RemoveRoleContext(ctx, role)
+	span.End(e)
+	return e
+}
+
+// ObjectJoinContext joins an article or a sub category to a category
+func (a *tracedAuthorizer) ObjectJoinContext(ctx context.Context, obj types.Object, cat types.Category) error {
+	ctx, span := a.tracer.Start(ctx, "rbac.ObjectJoin", objectAttrs(obj, cat)...)
+	e := a.AuthorizerContext.ObjectJoinContext(ctx, obj, cat)
+	span.End(e)
+	return e
+}
+
+// ObjectLeaveContext removes an article or a sub category from a category
+func (a *tracedAuthorizer) ObjectLeaveContext(ctx context.Context, obj types.Object, cat types.Category) error {
+	ctx, span := a.tracer.Start(ctx, "rbac.ObjectLeave", objectAttrs(obj, cat)...)
+	e := a.AuthorizerContext.ObjectLeaveContext(ctx, obj, cat)
+	span.End(e)
+	return e
+}
+
+// RemoveArticleContext removes an article and all polices about it
+func (a *tracedAuthorizer) RemoveArticleContext(ctx context.Context, art types.Article) error {
+	ctx, span := a.tracer.Start(ctx, "rbac.RemoveArticle", types.Attr(trace.AttrEntity, art.String()))
+	e := a.AuthorizerContext.RemoveArticleContext(ctx, art)
+	span.End(e)
+	return e
+}
+
+// RemoveCategoryContext removes a category and all polices about it
+func (a *tracedAuthorizer) RemoveCategoryContext(ctx context.Context, cat types.Category) error {
+	ctx, span := a.tracer.Start(ctx, "rbac.RemoveCategory", types.Attr(trace.AttrGroup, cat.String()))
+	e := a.AuthorizerContext.RemoveCategoryContext(ctx, cat)
+	span.End(e)
+	return e
+}
+
+// PermitContext permits subject to perform action on object
+func (a *tracedAuthorizer) PermitContext(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) error {
+	ctx, span := a.tracer.Start(ctx, "rbac.Permit", permissionAttrs(sub, obj, act)...)
+	e := a.AuthorizerContext.PermitContext(ctx, sub, obj, act)
+	span.End(e)
+	return e
+}
+
+// RevokeContext revokes permission for subject to perform action on object
+func (a *tracedAuthorizer) RevokeContext(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) error {
+	ctx, span := a.tracer.Start(ctx, "rbac.Revoke", permissionAttrs(sub, obj, act)...)
+	e := a.AuthorizerContext.RevokeContext(ctx, sub, obj, act)
+	span.End(e)
+	return e
+}
+
+// ShallContext tells if subject shall perform action on object
+func (a *tracedAuthorizer) ShallContext(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) (bool, error) {
+	ctx, span := a.tracer.Start(ctx, "rbac.Shall", permissionAttrs(sub, obj, act)...)
+	allowed, e := a.AuthorizerContext.ShallContext(ctx, sub, obj, act)
+	span.SetAttributes(types.Attr(trace.AttrAllowed, allowed))
+	span.End(e)
+	return allowed, e
+}
+
+// shallMany checks permissions in a batch, in one span
+func (a *tracedAuthorizer) shallMany(ctx context.Context, sub types.Subject, checks []types.ObjectAction) ([]bool, error) {
+	ctx, span := a.tracer.Start(ctx, "rbac.ShallMany",
+		types.Attr(trace.AttrSubject, sub.String()),
+		types.Attr(trace.AttrChecks, len(checks)),
+	)
+	results, e := shallManyOf(ctx, a.AuthorizerContext, sub, checks)
+	allowances := 0
+	for _, allowed := range results {
+		if allowed {
+			allowances++
+		}
+	}
+	span.SetAttributes(types.Attr(trace.AttrAllowances, allowances))
+	span.End(e)
+	return results, e
+}
+
+// PermissionsOnContext returns permissions on object for all subjects
+func (a *tracedAuthorizer) PermissionsOnContext(ctx context.Context, obj types.Object) (map[types.Subject]types.Action, error) {
+	ctx, span := a.tracer.Start(ctx, "rbac.PermissionsOn", types.Attr(trace.AttrObject, obj.String()))
+	perms, e := a.AuthorizerContext.PermissionsOnContext(ctx, obj)
+	span.End(e)
+	return perms, e
+}
+
+// PermissionsForContext returns permissions for subject on all objects
+func (a *tracedAuthorizer) PermissionsForContext(ctx context.Context, sub types.Subject) (map[types.Object]types.Action, error) {
+	ctx, span := a.tracer.Start(ctx, "rbac.PermissionsFor", types.Attr(trace.AttrSubject, sub.String()))
+	perms, e := a.AuthorizerContext.PermissionsForContext(ctx, sub)
+	span.End(e)
+	return perms, e
+}
+
+// PermittedActionsContext returns permitted actions for subject on object
+func (a *tracedAuthorizer) PermittedActionsContext(ctx context.Context, sub types.Subject, obj types.Object) (types.Action, error) {
+	ctx, span := a.tracer.Start(ctx, "rbac.PermittedActions",
+		types.Attr(trace.AttrSubject, sub.String()),
+		types.Attr(trace.AttrObject, obj.String()),
+	)
+	act, e := a.AuthorizerContext.PermittedActionsContext(ctx, sub, obj)
+	span.End(e)
+	return act, e
+}
+
+// policies lists all polices assigned directly
+func (a *tracedAuthorizer) policies() (types.Policies, error) {
+	return policiesOf(a.AuthorizerContext)
+}
+
+// snapshotAt returns a read-only authorizer, which decides with polices as they were at the given time,
+// decisions made by it are traced too
+func (a *tracedAuthorizer) snapshotAt(t time.Time) (types.AuthorizerContext, error) {
+	at, e := snapshotOf(a.AuthorizerContext, t)
+	if e != nil {
+		return nil, e
+	}
+	return newTracedAuthorizer(at, a.tracer), nil
+}
+
+// decisionCacheStats returns statistics of the decision cache
+func (a *tracedAuthorizer) decisionCacheStats() (types.DecisionCacheStats, error) {
+	return decisionCacheStatsOf(a.AuthorizerContext)
+}
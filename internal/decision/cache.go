@@ -0,0 +1,178 @@
+package decision
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/supremind/rbac/types"
+)
+
+// Cache keeps the least recently used decisions, keyed by subject, object, and action.
+// A decision depends on its subject, its object, and groups they belong to, it is dropped when any of them
+// joins or leaves a group, or is removed, or when permissions between a subject and an object it depends on change.
+// Decisions made while polices are changing are not cached, see Epoch.
+// All methods are safe for concurrent use, and a nil *Cache caches nothing.
+type Cache struct {
+	capacity int
+	entries  map[key]*list.Element
+	lru      *list.List // of *entry, the most recently used at front
+	deps     map[types.Entity]map[*entry]struct{}
+	epoch    uint64 // increased by every change
+	stats    types.DecisionCacheStats
+	sync.Mutex
+}
+
+type key struct {
+	sub types.Subject
+	obj types.Object
+	act types.Action
+}
+
+type entry struct {
+	key
+	result types.Action
+	deps   []types.Entity
+}
+
+// New creates a Cache keeps up to capacity decisions
+func New(capacity int) *Cache {
+	return &Cache{
+		capacity: capacity,
+		entries:  make(map[key]*list.Element, capacity),
+		lru:      list.New(),
+		deps:     make(map[types.Entity]map[*entry]struct{}),
+	}
+}
+
+// Get returns the result cached for subject performing act on object
+func (c *Cache) Get(sub types.Subject, obj types.Object, act types.Action) (types.Action, bool) {
+	if c == nil {
+		return types.None, false
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	elem, ok := c.entries[key{sub: sub, obj: obj, act: act}]
+	if !ok {
+		c.stats.Misses++
+		return types.None, false
+	}
+	c.stats.Hits++
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*entry).result, true
+}
+
+// Epoch returns the current epoch, it should be taken before making a decision to be cached
+func (c *Cache) Epoch() uint64 {
+	if c == nil {
+		return 0
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	return c.epoch
+}
+
+// Put caches the result of subject performing act on object, which depends on deps.
+// It is ignored if polices changed since epoch, because the result may be made with some of them outdated.
+func (c *Cache) Put(epoch uint64, sub types.Subject, obj types.Object, act types.Action, result types.Action, deps []types.Entity) {
+	if c == nil || c.capacity <= 0 {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if epoch != c.epoch {
+		return
+	}
+
+	k := key{sub: sub, obj: obj, act: act}
+	if elem, ok := c.entries[k]; ok {
+		c.remove(elem.Value.(*entry))
+	}
+
+	ent := &entry{key: k, result: result, deps: deps}
+	c.entries[k] = c.lru.PushFront(ent)
+	for _, dep := range deps {
+		dependents, ok := c.deps[dep]
+		if !ok {
+			dependents = make(map[*entry]struct{})
+			c.deps[dep] = dependents
+		}
+		dependents[ent] = struct{}{}
+	}
+
+	for c.lru.Len() > c.capacity {
+		c.remove(c.lru.Back().Value.(*entry))
+		c.stats.Evictions++
+	}
+}
+
+// GroupingChanged drops decisions depending on ent, after it joined or left a group, or was removed
+func (c *Cache) GroupingChanged(ent types.Entity) {
+	if c == nil {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	c.epoch++
+	for dependent := range c.deps[ent] {
+		c.remove(dependent)
+		c.stats.Invalidations++
+	}
+}
+
+// PermissionChanged drops decisions depending on both sub and obj, after permissions between them changed
+func (c *Cache) PermissionChanged(sub types.Subject, obj types.Object) {
+	if c == nil {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	c.epoch++
+	subDependents, objDependents := c.deps[sub], c.deps[obj]
+	if len(objDependents) < len(subDependents) {
+		subDependents, objDependents = objDependents, subDependents
+	}
+	for dependent := range subDependents {
+		if _, ok := objDependents[dependent]; ok {
+			c.remove(dependent)
+			c.stats.Invalidations++
+		}
+	}
+}
+
+// Stats returns statistics of the cache
+func (c *Cache) Stats() types.DecisionCacheStats {
+	c.Lock()
+	defer c.Unlock()
+
+	stats := c.stats
+	stats.Size = c.lru.Len()
+	return stats
+}
+
+// remove an entry, it must be called with lock held
+func (c *Cache) remove(ent *entry) {
+	elem, ok := c.entries[ent.key]
+	if !ok || elem.Value.(*entry) != ent {
+		return
+	}
+	delete(c.entries, ent.key)
+	c.lru.Remove(elem)
+
+	for _, dep := range ent.deps {
+		dependents := c.deps[dep]
+		delete(dependents, ent)
+		if len(dependents) == 0 {
+			delete(c.deps, dep)
+		}
+	}
+}
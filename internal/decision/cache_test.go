@@ -0,0 +1,85 @@
+package decision
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/supremind/rbac/types"
+)
+
+func TestDecision(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "decision test suit")
+}
+
+var _ = Describe("decision cache", func() {
+	var c *Cache
+
+	BeforeEach(func() {
+		c = New(3)
+	})
+
+	put := func(sub Subject, obj Object, act Action, deps ...Entity) {
+		c.Put(c.Epoch(), sub, obj, act, act, append([]Entity{sub, obj}, deps...))
+	}
+	cached := func(sub Subject, obj Object, act Action) bool {
+		_, ok := c.Get(sub, obj, act)
+		return ok
+	}
+
+	It("should evict the least recently used", func() {
+		put(User("alan"), Article("apollo"), Read)
+		put(User("alan"), Article("gemini"), Read)
+		put(User("bob"), Article("apollo"), Read)
+		Expect(cached(User("alan"), Article("apollo"), Read)).To(BeTrue())
+
+		put(User("bob"), Article("gemini"), Read)
+		Expect(cached(User("alan"), Article("gemini"), Read)).To(BeFalse())
+		Expect(cached(User("alan"), Article("apollo"), Read)).To(BeTrue())
+		Expect(c.Stats()).To(Equal(DecisionCacheStats{Size: 3, Hits: 2, Misses: 1, Evictions: 1}))
+	})
+
+	It("should drop decisions depending on changed groupings", func() {
+		put(User("alan"), Article("apollo"), Read, Role("editor"), Category("history"))
+		put(User("bob"), Article("apollo"), Read, Role("reader"), Category("history"))
+		put(User("bob"), Article("gemini"), Read, Role("reader"))
+
+		c.GroupingChanged(Role("editor"))
+		Expect(cached(User("alan"), Article("apollo"), Read)).To(BeFalse())
+		Expect(cached(User("bob"), Article("apollo"), Read)).To(BeTrue())
+
+		c.GroupingChanged(Category("history"))
+		Expect(cached(User("bob"), Article("apollo"), Read)).To(BeFalse())
+		Expect(cached(User("bob"), Article("gemini"), Read)).To(BeTrue())
+		Expect(c.Stats().Invalidations).To(BeEquivalentTo(2))
+	})
+
+	It("should drop decisions depending on both sides of changed permissions", func() {
+		put(User("alan"), Article("apollo"), Read, Role("editor"), Category("history"))
+		put(User("alan"), Article("gemini"), Read, Role("editor"), Category("science"))
+		put(User("bob"), Article("skylab"), Read, Role("editor"), Category("history"))
+
+		c.PermissionChanged(Role("editor"), Category("history"))
+		Expect(cached(User("alan"), Article("apollo"), Read)).To(BeFalse())
+		Expect(cached(User("alan"), Article("gemini"), Read)).To(BeTrue())
+		Expect(cached(User("bob"), Article("skylab"), Read)).To(BeFalse())
+	})
+
+	It("should not cache decisions made before changes", func() {
+		epoch := c.Epoch()
+		c.PermissionChanged(User("alan"), Article("apollo"))
+		c.Put(epoch, User("alan"), Article("apollo"), Read, Read, []Entity{User("alan"), Article("apollo")})
+		Expect(cached(User("alan"), Article("apollo"), Read)).To(BeFalse())
+	})
+
+	It("should cache nothing if nil", func() {
+		var c *Cache
+		c.Put(c.Epoch(), User("alan"), Article("apollo"), Read, Read, nil)
+		c.GroupingChanged(User("alan"))
+		c.PermissionChanged(User("alan"), Article("apollo"))
+		_, ok := c.Get(User("alan"), Article("apollo"), Read)
+		Expect(ok).To(BeFalse())
+	})
+})
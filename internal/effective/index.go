@@ -0,0 +1,289 @@
+package effective
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/supremind/rbac/types"
+)
+
+// Index materializes effective actions of users on articles, including ones through roles and categories,
+// so that they are looked up at once, instead of being evaluated through all pairs of roles and categories.
+// A user is materialized as a row when it is queried for the first time, rows are kept up to date
+// as groupings and permission change, until they are evicted as the least recently used,
+// to keep the number of cells, user and article pairs with any action permitted, within the budget.
+// All methods are safe for concurrent use.
+type Index struct {
+	budget     int
+	sg, og     types.GroupingReader
+	p          types.Permission
+	rows       map[types.User]*list.Element
+	lru        *list.List // of *row, the most recently used at front
+	cells      int        // number of cells in all rows
+	dependents map[types.Group]map[*row]struct{}
+	sync.Mutex
+}
+
+type row struct {
+	user    types.User
+	roles   map[types.Group]struct{}
+	actions map[types.Article]types.Action
+}
+
+// New creates an Index holds no more than budget cells
+func New(budget int) *Index {
+	return &Index{
+		budget:     budget,
+		rows:       make(map[types.User]*list.Element),
+		lru:        list.New(),
+		dependents: make(map[types.Group]map[*row]struct{}),
+	}
+}
+
+// Bind the index to groupings and permission it materializes, which could be nil if not used.
+// It must be called before queries, changes before binding are ignored, since nothing is materialized yet.
+func (x *Index) Bind(sg, og types.GroupingReader, p types.Permission) {
+	x.Lock()
+	defer x.Unlock()
+
+	x.sg, x.og, x.p = sg, og, p
+}
+
+// PermittedActions returns effective actions of user on article,
+// ok is false if the user could not be materialized within the budget
+func (x *Index) PermittedActions(user types.User, art types.Article) (act types.Action, ok bool, err error) {
+	x.Lock()
+	defer x.Unlock()
+
+	if elem, ok := x.rows[user]; ok {
+		x.lru.MoveToFront(elem)
+		return elem.Value.(*row).actions[art], true, nil
+	}
+
+	r, e := x.materialize(user)
+	if e != nil || r == nil {
+		return types.None, false, e
+	}
+	return r.actions[art], true, nil
+}
+
+// GroupingChanged updates rows depending on ent, after it joined or left a group, or was removed
+func (x *Index) GroupingChanged(ent types.Entity) {
+	x.Lock()
+	defer x.Unlock()
+
+	switch ent := ent.(type) {
+	case types.User:
+		if elem, ok := x.rows[ent]; ok {
+			x.rebuild(elem.Value.(*row))
+		}
+	case types.Role:
+		for _, r := range x.dependentRows(ent) {
+			x.rebuild(r)
+		}
+	case types.Article:
+		x.refresh(x.allRows(), map[types.Member]struct{}{ent: {}})
+	case types.Category:
+		x.refresh(x.allRows(), x.articlesIn(ent))
+	}
+}
+
+// PermissionChanged updates cells depending on permissions between sub and obj, after they changed
+func (x *Index) PermissionChanged(sub types.Subject, obj types.Object) {
+	x.Lock()
+	defer x.Unlock()
+
+	var rows []*row
+	switch sub := sub.(type) {
+	case types.User:
+		if elem, ok := x.rows[sub]; ok {
+			rows = append(rows, elem.Value.(*row))
+		}
+	case types.Role:
+		rows = x.dependentRows(sub)
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	switch obj := obj.(type) {
+	case types.Article:
+		x.refresh(rows, map[types.Member]struct{}{obj: {}})
+	case types.Category:
+		x.refresh(rows, x.articlesIn(obj))
+	}
+}
+
+// materialize a row for user, it returns nil if the row is larger than the budget
+func (x *Index) materialize(user types.User) (*row, error) {
+	if x.p == nil {
+		return nil, nil
+	}
+
+	r := &row{user: user, roles: make(map[types.Group]struct{}), actions: make(map[types.Article]types.Action)}
+	if x.sg != nil {
+		roles, e := x.sg.GroupsOf(user)
+		if e != nil {
+			return nil, e
+		}
+		// copied, since groupings may change the returned map
+		for role := range roles {
+			r.roles[role] = struct{}{}
+		}
+	}
+
+	subjects := []types.Subject{user}
+	for role := range r.roles {
+		subjects = append(subjects, role.(types.Role))
+	}
+	for _, sub := range subjects {
+		perms, e := x.p.PermissionsFor(sub)
+		if e != nil {
+			return nil, e
+		}
+		for obj, act := range perms {
+			switch obj := obj.(type) {
+			case types.Article:
+				r.actions[obj] |= act
+			case types.Category:
+				for art := range x.articlesIn(obj) {
+					r.actions[art.(types.Article)] |= act
+				}
+			}
+			if len(r.actions) > x.budget {
+				return nil, nil
+			}
+		}
+	}
+
+	x.add(r)
+	return r, nil
+}
+
+// rebuild a row after roles of its user changed, if it is not evicted yet,
+// it is left to be materialized when queried next time if failed
+func (x *Index) rebuild(r *row) {
+	if x.remove(r) {
+		x.materialize(r.user)
+	}
+}
+
+// refresh cells of articles in rows
+func (x *Index) refresh(rows []*row, arts map[types.Member]struct{}) {
+	for _, r := range rows {
+		for art := range arts {
+			act, e := x.evaluate(r, art.(types.Article))
+			if e != nil {
+				x.remove(r)
+				break
+			}
+			before := len(r.actions)
+			if act == types.None {
+				delete(r.actions, art.(types.Article))
+			} else {
+				r.actions[art.(types.Article)] = act
+			}
+			x.cells += len(r.actions) - before
+		}
+	}
+	x.evict()
+}
+
+// evaluate effective actions of the user of r on art, through all pairs of its roles and categories
+func (x *Index) evaluate(r *row, art types.Article) (types.Action, error) {
+	subjects := []types.Subject{r.user}
+	for role := range r.roles {
+		subjects = append(subjects, role.(types.Role))
+	}
+	objects := []types.Object{art}
+	if x.og != nil {
+		cats, e := x.og.GroupsOf(art)
+		if e != nil {
+			return types.None, e
+		}
+		for cat := range cats {
+			objects = append(objects, cat.(types.Category))
+		}
+	}
+
+	var act types.Action
+	for _, sub := range subjects {
+		for _, obj := range objects {
+			allowed, e := x.p.PermittedActions(sub, obj)
+			if e != nil {
+				return types.None, e
+			}
+			act |= allowed
+		}
+	}
+	return act, nil
+}
+
+// articlesIn returns articles in cat and its sub categories
+func (x *Index) articlesIn(cat types.Category) map[types.Member]struct{} {
+	if x.og == nil {
+		return nil
+	}
+	members, e := x.og.MembersIn(cat)
+	if e != nil {
+		return nil
+	}
+	return members
+}
+
+// dependentRows returns rows of users in role, collected so that they could be rebuilt meanwhile
+func (x *Index) dependentRows(role types.Role) []*row {
+	rows := make([]*row, 0, len(x.dependents[role]))
+	for r := range x.dependents[role] {
+		rows = append(rows, r)
+	}
+	return rows
+}
+
+func (x *Index) allRows() []*row {
+	rows := make([]*row, 0, x.lru.Len())
+	for elem := x.lru.Front(); elem != nil; elem = elem.Next() {
+		rows = append(rows, elem.Value.(*row))
+	}
+	return rows
+}
+
+func (x *Index) add(r *row) {
+	x.rows[r.user] = x.lru.PushFront(r)
+	x.cells += len(r.actions)
+	for role := range r.roles {
+		dependents, ok := x.dependents[role]
+		if !ok {
+			dependents = make(map[*row]struct{})
+			x.dependents[role] = dependents
+		}
+		dependents[r] = struct{}{}
+	}
+	x.evict()
+}
+
+// remove r, it returns false if r is not in the index
+func (x *Index) remove(r *row) bool {
+	elem, ok := x.rows[r.user]
+	if !ok || elem.Value.(*row) != r {
+		return false
+	}
+	delete(x.rows, r.user)
+	x.lru.Remove(elem)
+	x.cells -= len(r.actions)
+	for role := range r.roles {
+		dependents := x.dependents[role]
+		delete(dependents, r)
+		if len(dependents) == 0 {
+			delete(x.dependents, role)
+		}
+	}
+	return true
+}
+
+// evict the least recently used rows until cells are within the budget
+func (x *Index) evict() {
+	for x.cells > x.budget {
+		x.remove(x.lru.Back().Value.(*row))
+	}
+}
@@ -0,0 +1,98 @@
+package effective
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/supremind/rbac/internal/grouping"
+	"github.com/supremind/rbac/internal/permission"
+	"github.com/supremind/rbac/persist"
+	"github.com/supremind/rbac/persist/fake"
+	. "github.com/supremind/rbac/types"
+)
+
+func TestEffective(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "effective test suit")
+}
+
+var _ = Describe("effective index", func() {
+	var (
+		x      *Index
+		sg, og Grouping
+		p      Permission
+	)
+
+	newIndex := func(budget int) {
+		x = New(budget)
+		var e error
+		sg, e = grouping.New(context.Background(), persist.GroupingWithContext(fake.NewGroupingPersister()), logr.Discard(), grouping.Config{Observers: []grouping.Observer{x}})
+		Expect(e).To(Succeed())
+		og, e = grouping.New(context.Background(), persist.GroupingWithContext(fake.NewGroupingPersister()), logr.Discard(), grouping.Config{Observers: []grouping.Observer{x}})
+		Expect(e).To(Succeed())
+		p, e = permission.New(context.Background(), persist.PermissionWithContext(fake.NewPermissionPersister()), logr.Discard(), permission.Config{Observers: []permission.Observer{x}})
+		Expect(e).To(Succeed())
+		x.Bind(sg, og, p)
+
+		Expect(sg.Join(User("alan"), Role("editor"))).To(Succeed())
+		Expect(og.Join(Article("apollo"), Category("history"))).To(Succeed())
+		Expect(og.Join(Article("gemini"), Category("history"))).To(Succeed())
+		Expect(p.Permit(Role("editor"), Category("history"), Read)).To(Succeed())
+	}
+
+	lookup := func(user User, art Article) Action {
+		act, ok, e := x.PermittedActions(user, art)
+		Expect(e).To(Succeed())
+		Expect(ok).To(BeTrue())
+		return act
+	}
+
+	It("should materialize users when queried", func() {
+		newIndex(10)
+		Expect(x.rows).To(BeEmpty())
+		Expect(lookup(User("alan"), Article("apollo"))).To(Equal(Read))
+		Expect(lookup(User("bob"), Article("apollo"))).To(Equal(None))
+		Expect(x.rows).To(HaveLen(2))
+		Expect(x.cells).To(Equal(2))
+	})
+
+	It("should update cells as polices change", func() {
+		newIndex(10)
+		Expect(lookup(User("alan"), Article("apollo"))).To(Equal(Read))
+
+		Expect(p.Permit(User("alan"), Article("apollo"), Write)).To(Succeed())
+		Expect(lookup(User("alan"), Article("apollo"))).To(Equal(Read | Write))
+
+		Expect(og.Leave(Article("apollo"), Category("history"))).To(Succeed())
+		Expect(lookup(User("alan"), Article("apollo"))).To(Equal(Write))
+		Expect(x.cells).To(Equal(2))
+
+		Expect(sg.RemoveGroup(Role("editor"))).To(Succeed())
+		Expect(lookup(User("alan"), Article("gemini"))).To(Equal(None))
+		Expect(x.cells).To(Equal(1))
+	})
+
+	It("should keep cells within the budget", func() {
+		newIndex(2)
+		Expect(sg.Join(User("bob"), Role("editor"))).To(Succeed())
+
+		Expect(lookup(User("alan"), Article("apollo"))).To(Equal(Read))
+		Expect(lookup(User("bob"), Article("apollo"))).To(Equal(Read))
+		Expect(x.rows).To(HaveKey(User("bob")))
+		Expect(x.rows).NotTo(HaveKey(User("alan")))
+
+		Expect(og.Join(Article("skylab"), Category("history"))).To(Succeed())
+		_, ok, e := x.PermittedActions(User("carl"), Article("apollo"))
+		Expect(e).To(Succeed())
+		Expect(ok).To(BeTrue())
+		Expect(sg.Join(User("carl"), Role("editor"))).To(Succeed())
+		Expect(x.rows).NotTo(HaveKey(User("carl")))
+		_, ok, e = x.PermittedActions(User("carl"), Article("apollo"))
+		Expect(e).To(Succeed())
+		Expect(ok).To(BeFalse())
+	})
+})
@@ -0,0 +1,271 @@
+package grouping
+
+import (
+	"fmt"
+
+	"github.com/supremind/rbac/internal/intern"
+	"github.com/supremind/rbac/types"
+)
+
+var _ grouping = (*compactGrouping)(nil)
+
+// compactGrouping keeps polices as interned ids in sorted slices, instead of maps of entities.
+// Only immediate polices and groups above each group are kept, groups of members and members in groups
+// are expanded when queried, since there are much more members than groups.
+// compactGrouping takes a fraction of the memory of fatGrouping, and is slower on querying members in groups.
+type compactGrouping struct {
+	ids      *intern.Table
+	parents  []intern.Set          // by id of entities, groups they immediately belong to
+	children map[uint32]intern.Set // by id of groups, entities immediately belong to them
+	upward   map[uint32]intern.Set // by id of groups, all groups above them
+}
+
+func newCompactGrouping() *compactGrouping {
+	return &compactGrouping{
+		ids:      intern.NewTable(),
+		children: make(map[uint32]intern.Set),
+		upward:   make(map[uint32]intern.Set),
+	}
+}
+
+func (g *compactGrouping) intern(ent types.Entity) uint32 {
+	id := g.ids.Intern(ent)
+	for len(g.parents) < g.ids.Cap() {
+		g.parents = append(g.parents, nil)
+	}
+	return id
+}
+
+func (g *compactGrouping) Join(ent types.Entity, group types.Group) error {
+	e, grp := g.intern(ent), g.intern(group)
+	g.parents[e] = g.parents[e].Add(grp)
+	g.children[grp] = g.children[grp].Add(e)
+
+	if _, ok := ent.(types.Group); ok {
+		g.rebuildUpward(e)
+	}
+	return nil
+}
+
+func (g *compactGrouping) Leave(ent types.Entity, group types.Group) error {
+	e, ok := g.ids.Lookup(ent)
+	if !ok {
+		return fmt.Errorf("%w: grouping policy: %s -> %s", types.ErrNotFound, ent, group)
+	}
+	grp, ok := g.ids.Lookup(group)
+	if !ok || !g.parents[e].Has(grp) {
+		return fmt.Errorf("%w: grouping policy: %s -> %s", types.ErrNotFound, ent, group)
+	}
+
+	g.parents[e] = g.parents[e].Remove(grp)
+	g.children[grp] = g.children[grp].Remove(e)
+
+	if _, ok := ent.(types.Group); ok {
+		g.rebuildUpward(e)
+	}
+	return nil
+}
+
+// rebuildUpward rebuilds groups above sub and all groups under it
+func (g *compactGrouping) rebuildUpward(sub uint32) {
+	visited := map[uint32]struct{}{sub: {}}
+	lowers := []uint32{sub}
+	for len(lowers) > 0 {
+		curr := lowers[0]
+		lowers = lowers[1:]
+
+		g.upward[curr] = g.groupsAbove(curr)
+
+		for _, lower := range g.children[curr] {
+			if _, ok := visited[lower]; ok {
+				continue
+			}
+			if _, ok := g.ids.Entity(lower).(types.Group); ok {
+				visited[lower] = struct{}{}
+				lowers = append(lowers, lower)
+			}
+		}
+	}
+}
+
+// groupsAbove walks through parents of group, instead of using groups above its parents,
+// so that groups could be rebuilt in any order
+func (g *compactGrouping) groupsAbove(group uint32) intern.Set {
+	var uppers intern.Set
+	queue := []uint32{group}
+	for len(queue) > 0 {
+		curr := queue[0]
+		queue = queue[1:]
+
+		for _, upper := range g.parents[curr] {
+			if !uppers.Has(upper) {
+				uppers = uppers.Add(upper)
+				queue = append(queue, upper)
+			}
+		}
+	}
+	return uppers
+}
+
+func (g *compactGrouping) IsIn(member types.Member, group types.Group) (bool, error) {
+	m, ok := g.ids.Lookup(member)
+	if !ok {
+		return false, nil
+	}
+	grp, ok := g.ids.Lookup(group)
+	if !ok {
+		return false, nil
+	}
+
+	for _, parent := range g.parents[m] {
+		if parent == grp || g.upward[parent].Has(grp) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (g *compactGrouping) AllGroups() (map[types.Group]struct{}, error) {
+	groups := make(map[types.Group]struct{}, len(g.children))
+	g.ids.Range(func(_ uint32, ent types.Entity) bool {
+		if group, ok := ent.(types.Group); ok {
+			groups[group] = struct{}{}
+		}
+		return true
+	})
+	return groups, nil
+}
+
+func (g *compactGrouping) AllMembers() (map[types.Member]struct{}, error) {
+	members := make(map[types.Member]struct{}, g.ids.Len()-len(g.children))
+	g.ids.Range(func(_ uint32, ent types.Entity) bool {
+		if member, ok := ent.(types.Member); ok {
+			members[member] = struct{}{}
+		}
+		return true
+	})
+	return members, nil
+}
+
+func (g *compactGrouping) GroupsOf(ent types.Entity) (map[types.Group]struct{}, error) {
+	groups := make(map[types.Group]struct{})
+	e, ok := g.ids.Lookup(ent)
+	if !ok {
+		return groups, nil
+	}
+
+	if _, ok := ent.(types.Group); ok {
+		for _, upper := range g.upward[e] {
+			groups[g.ids.Entity(upper).(types.Group)] = struct{}{}
+		}
+		return groups, nil
+	}
+
+	for _, parent := range g.parents[e] {
+		groups[g.ids.Entity(parent).(types.Group)] = struct{}{}
+		for _, upper := range g.upward[parent] {
+			groups[g.ids.Entity(upper).(types.Group)] = struct{}{}
+		}
+	}
+	return groups, nil
+}
+
+func (g *compactGrouping) MembersIn(group types.Group) (map[types.Member]struct{}, error) {
+	members := make(map[types.Member]struct{})
+	grp, ok := g.ids.Lookup(group)
+	if !ok {
+		return members, nil
+	}
+
+	visited := map[uint32]struct{}{grp: {}}
+	groups := []uint32{grp}
+	for len(groups) > 0 {
+		curr := groups[0]
+		groups = groups[1:]
+
+		for _, child := range g.children[curr] {
+			switch ent := g.ids.Entity(child).(type) {
+			case types.Member:
+				members[ent] = struct{}{}
+			case types.Group:
+				if _, ok := visited[child]; !ok {
+					visited[child] = struct{}{}
+					groups = append(groups, child)
+				}
+			}
+		}
+	}
+	return members, nil
+}
+
+func (g *compactGrouping) RemoveGroup(group types.Group) error {
+	grp, ok := g.ids.Lookup(group)
+	if !ok {
+		return nil
+	}
+
+	var subs []uint32
+	for _, child := range g.children[grp] {
+		g.parents[child] = g.parents[child].Remove(grp)
+		if _, ok := g.ids.Entity(child).(types.Group); ok {
+			subs = append(subs, child)
+		}
+	}
+	for _, parent := range g.parents[grp] {
+		g.children[parent] = g.children[parent].Remove(grp)
+	}
+	delete(g.children, grp)
+	delete(g.upward, grp)
+	g.parents[grp] = nil
+	g.ids.Release(grp)
+
+	for _, sub := range subs {
+		g.rebuildUpward(sub)
+	}
+	return nil
+}
+
+func (g *compactGrouping) RemoveMember(member types.Member) error {
+	m, ok := g.ids.Lookup(member)
+	if !ok {
+		return nil
+	}
+
+	for _, parent := range g.parents[m] {
+		g.children[parent] = g.children[parent].Remove(m)
+	}
+	g.parents[m] = nil
+	g.ids.Release(m)
+	return nil
+}
+
+func (g *compactGrouping) Policies() ([]types.GroupingPolicy, error) {
+	polices := make([]types.GroupingPolicy, 0, g.ids.Len())
+	g.ids.Range(func(id uint32, ent types.Entity) bool {
+		for _, parent := range g.parents[id] {
+			polices = append(polices, types.GroupingPolicy{Entity: ent, Group: g.ids.Entity(parent).(types.Group)})
+		}
+		return true
+	})
+	return polices, nil
+}
+
+func (g *compactGrouping) immediateGroupsOf(ent types.Entity) (map[types.Group]struct{}, error) {
+	groups := make(map[types.Group]struct{})
+	if e, ok := g.ids.Lookup(ent); ok {
+		for _, parent := range g.parents[e] {
+			groups[g.ids.Entity(parent).(types.Group)] = struct{}{}
+		}
+	}
+	return groups, nil
+}
+
+func (g *compactGrouping) immediateEntitiesIn(group types.Group) (map[types.Entity]struct{}, error) {
+	entities := make(map[types.Entity]struct{})
+	if grp, ok := g.ids.Lookup(group); ok {
+		for _, child := range g.children[grp] {
+			entities[g.ids.Entity(child)] = struct{}{}
+		}
+	}
+	return entities, nil
+}
@@ -0,0 +1,378 @@
+package grouping
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/supremind/rbac/internal/hamt"
+	"github.com/supremind/rbac/internal/history"
+	"github.com/supremind/rbac/types"
+)
+
+var _ grouping = (*cowGrouping)(nil)
+
+// cowGrouping keeps grouping polices in immutable snapshots.
+// Writers build a new snapshot sharing unchanged parts with the current one, and swap it in atomically,
+// readers just load the current snapshot, they never block, and never see a half done change.
+type cowGrouping struct {
+	current    atomic.Value // *groupingSnapshot
+	history    *history.Log // optional, records every published snapshot
+	sync.Mutex              // serializes writers
+}
+
+func newCOWGrouping(h *history.Log) *cowGrouping {
+	g := &cowGrouping{history: h}
+	g.publish(&groupingSnapshot{})
+	return g
+}
+
+func (g *cowGrouping) publish(s *groupingSnapshot) {
+	g.current.Store(s)
+	if g.history != nil {
+		g.history.Record(s)
+	}
+}
+
+func (g *cowGrouping) load() *groupingSnapshot {
+	return g.current.Load().(*groupingSnapshot)
+}
+
+// update applies fn to a copy of the current snapshot, and publishes the copy if fn succeeded
+func (g *cowGrouping) update(fn func(*groupingSnapshot) error) error {
+	g.Lock()
+	defer g.Unlock()
+
+	next := *g.load()
+	if e := fn(&next); e != nil {
+		return e
+	}
+	g.publish(&next)
+	return nil
+}
+
+// At returns a read-only grouping as it was at the given time
+func (g *cowGrouping) At(t time.Time) (types.Grouping, error) {
+	if g.history == nil {
+		return nil, types.ErrNoHistory
+	}
+	s, ok := g.history.At(t)
+	if !ok {
+		return nil, fmt.Errorf("%w: grouping at %s", types.ErrNoHistory, t)
+	}
+	return frozenGrouping{s.(*groupingSnapshot)}, nil
+}
+
+// joinAll joins polices iterated by each to a copy of the current snapshot, and publishes them as a single version
+func (g *cowGrouping) joinAll(each func(join func(types.Entity, types.Group) error) error) error {
+	return g.update(func(s *groupingSnapshot) error {
+		return each(func(ent types.Entity, group types.Group) error {
+			s.join(ent, group)
+			return nil
+		})
+	})
+}
+
+// Frozen returns a read-only grouping of the current snapshot
+func (g *cowGrouping) Frozen() types.Grouping {
+	return frozenGrouping{g.load()}
+}
+
+// Join implements Grouping interface
+func (g *cowGrouping) Join(ent types.Entity, group types.Group) error {
+	return g.update(func(s *groupingSnapshot) error {
+		s.join(ent, group)
+		return nil
+	})
+}
+
+// Leave implements Grouping interface
+func (g *cowGrouping) Leave(ent types.Entity, group types.Group) error {
+	return g.update(func(s *groupingSnapshot) error {
+		return s.leave(ent, group)
+	})
+}
+
+// RemoveGroup implements Grouping interface
+func (g *cowGrouping) RemoveGroup(group types.Group) error {
+	return g.update(func(s *groupingSnapshot) error {
+		s.removeGroup(group)
+		return nil
+	})
+}
+
+// RemoveMember implements Grouping interface
+func (g *cowGrouping) RemoveMember(member types.Member) error {
+	return g.update(func(s *groupingSnapshot) error {
+		s.removeMember(member)
+		return nil
+	})
+}
+
+// IsIn implements Grouping interface
+func (g *cowGrouping) IsIn(member types.Member, group types.Group) (bool, error) {
+	return g.load().IsIn(member, group)
+}
+
+// AllGroups implements Grouping interface
+func (g *cowGrouping) AllGroups() (map[types.Group]struct{}, error) {
+	return g.load().AllGroups()
+}
+
+// AllMembers implements Grouping interface
+func (g *cowGrouping) AllMembers() (map[types.Member]struct{}, error) {
+	return g.load().AllMembers()
+}
+
+// GroupsOf implements Grouping interface
+func (g *cowGrouping) GroupsOf(ent types.Entity) (map[types.Group]struct{}, error) {
+	return g.load().GroupsOf(ent)
+}
+
+// MembersIn implements Grouping interface
+func (g *cowGrouping) MembersIn(group types.Group) (map[types.Member]struct{}, error) {
+	return g.load().MembersIn(group)
+}
+
+// Policies implements grouping interface
+func (g *cowGrouping) Policies() ([]types.GroupingPolicy, error) {
+	return g.load().Policies()
+}
+
+func (g *cowGrouping) immediateGroupsOf(ent types.Entity) (map[types.Group]struct{}, error) {
+	return g.load().immediateGroupsOf(ent)
+}
+
+func (g *cowGrouping) immediateEntitiesIn(group types.Group) (map[types.Entity]struct{}, error) {
+	return g.load().immediateEntitiesIn(group)
+}
+
+// groupingSnapshot is an immutable state of groupings once published,
+// copies of it share all the underlying data until being changed
+type groupingSnapshot struct {
+	parents     hamt.Map // entity => set of groups it immediately belongs to
+	children    hamt.Map // group => set of entities immediately belong to it
+	ancestors   hamt.Map // entity => set of all groups it belongs to
+	descendants hamt.Map // group => set of all entities belong to it
+}
+
+// frozenGrouping is a read-only grouping of a published snapshot
+type frozenGrouping struct {
+	*groupingSnapshot
+}
+
+func (frozenGrouping) Join(types.Entity, types.Group) error  { return types.ErrReadOnly }
+func (frozenGrouping) Leave(types.Entity, types.Group) error { return types.ErrReadOnly }
+func (frozenGrouping) RemoveGroup(types.Group) error         { return types.ErrReadOnly }
+func (frozenGrouping) RemoveMember(types.Member) error       { return types.ErrReadOnly }
+
+func setOf(m hamt.Map, ent types.Entity) hamt.Set {
+	if s, ok := m.Get(ent); ok {
+		return s.(hamt.Set)
+	}
+	return hamt.Set{}
+}
+
+func (s *groupingSnapshot) join(ent types.Entity, group types.Group) {
+	s.parents = s.parents.Set(ent, setOf(s.parents, ent).Add(group))
+	s.children = s.children.Set(group, setOf(s.children, group).Add(ent))
+
+	lowers := []types.Entity{ent}
+	if sub, ok := ent.(types.Group); ok {
+		setOf(s.descendants, sub).Range(func(lower types.Entity) bool {
+			lowers = append(lowers, lower)
+			return true
+		})
+	}
+	uppers := []types.Entity{group}
+	setOf(s.ancestors, group).Range(func(upper types.Entity) bool {
+		uppers = append(uppers, upper)
+		return true
+	})
+
+	for _, lower := range lowers {
+		ancestors := setOf(s.ancestors, lower)
+		for _, upper := range uppers {
+			ancestors = ancestors.Add(upper)
+		}
+		s.ancestors = s.ancestors.Set(lower, ancestors)
+	}
+	for _, upper := range uppers {
+		descendants := setOf(s.descendants, upper)
+		for _, lower := range lowers {
+			descendants = descendants.Add(lower)
+		}
+		s.descendants = s.descendants.Set(upper, descendants)
+	}
+}
+
+func (s *groupingSnapshot) leave(ent types.Entity, group types.Group) error {
+	parents := setOf(s.parents, ent)
+	if !parents.Has(group) {
+		return fmt.Errorf("%w: grouping policy: %s -> %s", types.ErrNotFound, ent, group)
+	}
+
+	s.parents = s.parents.Set(ent, parents.Remove(group))
+	s.children = s.children.Set(group, setOf(s.children, group).Remove(ent))
+	s.rebuildAncestors(ent)
+
+	return nil
+}
+
+func (s *groupingSnapshot) removeGroup(group types.Group) {
+	setOf(s.children, group).Range(func(child types.Entity) bool {
+		s.parents = s.parents.Set(child, setOf(s.parents, child).Remove(group))
+		return true
+	})
+	setOf(s.parents, group).Range(func(parent types.Entity) bool {
+		s.children = s.children.Set(parent, setOf(s.children, parent).Remove(group))
+		return true
+	})
+	s.children = s.children.Delete(group)
+	s.parents = s.parents.Delete(group)
+
+	s.rebuildAncestors(group)
+	s.ancestors = s.ancestors.Delete(group)
+	s.descendants = s.descendants.Delete(group)
+}
+
+func (s *groupingSnapshot) removeMember(member types.Member) {
+	setOf(s.parents, member).Range(func(parent types.Entity) bool {
+		s.children = s.children.Set(parent, setOf(s.children, parent).Remove(member))
+		return true
+	})
+	s.parents = s.parents.Delete(member)
+
+	setOf(s.ancestors, member).Range(func(upper types.Entity) bool {
+		s.descendants = s.descendants.Set(upper, setOf(s.descendants, upper).Remove(member))
+		return true
+	})
+	s.ancestors = s.ancestors.Delete(member)
+}
+
+// rebuildAncestors recomputes ancestors of the entity and all its descendants from immediate relationships,
+// it is used after relationships are removed, so the ancestors could only shrink
+func (s *groupingSnapshot) rebuildAncestors(ent types.Entity) {
+	lowers := []types.Entity{ent}
+	if group, ok := ent.(types.Group); ok {
+		setOf(s.descendants, group).Range(func(lower types.Entity) bool {
+			lowers = append(lowers, lower)
+			return true
+		})
+	}
+
+	for _, lower := range lowers {
+		lower := lower
+		ancestors := s.collectAncestors(lower)
+		setOf(s.ancestors, lower).Range(func(upper types.Entity) bool {
+			if !ancestors.Has(upper) {
+				s.descendants = s.descendants.Set(upper, setOf(s.descendants, upper).Remove(lower))
+			}
+			return true
+		})
+		s.ancestors = s.ancestors.Set(lower, ancestors)
+	}
+}
+
+func (s *groupingSnapshot) collectAncestors(ent types.Entity) hamt.Set {
+	var ancestors hamt.Set
+	queue := []types.Entity{ent}
+	for len(queue) > 0 {
+		curr := queue[0]
+		queue = queue[1:]
+
+		setOf(s.parents, curr).Range(func(parent types.Entity) bool {
+			if !ancestors.Has(parent) {
+				ancestors = ancestors.Add(parent)
+				queue = append(queue, parent)
+			}
+			return true
+		})
+	}
+	return ancestors
+}
+
+func (s *groupingSnapshot) IsIn(member types.Member, group types.Group) (bool, error) {
+	return setOf(s.ancestors, member).Has(group), nil
+}
+
+func (s *groupingSnapshot) AllGroups() (map[types.Group]struct{}, error) {
+	groups := make(map[types.Group]struct{}, s.children.Len())
+	s.children.Range(func(ent types.Entity, _ interface{}) bool {
+		groups[ent.(types.Group)] = struct{}{}
+		return true
+	})
+	s.parents.Range(func(ent types.Entity, _ interface{}) bool {
+		if group, ok := ent.(types.Group); ok {
+			groups[group] = struct{}{}
+		}
+		return true
+	})
+	return groups, nil
+}
+
+func (s *groupingSnapshot) AllMembers() (map[types.Member]struct{}, error) {
+	members := make(map[types.Member]struct{}, s.parents.Len())
+	s.parents.Range(func(ent types.Entity, _ interface{}) bool {
+		if member, ok := ent.(types.Member); ok {
+			members[member] = struct{}{}
+		}
+		return true
+	})
+	return members, nil
+}
+
+func (s *groupingSnapshot) GroupsOf(ent types.Entity) (map[types.Group]struct{}, error) {
+	ancestors := setOf(s.ancestors, ent)
+	groups := make(map[types.Group]struct{}, ancestors.Len())
+	ancestors.Range(func(upper types.Entity) bool {
+		groups[upper.(types.Group)] = struct{}{}
+		return true
+	})
+	return groups, nil
+}
+
+func (s *groupingSnapshot) MembersIn(group types.Group) (map[types.Member]struct{}, error) {
+	descendants := setOf(s.descendants, group)
+	members := make(map[types.Member]struct{}, descendants.Len())
+	descendants.Range(func(lower types.Entity) bool {
+		if member, ok := lower.(types.Member); ok {
+			members[member] = struct{}{}
+		}
+		return true
+	})
+	return members, nil
+}
+
+func (s *groupingSnapshot) Policies() ([]types.GroupingPolicy, error) {
+	polices := make([]types.GroupingPolicy, 0, s.parents.Len())
+	s.parents.Range(func(ent types.Entity, groups interface{}) bool {
+		groups.(hamt.Set).Range(func(group types.Entity) bool {
+			polices = append(polices, types.GroupingPolicy{Entity: ent, Group: group.(types.Group)})
+			return true
+		})
+		return true
+	})
+	return polices, nil
+}
+
+func (s *groupingSnapshot) immediateGroupsOf(ent types.Entity) (map[types.Group]struct{}, error) {
+	parents := setOf(s.parents, ent)
+	groups := make(map[types.Group]struct{}, parents.Len())
+	parents.Range(func(parent types.Entity) bool {
+		groups[parent.(types.Group)] = struct{}{}
+		return true
+	})
+	return groups, nil
+}
+
+func (s *groupingSnapshot) immediateEntitiesIn(group types.Group) (map[types.Entity]struct{}, error) {
+	children := setOf(s.children, group)
+	entities := make(map[types.Entity]struct{}, children.Len())
+	children.Range(func(child types.Entity) bool {
+		entities[child] = struct{}{}
+		return true
+	})
+	return entities, nil
+}
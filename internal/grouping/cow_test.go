@@ -0,0 +1,45 @@
+package grouping
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/supremind/rbac/internal/history"
+	"github.com/supremind/rbac/persist"
+	"github.com/supremind/rbac/persist/fake"
+	"github.com/supremind/rbac/types"
+)
+
+var _ = Describe("frozen copy-on-write grouping", func() {
+	It("should keep the state it is frozen at", func() {
+		g := newCOWGrouping(nil)
+		Expect(g.Join(types.User("alan"), types.Role("editor"))).To(Succeed())
+
+		frozen := g.Frozen()
+		Expect(g.Join(types.User("alan"), types.Role("author"))).To(Succeed())
+		Expect(g.Leave(types.User("alan"), types.Role("editor"))).To(Succeed())
+
+		Expect(frozen.GroupsOf(types.User("alan"))).To(Equal(map[types.Group]struct{}{types.Role("editor"): {}}))
+		Expect(g.GroupsOf(types.User("alan"))).To(Equal(map[types.Group]struct{}{types.Role("author"): {}}))
+		Expect(frozen.Join(types.User("bob"), types.Role("editor"))).To(MatchError(types.ErrReadOnly))
+	})
+
+	It("should record persisted polices as a single version", func() {
+		p := fake.NewGroupingPersister()
+		Expect(p.Insert(types.User("alan"), types.Role("editor"))).To(Succeed())
+		Expect(p.Insert(types.User("bob"), types.Role("editor"))).To(Succeed())
+		Expect(p.Insert(types.Role("editor"), types.Role("author"))).To(Succeed())
+
+		h := history.New(0, 0)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		g, e := New(ctx, persist.GroupingWithContext(p), logr.Discard(), Config{Component: "subject", History: h})
+		Expect(e).To(Succeed())
+
+		Expect(g.IsIn(types.User("bob"), types.Role("author"))).To(BeTrue())
+		Expect(h.Len()).To(Equal(2))
+	})
+})
@@ -62,23 +62,32 @@ func (g *fatGrouping) joinMemberToGroup(member types.Member, group types.Group)
 
 	for upper := range g.groupUpward[group] {
 		g.memberGroups[member][upper] = struct{}{}
-		g.groupMembers[group][member] = struct{}{}
+		if _, ok := g.groupMembers[upper]; !ok {
+			g.groupMembers[upper] = make(map[types.Member]struct{})
+		}
+		g.groupMembers[upper][member] = struct{}{}
 	}
 }
 
 func (g *fatGrouping) joinGroupToGroup(sub, super types.Group) {
-	if _, ok := g.groupDownward[super]; !ok {
-		g.groupDownward[super] = make(map[types.Group]struct{})
+	// sub and all groups under it are now under super and all groups above it
+	lowers := []types.Group{sub}
+	for lower := range g.groupDownward[sub] {
+		lowers = append(lowers, lower)
 	}
-	g.groupDownward[super][sub] = struct{}{}
-
-	if _, ok := g.groupUpward[sub]; !ok {
-		g.groupUpward[sub] = make(map[types.Group]struct{})
+	uppers := []types.Group{super}
+	for upper := range g.groupUpward[super] {
+		uppers = append(uppers, upper)
 	}
-	g.groupUpward[sub][super] = struct{}{}
 
-	for upper := range g.groupUpward[super] {
-		for lower := range g.groupDownward[sub] {
+	for _, lower := range lowers {
+		if _, ok := g.groupUpward[lower]; !ok {
+			g.groupUpward[lower] = make(map[types.Group]struct{})
+		}
+		for _, upper := range uppers {
+			if _, ok := g.groupDownward[upper]; !ok {
+				g.groupDownward[upper] = make(map[types.Group]struct{})
+			}
 			g.groupUpward[lower][upper] = struct{}{}
 			g.groupDownward[upper][lower] = struct{}{}
 		}
@@ -270,6 +279,10 @@ func (g *fatGrouping) RemoveMember(member types.Member) error {
 	return nil
 }
 
+func (g *fatGrouping) Policies() ([]types.GroupingPolicy, error) {
+	return g.slim.Policies()
+}
+
 func (g *fatGrouping) immediateGroupsOf(ent types.Entity) (map[types.Group]struct{}, error) {
 	return g.slim.immediateGroupsOf(ent)
 }
@@ -4,17 +4,79 @@ import (
 	"context"
 
 	"github.com/go-logr/logr"
+	"github.com/supremind/rbac/internal/history"
+	"github.com/supremind/rbac/internal/snapshot"
 	"github.com/supremind/rbac/types"
 )
 
+// Config controls optional features of the grouping
+type Config struct {
+	// CopyOnWrite keeps polices in immutable snapshots, so that they could be read without locking
+	CopyOnWrite bool
+
+	// History records versions of polices, it implies CopyOnWrite
+	History *history.Log
+
+	// Lazy loads polices from it on demand, and caches up to LazySize entities, instead of loading all polices at once,
+	// it must query the persister, other options about how polices are kept are ignored
+	Lazy     types.GroupingQuerier
+	LazySize int
+
+	// Compact keeps polices as interned ids, to take less memory, it is ignored with CopyOnWrite
+	Compact bool
+
+	// Snapshot loads polices from it, and resumes watching changes after them, instead of listing polices,
+	// if the persister could resume watching; polices are saved to it with the token of the latest watched change.
+	// It is ignored with Lazy
+	Snapshot *snapshot.Grouping
+
+	// Shards partitions polices of members into independently locked shards if positive,
+	// so that members join and leave groups in parallel, it is ignored with CopyOnWrite or Compact
+	Shards int
+
+	// Metrics measures the grouping under the name of Component
+	Metrics   types.Metrics
+	Component string
+
+	// Tracer starts spans around writes and persister round-trips
+	Tracer types.Tracer
+
+	// Observers are told about changes of polices in order, both made locally and watched from the persister
+	Observers []Observer
+}
+
+// Observer is told about entities whose groups changed, after the changes are made
+type Observer interface {
+	GroupingChanged(ent types.Entity)
+}
+
 // New creates a concurent safe, persisted grouping
-func New(ctx context.Context, gp types.GroupingPersister, l logr.Logger) (types.Grouping, error) {
-	return newPersistedGrouping(ctx, gp, l)
+func New(ctx context.Context, gp types.GroupingPersisterContext, l logr.Logger, cfg Config) (types.Grouping, error) {
+	var inner grouping
+	switch {
+	case cfg.Lazy != nil:
+		inner = newLazyGrouping(cfg.Lazy, gp, cfg.LazySize)
+	case cfg.History != nil:
+		inner = newCOWGrouping(cfg.History)
+	case cfg.CopyOnWrite:
+		inner = newCOWGrouping(nil)
+	case cfg.Compact:
+		inner = newSyncedGrouping(newCompactGrouping())
+	case cfg.Shards > 0:
+		inner = newShardedGrouping(cfg.Shards)
+	default:
+		inner = newSyncedGrouping(newFatGrouping())
+	}
+
+	return newPersistedGrouping(ctx, inner, gp, l, cfg)
 }
 
 type grouping interface {
 	types.Grouping
 
+	// Policies lists all polices immediately assigned, groups joined through sub groups are not listed
+	Policies() ([]types.GroupingPolicy, error)
+
 	// private methods to be used between private groupings
 
 	// immediateEntitiesIn returns Entities immediately belongs to Group
@@ -8,12 +8,14 @@ import (
 	"strconv"
 	"testing"
 
+	"github.com/go-logr/logr"
 	"github.com/go-logr/stdr"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 
 	. "github.com/supremind/rbac/internal/testdata"
+	"github.com/supremind/rbac/persist"
 	"github.com/supremind/rbac/persist/fake"
 	. "github.com/supremind/rbac/types"
 )
@@ -47,7 +49,29 @@ var _ = Describe("grouping implementation", func() {
 				logger := stdr.New(log.New(os.Stderr, "", log.LstdFlags|log.Lshortfile))
 				stdr.SetVerbosity(4)
 
-				g, e := newPersistedGrouping(context.Background(), fake.NewGroupingPersister(), logger)
+				g, e := newPersistedGrouping(context.Background(), newSyncedGrouping(newFatGrouping()), persist.GroupingWithContext(fake.NewGroupingPersister()), logger, Config{Component: "subject"})
+				Expect(e).To(Succeed())
+				return g
+			},
+		},
+		{
+			name: "copy on write",
+			g:    func() grouping { return newCOWGrouping(nil) },
+		},
+		{
+			name: "synced compact",
+			g:    func() grouping { return newSyncedGrouping(newCompactGrouping()) },
+		},
+		{
+			name: "sharded",
+			g:    func() grouping { return newShardedGrouping(4) },
+		},
+		{
+			name: "fake persisted lazy",
+			g: func() grouping {
+				p := fake.NewGroupingPersister()
+				pc := persist.GroupingWithContext(p)
+				g, e := newPersistedGrouping(context.Background(), newLazyGrouping(p, pc, 10), pc, logr.Discard(), Config{Component: "subject", Lazy: p})
 				Expect(e).To(Succeed())
 				return g
 			},
@@ -267,6 +291,24 @@ var _ = Describe("grouping implementation", func() {
 					}
 				})
 			})
+
+			Describe("with super roles joined before sub roles get members", func() {
+				JustBeforeEach(func() {
+					Expect(g.Join(Role("editor"), Role("staff"))).To(Succeed())
+					Expect(g.Join(Role("writer"), Role("editor"))).To(Succeed())
+					Expect(g.Join(User("alan"), Role("writer"))).To(Succeed())
+				})
+
+				Specify("user should be in all super roles", func() {
+					Expect(g.GroupsOf(User("alan"))).To(haveExactKeys(Role("writer"), Role("editor"), Role("staff")))
+					Expect(g.IsIn(User("alan"), Role("staff"))).To(BeTrue())
+				})
+
+				Specify("super roles should have the user", func() {
+					Expect(g.MembersIn(Role("editor"))).To(haveExactKeys(User("alan")))
+					Expect(g.MembersIn(Role("staff"))).To(haveExactKeys(User("alan")))
+				})
+			})
 		})
 	}
 })
@@ -0,0 +1,220 @@
+package grouping
+
+import (
+	"context"
+
+	"github.com/supremind/rbac/internal/lazy"
+	"github.com/supremind/rbac/types"
+)
+
+var _ grouping = (*lazyGrouping)(nil)
+
+// lazyGrouping loads immediate polices of entities and groups from the persister when they are queried,
+// and caches the recently used ones, instead of keeping all polices in memory.
+// The persister is the only source of truth, writes just drop outdated polices from the cache,
+// they are loaded again when queried next time.
+// Listing all groups, members, or polices lists all polices from the persister.
+type lazyGrouping struct {
+	q     types.GroupingQuerier
+	gp    types.GroupingPersisterContext
+	cache *lazy.Cache
+}
+
+// lazyKey is the key of polices cached for ent, of groups it immediately belongs to,
+// or of entities immediately in it, if it is down
+type lazyKey struct {
+	ent  types.Entity
+	down bool
+}
+
+func newLazyGrouping(q types.GroupingQuerier, gp types.GroupingPersisterContext, size int) *lazyGrouping {
+	return &lazyGrouping{
+		q:     q,
+		gp:    gp,
+		cache: lazy.New(size),
+	}
+}
+
+func (g *lazyGrouping) Join(ent types.Entity, group types.Group) error {
+	g.cache.Invalidate(lazyKey{ent: ent}, lazyKey{ent: group, down: true})
+	return nil
+}
+
+func (g *lazyGrouping) Leave(ent types.Entity, group types.Group) error {
+	g.cache.Invalidate(lazyKey{ent: ent}, lazyKey{ent: group, down: true})
+	return nil
+}
+
+func (g *lazyGrouping) IsIn(member types.Member, group types.Group) (bool, error) {
+	groups, e := g.GroupsOf(member)
+	if e != nil {
+		return false, e
+	}
+	_, ok := groups[group]
+	return ok, nil
+}
+
+func (g *lazyGrouping) AllGroups() (map[types.Group]struct{}, error) {
+	polices, e := g.gp.ListContext(context.Background())
+	if e != nil {
+		return nil, e
+	}
+
+	groups := make(map[types.Group]struct{})
+	for _, policy := range polices {
+		groups[policy.Group] = struct{}{}
+		if group, ok := policy.Entity.(types.Group); ok {
+			groups[group] = struct{}{}
+		}
+	}
+	return groups, nil
+}
+
+func (g *lazyGrouping) AllMembers() (map[types.Member]struct{}, error) {
+	polices, e := g.gp.ListContext(context.Background())
+	if e != nil {
+		return nil, e
+	}
+
+	members := make(map[types.Member]struct{})
+	for _, policy := range polices {
+		if member, ok := policy.Entity.(types.Member); ok {
+			members[member] = struct{}{}
+		}
+	}
+	return members, nil
+}
+
+func (g *lazyGrouping) GroupsOf(ent types.Entity) (map[types.Group]struct{}, error) {
+	groups := make(map[types.Group]struct{})
+	queue := []types.Entity{ent}
+	for len(queue) > 0 {
+		parents, e := g.immediateGroupsOf(queue[0])
+		if e != nil {
+			return nil, e
+		}
+		queue = queue[1:]
+
+		for parent := range parents {
+			if _, ok := groups[parent]; !ok {
+				groups[parent] = struct{}{}
+				queue = append(queue, parent)
+			}
+		}
+	}
+	return groups, nil
+}
+
+func (g *lazyGrouping) MembersIn(group types.Group) (map[types.Member]struct{}, error) {
+	members := make(map[types.Member]struct{})
+	visited := map[types.Group]struct{}{group: {}}
+	queue := []types.Group{group}
+	for len(queue) > 0 {
+		children, e := g.immediateEntitiesIn(queue[0])
+		if e != nil {
+			return nil, e
+		}
+		queue = queue[1:]
+
+		for child := range children {
+			switch child := child.(type) {
+			case types.Member:
+				members[child] = struct{}{}
+			case types.Group:
+				if _, ok := visited[child]; !ok {
+					visited[child] = struct{}{}
+					queue = append(queue, child)
+				}
+			}
+		}
+	}
+	return members, nil
+}
+
+// RemoveGroup drops polices cached for group and its neighbours,
+// or all cached polices, if its neighbours are not known without loading them again from the persister,
+// where polices about group have been removed
+func (g *lazyGrouping) RemoveGroup(group types.Group) error {
+	entities, ok := g.cache.Get(lazyKey{ent: group, down: true})
+	if !ok {
+		g.cache.Purge()
+		return nil
+	}
+	groups, ok := g.cache.Get(lazyKey{ent: group})
+	if !ok {
+		g.cache.Purge()
+		return nil
+	}
+
+	keys := []interface{}{lazyKey{ent: group}, lazyKey{ent: group, down: true}}
+	for ent := range entities.(map[types.Entity]struct{}) {
+		keys = append(keys, lazyKey{ent: ent})
+	}
+	for super := range groups.(map[types.Group]struct{}) {
+		keys = append(keys, lazyKey{ent: super, down: true})
+	}
+	g.cache.Invalidate(keys...)
+	return nil
+}
+
+// RemoveMember drops polices cached for member and its groups, or all cached polices, as RemoveGroup does
+func (g *lazyGrouping) RemoveMember(member types.Member) error {
+	groups, ok := g.cache.Get(lazyKey{ent: member})
+	if !ok {
+		g.cache.Purge()
+		return nil
+	}
+
+	keys := []interface{}{lazyKey{ent: member}}
+	for group := range groups.(map[types.Group]struct{}) {
+		keys = append(keys, lazyKey{ent: group, down: true})
+	}
+	g.cache.Invalidate(keys...)
+	return nil
+}
+
+func (g *lazyGrouping) Policies() ([]types.GroupingPolicy, error) {
+	return g.gp.ListContext(context.Background())
+}
+
+// immediateGroupsOf returns cached groups ent immediately belongs to, or loads them from the persister.
+// Returned groups must not be changed.
+func (g *lazyGrouping) immediateGroupsOf(ent types.Entity) (map[types.Group]struct{}, error) {
+	key := lazyKey{ent: ent}
+	if groups, ok := g.cache.Get(key); ok {
+		return groups.(map[types.Group]struct{}), nil
+	}
+
+	epoch := g.cache.Epoch()
+	polices, _, e := g.q.ListByEntity(ent, "", 0)
+	if e != nil {
+		return nil, e
+	}
+	groups := make(map[types.Group]struct{}, len(polices))
+	for _, policy := range polices {
+		groups[policy.Group] = struct{}{}
+	}
+	g.cache.Put(epoch, key, groups)
+	return groups, nil
+}
+
+// immediateEntitiesIn returns cached entities immediately in group, or loads them from the persister.
+// Returned entities must not be changed.
+func (g *lazyGrouping) immediateEntitiesIn(group types.Group) (map[types.Entity]struct{}, error) {
+	key := lazyKey{ent: group, down: true}
+	if entities, ok := g.cache.Get(key); ok {
+		return entities.(map[types.Entity]struct{}), nil
+	}
+
+	epoch := g.cache.Epoch()
+	polices, _, e := g.q.ListByGroup(group, "", 0)
+	if e != nil {
+		return nil, e
+	}
+	entities := make(map[types.Entity]struct{}, len(polices))
+	for _, policy := range polices {
+		entities[policy.Entity] = struct{}{}
+	}
+	g.cache.Put(epoch, key, entities)
+	return entities, nil
+}
@@ -3,52 +3,150 @@ package grouping
 import (
 	"context"
 	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/supremind/rbac/internal/persist/filter"
+	"github.com/supremind/rbac/metrics"
+	"github.com/supremind/rbac/persist"
+	"github.com/supremind/rbac/trace"
 	"github.com/supremind/rbac/types"
 )
 
-var _ grouping = (*persistedGrouping)(nil)
+var (
+	_ grouping                    = (*persistedGrouping)(nil)
+	_ types.GroupingWriterContext = (*persistedGrouping)(nil)
+)
 
 // persistedGrouping persists grouping roles of the inner grouping
 type persistedGrouping struct {
-	persist types.GroupingPersister
+	policies int64 // number of polices, updated atomically
+	lazy     bool  // polices are loaded on demand, they are not counted
+	persist  types.GroupingPersisterContext
+	stream   types.GroupingStreamer // nil if the persister could not stream polices
+	resumer  types.GroupingResumer  // nil if the persister could not resume watching
+	token    atomic.Value           // of string, the token after the latest watched change, if resumed
+	saving   sync.RWMutex           // held for reading by writes, so that snapshots are not saved in the middle of them
 	grouping
-	log logr.Logger
+	log       logr.Logger
+	component string
+	metrics   types.Metrics
+	tracer    types.Tracer
+	observers []Observer
 }
 
-func newPersistedGrouping(ctx context.Context, persist types.GroupingPersister, l logr.Logger) (*persistedGrouping, error) {
+func newPersistedGrouping(ctx context.Context, inner grouping, gp types.GroupingPersisterContext, l logr.Logger, cfg Config) (*persistedGrouping, error) {
 	g := &persistedGrouping{
-		persist:  filter.NewGroupingPersister(persist),
-		log:      l,
-		grouping: newSyncedGrouping(newFatGrouping()),
+		log:       l,
+		grouping:  inner,
+		component: cfg.Component,
+		metrics:   cfg.Metrics,
+		tracer:    cfg.Tracer,
+		observers: cfg.Observers,
 	}
-	if e := g.loadPersisted(); e != nil {
-		return nil, e
+	if g.metrics == nil {
+		g.metrics = metrics.Discard
 	}
-	if e := g.startWatching(ctx); e != nil {
-		return nil, e
+	if g.tracer == nil {
+		g.tracer = trace.Nop
+	}
+	f := filter.NewGroupingPersister(gp, g.component, g.metrics)
+	g.persist = f
+	g.stream, _ = persist.GroupingStreamerOf(gp)
+	g.resumer, _ = f.Resumer()
+
+	switch {
+	case cfg.Lazy != nil:
+		// polices are loaded on demand by lazy ones
+		g.lazy = true
+		if e := g.startWatching(ctx); e != nil {
+			return nil, e
+		}
+	case g.resumer != nil:
+		if e := g.resume(ctx, cfg.Snapshot); e != nil {
+			return nil, e
+		}
+	default:
+		if e := g.loadPersisted(ctx); e != nil {
+			return nil, e
+		}
+		if e := g.startWatching(ctx); e != nil {
+			return nil, e
+		}
 	}
 
 	return g, nil
 }
 
-func (g *persistedGrouping) loadPersisted() error {
+func (g *persistedGrouping) loadPersisted(ctx context.Context) error {
 	g.log.V(4).Info("load persisted polices")
 
-	polices, e := g.persist.List()
+	if g.stream != nil {
+		return g.streamPersisted(ctx)
+	}
+
+	ctx, span := g.startSpan(ctx, "rbac.persister.List")
+	polices, e := g.persist.ListContext(ctx)
+	span.End(e)
 	if e != nil {
 		return e
 	}
-	for _, policy := range polices {
-		if e := g.grouping.Join(policy.Entity, policy.Group); e != nil {
-			return e
+	e = g.joinAll(func(join func(types.Entity, types.Group) error) error {
+		for _, policy := range polices {
+			if e := join(policy.Entity, policy.Group); e != nil {
+				return e
+			}
 		}
+		return nil
+	})
+	if e != nil {
+		return e
+	}
+	g.countPolicies(len(polices))
+	return nil
+}
+
+// joinAll joins polices iterated by each to the inner grouping,
+// copy-on-write groupings join all of them at once, so that they are recorded as a single version in history
+func (g *persistedGrouping) joinAll(each func(join func(types.Entity, types.Group) error) error) error {
+	if b, ok := g.grouping.(interface {
+		joinAll(func(join func(types.Entity, types.Group) error) error) error
+	}); ok {
+		return b.joinAll(each)
+	}
+	return each(g.grouping.Join)
+}
+
+// streamPersisted loads persisted polices one by one, without listing all of them in memory
+func (g *persistedGrouping) streamPersisted(ctx context.Context) error {
+	ctx, span := g.startSpan(ctx, "rbac.persister.Each")
+	n := 0
+	e := g.joinAll(func(join func(types.Entity, types.Group) error) error {
+		return g.stream.Each(ctx, func(policy types.GroupingPolicy) error {
+			n++
+			return join(policy.Entity, policy.Group)
+		})
+	})
+	span.End(e)
+	if e != nil {
+		return e
 	}
+	g.countPolicies(n)
 	return nil
 }
 
+// countPolicies adds delta to the number of polices, and reports it to metrics,
+// polices loaded lazily are not counted, as the number of all polices is unknown
+func (g *persistedGrouping) countPolicies(delta int) {
+	if g.lazy {
+		return
+	}
+	n := atomic.AddInt64(&g.policies, int64(delta))
+	g.metrics.SetPolicies(g.component, int(n))
+}
+
 func (g *persistedGrouping) startWatching(ctx context.Context) error {
 	changes, e := g.persist.Watch(ctx)
 	if e != nil {
@@ -58,8 +156,12 @@ func (g *persistedGrouping) startWatching(ctx context.Context) error {
 	go func() {
 		for {
 			select {
-			case change := <-changes:
-				if e := g.coordinateChange(change); e != nil {
+			case change, ok := <-changes:
+				if !ok {
+					g.log.V(4).Info("grouping persister stopped watching")
+					return
+				}
+				if e := g.coordinateChange(ctx, change); e != nil {
 					g.log.Error(e, "coordinate grouping changes")
 				}
 			case <-ctx.Done():
@@ -71,47 +173,112 @@ func (g *persistedGrouping) startWatching(ctx context.Context) error {
 	return nil
 }
 
-func (g *persistedGrouping) coordinateChange(change types.GroupingPolicyChange) error {
+func (g *persistedGrouping) coordinateChange(ctx context.Context, change types.GroupingPolicyChange) (err error) {
 	g.log.V(4).Info("coordinate grouping changes", "change", change)
 
+	_, span := g.startSpan(ctx, "rbac.grouping.Coordinate", policyAttrs(change.Entity, change.Group)...)
+	defer func() { span.End(err) }()
+
 	switch change.Method {
 	case types.PersistInsert:
-		return g.grouping.Join(change.Entity, change.Group)
+		if e := g.grouping.Join(change.Entity, change.Group); e != nil {
+			return e
+		}
+		g.changed(change.Entity)
+		g.countPolicies(1)
+		return nil
 	case types.PersistDelete:
-		return g.grouping.Leave(change.Entity, change.Group)
+		if e := g.grouping.Leave(change.Entity, change.Group); e != nil {
+			return e
+		}
+		g.changed(change.Entity)
+		g.countPolicies(-1)
+		return nil
 	}
 
 	return fmt.Errorf("%w: grouping persister changes: %s", types.ErrUnsupportedChange, change.Method)
 }
 
 func (g *persistedGrouping) Join(ent types.Entity, group types.Group) error {
+	return g.JoinContext(context.Background(), ent, group)
+}
+
+func (g *persistedGrouping) Leave(ent types.Entity, group types.Group) error {
+	return g.LeaveContext(context.Background(), ent, group)
+}
+
+func (g *persistedGrouping) RemoveGroup(group types.Group) error {
+	return g.RemoveGroupContext(context.Background(), group)
+}
+
+func (g *persistedGrouping) RemoveMember(m types.Member) error {
+	return g.RemoveMemberContext(context.Background(), m)
+}
+
+func (g *persistedGrouping) JoinContext(ctx context.Context, ent types.Entity, group types.Group) error {
+	ctx, span := g.startSpan(ctx, "rbac.grouping.Join", policyAttrs(ent, group)...)
+	e := g.join(ctx, ent, group)
+	span.End(e)
+	return e
+}
+
+func (g *persistedGrouping) join(ctx context.Context, ent types.Entity, group types.Group) error {
 	g.log.V(4).Info("join", "member", ent, "group", group)
+	g.saving.RLock()
+	defer g.saving.RUnlock()
 
-	if e := g.persist.Insert(ent, group); e != nil {
+	if e := g.insert(ctx, ent, group); e != nil {
+		return e
+	}
+	if e := g.grouping.Join(ent, group); e != nil {
 		return e
 	}
-	return g.grouping.Join(ent, group)
+	g.changed(ent)
+	g.countPolicies(1)
+	return nil
 }
 
-func (g *persistedGrouping) Leave(ent types.Entity, group types.Group) error {
+func (g *persistedGrouping) LeaveContext(ctx context.Context, ent types.Entity, group types.Group) error {
+	ctx, span := g.startSpan(ctx, "rbac.grouping.Leave", policyAttrs(ent, group)...)
+	e := g.leave(ctx, ent, group)
+	span.End(e)
+	return e
+}
+
+func (g *persistedGrouping) leave(ctx context.Context, ent types.Entity, group types.Group) error {
 	g.log.V(4).Info("leave", "member", ent, "group", group)
+	g.saving.RLock()
+	defer g.saving.RUnlock()
 
-	if e := g.persist.Remove(ent, group); e != nil {
+	if e := g.remove(ctx, ent, group); e != nil {
 		return e
 	}
+	if e := g.grouping.Leave(ent, group); e != nil {
+		return e
+	}
+	g.changed(ent)
+	g.countPolicies(-1)
+	return nil
+}
 
-	return g.grouping.Leave(ent, group)
+func (g *persistedGrouping) RemoveGroupContext(ctx context.Context, group types.Group) error {
+	ctx, span := g.startSpan(ctx, "rbac.grouping.RemoveGroup", types.Attr(trace.AttrGroup, group.String()))
+	e := g.removeGroup(ctx, group)
+	span.End(e)
+	return e
 }
 
-func (g *persistedGrouping) RemoveGroup(group types.Group) error {
+func (g *persistedGrouping) removeGroup(ctx context.Context, group types.Group) error {
 	g.log.V(4).Info("remove group", "group", group)
+	g.saving.RLock()
+	defer g.saving.RUnlock()
 
 	members, e := g.grouping.immediateEntitiesIn(group)
 	if e != nil {
 		return e
 	}
 	for member := range members {
-		if e := g.persist.Remove(member, group); e != nil {
+		if e := g.remove(ctx, member, group); e != nil {
 			return e
 		}
 	}
@@ -121,26 +288,104 @@ func (g *persistedGrouping) RemoveGroup(group types.Group) error {
 		return e
 	}
 	for super := range groups {
-		if e := g.persist.Remove(group, super); e != nil {
+		if e := g.remove(ctx, group, super); e != nil {
 			return e
 		}
 	}
 
-	return g.grouping.RemoveGroup(group)
+	if e := g.grouping.RemoveGroup(group); e != nil {
+		return e
+	}
+	g.changed(group)
+	for member := range members {
+		g.changed(member)
+	}
+	g.countPolicies(-len(members) - len(groups))
+	return nil
+}
+
+func (g *persistedGrouping) RemoveMemberContext(ctx context.Context, m types.Member) error {
+	ctx, span := g.startSpan(ctx, "rbac.grouping.RemoveMember", types.Attr(trace.AttrEntity, m.String()))
+	e := g.removeMember(ctx, m)
+	span.End(e)
+	return e
 }
 
-func (g *persistedGrouping) RemoveMember(m types.Member) error {
+func (g *persistedGrouping) removeMember(ctx context.Context, m types.Member) error {
 	g.log.V(4).Info("remove member", "member", m)
+	g.saving.RLock()
+	defer g.saving.RUnlock()
 
 	groups, e := g.grouping.immediateGroupsOf(m)
 	if e != nil {
 		return e
 	}
 	for group := range groups {
-		if e := g.persist.Remove(m, group); e != nil {
+		if e := g.remove(ctx, m, group); e != nil {
 			return e
 		}
 	}
 
-	return g.grouping.RemoveMember(m)
+	if e := g.grouping.RemoveMember(m); e != nil {
+		return e
+	}
+	g.changed(m)
+	g.countPolicies(-len(groups))
+	return nil
+}
+
+// changed tells observers groups of ent changed
+func (g *persistedGrouping) changed(ent types.Entity) {
+	for _, o := range g.observers {
+		o.GroupingChanged(ent)
+	}
+}
+
+// insert a policy to the persister, as a traced operation
+func (g *persistedGrouping) insert(ctx context.Context, ent types.Entity, group types.Group) error {
+	ctx, span := g.startSpan(ctx, "rbac.persister.Insert", policyAttrs(ent, group)...)
+	e := g.persist.InsertContext(ctx, ent, group)
+	span.End(e)
+	return e
+}
+
+// remove a policy from the persister, as a traced operation
+func (g *persistedGrouping) remove(ctx context.Context, ent types.Entity, group types.Group) error {
+	ctx, span := g.startSpan(ctx, "rbac.persister.Remove", policyAttrs(ent, group)...)
+	e := g.persist.RemoveContext(ctx, ent, group)
+	span.End(e)
+	return e
+}
+
+// startSpan starts a span with the component name attached
+func (g *persistedGrouping) startSpan(ctx context.Context, name string, attrs ...types.Attribute) (context.Context, types.Span) {
+	return g.tracer.Start(ctx, name, append([]types.Attribute{types.Attr(trace.AttrComponent, g.component)}, attrs...)...)
+}
+
+func policyAttrs(ent types.Entity, group types.Group) []types.Attribute {
+	return []types.Attribute{
+		types.Attr(trace.AttrEntity, ent.String()),
+		types.Attr(trace.AttrGroup, group.String()),
+	}
+}
+
+// At returns a read-only grouping as it was at the given time, if the inner grouping keeps history
+func (g *persistedGrouping) At(t time.Time) (types.Grouping, error) {
+	if h, ok := g.grouping.(interface {
+		At(time.Time) (types.Grouping, error)
+	}); ok {
+		return h.At(t)
+	}
+	return nil, types.ErrNoHistory
+}
+
+// Frozen returns a read-only grouping of the current snapshot, if the inner grouping keeps snapshots,
+// or the grouping itself otherwise
+func (g *persistedGrouping) Frozen() types.Grouping {
+	if f, ok := g.grouping.(interface {
+		Frozen() types.Grouping
+	}); ok {
+		return f.Frozen()
+	}
+	return g
 }
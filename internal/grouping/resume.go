@@ -0,0 +1,125 @@
+package grouping
+
+import (
+	"context"
+
+	"github.com/supremind/rbac/internal/snapshot"
+	"github.com/supremind/rbac/types"
+)
+
+var _ snapshot.GroupingSource = (*persistedGrouping)(nil)
+
+// resume loads polices from the snapshot, or lists them with the token of where they are listed at,
+// and watches changes after the token, so that no change is lost between loading and watching.
+// Polices are listed if the snapshot is empty, or changes after it are not kept by the persister any more.
+func (g *persistedGrouping) resume(ctx context.Context, snap *snapshot.Grouping) error {
+	var state snapshot.GroupingState
+	var changes <-chan types.ResumableGroupingChange
+
+	if snap != nil && snap.Token != "" {
+		var e error
+		changes, e = g.resumer.WatchFrom(ctx, snap.Token)
+		if e != nil {
+			g.log.Error(e, "resume watching after the snapshot, list polices instead")
+		} else {
+			g.log.V(4).Info("load polices from the snapshot", "token", snap.Token)
+			state = snap.GroupingState
+		}
+	}
+	if changes == nil {
+		g.log.V(4).Info("list persisted polices with token")
+		lctx, span := g.startSpan(ctx, "rbac.persister.ListWithToken")
+		var e error
+		state.Polices, state.Token, e = g.resumer.ListWithToken(lctx)
+		span.End(e)
+		if e != nil {
+			return e
+		}
+		if changes, e = g.resumer.WatchFrom(ctx, state.Token); e != nil {
+			return e
+		}
+	}
+
+	if e := g.warmStart(state); e != nil {
+		return e
+	}
+	g.countPolicies(len(state.Polices))
+	g.token.Store(state.Token)
+	if snap != nil {
+		snap.Track(g)
+	}
+
+	go func() {
+		for {
+			select {
+			case change, ok := <-changes:
+				if !ok {
+					g.log.V(4).Info("grouping persister stopped watching")
+					return
+				}
+				// changes made by this replica are applied already, they carry only tokens
+				if change.Method != "" {
+					if e := g.resumeChange(ctx, change.GroupingPolicyChange); e != nil {
+						g.log.Error(e, "coordinate grouping changes")
+					}
+				}
+				g.token.Store(change.Token)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// resumeChange coordinates a change watched after resuming, changes applied already are skipped,
+// they are listed or loaded from the snapshot, but watched again
+func (g *persistedGrouping) resumeChange(ctx context.Context, change types.GroupingPolicyChange) error {
+	groups, e := g.grouping.immediateGroupsOf(change.Entity)
+	if e != nil {
+		return e
+	}
+	_, joined := groups[change.Group]
+
+	switch {
+	case change.Method == types.PersistInsert && joined, change.Method == types.PersistDelete && !joined:
+		g.log.V(4).Info("skip grouping change applied already", "change", change)
+		return nil
+	}
+	return g.coordinateChange(ctx, change)
+}
+
+// warmStart restores the inner grouping from the state directly if it could, or joins polices to it one by one
+func (g *persistedGrouping) warmStart(state snapshot.GroupingState) error {
+	if w, ok := g.grouping.(warmer); ok {
+		return w.warmStart(state.Polices, state.Closures)
+	}
+	return g.joinAll(func(join func(types.Entity, types.Group) error) error {
+		for _, policy := range state.Polices {
+			if e := join(policy.Entity, policy.Group); e != nil {
+				return e
+			}
+		}
+		return nil
+	})
+}
+
+// SnapshotGrouping returns the state of the grouping, with the token of the latest watched change,
+// changes after the token may be included already, they are applied idempotently when resumed
+func (g *persistedGrouping) SnapshotGrouping() (snapshot.GroupingState, error) {
+	g.saving.Lock()
+	defer g.saving.Unlock()
+
+	// the token is taken before polices, so that no change before it is missed by them
+	state := snapshot.GroupingState{}
+	state.Token, _ = g.token.Load().(string)
+
+	var e error
+	if w, ok := g.grouping.(warmer); ok {
+		state.Polices, state.Closures, e = w.warmState()
+	} else {
+		state.Polices, e = g.grouping.Policies()
+	}
+	return state, e
+}
@@ -0,0 +1,320 @@
+package grouping
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/supremind/rbac/types"
+)
+
+var _ grouping = (*shardedGrouping)(nil)
+
+// shardedGrouping partitions member to group polices into shards by hashes of members,
+// every shard is locked independently, so that members of different shards join and leave groups in parallel.
+// Group to group polices are kept in a hierarchy shared by all shards, which is locked after shards if both are needed.
+type shardedGrouping struct {
+	shards       []*memberShard
+	hierarchy    *fatGrouping // only groups, no members
+	sync.RWMutex              // guards hierarchy
+}
+
+// memberShard keeps immediate polices of members in it
+type memberShard struct {
+	groups  map[types.Member]map[types.Group]struct{}
+	members map[types.Group]map[types.Member]struct{}
+	sync.RWMutex
+}
+
+func newShardedGrouping(shards int) *shardedGrouping {
+	g := &shardedGrouping{
+		shards:    make([]*memberShard, shards),
+		hierarchy: newFatGrouping(),
+	}
+	for i := range g.shards {
+		g.shards[i] = &memberShard{
+			groups:  make(map[types.Member]map[types.Group]struct{}),
+			members: make(map[types.Group]map[types.Member]struct{}),
+		}
+	}
+	return g
+}
+
+// ShardOf returns which one of shards member belongs to
+func ShardOf(member types.Member, shards int) int {
+	var name string
+	switch member := member.(type) {
+	case types.User:
+		name = string(member)
+	case types.Article:
+		name = string(member)
+	}
+
+	// FNV-1a
+	h := uint32(2166136261)
+	for i := 0; i < len(name); i++ {
+		h ^= uint32(name[i])
+		h *= 16777619
+	}
+	return int(h % uint32(shards))
+}
+
+func (g *shardedGrouping) shardOf(member types.Member) *memberShard {
+	return g.shards[ShardOf(member, len(g.shards))]
+}
+
+func (g *shardedGrouping) Join(ent types.Entity, group types.Group) error {
+	member, ok := ent.(types.Member)
+	if !ok {
+		g.Lock()
+		defer g.Unlock()
+		return g.hierarchy.Join(ent, group)
+	}
+
+	s := g.shardOf(member)
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.groups[member]; !ok {
+		s.groups[member] = make(map[types.Group]struct{}, 1)
+	}
+	s.groups[member][group] = struct{}{}
+	if _, ok := s.members[group]; !ok {
+		s.members[group] = make(map[types.Member]struct{})
+	}
+	s.members[group][member] = struct{}{}
+	return nil
+}
+
+func (g *shardedGrouping) Leave(ent types.Entity, group types.Group) error {
+	member, ok := ent.(types.Member)
+	if !ok {
+		g.Lock()
+		defer g.Unlock()
+		return g.hierarchy.Leave(ent, group)
+	}
+
+	s := g.shardOf(member)
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.groups[member][group]; !ok {
+		return fmt.Errorf("%w: grouping policy: %s -> %s", types.ErrNotFound, ent, group)
+	}
+	delete(s.groups[member], group)
+	delete(s.members[group], member)
+	return nil
+}
+
+func (g *shardedGrouping) IsIn(member types.Member, group types.Group) (bool, error) {
+	s := g.shardOf(member)
+	s.RLock()
+	defer s.RUnlock()
+
+	if _, ok := s.groups[member][group]; ok {
+		return true, nil
+	}
+
+	g.RLock()
+	defer g.RUnlock()
+	for parent := range s.groups[member] {
+		if _, ok := g.hierarchy.groupUpward[parent][group]; ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (g *shardedGrouping) AllGroups() (map[types.Group]struct{}, error) {
+	g.RLock()
+	groups, e := g.hierarchy.AllGroups()
+	g.RUnlock()
+	if e != nil {
+		return nil, e
+	}
+
+	for _, s := range g.shards {
+		s.RLock()
+		for group := range s.members {
+			groups[group] = struct{}{}
+		}
+		s.RUnlock()
+	}
+	return groups, nil
+}
+
+func (g *shardedGrouping) AllMembers() (map[types.Member]struct{}, error) {
+	members := make(map[types.Member]struct{})
+	for _, s := range g.shards {
+		s.RLock()
+		for member := range s.groups {
+			members[member] = struct{}{}
+		}
+		s.RUnlock()
+	}
+	return members, nil
+}
+
+func (g *shardedGrouping) GroupsOf(ent types.Entity) (map[types.Group]struct{}, error) {
+	groups := make(map[types.Group]struct{})
+
+	member, ok := ent.(types.Member)
+	if !ok {
+		g.RLock()
+		defer g.RUnlock()
+		for upper := range g.hierarchy.groupUpward[ent.(types.Group)] {
+			groups[upper] = struct{}{}
+		}
+		return groups, nil
+	}
+
+	s := g.shardOf(member)
+	s.RLock()
+	defer s.RUnlock()
+	g.RLock()
+	defer g.RUnlock()
+
+	for parent := range s.groups[member] {
+		groups[parent] = struct{}{}
+		for upper := range g.hierarchy.groupUpward[parent] {
+			groups[upper] = struct{}{}
+		}
+	}
+	return groups, nil
+}
+
+func (g *shardedGrouping) MembersIn(group types.Group) (map[types.Member]struct{}, error) {
+	lowers, e := g.groupsUnder(group)
+	if e != nil {
+		return nil, e
+	}
+
+	members := make(map[types.Member]struct{})
+	for _, s := range g.shards {
+		s.RLock()
+		for _, lower := range lowers {
+			for member := range s.members[lower] {
+				members[member] = struct{}{}
+			}
+		}
+		s.RUnlock()
+	}
+	return members, nil
+}
+
+// groupsUnder returns group and all groups under it in the hierarchy
+func (g *shardedGrouping) groupsUnder(group types.Group) ([]types.Group, error) {
+	g.RLock()
+	defer g.RUnlock()
+
+	visited := map[types.Group]struct{}{group: {}}
+	lowers := []types.Group{group}
+	for i := 0; i < len(lowers); i++ {
+		children, e := g.hierarchy.immediateEntitiesIn(lowers[i])
+		if e != nil {
+			return nil, e
+		}
+		for child := range children {
+			lower := child.(types.Group)
+			if _, ok := visited[lower]; !ok {
+				visited[lower] = struct{}{}
+				lowers = append(lowers, lower)
+			}
+		}
+	}
+	return lowers, nil
+}
+
+func (g *shardedGrouping) RemoveGroup(group types.Group) error {
+	for _, s := range g.shards {
+		s.Lock()
+		for member := range s.members[group] {
+			delete(s.groups[member], group)
+		}
+		delete(s.members, group)
+		s.Unlock()
+	}
+
+	g.Lock()
+	defer g.Unlock()
+	return g.hierarchy.RemoveGroup(group)
+}
+
+func (g *shardedGrouping) RemoveMember(member types.Member) error {
+	s := g.shardOf(member)
+	s.Lock()
+	defer s.Unlock()
+
+	for group := range s.groups[member] {
+		delete(s.members[group], member)
+	}
+	delete(s.groups, member)
+	return nil
+}
+
+func (g *shardedGrouping) Policies() ([]types.GroupingPolicy, error) {
+	g.RLock()
+	polices, e := g.hierarchy.Policies()
+	g.RUnlock()
+	if e != nil {
+		return nil, e
+	}
+
+	for _, s := range g.shards {
+		s.RLock()
+		for member, groups := range s.groups {
+			for group := range groups {
+				polices = append(polices, types.GroupingPolicy{Entity: member, Group: group})
+			}
+		}
+		s.RUnlock()
+	}
+	return polices, nil
+}
+
+func (g *shardedGrouping) immediateGroupsOf(ent types.Entity) (map[types.Group]struct{}, error) {
+	groups := make(map[types.Group]struct{})
+
+	member, ok := ent.(types.Member)
+	if !ok {
+		g.RLock()
+		defer g.RUnlock()
+		parents, e := g.hierarchy.immediateGroupsOf(ent)
+		if e != nil {
+			return nil, e
+		}
+		for parent := range parents {
+			groups[parent] = struct{}{}
+		}
+		return groups, nil
+	}
+
+	s := g.shardOf(member)
+	s.RLock()
+	defer s.RUnlock()
+	for group := range s.groups[member] {
+		groups[group] = struct{}{}
+	}
+	return groups, nil
+}
+
+func (g *shardedGrouping) immediateEntitiesIn(group types.Group) (map[types.Entity]struct{}, error) {
+	g.RLock()
+	children, e := g.hierarchy.immediateEntitiesIn(group)
+	entities := make(map[types.Entity]struct{}, len(children))
+	for child := range children {
+		entities[child] = struct{}{}
+	}
+	g.RUnlock()
+	if e != nil {
+		return nil, e
+	}
+
+	for _, s := range g.shards {
+		s.RLock()
+		for member := range s.members[group] {
+			entities[member] = struct{}{}
+		}
+		s.RUnlock()
+	}
+	return entities, nil
+}
@@ -130,6 +130,17 @@ func (g *slimGrouping) MembersIn(grp types.Group) (map[types.Member]struct{}, er
 	return children, nil
 }
 
+// Policies implements grouping interface
+func (g *slimGrouping) Policies() ([]types.GroupingPolicy, error) {
+	polices := make([]types.GroupingPolicy, 0, len(g.parents))
+	for ent, groups := range g.parents {
+		for grp := range groups {
+			polices = append(polices, types.GroupingPolicy{Entity: ent, Group: grp})
+		}
+	}
+	return polices, nil
+}
+
 // ImmediateGroupsOf implements Grouping interface
 func (g *slimGrouping) immediateGroupsOf(entity types.Entity) (map[types.Group]struct{}, error) {
 	return g.parents[entity], nil
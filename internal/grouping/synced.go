@@ -90,6 +90,13 @@ func (g *syncedGrouping) MembersIn(group types.Group) (map[types.Member]struct{}
 	return g.g.MembersIn(group)
 }
 
+// Policies implements grouping interface
+func (g *syncedGrouping) Policies() ([]types.GroupingPolicy, error) {
+	g.RLock()
+	defer g.RUnlock()
+	return g.g.Policies()
+}
+
 //  ImmediateGroupsOf implements Grouping interface
 func (g *syncedGrouping) immediateGroupsOf(ent types.Entity) (map[types.Group]struct{}, error) {
 	g.RLock()
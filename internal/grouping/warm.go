@@ -0,0 +1,151 @@
+package grouping
+
+import (
+	"github.com/supremind/rbac/types"
+)
+
+// warmer is a grouping whose built state could be saved along with polices, and restored directly,
+// instead of being built again by joining polices one by one
+type warmer interface {
+	// warmState returns polices immediately assigned, and closures of all groups every entity belongs to,
+	// closures are nil if the grouping does not build them
+	warmState() (polices, closures []types.GroupingPolicy, err error)
+
+	// warmStart restores an empty grouping with polices and closures returned by warmState
+	warmStart(polices, closures []types.GroupingPolicy) error
+}
+
+var (
+	_ warmer = (*fatGrouping)(nil)
+	_ warmer = (*syncedGrouping)(nil)
+)
+
+func (g *fatGrouping) warmState() ([]types.GroupingPolicy, []types.GroupingPolicy, error) {
+	polices, e := g.slim.Policies()
+	if e != nil {
+		return nil, nil, e
+	}
+
+	closures := make([]types.GroupingPolicy, 0, len(g.memberGroups)+len(g.groupUpward))
+	for member, groups := range g.memberGroups {
+		for group := range groups {
+			closures = append(closures, types.GroupingPolicy{Entity: member, Group: group})
+		}
+	}
+	for sub, uppers := range g.groupUpward {
+		for upper := range uppers {
+			closures = append(closures, types.GroupingPolicy{Entity: sub, Group: upper})
+		}
+	}
+	return polices, closures, nil
+}
+
+// warmStart restores closures as they are, and their reverse mappings, without expanding sub groups.
+// Maps are allocated in their final sizes, since most time of restoring is taken by growing them.
+func (g *fatGrouping) warmStart(polices, closures []types.GroupingPolicy) error {
+	type counts struct{ children, members, lowers int }
+	groups := make(map[types.Group]*counts)
+	count := func(group types.Group) *counts {
+		c, ok := groups[group]
+		if !ok {
+			c = &counts{}
+			groups[group] = c
+		}
+		return c
+	}
+	for _, policy := range polices {
+		count(policy.Group).children++
+	}
+	for _, closure := range closures {
+		if _, ok := closure.Entity.(types.Member); ok {
+			count(closure.Group).members++
+		} else {
+			count(closure.Group).lowers++
+		}
+	}
+
+	for group, c := range groups {
+		if c.children > 0 && g.slim.children[group] == nil {
+			g.slim.children[group] = make(map[types.Entity]struct{}, c.children)
+		}
+		if c.members > 0 && g.groupMembers[group] == nil {
+			g.groupMembers[group] = make(map[types.Member]struct{}, c.members)
+		}
+		if c.lowers > 0 && g.groupDownward[group] == nil {
+			g.groupDownward[group] = make(map[types.Group]struct{}, c.lowers)
+		}
+	}
+
+	eachEntity(polices, func(ent types.Entity, run []types.GroupingPolicy) {
+		if g.slim.parents[ent] == nil {
+			g.slim.parents[ent] = make(map[types.Group]struct{}, len(run))
+		}
+		for _, policy := range run {
+			g.slim.parents[ent][policy.Group] = struct{}{}
+			g.slim.children[policy.Group][ent] = struct{}{}
+		}
+	})
+
+	eachEntity(closures, func(ent types.Entity, run []types.GroupingPolicy) {
+		switch ent := ent.(type) {
+		case types.Member:
+			if g.memberGroups[ent] == nil {
+				g.memberGroups[ent] = make(map[types.Group]struct{}, len(run))
+			}
+			for _, closure := range run {
+				g.memberGroups[ent][closure.Group] = struct{}{}
+				g.groupMembers[closure.Group][ent] = struct{}{}
+			}
+
+		case types.Group:
+			if g.groupUpward[ent] == nil {
+				g.groupUpward[ent] = make(map[types.Group]struct{}, len(run))
+			}
+			for _, closure := range run {
+				g.groupUpward[ent][closure.Group] = struct{}{}
+				g.groupDownward[closure.Group][ent] = struct{}{}
+			}
+		}
+	})
+	return nil
+}
+
+// eachEntity calls fn with every run of polices of the same entity, polices of an entity are listed together
+// by warmState, and fn may be called more than once for an entity otherwise
+func eachEntity(polices []types.GroupingPolicy, fn func(ent types.Entity, run []types.GroupingPolicy)) {
+	for i := 0; i < len(polices); {
+		j := i + 1
+		for j < len(polices) && polices[j].Entity == polices[i].Entity {
+			j++
+		}
+		fn(polices[i].Entity, polices[i:j])
+		i = j
+	}
+}
+
+func (g *syncedGrouping) warmState() ([]types.GroupingPolicy, []types.GroupingPolicy, error) {
+	g.RLock()
+	defer g.RUnlock()
+
+	if w, ok := g.g.(warmer); ok {
+		return w.warmState()
+	}
+	polices, e := g.g.Policies()
+	return polices, nil, e
+}
+
+// warmStart restores the inner grouping if it is a warmer, or joins polices to it otherwise
+func (g *syncedGrouping) warmStart(polices, closures []types.GroupingPolicy) error {
+	g.Lock()
+	defer g.Unlock()
+
+	if w, ok := g.g.(warmer); ok && closures != nil {
+		return w.warmStart(polices, closures)
+	}
+	for _, policy := range polices {
+		if e := g.g.Join(policy.Entity, policy.Group); e != nil {
+			return e
+		}
+	}
+	return nil
+}
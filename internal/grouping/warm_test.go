@@ -0,0 +1,74 @@
+package grouping
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/supremind/rbac/internal/testdata"
+	"github.com/supremind/rbac/types"
+)
+
+var _ = Describe("warm started fat grouping", func() {
+	var built *fatGrouping
+
+	BeforeEach(func() {
+		built = newFatGrouping()
+		for user, roles := range UserRoles {
+			for _, role := range roles {
+				Expect(built.Join(user, role)).To(Succeed())
+			}
+		}
+		for role, uppers := range RoleInRoles {
+			for _, upper := range uppers {
+				Expect(built.Join(role, upper)).To(Succeed())
+			}
+		}
+	})
+
+	restore := func() *fatGrouping {
+		polices, closures, e := built.warmState()
+		Expect(e).To(Succeed())
+		Expect(closures).NotTo(BeNil())
+
+		g := newFatGrouping()
+		Expect(g.warmStart(polices, closures)).To(Succeed())
+		return g
+	}
+
+	It("should restore the same state as built by joining", func() {
+		g := restore()
+		Expect(g.slim.parents).To(Equal(built.slim.parents))
+		Expect(g.slim.children).To(Equal(built.slim.children))
+		Expect(g.memberGroups).To(Equal(built.memberGroups))
+		Expect(g.groupMembers).To(Equal(built.groupMembers))
+		Expect(g.groupUpward).To(Equal(built.groupUpward))
+		Expect(g.groupDownward).To(Equal(built.groupDownward))
+	})
+
+	It("should be changed as the built one after restored", func() {
+		g := restore()
+		user := types.User("1")
+		Expect(built.memberGroups[user]).NotTo(BeEmpty())
+
+		for role := range built.slim.parents[user] {
+			Expect(g.Leave(user, role)).To(Succeed())
+			Expect(built.Leave(user, role)).To(Succeed())
+			break
+		}
+		Expect(g.GroupsOf(user)).To(Equal(built.memberGroups[user]))
+		for group := range built.groupMembers {
+			Expect(g.MembersIn(group)).To(Equal(built.groupMembers[group]))
+		}
+	})
+
+	It("should join polices without closures", func() {
+		polices, _, e := built.warmState()
+		Expect(e).To(Succeed())
+
+		g := newSyncedGrouping(newFatGrouping())
+		Expect(g.warmStart(polices, nil)).To(Succeed())
+		for member := range built.memberGroups {
+			Expect(g.GroupsOf(member)).To(Equal(built.memberGroups[member]))
+		}
+	})
+})
@@ -0,0 +1,276 @@
+// Package hamt implements an immutable hash array mapped trie keyed by entities.
+//
+// Every update returns a new Map which shares all untouched nodes with the old one,
+// so a Map could be read by any number of goroutines without locking,
+// while writers build new versions next to it.
+package hamt
+
+import (
+	"math/bits"
+
+	"github.com/supremind/rbac/types"
+)
+
+const (
+	bitsPerLevel = 5
+	levelMask    = 1<<bitsPerLevel - 1
+	maxShift     = 32
+)
+
+// Map is an immutable map from entities to arbitrary values, the zero value is an empty map
+type Map struct {
+	root *node
+	size int
+}
+
+type node struct {
+	bitmap  uint32
+	entries []entry
+}
+
+// entry is either a sub node, or a leaf of key-values sharing the same hash
+type entry struct {
+	child *node
+	hash  uint32
+	kvs   []kv
+}
+
+type kv struct {
+	key   types.Entity
+	value interface{}
+}
+
+// hashOf hashes serialized keys with 32 bits FNV-1a,
+// known entities are hashed piece by piece, to avoid allocations of concatenating strings
+func hashOf(key types.Entity) uint32 {
+	const offset32 = 2166136261
+
+	switch k := key.(type) {
+	case types.User:
+		return fnv32a(fnv32a(offset32, "user:"), string(k))
+	case types.Role:
+		return fnv32a(fnv32a(offset32, "role:"), string(k))
+	case types.Article:
+		return fnv32a(fnv32a(offset32, "art:"), string(k))
+	case types.Category:
+		return fnv32a(fnv32a(offset32, "cat:"), string(k))
+	}
+	return fnv32a(offset32, key.String())
+}
+
+func fnv32a(h uint32, s string) uint32 {
+	const prime32 = 16777619
+
+	for i := 0; i < len(s); i++ {
+		h ^= uint32(s[i])
+		h *= prime32
+	}
+	return h
+}
+
+// Len returns number of keys in the map
+func (m Map) Len() int {
+	return m.size
+}
+
+// Get returns value of the key, and if the key exists
+func (m Map) Get(key types.Entity) (interface{}, bool) {
+	h := hashOf(key)
+	n := m.root
+	for shift := uint(0); n != nil; shift += bitsPerLevel {
+		bit := uint32(1) << ((h >> shift) & levelMask)
+		if n.bitmap&bit == 0 {
+			return nil, false
+		}
+		e := n.entries[bits.OnesCount32(n.bitmap&(bit-1))]
+		if e.child != nil {
+			n = e.child
+			continue
+		}
+		if e.hash != h {
+			return nil, false
+		}
+		for _, kv := range e.kvs {
+			if kv.key == key {
+				return kv.value, true
+			}
+		}
+		return nil, false
+	}
+	return nil, false
+}
+
+// Has tells if the key exists
+func (m Map) Has(key types.Entity) bool {
+	_, ok := m.Get(key)
+	return ok
+}
+
+// Set returns a new map with key set to value
+func (m Map) Set(key types.Entity, value interface{}) Map {
+	root := m.root
+	if root == nil {
+		root = &node{}
+	}
+	root, added := root.set(hashOf(key), 0, kv{key: key, value: value})
+	size := m.size
+	if added {
+		size++
+	}
+	return Map{root: root, size: size}
+}
+
+// Delete returns a new map without the key
+func (m Map) Delete(key types.Entity) Map {
+	if m.root == nil {
+		return m
+	}
+	root, removed := m.root.delete(hashOf(key), 0, key)
+	if !removed {
+		return m
+	}
+	if len(root.entries) == 0 {
+		root = nil
+	}
+	return Map{root: root, size: m.size - 1}
+}
+
+// Range calls fn for every key and value in the map, until fn returns false
+func (m Map) Range(fn func(types.Entity, interface{}) bool) {
+	if m.root != nil {
+		m.root.rangeOver(fn)
+	}
+}
+
+func (n *node) set(h uint32, shift uint, item kv) (*node, bool) {
+	bit := uint32(1) << ((h >> shift) & levelMask)
+	pos := bits.OnesCount32(n.bitmap & (bit - 1))
+
+	if n.bitmap&bit == 0 {
+		entries := make([]entry, len(n.entries)+1)
+		copy(entries, n.entries[:pos])
+		entries[pos] = entry{hash: h, kvs: []kv{item}}
+		copy(entries[pos+1:], n.entries[pos:])
+		return &node{bitmap: n.bitmap | bit, entries: entries}, true
+	}
+
+	e := n.entries[pos]
+	var added bool
+	switch {
+	case e.child != nil:
+		e.child, added = e.child.set(h, shift+bitsPerLevel, item)
+
+	case e.hash == h:
+		kvs := make([]kv, len(e.kvs), len(e.kvs)+1)
+		copy(kvs, e.kvs)
+		added = true
+		for i := range kvs {
+			if kvs[i].key == item.key {
+				kvs[i].value = item.value
+				added = false
+				break
+			}
+		}
+		if added {
+			kvs = append(kvs, item)
+		}
+		e.kvs = kvs
+
+	default:
+		// different hashes always diverge before running out of bits
+		var child *node
+		child, added = pushDown(e, shift+bitsPerLevel).set(h, shift+bitsPerLevel, item)
+		e = entry{child: child}
+	}
+
+	entries := make([]entry, len(n.entries))
+	copy(entries, n.entries)
+	entries[pos] = e
+	return &node{bitmap: n.bitmap, entries: entries}, added
+}
+
+// pushDown moves an existing leaf one level down
+func pushDown(leaf entry, shift uint) *node {
+	if shift >= maxShift {
+		panic("hamt: hash bits exhausted")
+	}
+	bit := uint32(1) << ((leaf.hash >> shift) & levelMask)
+	return &node{bitmap: bit, entries: []entry{leaf}}
+}
+
+func (n *node) delete(h uint32, shift uint, key types.Entity) (*node, bool) {
+	bit := uint32(1) << ((h >> shift) & levelMask)
+	if n.bitmap&bit == 0 {
+		return n, false
+	}
+	pos := bits.OnesCount32(n.bitmap & (bit - 1))
+	e := n.entries[pos]
+
+	switch {
+	case e.child != nil:
+		child, removed := e.child.delete(h, shift+bitsPerLevel, key)
+		if !removed {
+			return n, false
+		}
+		if len(child.entries) == 0 {
+			return n.without(pos, bit), true
+		}
+		if len(child.entries) == 1 && child.entries[0].child == nil {
+			// pull a lonely leaf up
+			e = child.entries[0]
+		} else {
+			e.child = child
+		}
+
+	case e.hash == h:
+		idx := -1
+		for i := range e.kvs {
+			if e.kvs[i].key == key {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return n, false
+		}
+		if len(e.kvs) == 1 {
+			return n.without(pos, bit), true
+		}
+		kvs := make([]kv, 0, len(e.kvs)-1)
+		kvs = append(kvs, e.kvs[:idx]...)
+		kvs = append(kvs, e.kvs[idx+1:]...)
+		e.kvs = kvs
+
+	default:
+		return n, false
+	}
+
+	entries := make([]entry, len(n.entries))
+	copy(entries, n.entries)
+	entries[pos] = e
+	return &node{bitmap: n.bitmap, entries: entries}, true
+}
+
+func (n *node) without(pos int, bit uint32) *node {
+	entries := make([]entry, 0, len(n.entries)-1)
+	entries = append(entries, n.entries[:pos]...)
+	entries = append(entries, n.entries[pos+1:]...)
+	return &node{bitmap: n.bitmap &^ bit, entries: entries}
+}
+
+func (n *node) rangeOver(fn func(types.Entity, interface{}) bool) bool {
+	for _, e := range n.entries {
+		if e.child != nil {
+			if !e.child.rangeOver(fn) {
+				return false
+			}
+			continue
+		}
+		for _, kv := range e.kvs {
+			if !fn(kv.key, kv.value) {
+				return false
+			}
+		}
+	}
+	return true
+}
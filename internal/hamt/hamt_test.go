@@ -0,0 +1,77 @@
+package hamt
+
+import (
+	"strconv"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/supremind/rbac/types"
+)
+
+func TestHAMT(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "hamt test suit")
+}
+
+var _ = Describe("immutable map", func() {
+	const n = 10000
+
+	var full Map
+	BeforeEach(func() {
+		full = Map{}
+		for i := 0; i < n; i++ {
+			full = full.Set(types.User(strconv.Itoa(i)), i)
+		}
+	})
+
+	It("should get what have been set", func() {
+		Expect(full.Len()).To(Equal(n))
+		for i := 0; i < n; i++ {
+			v, ok := full.Get(types.User(strconv.Itoa(i)))
+			Expect(ok).To(BeTrue())
+			Expect(v).To(Equal(i))
+		}
+		Expect(full.Has(types.Role("0"))).To(BeFalse())
+	})
+
+	It("should range over all keys", func() {
+		seen := make(map[types.Entity]interface{}, n)
+		full.Range(func(key types.Entity, value interface{}) bool {
+			seen[key] = value
+			return true
+		})
+		Expect(seen).To(HaveLen(n))
+	})
+
+	It("should not change old versions", func() {
+		old := full
+		updated := full.Set(types.User("0"), "updated").Delete(types.User("1")).Set(types.Role("0"), 0)
+
+		Expect(old.Len()).To(Equal(n))
+		Expect(valueOf(old, types.User("0"))).To(Equal(0))
+		Expect(old.Has(types.User("1"))).To(BeTrue())
+		Expect(old.Has(types.Role("0"))).To(BeFalse())
+
+		Expect(updated.Len()).To(Equal(n))
+		Expect(valueOf(updated, types.User("0"))).To(Equal("updated"))
+		Expect(updated.Has(types.User("1"))).To(BeFalse())
+		Expect(updated.Has(types.Role("0"))).To(BeTrue())
+	})
+
+	It("should be empty after deleting all keys", func() {
+		m := full
+		for i := 0; i < n; i++ {
+			m = m.Delete(types.User(strconv.Itoa(i)))
+		}
+		Expect(m.Len()).To(BeZero())
+		Expect(m.root).To(BeNil())
+		Expect(full.Len()).To(Equal(n))
+	})
+})
+
+func valueOf(m Map, key types.Entity) interface{} {
+	v, _ := m.Get(key)
+	return v
+}
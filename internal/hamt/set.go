@@ -0,0 +1,38 @@
+package hamt
+
+import "github.com/supremind/rbac/types"
+
+// Set is an immutable set of entities, the zero value is an empty set
+type Set struct {
+	m Map
+}
+
+// Len returns number of entities in the set
+func (s Set) Len() int {
+	return s.m.Len()
+}
+
+// Has tells if the entity is in the set
+func (s Set) Has(ent types.Entity) bool {
+	return s.m.Has(ent)
+}
+
+// Add returns a new set with the entity added
+func (s Set) Add(ent types.Entity) Set {
+	if s.m.Has(ent) {
+		return s
+	}
+	return Set{m: s.m.Set(ent, nil)}
+}
+
+// Remove returns a new set without the entity
+func (s Set) Remove(ent types.Entity) Set {
+	return Set{m: s.m.Delete(ent)}
+}
+
+// Range calls fn for every entity in the set, until fn returns false
+func (s Set) Range(fn func(types.Entity) bool) {
+	s.m.Range(func(ent types.Entity, _ interface{}) bool {
+		return fn(ent)
+	})
+}
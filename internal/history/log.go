@@ -0,0 +1,80 @@
+// Package history keeps versions of immutable states, so that states in the past could be looked up by time
+package history
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Log records versions of an immutable state with time they were published,
+// versions older than retention, or beyond the limit are dropped
+type Log struct {
+	versions  []version
+	retention time.Duration
+	limit     int
+	sync.RWMutex
+}
+
+type version struct {
+	at    time.Time
+	state interface{}
+}
+
+// New creates a Log keeps versions for retention, but no more than limit versions,
+// non-positive retention or limit means no limit on it
+func New(retention time.Duration, limit int) *Log {
+	return &Log{
+		retention: retention,
+		limit:     limit,
+	}
+}
+
+// Record the state as the latest version
+func (l *Log) Record(state interface{}) {
+	now := time.Now()
+
+	l.Lock()
+	defer l.Unlock()
+
+	l.versions = append(l.versions, version{at: now, state: state})
+
+	drop := 0
+	if l.retention > 0 {
+		// the newest version published before the cutoff is still effective at the cutoff, keep it
+		cutoff := now.Add(-l.retention)
+		for drop+1 < len(l.versions) && !l.versions[drop+1].at.After(cutoff) {
+			drop++
+		}
+	}
+	if l.limit > 0 && len(l.versions)-drop > l.limit {
+		drop = len(l.versions) - l.limit
+	}
+	for i := 0; i < drop; i++ {
+		l.versions[i] = version{}
+	}
+	l.versions = l.versions[drop:]
+}
+
+// At returns the version effective at the given time, it returns false if the time is out of the log
+func (l *Log) At(t time.Time) (interface{}, bool) {
+	l.RLock()
+	defer l.RUnlock()
+
+	// index of the first version published after t
+	i := sort.Search(len(l.versions), func(i int) bool {
+		return l.versions[i].at.After(t)
+	})
+	if i == 0 {
+		return nil, false
+	}
+	return l.versions[i-1].state, true
+}
+
+// Len returns the number of versions kept
+func (l *Log) Len() int {
+	l.RLock()
+	defer l.RUnlock()
+
+	return len(l.versions)
+}
@@ -0,0 +1,86 @@
+package intern
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/supremind/rbac/types"
+)
+
+func TestIntern(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "intern test suit")
+}
+
+var _ = Describe("interning table", func() {
+	var t *Table
+	BeforeEach(func() {
+		t = NewTable()
+	})
+
+	It("should intern entities into dense ids", func() {
+		Expect(t.Intern(types.User("alan"))).To(BeEquivalentTo(0))
+		Expect(t.Intern(types.Role("alan"))).To(BeEquivalentTo(1))
+		Expect(t.Intern(types.User("alan"))).To(BeEquivalentTo(0))
+		Expect(t.Len()).To(Equal(2))
+
+		id, ok := t.Lookup(types.Role("alan"))
+		Expect(ok).To(BeTrue())
+		Expect(t.Entity(id)).To(Equal(types.Role("alan")))
+		_, ok = t.Lookup(types.Article("alan"))
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should reuse released ids", func() {
+		t.Intern(types.User("alan"))
+		bob := t.Intern(types.User("bob"))
+		t.Intern(types.User("carl"))
+
+		t.Release(bob)
+		t.Release(bob)
+		Expect(t.Len()).To(Equal(2))
+		Expect(t.Entity(bob)).To(BeNil())
+		_, ok := t.Lookup(types.User("bob"))
+		Expect(ok).To(BeFalse())
+
+		Expect(t.Intern(types.User("dan"))).To(Equal(bob))
+		Expect(t.Cap()).To(Equal(3))
+
+		var ents []types.Entity
+		t.Range(func(_ uint32, ent types.Entity) bool {
+			ents = append(ents, ent)
+			return true
+		})
+		Expect(ents).To(Equal([]types.Entity{types.User("alan"), types.User("dan"), types.User("carl")}))
+	})
+})
+
+var _ = Describe("sorted set", func() {
+	It("should be the same as a map", func() {
+		r := rand.New(rand.NewSource(GinkgoRandomSeed()))
+		var s Set
+		m := make(map[uint32]struct{})
+		for i := 0; i < 10000; i++ {
+			id := uint32(r.Intn(100))
+			if r.Intn(2) == 0 {
+				s = s.Add(id)
+				m[id] = struct{}{}
+			} else {
+				s = s.Remove(id)
+				delete(m, id)
+			}
+			_, ok := m[id]
+			Expect(s.Has(id)).To(Equal(ok))
+		}
+
+		Expect(s).To(HaveLen(len(m)))
+		Expect(sort.SliceIsSorted(s, func(i, j int) bool { return s[i] < s[j] })).To(BeTrue())
+		for id := range m {
+			Expect(s.Has(id)).To(BeTrue())
+		}
+	})
+})
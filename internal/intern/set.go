@@ -0,0 +1,43 @@
+package intern
+
+import "sort"
+
+// Set is a set of ids kept in a sorted slice, the zero value is an empty set.
+// Add and Remove may change the underlying array, results of them should be kept instead.
+type Set []uint32
+
+func (s Set) search(id uint32) int {
+	return sort.Search(len(s), func(i int) bool { return s[i] >= id })
+}
+
+// Has tells if id is in the set
+func (s Set) Has(id uint32) bool {
+	i := s.search(id)
+	return i < len(s) && s[i] == id
+}
+
+// Add returns the set with id added
+func (s Set) Add(id uint32) Set {
+	i := s.search(id)
+	if i < len(s) && s[i] == id {
+		return s
+	}
+	s = append(s, 0)
+	copy(s[i+1:], s[i:])
+	s[i] = id
+	return s
+}
+
+// Remove returns the set without id
+func (s Set) Remove(id uint32) Set {
+	i := s.search(id)
+	if i == len(s) || s[i] != id {
+		return s
+	}
+	copy(s[i:], s[i+1:])
+	s = s[:len(s)-1]
+	if len(s) == 0 {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,84 @@
+// Package intern maps entities to dense integer ids, and keeps sets of ids in sorted slices.
+//
+// An interface value wrapping a string takes a few words, and a map of them many more,
+// while an id takes 4 bytes, so polices kept as ids take a fraction of the memory.
+package intern
+
+import "github.com/supremind/rbac/types"
+
+// Table interns entities into dense integer ids, so that polices could be kept as ids,
+// instead of interface values wrapping strings. Ids of released entities are reused.
+// It is not safe for concurrent use.
+type Table struct {
+	ids      map[types.Entity]uint32
+	entities []types.Entity // by id, nil if released
+	free     []uint32
+}
+
+// NewTable creates an empty Table
+func NewTable() *Table {
+	return &Table{ids: make(map[types.Entity]uint32)}
+}
+
+// Intern returns id of ent, it is allocated if ent is not interned yet
+func (t *Table) Intern(ent types.Entity) uint32 {
+	if id, ok := t.ids[ent]; ok {
+		return id
+	}
+
+	var id uint32
+	if n := len(t.free); n > 0 {
+		id = t.free[n-1]
+		t.free = t.free[:n-1]
+		t.entities[id] = ent
+	} else {
+		id = uint32(len(t.entities))
+		t.entities = append(t.entities, ent)
+	}
+	t.ids[ent] = id
+	return id
+}
+
+// Lookup returns id of ent, ok is false if it is not interned
+func (t *Table) Lookup(ent types.Entity) (id uint32, ok bool) {
+	id, ok = t.ids[ent]
+	return
+}
+
+// Entity returns the entity of id, or nil if it is released
+func (t *Table) Entity(id uint32) types.Entity {
+	return t.entities[id]
+}
+
+// Release id of ent, so that it could be reused
+func (t *Table) Release(id uint32) {
+	ent := t.entities[id]
+	if ent == nil {
+		return
+	}
+	delete(t.ids, ent)
+	t.entities[id] = nil
+	t.free = append(t.free, id)
+}
+
+// Len returns number of interned entities
+func (t *Table) Len() int {
+	return len(t.ids)
+}
+
+// Cap returns the upper bound of ids, so that slices indexed by ids could be sized
+func (t *Table) Cap() int {
+	return len(t.entities)
+}
+
+// Range calls fn for every interned entity, until fn returns false
+func (t *Table) Range(fn func(id uint32, ent types.Entity) bool) {
+	for id, ent := range t.entities {
+		if ent == nil {
+			continue
+		}
+		if !fn(uint32(id), ent) {
+			return
+		}
+	}
+}
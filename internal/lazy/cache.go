@@ -0,0 +1,108 @@
+// Package lazy keeps polices loaded on demand from persisters, in a bounded cache.
+package lazy
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Cache keeps the least recently used polices loaded from persisters, keyed by what they are loaded for.
+// Cached polices are never changed, they are dropped by Invalidate instead, after polices in persisters change,
+// and polices loaded while changing are not cached, see Epoch.
+// All methods are safe for concurrent use.
+type Cache struct {
+	capacity int
+	entries  map[interface{}]*list.Element
+	lru      *list.List // of *entry, the most recently used at front
+	epoch    uint64     // increased by every invalidation
+	sync.Mutex
+}
+
+type entry struct {
+	key   interface{}
+	value interface{}
+}
+
+// New creates a Cache keeps up to capacity entries
+func New(capacity int) *Cache {
+	return &Cache{
+		capacity: capacity,
+		entries:  make(map[interface{}]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// Get returns the value cached for key
+func (c *Cache) Get(key interface{}) (interface{}, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*entry).value, true
+}
+
+// Epoch returns the current epoch, it should be taken before loading a value to be cached
+func (c *Cache) Epoch() uint64 {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.epoch
+}
+
+// Put caches value for key, it is ignored if anything is invalidated since epoch,
+// because the value may be loaded before the change
+func (c *Cache) Put(epoch uint64, key, value interface{}) {
+	c.Lock()
+	defer c.Unlock()
+
+	if epoch != c.epoch || c.capacity <= 0 {
+		return
+	}
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*entry).value = value
+		c.lru.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(&entry{key: key, value: value})
+	for c.lru.Len() > c.capacity {
+		oldest := c.lru.Remove(c.lru.Back()).(*entry)
+		delete(c.entries, oldest.key)
+	}
+}
+
+// Invalidate drops values cached for keys
+func (c *Cache) Invalidate(keys ...interface{}) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.epoch++
+	for _, key := range keys {
+		if elem, ok := c.entries[key]; ok {
+			c.lru.Remove(elem)
+			delete(c.entries, key)
+		}
+	}
+}
+
+// Purge drops all cached values
+func (c *Cache) Purge() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.epoch++
+	c.entries = make(map[interface{}]*list.Element)
+	c.lru.Init()
+}
+
+// Len returns number of cached values
+func (c *Cache) Len() int {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.lru.Len()
+}
@@ -0,0 +1,63 @@
+package lazy
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestLazy(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "lazy test suit")
+}
+
+var _ = Describe("lazy cache", func() {
+	var c *Cache
+
+	BeforeEach(func() {
+		c = New(2)
+	})
+
+	put := func(key, value interface{}) {
+		c.Put(c.Epoch(), key, value)
+	}
+	get := func(key interface{}) interface{} {
+		value, _ := c.Get(key)
+		return value
+	}
+
+	It("should evict the least recently used", func() {
+		put("alan", 1)
+		put("bob", 2)
+		Expect(get("alan")).To(Equal(1))
+
+		put("carl", 3)
+		Expect(c.Len()).To(Equal(2))
+		_, ok := c.Get("bob")
+		Expect(ok).To(BeFalse())
+		Expect(get("alan")).To(Equal(1))
+		Expect(get("carl")).To(Equal(3))
+	})
+
+	It("should drop invalidated values", func() {
+		put("alan", 1)
+		put("bob", 2)
+
+		c.Invalidate("alan", "carl")
+		_, ok := c.Get("alan")
+		Expect(ok).To(BeFalse())
+		Expect(get("bob")).To(Equal(2))
+
+		c.Purge()
+		Expect(c.Len()).To(BeZero())
+	})
+
+	It("should not cache values loaded before invalidations", func() {
+		epoch := c.Epoch()
+		c.Invalidate("bob")
+		c.Put(epoch, "alan", 1)
+		_, ok := c.Get("alan")
+		Expect(ok).To(BeFalse())
+	})
+})
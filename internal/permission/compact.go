@@ -0,0 +1,163 @@
+package permission
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/supremind/rbac/internal/intern"
+	"github.com/supremind/rbac/types"
+)
+
+var _ types.Permission = (*compactPermission)(nil)
+
+// compactPermission knows only direct subject-object-actions relationships, as thinPermission does,
+// but keeps them as interned ids in sorted slices, instead of maps of entities.
+type compactPermission struct {
+	ids *intern.Table
+	// by id of entities, actions on objects for subjects, or actions of subjects for objects,
+	// subjects and objects never share ids since they are different entities
+	grants []grants
+}
+
+// grant is actions on an object or of a subject, by its id
+type grant struct {
+	id  uint32
+	act types.Action
+}
+
+// grants are sorted by ids
+type grants []grant
+
+func (gs grants) search(id uint32) int {
+	return sort.Search(len(gs), func(i int) bool { return gs[i].id >= id })
+}
+
+func (gs grants) get(id uint32) types.Action {
+	if i := gs.search(id); i < len(gs) && gs[i].id == id {
+		return gs[i].act
+	}
+	return types.None
+}
+
+// set actions of id, it is removed if act is None
+func (gs grants) set(id uint32, act types.Action) grants {
+	i := gs.search(id)
+	if i < len(gs) && gs[i].id == id {
+		if act != types.None {
+			gs[i].act = act
+			return gs
+		}
+		copy(gs[i:], gs[i+1:])
+		gs = gs[:len(gs)-1]
+		if len(gs) == 0 {
+			return nil
+		}
+		return gs
+	}
+	if act == types.None {
+		return gs
+	}
+
+	gs = append(gs, grant{})
+	copy(gs[i+1:], gs[i:])
+	gs[i] = grant{id: id, act: act}
+	return gs
+}
+
+func newCompactPermission() *compactPermission {
+	return &compactPermission{ids: intern.NewTable()}
+}
+
+func (p *compactPermission) intern(ent types.Entity) uint32 {
+	id := p.ids.Intern(ent)
+	for len(p.grants) < p.ids.Cap() {
+		p.grants = append(p.grants, nil)
+	}
+	return id
+}
+
+// release id if nothing is granted on or to it
+func (p *compactPermission) release(id uint32) {
+	if len(p.grants[id]) == 0 {
+		p.ids.Release(id)
+	}
+}
+
+func (p *compactPermission) Permit(sub types.Subject, obj types.Object, act types.Action) error {
+	s, o := p.intern(sub), p.intern(obj)
+	p.grants[s] = p.grants[s].set(o, p.grants[s].get(o)|act)
+	p.grants[o] = p.grants[o].set(s, p.grants[o].get(s)|act)
+	p.release(s)
+	p.release(o)
+	return nil
+}
+
+func (p *compactPermission) Revoke(sub types.Subject, obj types.Object, act types.Action) error {
+	s, ok := p.ids.Lookup(sub)
+	if !ok {
+		return fmt.Errorf("%w: permission %s -[%s]-> %s", types.ErrNotFound, sub, obj, act)
+	}
+	o, ok := p.ids.Lookup(obj)
+	if !ok {
+		return fmt.Errorf("%w: permission %s -[%s]-> %s", types.ErrNotFound, sub, obj, act)
+	}
+
+	p.grants[s] = p.grants[s].set(o, p.grants[s].get(o).Difference(act))
+	p.grants[o] = p.grants[o].set(s, p.grants[o].get(s).Difference(act))
+	p.release(s)
+	p.release(o)
+	return nil
+}
+
+func (p *compactPermission) Shall(sub types.Subject, obj types.Object, act types.Action) (bool, error) {
+	allowed, e := p.PermittedActions(sub, obj)
+	return allowed.Includes(act), e
+}
+
+// Policies lists all polices
+func (p *compactPermission) Policies() ([]types.PermissionPolicy, error) {
+	polices := make([]types.PermissionPolicy, 0, p.ids.Len())
+	p.ids.Range(func(id uint32, ent types.Entity) bool {
+		sub, ok := ent.(types.Subject)
+		if !ok {
+			return true
+		}
+		for _, g := range p.grants[id] {
+			polices = append(polices, types.PermissionPolicy{Subject: sub, Object: p.ids.Entity(g.id).(types.Object), Action: g.act})
+		}
+		return true
+	})
+	return polices, nil
+}
+
+func (p *compactPermission) PermissionsOn(obj types.Object) (map[types.Subject]types.Action, error) {
+	perms := make(map[types.Subject]types.Action)
+	if o, ok := p.ids.Lookup(obj); ok {
+		for _, g := range p.grants[o] {
+			perms[p.ids.Entity(g.id).(types.Subject)] = g.act
+		}
+	}
+	return perms, nil
+}
+
+func (p *compactPermission) PermissionsFor(sub types.Subject) (map[types.Object]types.Action, error) {
+	perms := make(map[types.Object]types.Action)
+	if s, ok := p.ids.Lookup(sub); ok {
+		for _, g := range p.grants[s] {
+			perms[p.ids.Entity(g.id).(types.Object)] = g.act
+		}
+	}
+	return perms, nil
+}
+
+func (p *compactPermission) PermittedActions(sub types.Subject, obj types.Object) (types.Action, error) {
+	s, ok := p.ids.Lookup(sub)
+	if !ok {
+		return types.None, nil
+	}
+	o, ok := p.ids.Lookup(obj)
+	if !ok {
+		return types.None, nil
+	}
+	return p.grants[s].get(o), nil
+}
@@ -0,0 +1,224 @@
+package permission
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/supremind/rbac/internal/hamt"
+	"github.com/supremind/rbac/internal/history"
+	"github.com/supremind/rbac/types"
+)
+
+var _ types.Permission = (*cowPermission)(nil)
+
+// cowPermission keeps permission polices in immutable snapshots.
+// Writers build a new snapshot sharing unchanged parts with the current one, and swap it in atomically,
+// readers just load the current snapshot, they never block, and never see a half done change.
+type cowPermission struct {
+	current    atomic.Value // *permissionSnapshot
+	history    *history.Log // optional, records every published snapshot
+	sync.Mutex              // serializes writers
+}
+
+func newCOWPermission(h *history.Log) *cowPermission {
+	p := &cowPermission{history: h}
+	p.publish(&permissionSnapshot{})
+	return p
+}
+
+func (p *cowPermission) publish(s *permissionSnapshot) {
+	p.current.Store(s)
+	if p.history != nil {
+		p.history.Record(s)
+	}
+}
+
+func (p *cowPermission) load() *permissionSnapshot {
+	return p.current.Load().(*permissionSnapshot)
+}
+
+// update applies fn to a copy of the current snapshot, and publishes the copy if fn succeeded
+func (p *cowPermission) update(fn func(*permissionSnapshot) error) error {
+	p.Lock()
+	defer p.Unlock()
+
+	next := *p.load()
+	if e := fn(&next); e != nil {
+		return e
+	}
+	p.publish(&next)
+	return nil
+}
+
+// At returns a read-only permission as it was at the given time
+func (p *cowPermission) At(t time.Time) (types.Permission, error) {
+	if p.history == nil {
+		return nil, types.ErrNoHistory
+	}
+	s, ok := p.history.At(t)
+	if !ok {
+		return nil, fmt.Errorf("%w: permission at %s", types.ErrNoHistory, t)
+	}
+	return frozenPermission{s.(*permissionSnapshot)}, nil
+}
+
+// permitAll permits polices iterated by each on a copy of the current snapshot, and publishes them as a single version
+func (p *cowPermission) permitAll(each func(permit func(types.Subject, types.Object, types.Action) error) error) error {
+	return p.update(func(s *permissionSnapshot) error {
+		return each(func(sub types.Subject, obj types.Object, act types.Action) error {
+			s.permit(sub, obj, act)
+			return nil
+		})
+	})
+}
+
+// Frozen returns a read-only permission of the current snapshot
+func (p *cowPermission) Frozen() types.Permission {
+	return frozenPermission{p.load()}
+}
+
+func (p *cowPermission) Permit(sub types.Subject, obj types.Object, act types.Action) error {
+	return p.update(func(s *permissionSnapshot) error {
+		s.permit(sub, obj, act)
+		return nil
+	})
+}
+
+func (p *cowPermission) Revoke(sub types.Subject, obj types.Object, act types.Action) error {
+	return p.update(func(s *permissionSnapshot) error {
+		return s.revoke(sub, obj, act)
+	})
+}
+
+func (p *cowPermission) Shall(sub types.Subject, obj types.Object, act types.Action) (bool, error) {
+	return p.load().Shall(sub, obj, act)
+}
+
+func (p *cowPermission) PermissionsOn(obj types.Object) (map[types.Subject]types.Action, error) {
+	return p.load().PermissionsOn(obj)
+}
+
+func (p *cowPermission) PermissionsFor(sub types.Subject) (map[types.Object]types.Action, error) {
+	return p.load().PermissionsFor(sub)
+}
+
+// Policies lists all polices
+func (p *cowPermission) Policies() ([]types.PermissionPolicy, error) {
+	return p.load().Policies()
+}
+
+func (p *cowPermission) PermittedActions(sub types.Subject, obj types.Object) (types.Action, error) {
+	return p.load().PermittedActions(sub, obj)
+}
+
+// permissionSnapshot is an immutable state of permissions once published,
+// copies of it share all the underlying data until being changed
+type permissionSnapshot struct {
+	bySubject hamt.Map // subject => hamt.Map of object => action
+	byObject  hamt.Map // object => hamt.Map of subject => action
+}
+
+// frozenPermission is a read-only permission of a published snapshot
+type frozenPermission struct {
+	*permissionSnapshot
+}
+
+func (frozenPermission) Permit(types.Subject, types.Object, types.Action) error {
+	return types.ErrReadOnly
+}
+
+func (frozenPermission) Revoke(types.Subject, types.Object, types.Action) error {
+	return types.ErrReadOnly
+}
+
+func mapOf(m hamt.Map, ent types.Entity) hamt.Map {
+	if inner, ok := m.Get(ent); ok {
+		return inner.(hamt.Map)
+	}
+	return hamt.Map{}
+}
+
+func actionOf(m hamt.Map, ent types.Entity) types.Action {
+	if act, ok := m.Get(ent); ok {
+		return act.(types.Action)
+	}
+	return 0
+}
+
+func (s *permissionSnapshot) permit(sub types.Subject, obj types.Object, act types.Action) {
+	objs := mapOf(s.bySubject, sub)
+	s.bySubject = s.bySubject.Set(sub, objs.Set(obj, actionOf(objs, obj)|act))
+
+	subs := mapOf(s.byObject, obj)
+	s.byObject = s.byObject.Set(obj, subs.Set(sub, actionOf(subs, sub)|act))
+}
+
+func (s *permissionSnapshot) revoke(sub types.Subject, obj types.Object, act types.Action) error {
+	if !s.bySubject.Has(sub) || !s.byObject.Has(obj) {
+		return fmt.Errorf("%w: permission %s -[%s]-> %s", types.ErrNotFound, sub, obj, act)
+	}
+
+	objs := mapOf(s.bySubject, sub)
+	if after := actionOf(objs, obj).Difference(act); after > 0 {
+		objs = objs.Set(obj, after)
+	} else {
+		objs = objs.Delete(obj)
+	}
+	s.bySubject = s.bySubject.Set(sub, objs)
+
+	subs := mapOf(s.byObject, obj)
+	if after := actionOf(subs, sub).Difference(act); after > 0 {
+		subs = subs.Set(sub, after)
+	} else {
+		subs = subs.Delete(sub)
+	}
+	s.byObject = s.byObject.Set(obj, subs)
+
+	return nil
+}
+
+func (s *permissionSnapshot) Shall(sub types.Subject, obj types.Object, act types.Action) (bool, error) {
+	return actionOf(mapOf(s.bySubject, sub), obj).Includes(act), nil
+}
+
+func (s *permissionSnapshot) PermissionsOn(obj types.Object) (map[types.Subject]types.Action, error) {
+	subs := mapOf(s.byObject, obj)
+	perms := make(map[types.Subject]types.Action, subs.Len())
+	subs.Range(func(sub types.Entity, act interface{}) bool {
+		perms[sub.(types.Subject)] = act.(types.Action)
+		return true
+	})
+	return perms, nil
+}
+
+func (s *permissionSnapshot) PermissionsFor(sub types.Subject) (map[types.Object]types.Action, error) {
+	objs := mapOf(s.bySubject, sub)
+	perms := make(map[types.Object]types.Action, objs.Len())
+	objs.Range(func(obj types.Entity, act interface{}) bool {
+		perms[obj.(types.Object)] = act.(types.Action)
+		return true
+	})
+	return perms, nil
+}
+
+func (s *permissionSnapshot) Policies() ([]types.PermissionPolicy, error) {
+	polices := make([]types.PermissionPolicy, 0, s.bySubject.Len())
+	s.bySubject.Range(func(sub types.Entity, objs interface{}) bool {
+		objs.(hamt.Map).Range(func(obj types.Entity, act interface{}) bool {
+			polices = append(polices, types.PermissionPolicy{
+				Subject: sub.(types.Subject),
+				Object:  obj.(types.Object),
+				Action:  act.(types.Action),
+			})
+			return true
+		})
+		return true
+	})
+	return polices, nil
+}
+
+func (s *permissionSnapshot) PermittedActions(sub types.Subject, obj types.Object) (types.Action, error) {
+	return actionOf(mapOf(s.bySubject, sub), obj), nil
+}
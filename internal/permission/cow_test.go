@@ -0,0 +1,33 @@
+package permission
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/supremind/rbac/internal/history"
+	"github.com/supremind/rbac/persist"
+	"github.com/supremind/rbac/persist/fake"
+)
+
+var _ = Describe("copy-on-write permission with history", func() {
+	It("should record persisted polices as a single version", func() {
+		pp := fake.NewPermissionPersister()
+		for _, policy := range directPolices {
+			Expect(pp.Insert(policy.sub, policy.obj, policy.act)).To(Succeed())
+		}
+
+		h := history.New(0, 0)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		p, e := New(ctx, persist.PermissionWithContext(pp), logr.Discard(), Config{Component: "permission", History: h})
+		Expect(e).To(Succeed())
+
+		for _, policy := range directPolices {
+			Expect(p.PermittedActions(policy.sub, policy.obj)).To(Equal(policy.act))
+		}
+		Expect(h.Len()).To(Equal(2))
+	})
+})
@@ -0,0 +1,125 @@
+package permission
+
+import (
+	"context"
+	"errors"
+
+	"github.com/supremind/rbac/internal/lazy"
+	"github.com/supremind/rbac/types"
+)
+
+var _ types.Permission = (*lazyPermission)(nil)
+
+// lazyPermission loads polices of subjects and objects from the persister when they are queried,
+// and caches the recently used ones, instead of keeping all polices in memory.
+// The persister is the only source of truth, writes just drop outdated polices from the cache,
+// they are loaded again when queried next time.
+// Subjects and objects are different entities, so they are cached with themselves as keys,
+// and actions permitted to a subject on an object are cached with the pair of them as the key.
+type lazyPermission struct {
+	q     types.PermissionQuerier
+	pp    types.PermissionPersisterContext
+	cache *lazy.Cache
+}
+
+func newLazyPermission(q types.PermissionQuerier, pp types.PermissionPersisterContext, size int) *lazyPermission {
+	return &lazyPermission{
+		q:     q,
+		pp:    pp,
+		cache: lazy.New(size),
+	}
+}
+
+func (p *lazyPermission) Permit(sub types.Subject, obj types.Object, act types.Action) error {
+	p.reload(sub, obj)
+	return nil
+}
+
+func (p *lazyPermission) Revoke(sub types.Subject, obj types.Object, act types.Action) error {
+	p.reload(sub, obj)
+	return nil
+}
+
+// lazyGrant is the cache key of actions permitted to a subject on an object
+type lazyGrant struct {
+	sub types.Subject
+	obj types.Object
+}
+
+// reload drops polices cached for sub and obj, so that they are loaded again when queried
+func (p *lazyPermission) reload(sub types.Subject, obj types.Object) {
+	p.cache.Invalidate(sub, obj, lazyGrant{sub: sub, obj: obj})
+}
+
+func (p *lazyPermission) Shall(sub types.Subject, obj types.Object, act types.Action) (bool, error) {
+	allowed, e := p.PermittedActions(sub, obj)
+	return allowed.Includes(act), e
+}
+
+// Policies lists all polices from the persister
+func (p *lazyPermission) Policies() ([]types.PermissionPolicy, error) {
+	return p.pp.ListContext(context.Background())
+}
+
+// PermissionsOn returns cached permissions on obj, or loads them from the persister.
+// Returned permissions must not be changed.
+func (p *lazyPermission) PermissionsOn(obj types.Object) (map[types.Subject]types.Action, error) {
+	if perms, ok := p.cache.Get(obj); ok {
+		return perms.(map[types.Subject]types.Action), nil
+	}
+
+	epoch := p.cache.Epoch()
+	polices, _, e := p.q.ListByObject(obj, "", 0)
+	if e != nil {
+		return nil, e
+	}
+	perms := make(map[types.Subject]types.Action, len(polices))
+	for _, policy := range polices {
+		perms[policy.Subject] |= policy.Action
+	}
+	p.cache.Put(epoch, obj, perms)
+	return perms, nil
+}
+
+// PermissionsFor returns cached permissions for sub, or loads them from the persister.
+// Returned permissions must not be changed.
+func (p *lazyPermission) PermissionsFor(sub types.Subject) (map[types.Object]types.Action, error) {
+	if perms, ok := p.cache.Get(sub); ok {
+		return perms.(map[types.Object]types.Action), nil
+	}
+
+	epoch := p.cache.Epoch()
+	polices, _, e := p.q.ListBySubject(sub, "", 0)
+	if e != nil {
+		return nil, e
+	}
+	perms := make(map[types.Object]types.Action, len(polices))
+	for _, policy := range polices {
+		perms[policy.Object] |= policy.Action
+	}
+	p.cache.Put(epoch, sub, perms)
+	return perms, nil
+}
+
+// PermittedActions returns actions permitted to sub on obj, from cached permissions for sub if there are,
+// or else the single policy of them is cached, or loaded from the persister
+func (p *lazyPermission) PermittedActions(sub types.Subject, obj types.Object) (types.Action, error) {
+	if perms, ok := p.cache.Get(sub); ok {
+		return perms.(map[types.Object]types.Action)[obj], nil
+	}
+	key := lazyGrant{sub: sub, obj: obj}
+	if act, ok := p.cache.Get(key); ok {
+		return act.(types.Action), nil
+	}
+
+	epoch := p.cache.Epoch()
+	act, e := p.q.Get(sub, obj)
+	if errors.Is(e, types.ErrNotFound) {
+		act, e = types.None, nil
+	}
+	if e != nil {
+		return types.None, e
+	}
+	p.cache.Put(epoch, key, act)
+	return act, nil
+}
@@ -4,10 +4,83 @@ import (
 	"context"
 
 	"github.com/go-logr/logr"
+	"github.com/supremind/rbac/internal/history"
+	"github.com/supremind/rbac/internal/snapshot"
 	"github.com/supremind/rbac/types"
 )
 
+// Config controls optional features of the permission
+type Config struct {
+	// CopyOnWrite keeps polices in immutable snapshots, so that they could be read without locking
+	CopyOnWrite bool
+
+	// History records versions of polices, it implies CopyOnWrite
+	History *history.Log
+
+	// Lazy loads polices from it on demand, and caches up to LazySize entities, instead of loading all polices at once,
+	// it must query the persister, other options about how polices are kept are ignored
+	Lazy     types.PermissionQuerier
+	LazySize int
+
+	// Compact keeps polices as interned ids, to take less memory, it is ignored with CopyOnWrite
+	Compact bool
+
+	// Snapshot loads polices from it, and resumes watching changes after them, instead of listing polices,
+	// if the persister could resume watching; polices are saved to it with the token of the latest watched change.
+	// It is ignored with Lazy
+	Snapshot *snapshot.Permission
+
+	// Metrics measures the permission under the name of Component
+	Metrics   types.Metrics
+	Component string
+
+	// Tracer starts spans around writes and persister round-trips
+	Tracer types.Tracer
+
+	// Observers are told about changes of polices in order, both made locally and watched from the persister
+	Observers []Observer
+}
+
+// Observer is told about subjects and objects whose permissions changed, after the changes are made
+type Observer interface {
+	PermissionChanged(sub types.Subject, obj types.Object)
+}
+
+// policyLister is a permission could list its polices
+type policyLister interface {
+	// Policies lists all polices
+	Policies() ([]types.PermissionPolicy, error)
+}
+
+// reloader is a permission loads polices from the persister on demand,
+// changes watched from the persister are made there already, polices about them are reloaded instead of being applied
+type reloader interface {
+	reload(sub types.Subject, obj types.Object)
+}
+
+// policiesOf lists polices of p, if it supports
+func policiesOf(p types.Permission) ([]types.PermissionPolicy, error) {
+	if l, ok := p.(policyLister); ok {
+		return l.Policies()
+	}
+	return nil, types.ErrNoPolicyList
+}
+
 // New creates a concurent safe, persisted permission
-func New(ctx context.Context, pp types.PermissionPersister, l logr.Logger) (types.Permission, error) {
-	return newPersistedPermission(ctx, newThinPermission(), pp, l)
+func New(ctx context.Context, pp types.PermissionPersisterContext, l logr.Logger, cfg Config) (types.Permission, error) {
+	var inner types.Permission
+	switch {
+	case cfg.Lazy != nil:
+		inner = newLazyPermission(cfg.Lazy, pp, cfg.LazySize)
+	case cfg.History != nil:
+		inner = newCOWPermission(cfg.History)
+	case cfg.CopyOnWrite:
+		inner = newCOWPermission(nil)
+	case cfg.Compact:
+		inner = newSyncedPermission(newCompactPermission())
+	default:
+		inner = newSyncedPermission(newThinPermission())
+	}
+
+	return newPersistedPermission(ctx, inner, pp, l, cfg)
 }
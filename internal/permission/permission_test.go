@@ -7,11 +7,13 @@ import (
 	"os"
 	"testing"
 
+	"github.com/go-logr/logr"
 	"github.com/go-logr/stdr"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 
+	"github.com/supremind/rbac/persist"
 	"github.com/supremind/rbac/persist/fake"
 	. "github.com/supremind/rbac/types"
 )
@@ -46,13 +48,33 @@ var _ = Describe("base permitter implementation", func() {
 			name: "synced",
 			p:    newSyncedPermission(newThinPermission()),
 		},
+		{
+			name: "copy on write",
+			p:    newCOWPermission(nil),
+		},
+		{
+			name: "synced compact",
+			p:    newSyncedPermission(newCompactPermission()),
+		},
+		{
+			name: "lazy persisted",
+			p: func() Permission {
+				pp := fake.NewPermissionPersister()
+				pc := persist.PermissionWithContext(pp)
+				p, e := newPersistedPermission(context.Background(), newLazyPermission(pp, pc, 10), pc, logr.Discard(), Config{Component: "permission", Lazy: pp})
+				Specify("lazy persisted permission is created", func() {
+					Expect(e).To(Succeed())
+				})
+				return p
+			}(),
+		},
 		{
 			name: "persisted",
 			p: func() Permission {
 				logger := stdr.New(log.New(os.Stderr, "", log.LstdFlags|log.Lshortfile))
 				stdr.SetVerbosity(4)
 
-				p, e := newPersistedPermission(context.Background(), newSyncedPermission(newThinPermission()), fake.NewPermissionPersister(), logger)
+				p, e := newPersistedPermission(context.Background(), newSyncedPermission(newThinPermission()), persist.PermissionWithContext(fake.NewPermissionPersister()), logger, Config{Component: "permission"})
 				Specify("persisted permission is created", func() {
 					Expect(e).To(Succeed())
 				})
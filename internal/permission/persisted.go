@@ -3,51 +3,137 @@ package permission
 import (
 	"context"
 	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/supremind/rbac/internal/persist/filter"
+	"github.com/supremind/rbac/metrics"
+	"github.com/supremind/rbac/persist"
+	"github.com/supremind/rbac/trace"
 	"github.com/supremind/rbac/types"
 )
 
+var _ types.Permission = (*persistedPermission)(nil)
+
 // persistedPermission persists the permission polices with given persister, and makes sure it is synced
 type persistedPermission struct {
-	persist types.PermissionPersister
+	policies int64 // number of subject-object pairs with any action permitted, updated atomically
+	lazy     bool  // polices are loaded on demand, they are not counted
+	persist  types.PermissionPersisterContext
+	stream   types.PermissionStreamer // nil if the persister could not stream polices
+	resumer  types.PermissionResumer  // nil if the persister could not resume watching
+	token    atomic.Value             // of string, the token after the latest watched change, if resumed
+	saving   sync.RWMutex             // held for reading by writes, so that snapshots are not saved in the middle of them
 	types.Permission
-	log logr.Logger
+	log       logr.Logger
+	component string
+	metrics   types.Metrics
+	tracer    types.Tracer
+	observers []Observer
 }
 
-func newPersistedPermission(ctx context.Context, inner types.Permission, persist types.PermissionPersister, l logr.Logger) (*persistedPermission, error) {
+func newPersistedPermission(ctx context.Context, inner types.Permission, pp types.PermissionPersisterContext, l logr.Logger, cfg Config) (*persistedPermission, error) {
 	p := &persistedPermission{
-		persist:    filter.NewPermissionPersister(persist),
-		Permission: newSyncedPermission(inner),
+		Permission: inner,
 		log:        l,
+		component:  cfg.Component,
+		metrics:    cfg.Metrics,
+		tracer:     cfg.Tracer,
+		observers:  cfg.Observers,
 	}
-
-	if e := p.loadPersisted(); e != nil {
-		return nil, e
+	if p.metrics == nil {
+		p.metrics = metrics.Discard
 	}
-	if e := p.startWatching(ctx); e != nil {
-		return nil, e
+	if p.tracer == nil {
+		p.tracer = trace.Nop
+	}
+	f := filter.NewPermissionPersister(pp, p.component, p.metrics)
+	p.persist = f
+	p.stream, _ = persist.PermissionStreamerOf(pp)
+	p.resumer, _ = f.Resumer()
+
+	switch {
+	case cfg.Lazy != nil:
+		// polices are loaded on demand by lazy ones
+		p.lazy = true
+		if e := p.startWatching(ctx); e != nil {
+			return nil, e
+		}
+	case p.resumer != nil:
+		if e := p.resume(ctx, cfg.Snapshot); e != nil {
+			return nil, e
+		}
+	default:
+		if e := p.loadPersisted(ctx); e != nil {
+			return nil, e
+		}
+		if e := p.startWatching(ctx); e != nil {
+			return nil, e
+		}
 	}
 
 	return p, nil
 }
 
-func (p *persistedPermission) loadPersisted() error {
+func (p *persistedPermission) loadPersisted(ctx context.Context) error {
 	p.log.V(4).Info("load persisted changes")
-	polices, e := p.persist.List()
+	if p.stream != nil {
+		return p.streamPersisted(ctx)
+	}
+
+	ctx, span := p.startSpan(ctx, "rbac.persister.List")
+	polices, e := p.persist.ListContext(ctx)
+	span.End(e)
 	if e != nil {
 		return e
 	}
-	for _, policy := range polices {
-		if e := p.Permission.Permit(policy.Subject, policy.Object, policy.Action); e != nil {
-			return e
+	e = p.permitAll(func(permit func(types.Subject, types.Object, types.Action) error) error {
+		for _, policy := range polices {
+			if e := permit(policy.Subject, policy.Object, policy.Action); e != nil {
+				return e
+			}
 		}
+		return nil
+	})
+	if e != nil {
+		return e
 	}
+	p.countPolicies(len(polices))
 
 	return nil
 }
 
+// permitAll permits polices iterated by each on the inner permission,
+// copy-on-write permissions permit all of them at once, so that they are recorded as a single version in history
+func (p *persistedPermission) permitAll(each func(permit func(types.Subject, types.Object, types.Action) error) error) error {
+	if b, ok := p.Permission.(interface {
+		permitAll(func(permit func(types.Subject, types.Object, types.Action) error) error) error
+	}); ok {
+		return b.permitAll(each)
+	}
+	return each(p.Permission.Permit)
+}
+
+// streamPersisted loads persisted polices one by one, without listing all of them in memory
+func (p *persistedPermission) streamPersisted(ctx context.Context) error {
+	ctx, span := p.startSpan(ctx, "rbac.persister.Each")
+	n := 0
+	e := p.permitAll(func(permit func(types.Subject, types.Object, types.Action) error) error {
+		return p.stream.Each(ctx, func(policy types.PermissionPolicy) error {
+			n++
+			return permit(policy.Subject, policy.Object, policy.Action)
+		})
+	})
+	span.End(e)
+	if e != nil {
+		return e
+	}
+	p.countPolicies(n)
+	return nil
+}
+
 func (p *persistedPermission) startWatching(ctx context.Context) error {
 	changes, e := p.persist.Watch(ctx)
 	if e != nil {
@@ -57,8 +143,12 @@ func (p *persistedPermission) startWatching(ctx context.Context) error {
 	go func() {
 		for {
 			select {
-			case change := <-changes:
-				if e := p.coordinateChange(change); e != nil {
+			case change, ok := <-changes:
+				if !ok {
+					p.log.V(4).Info("permission persister stopped watching")
+					return
+				}
+				if e := p.coordinateChange(ctx, change); e != nil {
 					p.log.Error(e, "coordinate permission changes")
 				}
 			case <-ctx.Done():
@@ -70,28 +160,45 @@ func (p *persistedPermission) startWatching(ctx context.Context) error {
 	return nil
 }
 
-func (p *persistedPermission) coordinateChange(change types.PermissionPolicyChange) error {
+func (p *persistedPermission) coordinateChange(ctx context.Context, change types.PermissionPolicyChange) (err error) {
 	p.log.V(4).Info("coordinate permission changes", "change", change)
 
+	_, span := p.startSpan(ctx, "rbac.permission.Coordinate", policyAttrs(change.Subject, change.Object, change.Action)...)
+	defer func() { span.End(err) }()
+
+	if r, ok := p.Permission.(reloader); ok {
+		r.reload(change.Subject, change.Object)
+		p.changed(change.Subject, change.Object)
+		return nil
+	}
+
+	prev, e := p.Permission.PermittedActions(change.Subject, change.Object)
+	if e != nil {
+		return e
+	}
+
 	switch change.Method {
 	case types.PersistInsert, types.PersistUpdate:
-		prev, e := p.Permission.PermittedActions(change.Subject, change.Object)
-		if e != nil {
-			return e
-		}
 		if prev.Includes(change.Action) {
-			return p.Permission.Revoke(change.Subject, change.Object, prev.Difference(change.Action))
+			e = p.Permission.Revoke(change.Subject, change.Object, prev.Difference(change.Action))
+		} else {
+			e = p.Permission.Permit(change.Subject, change.Object, change.Action.Difference(prev))
 		}
-		return p.Permission.Permit(change.Subject, change.Object, change.Action.Difference(prev))
-
-	case types.PersistDelete:
-		prev, e := p.Permission.PermittedActions(change.Subject, change.Object)
 		if e != nil {
 			return e
 		}
+		p.changed(change.Subject, change.Object)
+		p.countChange(prev, change.Action)
+		return nil
+
+	case types.PersistDelete:
 		if prev > 0 {
-			return p.Permission.Revoke(change.Subject, change.Object, prev)
+			if e := p.Permission.Revoke(change.Subject, change.Object, prev); e != nil {
+				return e
+			}
+			p.changed(change.Subject, change.Object)
 		}
+		p.countChange(prev, 0)
 		return nil
 	}
 
@@ -100,7 +207,26 @@ func (p *persistedPermission) coordinateChange(change types.PermissionPolicyChan
 
 // Permit subject to perform action on object
 func (p *persistedPermission) Permit(sub types.Subject, obj types.Object, act types.Action) error {
+	return p.PermitContext(context.Background(), sub, obj, act)
+}
+
+// Revoke permission for subject to perform action on object
+func (p *persistedPermission) Revoke(sub types.Subject, obj types.Object, act types.Action) error {
+	return p.RevokeContext(context.Background(), sub, obj, act)
+}
+
+// PermitContext permits subject to perform action on object
+func (p *persistedPermission) PermitContext(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) error {
+	ctx, span := p.startSpan(ctx, "rbac.permission.Permit", policyAttrs(sub, obj, act)...)
+	e := p.permit(ctx, sub, obj, act)
+	span.End(e)
+	return e
+}
+
+func (p *persistedPermission) permit(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) error {
 	p.log.V(4).Info("permit", "subject", sub, "object", obj, "action", act)
+	p.saving.RLock()
+	defer p.saving.RUnlock()
 
 	before, e := p.Permission.PermittedActions(sub, obj)
 	if e != nil {
@@ -108,21 +234,35 @@ func (p *persistedPermission) Permit(sub types.Subject, obj types.Object, act ty
 	}
 
 	if before > 0 {
-		if e := p.persist.Update(sub, obj, act|before); e != nil {
+		if e := p.update(ctx, sub, obj, act|before); e != nil {
 			return e
 		}
 	} else {
-		if e := p.persist.Insert(sub, obj, act); e != nil {
+		if e := p.insert(ctx, sub, obj, act); e != nil {
 			return e
 		}
 	}
 
-	return p.Permission.Permit(sub, obj, act)
+	if e := p.Permission.Permit(sub, obj, act); e != nil {
+		return e
+	}
+	p.changed(sub, obj)
+	p.countChange(before, before|act)
+	return nil
 }
 
-// Revoke permission for subject to perform action on object
-func (p *persistedPermission) Revoke(sub types.Subject, obj types.Object, act types.Action) error {
+// RevokeContext revokes permission for subject to perform action on object
+func (p *persistedPermission) RevokeContext(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) error {
+	ctx, span := p.startSpan(ctx, "rbac.permission.Revoke", policyAttrs(sub, obj, act)...)
+	e := p.revoke(ctx, sub, obj, act)
+	span.End(e)
+	return e
+}
+
+func (p *persistedPermission) revoke(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) error {
 	p.log.V(4).Info("revoke", "subject", sub, "object", obj, "action", act)
+	p.saving.RLock()
+	defer p.saving.RUnlock()
 
 	before, e := p.Permission.PermittedActions(sub, obj)
 	if e != nil {
@@ -131,14 +271,112 @@ func (p *persistedPermission) Revoke(sub types.Subject, obj types.Object, act ty
 	after := before.Difference(act)
 
 	if after > 0 {
-		if e := p.persist.Update(sub, obj, after); e != nil {
+		if e := p.update(ctx, sub, obj, after); e != nil {
 			return e
 		}
 	} else {
-		if e := p.persist.Remove(sub, obj); e != nil {
+		if e := p.remove(ctx, sub, obj); e != nil {
 			return e
 		}
 	}
 
-	return p.Permission.Revoke(sub, obj, act)
+	if e := p.Permission.Revoke(sub, obj, act); e != nil {
+		return e
+	}
+	p.changed(sub, obj)
+	p.countChange(before, after)
+	return nil
+}
+
+// changed tells observers permissions between sub and obj changed
+func (p *persistedPermission) changed(sub types.Subject, obj types.Object) {
+	for _, o := range p.observers {
+		o.PermissionChanged(sub, obj)
+	}
+}
+
+// countChange counts polices by actions permitted before and after a change
+func (p *persistedPermission) countChange(before, after types.Action) {
+	switch {
+	case before == 0 && after > 0:
+		p.countPolicies(1)
+	case before > 0 && after == 0:
+		p.countPolicies(-1)
+	}
+}
+
+// countPolicies adds delta to the number of polices, and reports it to metrics,
+// polices loaded lazily are not counted, as the number of all polices is unknown
+func (p *persistedPermission) countPolicies(delta int) {
+	if p.lazy {
+		return
+	}
+	n := atomic.AddInt64(&p.policies, int64(delta))
+	p.metrics.SetPolicies(p.component, int(n))
+}
+
+// insert a policy to the persister, as a traced operation
+func (p *persistedPermission) insert(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) error {
+	ctx, span := p.startSpan(ctx, "rbac.persister.Insert", policyAttrs(sub, obj, act)...)
+	e := p.persist.InsertContext(ctx, sub, obj, act)
+	span.End(e)
+	return e
+}
+
+// update a policy to the persister, as a traced operation
+func (p *persistedPermission) update(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) error {
+	ctx, span := p.startSpan(ctx, "rbac.persister.Update", policyAttrs(sub, obj, act)...)
+	e := p.persist.UpdateContext(ctx, sub, obj, act)
+	span.End(e)
+	return e
+}
+
+// remove a policy from the persister, as a traced operation
+func (p *persistedPermission) remove(ctx context.Context, sub types.Subject, obj types.Object) error {
+	ctx, span := p.startSpan(ctx, "rbac.persister.Remove",
+		types.Attr(trace.AttrSubject, sub.String()),
+		types.Attr(trace.AttrObject, obj.String()),
+	)
+	e := p.persist.RemoveContext(ctx, sub, obj)
+	span.End(e)
+	return e
+}
+
+// startSpan starts a span with the component name attached
+func (p *persistedPermission) startSpan(ctx context.Context, name string, attrs ...types.Attribute) (context.Context, types.Span) {
+	return p.tracer.Start(ctx, name, append([]types.Attribute{types.Attr(trace.AttrComponent, p.component)}, attrs...)...)
+}
+
+func policyAttrs(sub types.Subject, obj types.Object, act types.Action) []types.Attribute {
+	return []types.Attribute{
+		types.Attr(trace.AttrSubject, sub.String()),
+		types.Attr(trace.AttrObject, obj.String()),
+		types.Attr(trace.AttrAction, act.String()),
+	}
+}
+
+// Policies lists all polices, if the inner permission supports
+func (p *persistedPermission) Policies() ([]types.PermissionPolicy, error) {
+	return policiesOf(p.Permission)
+}
+
+// At returns a read-only permission as it was at the given time, if the inner permission keeps history
+func (p *persistedPermission) At(t time.Time) (types.Permission, error) {
+	if h, ok := p.Permission.(interface {
+		At(time.Time) (types.Permission, error)
+	}); ok {
+		return h.At(t)
+	}
+	return nil, types.ErrNoHistory
+}
+
+// Frozen returns a read-only permission of the current snapshot, if the inner permission keeps snapshots,
+// or the permission itself otherwise
+func (p *persistedPermission) Frozen() types.Permission {
+	if f, ok := p.Permission.(interface {
+		Frozen() types.Permission
+	}); ok {
+		return f.Frozen()
+	}
+	return p
 }
@@ -0,0 +1,111 @@
+package permission
+
+import (
+	"context"
+
+	"github.com/supremind/rbac/internal/snapshot"
+	"github.com/supremind/rbac/types"
+)
+
+var _ snapshot.PermissionSource = (*persistedPermission)(nil)
+
+// resume loads polices from the snapshot, or lists them with the token of where they are listed at,
+// and watches changes after the token, so that no change is lost between loading and watching.
+// Polices are listed if the snapshot is empty, or changes after it are not kept by the persister any more.
+func (p *persistedPermission) resume(ctx context.Context, snap *snapshot.Permission) error {
+	var polices []types.PermissionPolicy
+	var token string
+	var changes <-chan types.ResumablePermissionChange
+
+	if snap != nil && snap.Token != "" {
+		var e error
+		changes, e = p.resumer.WatchFrom(ctx, snap.Token)
+		if e != nil {
+			p.log.Error(e, "resume watching after the snapshot, list polices instead")
+		} else {
+			p.log.V(4).Info("load polices from the snapshot", "token", snap.Token)
+			polices, token = snap.Polices, snap.Token
+		}
+	}
+	if changes == nil {
+		p.log.V(4).Info("list persisted polices with token")
+		lctx, span := p.startSpan(ctx, "rbac.persister.ListWithToken")
+		var e error
+		polices, token, e = p.resumer.ListWithToken(lctx)
+		span.End(e)
+		if e != nil {
+			return e
+		}
+		if changes, e = p.resumer.WatchFrom(ctx, token); e != nil {
+			return e
+		}
+	}
+
+	e := p.permitAll(func(permit func(types.Subject, types.Object, types.Action) error) error {
+		for _, policy := range polices {
+			if e := permit(policy.Subject, policy.Object, policy.Action); e != nil {
+				return e
+			}
+		}
+		return nil
+	})
+	if e != nil {
+		return e
+	}
+	p.countPolicies(len(polices))
+	p.token.Store(token)
+	if snap != nil {
+		snap.Track(p)
+	}
+
+	go func() {
+		for {
+			select {
+			case change, ok := <-changes:
+				if !ok {
+					p.log.V(4).Info("permission persister stopped watching")
+					return
+				}
+				// changes made by this replica are applied already, they carry only tokens
+				if change.Method != "" {
+					if e := p.resumeChange(ctx, change.PermissionPolicyChange); e != nil {
+						p.log.Error(e, "coordinate permission changes")
+					}
+				}
+				p.token.Store(change.Token)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// resumeChange coordinates a change watched after resuming, changes applied already are skipped,
+// they are listed or loaded from the snapshot, but watched again
+func (p *persistedPermission) resumeChange(ctx context.Context, change types.PermissionPolicyChange) error {
+	prev, e := p.Permission.PermittedActions(change.Subject, change.Object)
+	if e != nil {
+		return e
+	}
+
+	switch {
+	case change.Method == types.PersistDelete && prev == 0, change.Method != types.PersistDelete && prev == change.Action:
+		p.log.V(4).Info("skip permission change applied already", "change", change)
+		return nil
+	}
+	return p.coordinateChange(ctx, change)
+}
+
+// SnapshotPermission returns all polices, with the token of the latest watched change,
+// changes after the token may be included already, they are applied idempotently when resumed
+func (p *persistedPermission) SnapshotPermission() ([]types.PermissionPolicy, string, error) {
+	p.saving.Lock()
+	defer p.saving.Unlock()
+
+	// the token is taken before polices, so that no change before it is missed by them
+	token, _ := p.token.Load().(string)
+	polices, e := policiesOf(p.Permission)
+	return polices, token, e
+}
@@ -68,6 +68,13 @@ func (p *syncedPermission) PermissionsFor(sub types.Subject) (map[types.Object]t
 	return res, nil
 }
 
+// Policies lists all polices, if the inner permission supports
+func (p *syncedPermission) Policies() ([]types.PermissionPolicy, error) {
+	p.RLock()
+	defer p.RUnlock()
+	return policiesOf(p.p)
+}
+
 func (p *syncedPermission) PermittedActions(sub types.Subject, obj types.Object) (types.Action, error) {
 	p.RLock()
 	defer p.RUnlock()
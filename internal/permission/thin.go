@@ -65,6 +65,17 @@ func (p *thinPermission) Shall(sub types.Subject, obj types.Object, act types.Ac
 	return false, nil
 }
 
+// Policies lists all polices
+func (p *thinPermission) Policies() ([]types.PermissionPolicy, error) {
+	polices := make([]types.PermissionPolicy, 0, len(p.bySubject))
+	for sub, objs := range p.bySubject {
+		for obj, act := range objs {
+			polices = append(polices, types.PermissionPolicy{Subject: sub, Object: obj, Action: act})
+		}
+	}
+	return polices, nil
+}
+
 func (p *thinPermission) PermissionsOn(obj types.Object) (map[types.Subject]types.Action, error) {
 	return p.byObject[obj], nil
 }
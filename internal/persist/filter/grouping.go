@@ -3,27 +3,33 @@ package filter
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/supremind/rbac/types"
 )
 
 type groupingPersisterFilter struct {
-	types.GroupingPersister
-	changes map[types.GroupingPolicyChange]struct{}
-	sync.RWMutex
+	types.GroupingPersisterContext
+	changes   map[types.GroupingPolicyChange][]time.Time // changes made by the inner persister => when they were made
+	component string
+	metrics   types.Metrics
+	sync.Mutex
 }
 
 // NewGroupingPersister checks if the incoming changes are made by the inner persister itself,
-// and does not call it again if true
-func NewGroupingPersister(p types.GroupingPersister) *groupingPersisterFilter {
+// and does not call it again if true.
+// Writes and changes are measured by metrics, under the name of component.
+func NewGroupingPersister(p types.GroupingPersisterContext, component string, m types.Metrics) *groupingPersisterFilter {
 	return &groupingPersisterFilter{
-		GroupingPersister: p,
-		changes:           make(map[types.GroupingPolicyChange]struct{}),
+		GroupingPersisterContext: p,
+		changes:                  make(map[types.GroupingPolicyChange][]time.Time),
+		component:                component,
+		metrics:                  m,
 	}
 }
 
-// Insert inserts a policy to the persister
-func (f *groupingPersisterFilter) Insert(ent types.Entity, group types.Group) error {
+// InsertContext inserts a policy to the persister
+func (f *groupingPersisterFilter) InsertContext(ctx context.Context, ent types.Entity, group types.Group) error {
 	change := types.GroupingPolicyChange{
 		GroupingPolicy: types.GroupingPolicy{
 			Entity: ent,
@@ -32,15 +38,19 @@ func (f *groupingPersisterFilter) Insert(ent types.Entity, group types.Group) er
 		Method: types.PersistInsert,
 	}
 
-	f.Lock()
-	f.changes[change] = struct{}{}
-	f.Unlock()
-
-	return f.GroupingPersister.Insert(ent, group)
+	f.remember(change)
+	start := time.Now()
+	e := f.GroupingPersisterContext.InsertContext(ctx, ent, group)
+	f.metrics.ObservePersist(f.component, change.Method, e, time.Since(start))
+	if e != nil {
+		f.forget(change)
+		return e
+	}
+	return nil
 }
 
-// Remove a policy from the persister
-func (f *groupingPersisterFilter) Remove(ent types.Entity, group types.Group) error {
+// RemoveContext removes a policy from the persister
+func (f *groupingPersisterFilter) RemoveContext(ctx context.Context, ent types.Entity, group types.Group) error {
 	change := types.GroupingPolicyChange{
 		GroupingPolicy: types.GroupingPolicy{
 			Entity: ent,
@@ -49,15 +59,19 @@ func (f *groupingPersisterFilter) Remove(ent types.Entity, group types.Group) er
 		Method: types.PersistDelete,
 	}
 
-	f.Lock()
-	f.changes[change] = struct{}{}
-	f.Unlock()
-
-	return f.GroupingPersister.Remove(ent, group)
+	f.remember(change)
+	start := time.Now()
+	e := f.GroupingPersisterContext.RemoveContext(ctx, ent, group)
+	f.metrics.ObservePersist(f.component, change.Method, e, time.Since(start))
+	if e != nil {
+		f.forget(change)
+		return e
+	}
+	return nil
 }
 
 func (f *groupingPersisterFilter) Watch(ctx context.Context) (<-chan types.GroupingPolicyChange, error) {
-	in, e := f.GroupingPersister.Watch(ctx)
+	in, e := f.GroupingPersisterContext.Watch(ctx)
 	if e != nil {
 		return nil, e
 	}
@@ -78,15 +92,7 @@ func (f *groupingPersisterFilter) Watch(ctx context.Context) (<-chan types.Group
 					return
 				}
 
-				f.RLock()
-				_, ok = f.changes[change]
-				f.RUnlock()
-
-				if ok {
-					f.Lock()
-					delete(f.changes, change)
-					f.Unlock()
-				} else {
+				if !f.filter(change) {
 					out <- change
 				}
 			}
@@ -95,3 +101,46 @@ func (f *groupingPersisterFilter) Watch(ctx context.Context) (<-chan types.Group
 
 	return out, nil
 }
+
+// remember a change made by the inner persister itself, to be filtered out when watched
+func (f *groupingPersisterFilter) remember(change types.GroupingPolicyChange) {
+	f.Lock()
+	f.changes[change] = append(f.changes[change], time.Now())
+	f.Unlock()
+}
+
+// forget a change the inner persister failed to make, it will never be watched
+func (f *groupingPersisterFilter) forget(change types.GroupingPolicyChange) {
+	f.Lock()
+	f.pop(change, len(f.changes[change])-1)
+	f.Unlock()
+}
+
+// filter tells if the change is made by the inner persister itself, and measures it
+func (f *groupingPersisterFilter) filter(change types.GroupingPolicyChange) bool {
+	f.Lock()
+	at, ok := f.pop(change, 0)
+	f.Unlock()
+
+	f.metrics.ObserveChange(f.component, change.Method, ok)
+	if ok {
+		f.metrics.ObserveSyncLag(f.component, time.Since(at))
+	}
+	return ok
+}
+
+// pop removes the i-th time the change was made, it must be called with lock held
+func (f *groupingPersisterFilter) pop(change types.GroupingPolicyChange, i int) (time.Time, bool) {
+	times := f.changes[change]
+	if i < 0 || i >= len(times) {
+		return time.Time{}, false
+	}
+
+	at := times[i]
+	if len(times) == 1 {
+		delete(f.changes, change)
+	} else {
+		f.changes[change] = append(times[:i:i], times[i+1:]...)
+	}
+	return at, true
+}
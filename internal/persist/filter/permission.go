@@ -3,27 +3,33 @@ package filter
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/supremind/rbac/types"
 )
 
 type permissionPersisterFilter struct {
-	types.PermissionPersister
-	changes map[types.PermissionPolicyChange]struct{}
-	sync.RWMutex
+	types.PermissionPersisterContext
+	changes   map[types.PermissionPolicyChange][]time.Time // changes made by the inner persister => when they were made
+	component string
+	metrics   types.Metrics
+	sync.Mutex
 }
 
 // NewPermissionPersister checks if the incoming changes are made by the inner persister itself,
-// and does not call it again if true
-func NewPermissionPersister(p types.PermissionPersister) *permissionPersisterFilter {
+// and does not call it again if true.
+// Writes and changes are measured by metrics, under the name of component.
+func NewPermissionPersister(p types.PermissionPersisterContext, component string, m types.Metrics) *permissionPersisterFilter {
 	return &permissionPersisterFilter{
-		PermissionPersister: p,
-		changes:             make(map[types.PermissionPolicyChange]struct{}),
+		PermissionPersisterContext: p,
+		changes:                    make(map[types.PermissionPolicyChange][]time.Time),
+		component:                  component,
+		metrics:                    m,
 	}
 }
 
-// Insert a permission policy to the persister
-func (f *permissionPersisterFilter) Insert(sub types.Subject, obj types.Object, act types.Action) error {
+// InsertContext inserts a permission policy to the persister
+func (f *permissionPersisterFilter) InsertContext(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) error {
 	change := types.PermissionPolicyChange{
 		PermissionPolicy: types.PermissionPolicy{
 			Subject: sub,
@@ -33,15 +39,19 @@ func (f *permissionPersisterFilter) Insert(sub types.Subject, obj types.Object,
 		Method: types.PersistInsert,
 	}
 
-	f.Lock()
-	f.changes[change] = struct{}{}
-	f.Unlock()
-
-	return f.PermissionPersister.Insert(sub, obj, act)
+	f.remember(change)
+	start := time.Now()
+	e := f.PermissionPersisterContext.InsertContext(ctx, sub, obj, act)
+	f.metrics.ObservePersist(f.component, change.Method, e, time.Since(start))
+	if e != nil {
+		f.forget(change)
+		return e
+	}
+	return nil
 }
 
-// Update a permission policy to the persister
-func (f *permissionPersisterFilter) Update(sub types.Subject, obj types.Object, act types.Action) error {
+// UpdateContext updates a permission policy to the persister
+func (f *permissionPersisterFilter) UpdateContext(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) error {
 	change := types.PermissionPolicyChange{
 		PermissionPolicy: types.PermissionPolicy{
 			Subject: sub,
@@ -51,15 +61,19 @@ func (f *permissionPersisterFilter) Update(sub types.Subject, obj types.Object,
 		Method: types.PersistUpdate,
 	}
 
-	f.Lock()
-	f.changes[change] = struct{}{}
-	f.Unlock()
-
-	return f.PermissionPersister.Update(sub, obj, act)
+	f.remember(change)
+	start := time.Now()
+	e := f.PermissionPersisterContext.UpdateContext(ctx, sub, obj, act)
+	f.metrics.ObservePersist(f.component, change.Method, e, time.Since(start))
+	if e != nil {
+		f.forget(change)
+		return e
+	}
+	return nil
 }
 
-// Remove a permission policy from the persister
-func (f *permissionPersisterFilter) Remove(sub types.Subject, obj types.Object) error {
+// RemoveContext removes a permission policy from the persister
+func (f *permissionPersisterFilter) RemoveContext(ctx context.Context, sub types.Subject, obj types.Object) error {
 	change := types.PermissionPolicyChange{
 		PermissionPolicy: types.PermissionPolicy{
 			Subject: sub,
@@ -68,15 +82,19 @@ func (f *permissionPersisterFilter) Remove(sub types.Subject, obj types.Object)
 		Method: types.PersistDelete,
 	}
 
-	f.Lock()
-	f.changes[change] = struct{}{}
-	f.Unlock()
-
-	return f.PermissionPersister.Remove(sub, obj)
+	f.remember(change)
+	start := time.Now()
+	e := f.PermissionPersisterContext.RemoveContext(ctx, sub, obj)
+	f.metrics.ObservePersist(f.component, change.Method, e, time.Since(start))
+	if e != nil {
+		f.forget(change)
+		return e
+	}
+	return nil
 }
 
 func (f *permissionPersisterFilter) Watch(ctx context.Context) (<-chan types.PermissionPolicyChange, error) {
-	in, e := f.PermissionPersister.Watch(ctx)
+	in, e := f.PermissionPersisterContext.Watch(ctx)
 	if e != nil {
 		return nil, e
 	}
@@ -87,15 +105,7 @@ func (f *permissionPersisterFilter) Watch(ctx context.Context) (<-chan types.Per
 		defer close(out)
 
 		for change := range in {
-			f.RLock()
-			_, ok := f.changes[change]
-			f.RUnlock()
-
-			if ok {
-				f.Lock()
-				delete(f.changes, change)
-				f.Unlock()
-			} else {
+			if !f.filter(change) {
 				out <- change
 			}
 		}
@@ -103,3 +113,46 @@ func (f *permissionPersisterFilter) Watch(ctx context.Context) (<-chan types.Per
 
 	return out, nil
 }
+
+// remember a change made by the inner persister itself, to be filtered out when watched
+func (f *permissionPersisterFilter) remember(change types.PermissionPolicyChange) {
+	f.Lock()
+	f.changes[change] = append(f.changes[change], time.Now())
+	f.Unlock()
+}
+
+// forget a change the inner persister failed to make, it will never be watched
+func (f *permissionPersisterFilter) forget(change types.PermissionPolicyChange) {
+	f.Lock()
+	f.pop(change, len(f.changes[change])-1)
+	f.Unlock()
+}
+
+// filter tells if the change is made by the inner persister itself, and measures it
+func (f *permissionPersisterFilter) filter(change types.PermissionPolicyChange) bool {
+	f.Lock()
+	at, ok := f.pop(change, 0)
+	f.Unlock()
+
+	f.metrics.ObserveChange(f.component, change.Method, ok)
+	if ok {
+		f.metrics.ObserveSyncLag(f.component, time.Since(at))
+	}
+	return ok
+}
+
+// pop removes the i-th time the change was made, it must be called with lock held
+func (f *permissionPersisterFilter) pop(change types.PermissionPolicyChange, i int) (time.Time, bool) {
+	times := f.changes[change]
+	if i < 0 || i >= len(times) {
+		return time.Time{}, false
+	}
+
+	at := times[i]
+	if len(times) == 1 {
+		delete(f.changes, change)
+	} else {
+		f.changes[change] = append(times[:i:i], times[i+1:]...)
+	}
+	return at, true
+}
@@ -0,0 +1,123 @@
+package filter
+
+import (
+	"context"
+	"time"
+
+	"github.com/supremind/rbac/persist"
+	"github.com/supremind/rbac/types"
+)
+
+// Resumer returns a GroupingResumer of the inner persister, ok is false if the inner persister could not resume watching.
+// Watched changes made by the inner persister itself are filtered as Watch does, but they are still sent with only tokens,
+// so that positions after them are tracked.
+// Sync lags of changes made by others are measured if the inner persister tells when they were made
+func (f *groupingPersisterFilter) Resumer() (types.GroupingResumer, bool) {
+	r, ok := persist.GroupingResumerOf(f.GroupingPersisterContext)
+	if !ok {
+		return nil, false
+	}
+	return groupingResumerFilter{f: f, r: r}, true
+}
+
+type groupingResumerFilter struct {
+	f *groupingPersisterFilter
+	r types.GroupingResumer
+}
+
+func (rf groupingResumerFilter) ListWithToken(ctx context.Context) ([]types.GroupingPolicy, string, error) {
+	return rf.r.ListWithToken(ctx)
+}
+
+func (rf groupingResumerFilter) WatchFrom(ctx context.Context, token string) (<-chan types.ResumableGroupingChange, error) {
+	in, e := rf.r.WatchFrom(ctx, token)
+	if e != nil {
+		return nil, e
+	}
+
+	out := make(chan types.ResumableGroupingChange)
+
+	go func() {
+		defer close(out)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case change, ok := <-in:
+				if !ok {
+					return
+				}
+				if rf.f.filter(change.GroupingPolicyChange) {
+					change = types.ResumableGroupingChange{Token: change.Token}
+				} else if !change.Time.IsZero() {
+					rf.f.metrics.ObserveSyncLag(rf.f.component, time.Since(change.Time))
+				}
+				select {
+				case out <- change:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// Resumer returns a PermissionResumer of the inner persister, ok is false if the inner persister could not resume watching.
+// Watched changes made by the inner persister itself are filtered as Watch does, but they are still sent with only tokens,
+// so that positions after them are tracked.
+// Sync lags of changes made by others are measured if the inner persister tells when they were made
+func (f *permissionPersisterFilter) Resumer() (types.PermissionResumer, bool) {
+	r, ok := persist.PermissionResumerOf(f.PermissionPersisterContext)
+	if !ok {
+		return nil, false
+	}
+	return permissionResumerFilter{f: f, r: r}, true
+}
+
+type permissionResumerFilter struct {
+	f *permissionPersisterFilter
+	r types.PermissionResumer
+}
+
+func (rf permissionResumerFilter) ListWithToken(ctx context.Context) ([]types.PermissionPolicy, string, error) {
+	return rf.r.ListWithToken(ctx)
+}
+
+func (rf permissionResumerFilter) WatchFrom(ctx context.Context, token string) (<-chan types.ResumablePermissionChange, error) {
+	in, e := rf.r.WatchFrom(ctx, token)
+	if e != nil {
+		return nil, e
+	}
+
+	out := make(chan types.ResumablePermissionChange)
+
+	go func() {
+		defer close(out)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case change, ok := <-in:
+				if !ok {
+					return
+				}
+				if rf.f.filter(change.PermissionPolicyChange) {
+					change = types.ResumablePermissionChange{Token: change.Token}
+				} else if !change.Time.IsZero() {
+					rf.f.metrics.ObserveSyncLag(rf.f.component, time.Since(change.Time))
+				}
+				select {
+				case out <- change:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
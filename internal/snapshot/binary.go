@@ -0,0 +1,254 @@
+package snapshot
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/supremind/rbac/types"
+)
+
+// ErrFormat means the snapshot file is not in the current format, or it is corrupted
+var ErrFormat = errors.New("invalid snapshot file format")
+
+// Snapshot files start with the magic and the version of the format,
+// then serialized entities, and parts referring to them by their indexes:
+//
+//	magic version
+//	entities: count (length bytes)...
+//	subjects, objects: token polices closures
+//	permissions: token count (subject object action)...
+//
+// polices and closures are counts followed by entity-group index pairs, closures are counted one more,
+// so that zero means they are not saved. Strings are lengths followed by bytes, all numbers are uvarints.
+const (
+	magic   = "RBACSNAP"
+	version = 1
+)
+
+// content of snapshot files
+type content struct {
+	subjects        GroupingState
+	objects         GroupingState
+	permissions     []types.PermissionPolicy
+	permissionToken string
+}
+
+type encoder struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+	ids map[string]uint64
+}
+
+func encode(w *bufio.Writer, c *content) error {
+	enc := &encoder{w: w, ids: make(map[string]uint64)}
+
+	// entities are listed first, so that parts refer to them by indexes
+	var entities []string
+	intern := func(ent types.Entity) {
+		s := ent.String()
+		if _, ok := enc.ids[s]; !ok {
+			enc.ids[s] = uint64(len(entities))
+			entities = append(entities, s)
+		}
+	}
+	for _, g := range []*GroupingState{&c.subjects, &c.objects} {
+		for _, polices := range [][]types.GroupingPolicy{g.Polices, g.Closures} {
+			for _, policy := range polices {
+				intern(policy.Entity)
+				intern(policy.Group)
+			}
+		}
+	}
+	for _, policy := range c.permissions {
+		intern(policy.Subject)
+		intern(policy.Object)
+	}
+
+	if _, e := w.WriteString(magic); e != nil {
+		return e
+	}
+	enc.uint(version)
+	enc.uint(uint64(len(entities)))
+	for _, s := range entities {
+		enc.string(s)
+	}
+
+	for _, g := range []*GroupingState{&c.subjects, &c.objects} {
+		enc.string(g.Token)
+		enc.groupings(g.Polices)
+		if g.Closures == nil {
+			enc.uint(0)
+		} else {
+			enc.uint(uint64(len(g.Closures)) + 1)
+			enc.pairs(g.Closures)
+		}
+	}
+
+	enc.string(c.permissionToken)
+	enc.uint(uint64(len(c.permissions)))
+	for _, policy := range c.permissions {
+		enc.uint(enc.ids[policy.Subject.String()])
+		enc.uint(enc.ids[policy.Object.String()])
+		enc.uint(uint64(policy.Action))
+	}
+
+	// bufio.Writer keeps the first error, and returns it on flushing
+	return w.Flush()
+}
+
+func (enc *encoder) uint(n uint64) {
+	enc.w.Write(enc.buf[:binary.PutUvarint(enc.buf[:], n)])
+}
+
+func (enc *encoder) string(s string) {
+	enc.uint(uint64(len(s)))
+	enc.w.WriteString(s)
+}
+
+func (enc *encoder) groupings(polices []types.GroupingPolicy) {
+	enc.uint(uint64(len(polices)))
+	enc.pairs(polices)
+}
+
+func (enc *encoder) pairs(polices []types.GroupingPolicy) {
+	for _, policy := range polices {
+		enc.uint(enc.ids[policy.Entity.String()])
+		enc.uint(enc.ids[policy.Group.String()])
+	}
+}
+
+type decoder struct {
+	r        *bufio.Reader
+	entities []types.Entity
+	err      error
+}
+
+func decode(r *bufio.Reader) (*content, error) {
+	head := make([]byte, len(magic))
+	if _, e := io.ReadFull(r, head); e != nil || string(head) != magic {
+		return nil, fmt.Errorf("%w: missing magic", ErrFormat)
+	}
+
+	dec := &decoder{r: r}
+	if v := dec.uint(); dec.err == nil && v != version {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrFormat, v)
+	}
+
+	n := dec.count()
+	dec.entities = make([]types.Entity, 0, capacity(n))
+	for i := 0; i < n && dec.err == nil; i++ {
+		ent, e := types.ParseEntity(dec.string())
+		if e != nil && dec.err == nil {
+			dec.err = e
+		}
+		dec.entities = append(dec.entities, ent)
+	}
+
+	var c content
+	for _, g := range []*GroupingState{&c.subjects, &c.objects} {
+		g.Token = dec.string()
+		g.Polices = dec.pairs(dec.count())
+		if n := dec.count(); n > 0 {
+			g.Closures = dec.pairs(n - 1)
+		}
+	}
+
+	c.permissionToken = dec.string()
+	n = dec.count()
+	c.permissions = make([]types.PermissionPolicy, 0, capacity(n))
+	for i := 0; i < n && dec.err == nil; i++ {
+		sub, _ := dec.entity().(types.Subject)
+		obj, _ := dec.entity().(types.Object)
+		act := types.Action(dec.uint())
+		if (sub == nil || obj == nil) && dec.err == nil {
+			dec.err = errors.New("permission polices of non-subjects or non-objects")
+		}
+		c.permissions = append(c.permissions, types.PermissionPolicy{Subject: sub, Object: obj, Action: act})
+	}
+
+	if dec.err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFormat, dec.err)
+	}
+	return &c, nil
+}
+
+// limits of counts and lengths, so that corrupted files are not trusted to allocate unbounded memory up front
+const (
+	maxCount    = 1 << 30
+	maxString   = 1 << 20
+	maxPrealloc = 1 << 16
+)
+
+// capacity to preallocate for n items
+func capacity(n int) int {
+	if n > maxPrealloc {
+		return maxPrealloc
+	}
+	return n
+}
+
+// uint reads an uvarint, reading after any error returns zero values
+func (dec *decoder) uint() uint64 {
+	if dec.err != nil {
+		return 0
+	}
+	n, e := binary.ReadUvarint(dec.r)
+	if e != nil {
+		dec.err = e
+	}
+	return n
+}
+
+// count reads a number of following items
+func (dec *decoder) count() int {
+	n := dec.uint()
+	if n > maxCount {
+		dec.err = fmt.Errorf("too many items: %d", n)
+		return 0
+	}
+	return int(n)
+}
+
+func (dec *decoder) string() string {
+	n := dec.count()
+	if n > maxString {
+		dec.err = fmt.Errorf("too long string: %d", n)
+	}
+	if dec.err != nil {
+		return ""
+	}
+	b := make([]byte, n)
+	if _, e := io.ReadFull(dec.r, b); e != nil {
+		dec.err = e
+		return ""
+	}
+	return string(b)
+}
+
+func (dec *decoder) entity() types.Entity {
+	i := dec.uint()
+	if dec.err != nil {
+		return nil
+	}
+	if i >= uint64(len(dec.entities)) {
+		dec.err = fmt.Errorf("entity index out of range: %d", i)
+		return nil
+	}
+	return dec.entities[i]
+}
+
+func (dec *decoder) pairs(n int) []types.GroupingPolicy {
+	polices := make([]types.GroupingPolicy, 0, capacity(n))
+	for i := 0; i < n && dec.err == nil; i++ {
+		ent := dec.entity()
+		group, _ := dec.entity().(types.Group)
+		if group == nil && dec.err == nil {
+			dec.err = errors.New("grouping polices of non-groups")
+		}
+		polices = append(polices, types.GroupingPolicy{Entity: ent, Group: group})
+	}
+	return polices
+}
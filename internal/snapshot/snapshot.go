@@ -0,0 +1,173 @@
+// Package snapshot keeps polices of an authorizer in a local file, with tokens of the positions in change streams
+// of persisters they are at, so that replicas restart by loading the file and resuming watching changes after the tokens,
+// instead of listing all polices from persisters.
+// Groupings may save what they build from polices as well, like closures of groups above entities,
+// so that they are restored directly instead of being rebuilt by joining polices one by one.
+package snapshot
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/supremind/rbac/types"
+)
+
+// GroupingSource provides the state of a grouping to be saved
+type GroupingSource interface {
+	SnapshotGrouping() (GroupingState, error)
+}
+
+// PermissionSource provides polices of a permission, with the token to resume watching changes after them
+type PermissionSource interface {
+	SnapshotPermission() ([]types.PermissionPolicy, string, error)
+}
+
+// GroupingState is the state of a grouping at the position of Token, which is empty if nothing is loaded
+type GroupingState struct {
+	// Polices immediately assigned
+	Polices []types.GroupingPolicy
+	// Closures are all groups every entity belongs to, directly or through sub groups, nil if they are not saved
+	Closures []types.GroupingPolicy
+	Token    string
+}
+
+// Grouping is the part of a snapshot about a grouping
+type Grouping struct {
+	// GroupingState loaded from the file
+	GroupingState
+
+	source GroupingSource
+	sync.Mutex
+}
+
+// Track saves the state provided by src, when the snapshot is saved, the loaded state is dropped
+func (g *Grouping) Track(src GroupingSource) {
+	g.Lock()
+	defer g.Unlock()
+
+	g.source = src
+	g.GroupingState = GroupingState{}
+}
+
+// save returns the state from the tracked source, or nothing if no source is tracked
+func (g *Grouping) save() (GroupingState, error) {
+	g.Lock()
+	src := g.source
+	g.Unlock()
+
+	if src == nil {
+		return GroupingState{}, nil
+	}
+	return src.SnapshotGrouping()
+}
+
+// Permission is the part of a snapshot about a permission
+type Permission struct {
+	// Polices loaded from the file, they are at the position of Token, which is empty if nothing is loaded
+	Polices []types.PermissionPolicy
+	Token   string
+
+	source PermissionSource
+	sync.Mutex
+}
+
+// Track saves polices provided by src, when the snapshot is saved, the loaded polices are dropped
+func (p *Permission) Track(src PermissionSource) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.source = src
+	p.Polices, p.Token = nil, ""
+}
+
+// save returns polices and token from the tracked source, or nothing if no source is tracked
+func (p *Permission) save() ([]types.PermissionPolicy, string, error) {
+	p.Lock()
+	src := p.source
+	p.Unlock()
+
+	if src == nil {
+		return nil, "", nil
+	}
+	return src.SnapshotPermission()
+}
+
+// File is a snapshot file, it is loaded once when opened, and saved from tracked sources
+type File struct {
+	path        string
+	Subjects    *Grouping
+	Objects     *Grouping
+	Permissions *Permission
+}
+
+// Open loads the snapshot file at path, a missing file is an empty snapshot.
+// Files not in the current format are empty snapshots as well, along with an error of ErrFormat,
+// they are overwritten when saved.
+func Open(path string) (*File, error) {
+	f := &File{
+		path:        path,
+		Subjects:    &Grouping{},
+		Objects:     &Grouping{},
+		Permissions: &Permission{},
+	}
+
+	file, e := os.Open(path)
+	if os.IsNotExist(e) {
+		return f, nil
+	}
+	if e != nil {
+		return nil, e
+	}
+	defer file.Close()
+
+	c, e := decode(bufio.NewReader(file))
+	if e != nil {
+		return f, e
+	}
+
+	if c.subjects.Token != "" {
+		f.Subjects.GroupingState = c.subjects
+	}
+	if c.objects.Token != "" {
+		f.Objects.GroupingState = c.objects
+	}
+	if c.permissionToken != "" {
+		f.Permissions.Polices, f.Permissions.Token = c.permissions, c.permissionToken
+	}
+	return f, nil
+}
+
+// Save writes states provided by tracked sources to the file, by writing to a temporary file and renaming it,
+// parts without sources are saved without tokens, and they are not loaded next time
+func (f *File) Save() error {
+	var c content
+	var e error
+
+	if c.subjects, e = f.Subjects.save(); e != nil {
+		return e
+	}
+	if c.objects, e = f.Objects.save(); e != nil {
+		return e
+	}
+	if c.permissions, c.permissionToken, e = f.Permissions.save(); e != nil {
+		return e
+	}
+
+	tmp, e := ioutil.TempFile(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if e != nil {
+		return e
+	}
+	defer os.Remove(tmp.Name())
+
+	if e := encode(bufio.NewWriter(tmp), &c); e != nil {
+		tmp.Close()
+		return e
+	}
+	if e := tmp.Close(); e != nil {
+		return e
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
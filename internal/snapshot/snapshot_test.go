@@ -0,0 +1,129 @@
+package snapshot
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/supremind/rbac/types"
+)
+
+func TestSnapshot(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "snapshot test suit")
+}
+
+type groupingSource GroupingState
+
+func (s groupingSource) SnapshotGrouping() (GroupingState, error) {
+	return GroupingState(s), nil
+}
+
+type permissionSource struct {
+	polices []types.PermissionPolicy
+	token   string
+}
+
+func (s permissionSource) SnapshotPermission() ([]types.PermissionPolicy, string, error) {
+	return s.polices, s.token, nil
+}
+
+var _ = Describe("snapshot file", func() {
+	var dir, path string
+
+	BeforeEach(func() {
+		var e error
+		dir, e = ioutil.TempDir("", "rbac-snapshot")
+		Expect(e).To(Succeed())
+		path = filepath.Join(dir, "snapshot")
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	subjects := GroupingState{
+		Polices: []types.GroupingPolicy{
+			{Entity: types.User("alan"), Group: types.Role("editor")},
+			{Entity: types.Role("editor"), Group: types.Role("staff")},
+		},
+		Closures: []types.GroupingPolicy{
+			{Entity: types.User("alan"), Group: types.Role("editor")},
+			{Entity: types.User("alan"), Group: types.Role("staff")},
+			{Entity: types.Role("editor"), Group: types.Role("staff")},
+		},
+		Token: "3",
+	}
+	objects := GroupingState{
+		Polices: []types.GroupingPolicy{{Entity: types.Article("apollo"), Group: types.Category("history")}},
+		Token:   "resume:abc",
+	}
+	permissions := permissionSource{
+		polices: []types.PermissionPolicy{
+			{Subject: types.Role("editor"), Object: types.Category("history"), Action: types.ReadWrite},
+			{Subject: types.User("alan"), Object: types.Article("apollo"), Action: types.Exec},
+		},
+		token: "7",
+	}
+
+	It("should load what is saved", func() {
+		f, e := Open(path)
+		Expect(e).To(Succeed())
+		Expect(f.Subjects.Token).To(BeEmpty())
+
+		f.Subjects.Track(groupingSource(subjects))
+		f.Objects.Track(groupingSource(objects))
+		f.Permissions.Track(permissions)
+		Expect(f.Save()).To(Succeed())
+
+		f, e = Open(path)
+		Expect(e).To(Succeed())
+		Expect(f.Subjects.GroupingState).To(Equal(subjects))
+		Expect(f.Objects.GroupingState).To(Equal(objects))
+		Expect(f.Permissions.Polices).To(Equal(permissions.polices))
+		Expect(f.Permissions.Token).To(Equal(permissions.token))
+
+		By("dropping loaded states when tracking sources")
+		f.Subjects.Track(groupingSource(subjects))
+		Expect(f.Subjects.GroupingState).To(Equal(GroupingState{}))
+	})
+
+	It("should not load parts without sources", func() {
+		f, e := Open(path)
+		Expect(e).To(Succeed())
+		f.Objects.Track(groupingSource(objects))
+		Expect(f.Save()).To(Succeed())
+
+		f, e = Open(path)
+		Expect(e).To(Succeed())
+		Expect(f.Subjects.GroupingState).To(Equal(GroupingState{}))
+		Expect(f.Objects.GroupingState).To(Equal(objects))
+		Expect(f.Permissions.Polices).To(BeNil())
+	})
+
+	It("should be empty if the file is not in the current format", func() {
+		f, e := Open(path)
+		Expect(e).To(Succeed())
+		f.Subjects.Track(groupingSource(subjects))
+		Expect(f.Save()).To(Succeed())
+		raw, e := ioutil.ReadFile(path)
+		Expect(e).To(Succeed())
+
+		for name, content := range map[string][]byte{
+			"json":      []byte(`{"tokens":{"subjects":"3"}}`),
+			"version":   append([]byte(magic), version+1),
+			"truncated": raw[:len(raw)-1],
+		} {
+			By(name)
+			Expect(ioutil.WriteFile(path, content, 0644)).To(Succeed())
+			f, e := Open(path)
+			Expect(errors.Is(e, ErrFormat)).To(BeTrue())
+			Expect(f.Subjects.GroupingState).To(Equal(GroupingState{}))
+		}
+	})
+})
@@ -0,0 +1,134 @@
+// Package wire defines messages exchanged between the authorization server and its clients,
+// entities are in their serialized form, like user:alan, and actions are joined names, like read|write
+package wire
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/supremind/rbac/policy"
+	"github.com/supremind/rbac/types"
+)
+
+// Grouping is the body of join and leave requests
+type Grouping struct {
+	Entity string `json:"entity"`
+	Group  string `json:"group"`
+}
+
+// Permission is the body of permit, revoke and check requests
+type Permission struct {
+	Subject string `json:"subject"`
+	Object  string `json:"object"`
+	Action  string `json:"action"`
+}
+
+// Batch is the body of batch check requests
+type Batch struct {
+	Checks []Permission `json:"checks"`
+}
+
+// BatchResult is the response of batch check requests, in the same order as checks
+type BatchResult struct {
+	Results []Decision `json:"results"`
+}
+
+// Decision is the response of check requests, or the result of a check in batches
+type Decision struct {
+	Allowed bool   `json:"allowed"`
+	Error   *Error `json:"error,omitempty"`
+}
+
+// Actions is the response of permitted actions requests
+type Actions struct {
+	Action string `json:"action"`
+}
+
+// Bool is the response of is-in requests
+type Bool struct {
+	Result bool `json:"result"`
+}
+
+// Heartbeat is the interval of empty events sent in change feeds, so that broken connections could be told
+const Heartbeat = 15 * time.Second
+
+// Event is a line of change feeds, the first one is the snapshot of all polices, then changes follow,
+// events with neither are heartbeats
+type Event struct {
+	Snapshot *policy.Document `json:"snapshot,omitempty"`
+	Change   *Change          `json:"change,omitempty"`
+}
+
+// Change of a policy, kind is subject, object or permission,
+// entity and group are set for groupings, and subject, object and action are set for permissions
+type Change struct {
+	Kind    string `json:"kind"`
+	Method  string `json:"method"`
+	Entity  string `json:"entity,omitempty"`
+	Group   string `json:"group,omitempty"`
+	Subject string `json:"subject,omitempty"`
+	Object  string `json:"object,omitempty"`
+	Action  string `json:"action,omitempty"`
+}
+
+// kinds of changes
+const (
+	KindSubject    = "subject"
+	KindObject     = "object"
+	KindPermission = "permission"
+)
+
+// Error is the response of failed requests
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// error codes, and errors they stand for, so that clients could return the same errors as local authorizers
+var codes = []struct {
+	code   string
+	status int
+	err    error
+}{
+	{"not_found", http.StatusNotFound, types.ErrNotFound},
+	{"already_exists", http.StatusConflict, types.ErrAlreadyExists},
+	{"invalid_entity", http.StatusBadRequest, types.ErrInvalidEntity},
+	{"invalid_group", http.StatusBadRequest, types.ErrInvalidGroup},
+	{"invalid_member", http.StatusBadRequest, types.ErrInvalidMember},
+	{"invalid_subject", http.StatusBadRequest, types.ErrInvlaidSubject},
+	{"invalid_object", http.StatusBadRequest, types.ErrInvlaidObject},
+	{"unknown_action", http.StatusBadRequest, types.ErrUnknownAction},
+	{"read_only", http.StatusForbidden, types.ErrReadOnly},
+	{"no_subject_grouping", http.StatusNotImplemented, types.ErrNoSubjectGrouping},
+	{"no_object_grouping", http.StatusNotImplemented, types.ErrNoObjectGrouping},
+	{"no_policy_list", http.StatusNotImplemented, types.ErrNoPolicyList},
+}
+
+// CodeOf returns the error code and http status of e
+func CodeOf(e error) (string, int) {
+	for _, c := range codes {
+		if errors.Is(e, c.err) {
+			return c.code, c.status
+		}
+	}
+	return "internal", http.StatusInternalServerError
+}
+
+// ErrorOf returns the error standing for code, nil if it is unknown
+func ErrorOf(code string) error {
+	for _, c := range codes {
+		if c.code == code {
+			return c.err
+		}
+	}
+	return nil
+}
+
+// ParseAction parses actions, empty string is types.None
+func ParseAction(s string) (types.Action, error) {
+	if s == "" {
+		return types.None, nil
+	}
+	return types.ParseAction(s)
+}
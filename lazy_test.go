@@ -0,0 +1,202 @@
+package rbac_test
+
+import (
+	"context"
+	"sync/atomic"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/supremind/rbac"
+	"github.com/supremind/rbac/metrics"
+	"github.com/supremind/rbac/persist/fake"
+	. "github.com/supremind/rbac/types"
+)
+
+// lazyGroupingPersister queries polices, and counts queries
+type lazyGroupingPersister struct {
+	remoteGroupingPersister
+	querier GroupingQuerier
+	queries *int64
+}
+
+func (p lazyGroupingPersister) ListByEntity(ent Entity, cursor string, limit int) ([]GroupingPolicy, string, error) {
+	atomic.AddInt64(p.queries, 1)
+	return p.querier.ListByEntity(ent, cursor, limit)
+}
+
+func (p lazyGroupingPersister) ListByGroup(group Group, cursor string, limit int) ([]GroupingPolicy, string, error) {
+	atomic.AddInt64(p.queries, 1)
+	return p.querier.ListByGroup(group, cursor, limit)
+}
+
+// lazyPermissionPersister queries polices, and counts queries
+type lazyPermissionPersister struct {
+	remotePermissionPersister
+	querier PermissionQuerier
+	queries *int64
+}
+
+func (p lazyPermissionPersister) Get(sub Subject, obj Object) (Action, error) {
+	atomic.AddInt64(p.queries, 1)
+	return p.querier.Get(sub, obj)
+}
+
+func (p lazyPermissionPersister) ListBySubject(sub Subject, cursor string, limit int) ([]PermissionPolicy, string, error) {
+	atomic.AddInt64(p.queries, 1)
+	return p.querier.ListBySubject(sub, cursor, limit)
+}
+
+func (p lazyPermissionPersister) ListByObject(obj Object, cursor string, limit int) ([]PermissionPolicy, string, error) {
+	atomic.AddInt64(p.queries, 1)
+	return p.querier.ListByObject(obj, cursor, limit)
+}
+
+// gettingPermissionPersister gets single polices, and refuses to list polices of subjects
+type gettingPermissionPersister struct {
+	lazyPermissionPersister
+}
+
+func (p gettingPermissionPersister) ListBySubject(Subject, string, int) ([]PermissionPolicy, string, error) {
+	return nil, "", errListed
+}
+
+var _ = Describe("authorizer with lazy loading", func() {
+	var (
+		ctx     context.Context
+		cancel  context.CancelFunc
+		objects chan GroupingPolicyChange
+		perms   chan PermissionPolicyChange
+		op      GroupingPersister
+		pp      PermissionPersister
+		queries int64
+		authz   Authorizer
+	)
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		objects = make(chan GroupingPolicyChange)
+		perms = make(chan PermissionPolicyChange)
+		queries = 0
+
+		sp := fake.NewGroupingPersister()
+		Expect(sp.Insert(User("alan"), Role("editor"))).To(Succeed())
+		fop := fake.NewGroupingPersister()
+		Expect(fop.Insert(Article("apollo"), Category("history"))).To(Succeed())
+		Expect(fop.Insert(Article("gemini"), Category("science"))).To(Succeed())
+		Expect(fop.Insert(Category("history"), Category("library"))).To(Succeed())
+		fpp := fake.NewPermissionPersister()
+		Expect(fpp.Insert(Role("editor"), Category("library"), Read)).To(Succeed())
+		Expect(fpp.Insert(User("bob"), Article("gemini"), Write)).To(Succeed())
+		op, pp = fop, fpp
+
+		var e error
+		authz, e = rbac.New(ctx,
+			rbac.WithSubjectPersister(sp),
+			rbac.WithObjectPersister(lazyGroupingPersister{remoteGroupingPersister{fop, objects}, fop, &queries}),
+			rbac.WithPermissionPersister(lazyPermissionPersister{remotePermissionPersister{fpp, perms}, fpp, &queries}),
+			rbac.WithLazyLoading(100),
+		)
+		Expect(e).To(Succeed())
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	It("should load polices on demand", func() {
+		Expect(atomic.LoadInt64(&queries)).To(BeZero())
+
+		Expect(authz.Shall(User("alan"), Article("apollo"), Read)).To(BeTrue())
+		Expect(atomic.LoadInt64(&queries)).NotTo(BeZero())
+		// checks stop at the first permitting policy, all of them are loaded for permitted actions
+		Expect(authz.PermittedActions(User("alan"), Article("apollo"))).To(Equal(Read))
+		loaded := atomic.LoadInt64(&queries)
+		Expect(authz.Shall(User("alan"), Article("apollo"), Read)).To(BeTrue())
+		Expect(atomic.LoadInt64(&queries)).To(Equal(loaded))
+
+		Expect(authz.Objects().MembersIn(Category("library"))).To(HaveKey(Article("apollo")))
+		Expect(authz.PermissionsOn(Article("gemini"))).To(Equal(map[Subject]Action{User("bob"): Write}))
+	})
+
+	It("should reload polices changed locally", func() {
+		Expect(authz.Shall(User("alan"), Article("apollo"), Read)).To(BeTrue())
+		Expect(authz.ObjectLeave(Article("apollo"), Category("history"))).To(Succeed())
+		Expect(authz.Shall(User("alan"), Article("apollo"), Read)).To(BeFalse())
+
+		Expect(authz.Permit(User("alan"), Article("apollo"), Write)).To(Succeed())
+		Expect(authz.Shall(User("alan"), Article("apollo"), Write)).To(BeTrue())
+		Expect(authz.Revoke(User("alan"), Article("apollo"), Write)).To(Succeed())
+		Expect(authz.Shall(User("alan"), Article("apollo"), Write)).To(BeFalse())
+
+		Expect(authz.Shall(User("alan"), Category("history"), Read)).To(BeTrue())
+		Expect(authz.RemoveCategory(Category("library"))).To(Succeed())
+		Expect(authz.PermissionsOn(Category("library"))).To(BeEmpty())
+		Expect(authz.Shall(User("alan"), Category("history"), Read)).To(BeFalse())
+	})
+
+	It("should reload polices changed on other replicas", func() {
+		Expect(authz.Shall(User("bob"), Article("gemini"), Write)).To(BeTrue())
+		Expect(authz.PermissionsOn(Article("gemini"))).To(HaveLen(1))
+		Expect(pp.Remove(User("bob"), Article("gemini"))).To(Succeed())
+		perms <- PermissionPolicyChange{PermissionPolicy: PermissionPolicy{Subject: User("bob"), Object: Article("gemini")}, Method: PersistDelete}
+		Eventually(func() (bool, error) { return authz.Shall(User("bob"), Article("gemini"), Write) }).Should(BeFalse())
+		Expect(authz.PermissionsOn(Article("gemini"))).To(BeEmpty())
+
+		Expect(authz.Shall(User("alan"), Article("gemini"), Read)).To(BeFalse())
+		Expect(op.Insert(Category("science"), Category("library"))).To(Succeed())
+		objects <- GroupingPolicyChange{GroupingPolicy: GroupingPolicy{Entity: Category("science"), Group: Category("library")}, Method: PersistInsert}
+		Eventually(func() (bool, error) { return authz.Shall(User("alan"), Article("gemini"), Read) }).Should(BeTrue())
+	})
+
+	It("should decide the same as loading all polices", func() {
+		expectSameDecisions(ctx, authz)
+	})
+
+	It("should get single polices to check permissions", func() {
+		fpp := fake.NewPermissionPersister()
+		Expect(fpp.Insert(User("alan"), Article("apollo"), Read)).To(Succeed())
+		var gets int64
+		authz, e := rbac.New(ctx,
+			rbac.WithPermissionPersister(gettingPermissionPersister{lazyPermissionPersister{remotePermissionPersister{fpp, perms}, fpp, &gets}}),
+			rbac.WithLazyLoading(100),
+		)
+		Expect(e).To(Succeed())
+
+		Expect(authz.Shall(User("alan"), Article("apollo"), Read)).To(BeTrue())
+		Expect(authz.Shall(User("alan"), Article("apollo"), Read)).To(BeTrue())
+		Expect(authz.Shall(User("alan"), Article("gemini"), Read)).To(BeFalse())
+		Expect(atomic.LoadInt64(&gets)).To(BeEquivalentTo(2))
+
+		Expect(authz.Permit(User("alan"), Article("apollo"), Write)).To(Succeed())
+		Expect(authz.Shall(User("alan"), Article("apollo"), ReadWrite)).To(BeTrue())
+	})
+
+	It("should not count polices loaded lazily", func() {
+		m := metrics.NewExpvar()
+		fop := fake.NewGroupingPersister()
+		fpp := fake.NewPermissionPersister()
+		authz, e := rbac.New(ctx,
+			rbac.WithObjectPersister(lazyGroupingPersister{remoteGroupingPersister{fop, objects}, fop, &queries}),
+			rbac.WithPermissionPersister(lazyPermissionPersister{remotePermissionPersister{fpp, perms}, fpp, &queries}),
+			rbac.WithLazyLoading(100),
+			rbac.WithMetrics(m),
+		)
+		Expect(e).To(Succeed())
+
+		Expect(authz.ObjectJoin(Article("apollo"), Category("history"))).To(Succeed())
+		Expect(authz.Permit(User("alan"), Article("apollo"), Read)).To(Succeed())
+		Expect(authz.ObjectLeave(Article("apollo"), Category("history"))).To(Succeed())
+		Expect(authz.RemoveArticle(Article("apollo"))).To(Succeed())
+		Expect(m.String()).To(ContainSubstring(`"rbac_policies":{}`))
+	})
+
+	It("should require persisters to query polices", func() {
+		_, e := rbac.New(ctx,
+			rbac.WithObjectPersister(fake.NewGroupingPersister()),
+			rbac.WithPermissionPersister(remotePermissionPersister{fake.NewPermissionPersister(), perms}),
+			rbac.WithLazyLoading(100),
+		)
+		Expect(e).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,57 @@
+package rbac_test
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/supremind/rbac"
+	"github.com/supremind/rbac/persist/fake"
+)
+
+// recordingSink records messages logged at any level
+type recordingSink struct {
+	messages *[]string
+	lock     *sync.Mutex
+}
+
+func (s recordingSink) Init(logr.RuntimeInfo)  {}
+func (s recordingSink) Enabled(level int) bool { return true }
+
+func (s recordingSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	*s.messages = append(*s.messages, msg)
+}
+
+func (s recordingSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	s.Info(0, msg, keysAndValues...)
+}
+
+func (s recordingSink) WithValues(keysAndValues ...interface{}) logr.LogSink { return s }
+func (s recordingSink) WithName(name string) logr.LogSink                    { return s }
+
+var _ = Describe("authorizer logger", func() {
+	It("should log to the given logger", func() {
+		var messages []string
+		var lock sync.Mutex
+		authz, e := rbac.New(context.Background(),
+			rbac.WithPermissionPersister(fake.NewPermissionPersister()),
+			rbac.WithLogger(logr.New(recordingSink{messages: &messages, lock: &lock})),
+		)
+		Expect(e).To(Succeed())
+		Expect(authz).NotTo(BeNil())
+
+		lock.Lock()
+		defer lock.Unlock()
+		Expect(messages).NotTo(BeEmpty())
+	})
+
+	It("should log to stderr without a logger", func() {
+		_, e := rbac.New(context.Background(), rbac.WithPermissionPersister(fake.NewPermissionPersister()))
+		Expect(e).To(Succeed())
+	})
+})
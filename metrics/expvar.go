@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"encoding/json"
+	"expvar"
+	"strconv"
+	"time"
+
+	"github.com/supremind/rbac/types"
+)
+
+var (
+	_ types.Metrics = (*Expvar)(nil)
+	_ expvar.Var    = (*Expvar)(nil)
+)
+
+// Expvar keeps measurements in memory, it is an expvar.Var to be published like:
+//
+//	expvar.Publish("rbac", m)
+//
+// and an http.Handler exposing measurements in Prometheus text format
+type Expvar struct {
+	decisions       *family
+	decisionSeconds *family
+	writes          *family
+	writeSeconds    *family
+	changes         *family
+	syncLagSeconds  *family
+	policies        *family
+}
+
+// NewExpvar creates an Expvar metrics
+func NewExpvar() *Expvar {
+	return &Expvar{
+		decisions:       newFamily("rbac_decisions_total", "Decisions made by Shall.", counter),
+		decisionSeconds: newFamily("rbac_decision_duration_seconds", "Time taken to make decisions.", histogram, decisionBuckets...),
+		writes:          newFamily("rbac_persister_writes_total", "Writes to persisters.", counter),
+		writeSeconds:    newFamily("rbac_persister_write_duration_seconds", "Time taken to write to persisters.", histogram, roundTripBuckets...),
+		changes:         newFamily("rbac_persister_changes_total", "Changes watched from persisters.", counter),
+		syncLagSeconds:  newFamily("rbac_sync_lag_seconds", "Time taken for changes to be watched by this replica since they were made in persisters.", histogram, roundTripBuckets...),
+		policies:        newFamily("rbac_policies", "Number of polices held in memory.", gauge),
+	}
+}
+
+func (m *Expvar) families() []*family {
+	return []*family{m.decisions, m.decisionSeconds, m.writes, m.writeSeconds, m.changes, m.syncLagSeconds, m.policies}
+}
+
+// ObserveDecision implements types.Metrics
+func (m *Expvar) ObserveDecision(act types.Action, allowed bool, err error, took time.Duration) {
+	result := "denied"
+	switch {
+	case err != nil:
+		result = "error"
+	case allowed:
+		result = "allowed"
+	}
+
+	m.decisions.add(labels("action", act.String(), "result", result), 1)
+	m.decisionSeconds.observe("", took.Seconds())
+}
+
+// ObservePersist implements types.Metrics
+func (m *Expvar) ObservePersist(component string, method types.PersistMethod, err error, took time.Duration) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+
+	m.writes.add(labels("component", component, "method", string(method), "result", result), 1)
+	m.writeSeconds.observe(labels("component", component, "method", string(method)), took.Seconds())
+}
+
+// ObserveChange implements types.Metrics
+func (m *Expvar) ObserveChange(component string, method types.PersistMethod, filtered bool) {
+	result := "applied"
+	if filtered {
+		result = "filtered"
+	}
+
+	m.changes.add(labels("component", component, "method", string(method), "result", result), 1)
+}
+
+// ObserveSyncLag implements types.Metrics
+func (m *Expvar) ObserveSyncLag(component string, lag time.Duration) {
+	m.syncLagSeconds.observe(labels("component", component), lag.Seconds())
+}
+
+// SetPolicies implements types.Metrics
+func (m *Expvar) SetPolicies(component string, n int) {
+	m.policies.set(labels("component", component), float64(n))
+}
+
+// String implements expvar.Var, measurements are encoded as a json object,
+// keyed by names of metrics, and then labels of series
+func (m *Expvar) String() string {
+	doc := make(map[string]map[string]interface{})
+	for _, f := range m.families() {
+		f := f
+		values := make(map[string]interface{})
+		f.each(func(labels string, s series) {
+			if f.kind != histogram {
+				values[labels] = s.value
+				return
+			}
+
+			buckets := make(map[string]uint64, len(s.counts))
+			var cumulative uint64
+			for i, n := range s.counts {
+				cumulative += n
+				buckets[bucketLabel(f.buckets, i)] = cumulative
+			}
+			values[labels] = map[string]interface{}{
+				"count":   s.count,
+				"sum":     s.sum,
+				"buckets": buckets,
+			}
+		})
+		doc[f.name] = values
+	}
+
+	out, e := json.Marshal(doc)
+	if e != nil {
+		return "{}"
+	}
+	return string(out)
+}
+
+func bucketLabel(buckets []float64, i int) string {
+	if i >= len(buckets) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(buckets[i], 'g', -1, 64)
+}
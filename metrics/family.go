@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+type kind string
+
+const (
+	counter   kind = "counter"
+	gauge     kind = "gauge"
+	histogram kind = "histogram"
+)
+
+// upper bounds of histogram buckets, in seconds
+var (
+	decisionBuckets  = []float64{1e-6, 5e-6, 1e-5, 5e-5, 1e-4, 5e-4, 1e-3, 5e-3, 1e-2}
+	roundTripBuckets = []float64{1e-3, 5e-3, 1e-2, 5e-2, 0.1, 0.5, 1, 5, 10}
+)
+
+// family is a named metric, with series of values distinguished by labels
+type family struct {
+	name    string
+	help    string
+	kind    kind
+	buckets []float64
+	series  map[string]*series // rendered labels => series
+	sync.Mutex
+}
+
+type series struct {
+	value  float64  // of counter or gauge
+	counts []uint64 // of histogram, observations fell into each bucket, the last one is +Inf
+	sum    float64
+	count  uint64
+}
+
+func newFamily(name, help string, k kind, buckets ...float64) *family {
+	return &family{
+		name:    name,
+		help:    help,
+		kind:    k,
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+}
+
+func (f *family) get(labels string) *series {
+	s, ok := f.series[labels]
+	if !ok {
+		s = &series{}
+		if f.kind == histogram {
+			s.counts = make([]uint64, len(f.buckets)+1)
+		}
+		f.series[labels] = s
+	}
+	return s
+}
+
+func (f *family) add(labels string, v float64) {
+	f.Lock()
+	f.get(labels).value += v
+	f.Unlock()
+}
+
+func (f *family) set(labels string, v float64) {
+	f.Lock()
+	f.get(labels).value = v
+	f.Unlock()
+}
+
+func (f *family) observe(labels string, v float64) {
+	i := sort.SearchFloat64s(f.buckets, v)
+
+	f.Lock()
+	s := f.get(labels)
+	s.counts[i]++
+	s.sum += v
+	s.count++
+	f.Unlock()
+}
+
+// each calls fn with copies of series in order of their labels
+func (f *family) each(fn func(labels string, s series)) {
+	f.Lock()
+	keys := make([]string, 0, len(f.series))
+	copies := make(map[string]series, len(f.series))
+	for labels, s := range f.series {
+		keys = append(keys, labels)
+		c := *s
+		c.counts = append([]uint64(nil), s.counts...)
+		copies[labels] = c
+	}
+	f.Unlock()
+
+	sort.Strings(keys)
+	for _, labels := range keys {
+		fn(labels, copies[labels])
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labels renders pairs of label names and values in Prometheus text format, like: a="x",b="y"
+func labels(pairs ...string) string {
+	b := strings.Builder{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(pairs[i])
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(pairs[i+1]))
+		b.WriteByte('"')
+	}
+	return b.String()
+}
@@ -0,0 +1,19 @@
+// Package metrics provides implementations of types.Metrics without external dependencies
+package metrics
+
+import (
+	"time"
+
+	"github.com/supremind/rbac/types"
+)
+
+// Discard drops all measurements
+var Discard types.Metrics = discard{}
+
+type discard struct{}
+
+func (discard) ObserveDecision(types.Action, bool, error, time.Duration)         {}
+func (discard) ObservePersist(string, types.PersistMethod, error, time.Duration) {}
+func (discard) ObserveChange(string, types.PersistMethod, bool)                  {}
+func (discard) ObserveSyncLag(string, time.Duration)                             {}
+func (discard) SetPolicies(string, int)                                          {}
@@ -0,0 +1,71 @@
+package metrics_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/supremind/rbac/metrics"
+	. "github.com/supremind/rbac/types"
+)
+
+func TestMetrics(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "metrics test suit")
+}
+
+var _ = Describe("expvar metrics", func() {
+	scrape := func(m *Expvar) string {
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		Expect(rec.Header().Get("Content-Type")).To(HavePrefix("text/plain"))
+		body, e := ioutil.ReadAll(rec.Body)
+		Expect(e).To(Succeed())
+		return string(body)
+	}
+
+	It("should expose counters and gauges in prometheus text format", func() {
+		m := NewExpvar()
+		m.ObserveDecision(Read, true, nil, time.Microsecond)
+		m.ObserveDecision(Write, false, errors.New("oops"), time.Microsecond)
+		m.ObserveChange("subject", PersistInsert, false)
+		m.SetPolicies("permission", 3)
+		m.SetPolicies("permission", 2)
+
+		text := scrape(m)
+		Expect(text).To(ContainSubstring("# TYPE rbac_decisions_total counter\n"))
+		Expect(text).To(ContainSubstring(`rbac_decisions_total{action="read",result="allowed"} 1` + "\n"))
+		Expect(text).To(ContainSubstring(`rbac_decisions_total{action="write",result="error"} 1` + "\n"))
+		Expect(text).To(ContainSubstring(`rbac_persister_changes_total{component="subject",method="insert",result="applied"} 1` + "\n"))
+		Expect(text).To(ContainSubstring("# TYPE rbac_policies gauge\n"))
+		Expect(text).To(ContainSubstring(`rbac_policies{component="permission"} 2` + "\n"))
+	})
+
+	It("should expose cumulative histogram buckets", func() {
+		m := NewExpvar()
+		m.ObservePersist("object", PersistDelete, nil, 2*time.Millisecond)
+		m.ObservePersist("object", PersistDelete, nil, 20*time.Millisecond)
+		m.ObservePersist("object", PersistDelete, nil, time.Minute)
+
+		text := scrape(m)
+		Expect(text).To(ContainSubstring("# TYPE rbac_persister_write_duration_seconds histogram\n"))
+		Expect(text).To(ContainSubstring(`rbac_persister_write_duration_seconds_bucket{component="object",method="delete",le="0.001"} 0` + "\n"))
+		Expect(text).To(ContainSubstring(`rbac_persister_write_duration_seconds_bucket{component="object",method="delete",le="0.005"} 1` + "\n"))
+		Expect(text).To(ContainSubstring(`rbac_persister_write_duration_seconds_bucket{component="object",method="delete",le="0.05"} 2` + "\n"))
+		Expect(text).To(ContainSubstring(`rbac_persister_write_duration_seconds_bucket{component="object",method="delete",le="10"} 2` + "\n"))
+		Expect(text).To(ContainSubstring(`rbac_persister_write_duration_seconds_bucket{component="object",method="delete",le="+Inf"} 3` + "\n"))
+		Expect(text).To(ContainSubstring(`rbac_persister_write_duration_seconds_count{component="object",method="delete"} 3` + "\n"))
+		Expect(text).To(ContainSubstring(`rbac_persister_writes_total{component="object",method="delete",result="ok"} 3` + "\n"))
+	})
+
+	It("should escape label values", func() {
+		m := NewExpvar()
+		m.SetPolicies(`a"b\c`, 1)
+		Expect(scrape(m)).To(ContainSubstring(`rbac_policies{component="a\"b\\c"} 1` + "\n"))
+	})
+})
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// ServeHTTP exposes measurements in Prometheus text format
+func (m *Expvar) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	bw := bufio.NewWriter(w)
+	for _, f := range m.families() {
+		writeFamily(bw, f)
+	}
+	bw.Flush()
+}
+
+func writeFamily(w *bufio.Writer, f *family) {
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, f.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.kind)
+
+	f.each(func(labels string, s series) {
+		if f.kind != histogram {
+			fmt.Fprintf(w, "%s%s %s\n", f.name, braced(labels), formatValue(s.value))
+			return
+		}
+
+		var cumulative uint64
+		for i, n := range s.counts {
+			cumulative += n
+			le := `le="` + bucketLabel(f.buckets, i) + `"`
+			if labels != "" {
+				le = labels + "," + le
+			}
+			fmt.Fprintf(w, "%s_bucket{%s} %d\n", f.name, le, cumulative)
+		}
+		fmt.Fprintf(w, "%s_sum%s %s\n", f.name, braced(labels), formatValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", f.name, braced(labels), s.count)
+	})
+}
+
+func braced(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,105 @@
+package rbac_test
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/supremind/rbac"
+	"github.com/supremind/rbac/metrics"
+	"github.com/supremind/rbac/persist/fake"
+	. "github.com/supremind/rbac/types"
+)
+
+var _ = Describe("authorizer with metrics", func() {
+	var (
+		m     *metrics.Expvar
+		authz Authorizer
+	)
+
+	BeforeEach(func() {
+		m = metrics.NewExpvar()
+		var e error
+		authz, e = rbac.New(context.Background(),
+			rbac.WithSubjectPersister(fake.NewGroupingPersister()),
+			rbac.WithObjectPersister(fake.NewGroupingPersister()),
+			rbac.WithPermissionPersister(fake.NewPermissionPersister()),
+			rbac.WithMetrics(m),
+		)
+		Expect(e).To(Succeed())
+	})
+
+	values := func(name string) map[string]interface{} {
+		var doc map[string]map[string]interface{}
+		Expect(json.Unmarshal([]byte(m.String()), &doc)).To(Succeed())
+		return doc[name]
+	}
+
+	It("should count decisions by action and result", func() {
+		Expect(authz.Permit(User("alan"), Article("apollo"), Read)).To(Succeed())
+		Expect(authz.Shall(User("alan"), Article("apollo"), Read)).To(BeTrue())
+		Expect(authz.Shall(User("alan"), Article("apollo"), Read)).To(BeTrue())
+		Expect(authz.Shall(User("alan"), Article("apollo"), Write)).To(BeFalse())
+
+		Expect(values("rbac_decisions_total")).To(Equal(map[string]interface{}{
+			`action="read",result="allowed"`: 2.0,
+			`action="write",result="denied"`: 1.0,
+		}))
+		Expect(values("rbac_decision_duration_seconds")).To(HaveKeyWithValue("", HaveKeyWithValue("count", 3.0)))
+	})
+
+	It("should measure persister writes and polices held", func() {
+		Expect(authz.SubjectJoin(User("alan"), Role("editor"))).To(Succeed())
+		Expect(authz.SubjectJoin(User("bob"), Role("editor"))).To(Succeed())
+		Expect(authz.Permit(Role("editor"), Article("apollo"), Read)).To(Succeed())
+		Expect(authz.Permit(Role("editor"), Article("apollo"), Write)).To(Succeed())
+		Expect(authz.SubjectLeave(User("bob"), Role("editor"))).To(Succeed())
+		Expect(authz.SubjectLeave(User("bob"), Role("editor"))).NotTo(Succeed())
+
+		Expect(values("rbac_persister_writes_total")).To(Equal(map[string]interface{}{
+			`component="subject",method="insert",result="ok"`:    2.0,
+			`component="subject",method="delete",result="ok"`:    1.0,
+			`component="subject",method="delete",result="error"`: 1.0,
+			`component="permission",method="insert",result="ok"`: 1.0,
+			`component="permission",method="update",result="ok"`: 1.0,
+		}))
+		Expect(values("rbac_policies")).To(Equal(map[string]interface{}{
+			`component="subject"`:    1.0,
+			`component="object"`:     0.0,
+			`component="permission"`: 1.0,
+		}))
+	})
+
+	It("should filter changes made by itself, and measure the sync lag", func() {
+		Expect(authz.SubjectJoin(User("alan"), Role("editor"))).To(Succeed())
+
+		Eventually(func() map[string]interface{} {
+			return values("rbac_persister_changes_total")
+		}).Should(HaveKeyWithValue(`component="subject",method="insert",result="filtered"`, 1.0))
+		Eventually(func() map[string]interface{} {
+			return values("rbac_sync_lag_seconds")
+		}).Should(HaveKeyWithValue(`component="subject"`, HaveKeyWithValue("count", 1.0)))
+	})
+
+	It("should measure the sync lag of changes made by other replicas", func() {
+		sp, op, pp := fake.NewGroupingPersister(), fake.NewGroupingPersister(), fake.NewPermissionPersister()
+		writer, e := rbac.New(context.Background(),
+			rbac.WithSubjectPersister(sp), rbac.WithObjectPersister(op), rbac.WithPermissionPersister(pp))
+		Expect(e).To(Succeed())
+		_, e = rbac.New(context.Background(),
+			rbac.WithSubjectPersister(sp), rbac.WithObjectPersister(op), rbac.WithPermissionPersister(pp),
+			rbac.WithMetrics(m))
+		Expect(e).To(Succeed())
+
+		Expect(writer.Permit(User("alan"), Article("apollo"), Read)).To(Succeed())
+
+		Eventually(func() map[string]interface{} {
+			return values("rbac_persister_changes_total")
+		}).Should(HaveKeyWithValue(`component="permission",method="insert",result="applied"`, 1.0))
+		Eventually(func() map[string]interface{} {
+			return values("rbac_sync_lag_seconds")
+		}).Should(HaveKeyWithValue(`component="permission"`, HaveKeyWithValue("count", 1.0)))
+	})
+})
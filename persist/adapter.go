@@ -0,0 +1,165 @@
+// Package persist adapts persisters to the interfaces used by rbac
+package persist
+
+import (
+	"context"
+
+	"github.com/supremind/rbac/types"
+)
+
+// GroupingWithContext adapts a GroupingPersister to be context-aware,
+// persisters already implement GroupingPersisterContext are returned as is, and nil is nil,
+// otherwise contexts are only checked before calling the persister, calls in flight could not be canceled.
+func GroupingWithContext(p types.GroupingPersister) types.GroupingPersisterContext {
+	if p == nil {
+		return nil
+	}
+	if pc, ok := p.(types.GroupingPersisterContext); ok {
+		return pc
+	}
+	return groupingAdapter{p}
+}
+
+type groupingAdapter struct {
+	p types.GroupingPersister
+}
+
+func (a groupingAdapter) InsertContext(ctx context.Context, ent types.Entity, group types.Group) error {
+	if e := ctx.Err(); e != nil {
+		return e
+	}
+	return a.p.Insert(ent, group)
+}
+
+func (a groupingAdapter) RemoveContext(ctx context.Context, ent types.Entity, group types.Group) error {
+	if e := ctx.Err(); e != nil {
+		return e
+	}
+	return a.p.Remove(ent, group)
+}
+
+func (a groupingAdapter) ListContext(ctx context.Context) ([]types.GroupingPolicy, error) {
+	if e := ctx.Err(); e != nil {
+		return nil, e
+	}
+	return a.p.List()
+}
+
+func (a groupingAdapter) Watch(ctx context.Context) (<-chan types.GroupingPolicyChange, error) {
+	return a.p.Watch(ctx)
+}
+
+// PermissionWithContext adapts a PermissionPersister to be context-aware,
+// persisters already implement PermissionPersisterContext are returned as is, and nil is nil,
+// otherwise contexts are only checked before calling the persister, calls in flight could not be canceled.
+func PermissionWithContext(p types.PermissionPersister) types.PermissionPersisterContext {
+	if p == nil {
+		return nil
+	}
+	if pc, ok := p.(types.PermissionPersisterContext); ok {
+		return pc
+	}
+	return permissionAdapter{p}
+}
+
+type permissionAdapter struct {
+	p types.PermissionPersister
+}
+
+func (a permissionAdapter) InsertContext(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) error {
+	if e := ctx.Err(); e != nil {
+		return e
+	}
+	return a.p.Insert(sub, obj, act)
+}
+
+func (a permissionAdapter) UpdateContext(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) error {
+	if e := ctx.Err(); e != nil {
+		return e
+	}
+	return a.p.Update(sub, obj, act)
+}
+
+func (a permissionAdapter) RemoveContext(ctx context.Context, sub types.Subject, obj types.Object) error {
+	if e := ctx.Err(); e != nil {
+		return e
+	}
+	return a.p.Remove(sub, obj)
+}
+
+func (a permissionAdapter) ListContext(ctx context.Context) ([]types.PermissionPolicy, error) {
+	if e := ctx.Err(); e != nil {
+		return nil, e
+	}
+	return a.p.List()
+}
+
+func (a permissionAdapter) Watch(ctx context.Context) (<-chan types.PermissionPolicyChange, error) {
+	return a.p.Watch(ctx)
+}
+
+// GroupingQuerierOf returns p as a GroupingQuerier, ok is false if it could not query polices,
+// persisters adapted by GroupingWithContext are unwrapped
+func GroupingQuerierOf(p types.GroupingPersisterContext) (q types.GroupingQuerier, ok bool) {
+	if a, adapted := p.(groupingAdapter); adapted {
+		q, ok = a.p.(types.GroupingQuerier)
+		return
+	}
+	q, ok = p.(types.GroupingQuerier)
+	return
+}
+
+// PermissionQuerierOf returns p as a PermissionQuerier, ok is false if it could not query polices,
+// persisters adapted by PermissionWithContext are unwrapped
+func PermissionQuerierOf(p types.PermissionPersisterContext) (q types.PermissionQuerier, ok bool) {
+	if a, adapted := p.(permissionAdapter); adapted {
+		q, ok = a.p.(types.PermissionQuerier)
+		return
+	}
+	q, ok = p.(types.PermissionQuerier)
+	return
+}
+
+// GroupingStreamerOf returns p as a GroupingStreamer, ok is false if it could not stream polices,
+// persisters adapted by GroupingWithContext are unwrapped
+func GroupingStreamerOf(p types.GroupingPersisterContext) (s types.GroupingStreamer, ok bool) {
+	if a, adapted := p.(groupingAdapter); adapted {
+		s, ok = a.p.(types.GroupingStreamer)
+		return
+	}
+	s, ok = p.(types.GroupingStreamer)
+	return
+}
+
+// PermissionStreamerOf returns p as a PermissionStreamer, ok is false if it could not stream polices,
+// persisters adapted by PermissionWithContext are unwrapped
+func PermissionStreamerOf(p types.PermissionPersisterContext) (s types.PermissionStreamer, ok bool) {
+	if a, adapted := p.(permissionAdapter); adapted {
+		s, ok = a.p.(types.PermissionStreamer)
+		return
+	}
+	s, ok = p.(types.PermissionStreamer)
+	return
+}
+
+// GroupingResumerOf returns p as a GroupingResumer, ok is false if it could not resume watching,
+// persisters adapted by GroupingWithContext are unwrapped
+func GroupingResumerOf(p types.GroupingPersisterContext) (r types.GroupingResumer, ok bool) {
+	if a, adapted := p.(groupingAdapter); adapted {
+		r, ok = a.p.(types.GroupingResumer)
+		return
+	}
+	r, ok = p.(types.GroupingResumer)
+	return
+}
+
+// PermissionResumerOf returns p as a PermissionResumer, ok is false if it could not resume watching,
+// persisters adapted by PermissionWithContext are unwrapped
+func PermissionResumerOf(p types.PermissionPersisterContext) (r types.PermissionResumer, ok bool) {
+	if a, adapted := p.(permissionAdapter); adapted {
+		r, ok = a.p.(types.PermissionResumer)
+		return
+	}
+	r, ok = p.(types.PermissionResumer)
+	return
+}
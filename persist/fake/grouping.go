@@ -2,14 +2,18 @@ package fake
 
 import (
 	"context"
+	"sort"
 	"sync"
+	"time"
 
+	"github.com/supremind/rbac/persist"
 	"github.com/supremind/rbac/types"
 )
 
 type groupingPersister struct {
 	policies map[types.Entity]map[types.Group]struct{}
 	changes  chan types.GroupingPolicyChange
+	log      *changeLog // of types.GroupingPolicyChange
 	sync.RWMutex
 }
 
@@ -17,6 +21,7 @@ type groupingPersister struct {
 func NewGroupingPersister() *groupingPersister {
 	gp := &groupingPersister{
 		policies: make(map[types.Entity]map[types.Group]struct{}),
+		log:      newChangeLog(),
 	}
 	return gp
 }
@@ -35,15 +40,16 @@ func (p *groupingPersister) Insert(ent types.Entity, group types.Group) error {
 
 	p.policies[ent][group] = struct{}{}
 
+	change := types.GroupingPolicyChange{
+		GroupingPolicy: types.GroupingPolicy{
+			Entity: ent,
+			Group:  group,
+		},
+		Method: types.PersistInsert,
+	}
+	p.log.append(change)
 	if p.changes != nil {
-		p.policies[ent][group] = struct{}{}
-		p.changes <- types.GroupingPolicyChange{
-			GroupingPolicy: types.GroupingPolicy{
-				Entity: ent,
-				Group:  group,
-			},
-			Method: types.PersistInsert,
-		}
+		p.changes <- change
 	}
 
 	return nil
@@ -62,14 +68,16 @@ func (p *groupingPersister) Remove(ent types.Entity, group types.Group) error {
 
 	delete(p.policies[ent], group)
 
+	change := types.GroupingPolicyChange{
+		GroupingPolicy: types.GroupingPolicy{
+			Entity: ent,
+			Group:  group,
+		},
+		Method: types.PersistDelete,
+	}
+	p.log.append(change)
 	if p.changes != nil {
-		p.changes <- types.GroupingPolicyChange{
-			GroupingPolicy: types.GroupingPolicy{
-				Entity: ent,
-				Group:  group,
-			},
-			Method: types.PersistDelete,
-		}
+		p.changes <- change
 	}
 
 	return nil
@@ -79,6 +87,11 @@ func (p *groupingPersister) List() ([]types.GroupingPolicy, error) {
 	p.RLock()
 	defer p.RUnlock()
 
+	return p.list()
+}
+
+// list lists all polices, it must be called with lock held
+func (p *groupingPersister) list() ([]types.GroupingPolicy, error) {
 	polices := make([]types.GroupingPolicy, 0, len(p.policies))
 	for ent, groups := range p.policies {
 		for group := range groups {
@@ -89,6 +102,55 @@ func (p *groupingPersister) List() ([]types.GroupingPolicy, error) {
 	return polices, nil
 }
 
+// Each calls fn with every policy, fn must not write to the persister
+func (p *groupingPersister) Each(ctx context.Context, fn func(types.GroupingPolicy) error) error {
+	p.RLock()
+	defer p.RUnlock()
+
+	for ent, groups := range p.policies {
+		for group := range groups {
+			if e := ctx.Err(); e != nil {
+				return e
+			}
+			if e := fn(types.GroupingPolicy{Entity: ent, Group: group}); e != nil {
+				return e
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *groupingPersister) ListByEntity(ent types.Entity, cursor string, limit int) ([]types.GroupingPolicy, string, error) {
+	p.RLock()
+	defer p.RUnlock()
+
+	polices := make([]types.GroupingPolicy, 0, len(p.policies[ent]))
+	for group := range p.policies[ent] {
+		polices = append(polices, types.GroupingPolicy{Entity: ent, Group: group})
+	}
+	sort.Slice(polices, func(i, j int) bool { return polices[i].Group.String() < polices[j].Group.String() })
+
+	start, end, next := persist.Page(len(polices), func(i int) string { return polices[i].Group.String() }, cursor, limit)
+	return polices[start:end], next, nil
+}
+
+func (p *groupingPersister) ListByGroup(group types.Group, cursor string, limit int) ([]types.GroupingPolicy, string, error) {
+	p.RLock()
+	defer p.RUnlock()
+
+	var polices []types.GroupingPolicy
+	for ent, groups := range p.policies {
+		if _, ok := groups[group]; ok {
+			polices = append(polices, types.GroupingPolicy{Entity: ent, Group: group})
+		}
+	}
+	sort.Slice(polices, func(i, j int) bool { return polices[i].Entity.String() < polices[j].Entity.String() })
+
+	start, end, next := persist.Page(len(polices), func(i int) string { return polices[i].Entity.String() }, cursor, limit)
+	return polices[start:end], next, nil
+}
+
 func (p *groupingPersister) Watch(ctx context.Context) (<-chan types.GroupingPolicyChange, error) {
 	p.Lock()
 	defer p.Unlock()
@@ -97,6 +159,38 @@ func (p *groupingPersister) Watch(ctx context.Context) (<-chan types.GroupingPol
 	return p.changes, nil
 }
 
+// ListWithToken lists all polices, with the handshake token of the position in changes they are listed at,
+// it is valid until watched from, or ctx is done
+func (p *groupingPersister) ListWithToken(ctx context.Context) ([]types.GroupingPolicy, string, error) {
+	p.RLock()
+	defer p.RUnlock()
+
+	polices, e := p.list()
+	return polices, p.log.handshake(ctx), e
+}
+
+// WatchFrom watches changes after the position of token, all changes are kept, so any token sent along with changes is valid
+func (p *groupingPersister) WatchFrom(ctx context.Context, token string) (<-chan types.ResumableGroupingChange, error) {
+	pos, e := p.log.position(token)
+	if e != nil {
+		return nil, e
+	}
+
+	changes := make(chan types.ResumableGroupingChange)
+	go func() {
+		defer close(changes)
+		p.log.follow(ctx, pos, func(change interface{}, token string, at time.Time) bool {
+			select {
+			case changes <- types.ResumableGroupingChange{GroupingPolicyChange: change.(types.GroupingPolicyChange), Token: token, Time: at}:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}()
+	return changes, nil
+}
+
 func (p *groupingPersister) Close() {
 	close(p.changes)
 }
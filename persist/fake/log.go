@@ -0,0 +1,119 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/supremind/rbac/types"
+)
+
+// changeLog keeps all changes ever made, so that changes after any position could be watched again.
+// Positions are numbers of changes made before them, they are used as resume tokens.
+// Times when changes were made are kept along with them, to be sent to followers.
+// Positions of listing are handshake tokens instead, which could be watched from only once and only in this process,
+// as handshakes of the mgo persisters on servers not telling positions of change streams.
+type changeLog struct {
+	changes    []interface{}
+	times      []time.Time
+	handshakes map[string]int
+	next       int
+	cond       *sync.Cond
+	sync.Mutex
+}
+
+const handshakePrefix = "handshake:"
+
+func newChangeLog() *changeLog {
+	l := &changeLog{handshakes: make(map[string]int)}
+	l.cond = sync.NewCond(&l.Mutex)
+	return l
+}
+
+// append a change, and wakes up followers
+func (l *changeLog) append(change interface{}) {
+	l.Lock()
+	defer l.Unlock()
+
+	l.changes = append(l.changes, change)
+	l.times = append(l.times, time.Now())
+	l.cond.Broadcast()
+}
+
+// handshake returns a handshake token of the current position, it is dropped if not watched from before ctx is done
+func (l *changeLog) handshake(ctx context.Context) string {
+	l.Lock()
+	defer l.Unlock()
+
+	l.next++
+	token := handshakePrefix + strconv.Itoa(l.next)
+	l.handshakes[token] = len(l.changes)
+
+	go func() {
+		<-ctx.Done()
+		l.Lock()
+		delete(l.handshakes, token)
+		l.Unlock()
+	}()
+	return token
+}
+
+// position parses token, an empty token is the current position, handshake tokens are valid only once
+func (l *changeLog) position(token string) (int, error) {
+	l.Lock()
+	defer l.Unlock()
+
+	if token == "" {
+		return len(l.changes), nil
+	}
+	if strings.HasPrefix(token, handshakePrefix) {
+		pos, ok := l.handshakes[token]
+		if !ok {
+			return 0, fmt.Errorf("%w: %s", types.ErrInvalidToken, token)
+		}
+		delete(l.handshakes, token)
+		return pos, nil
+	}
+	pos, e := strconv.Atoi(token)
+	if e != nil || pos < 0 || pos > len(l.changes) {
+		return 0, fmt.Errorf("%w: %s", types.ErrInvalidToken, token)
+	}
+	return pos, nil
+}
+
+// follow calls send with every change after pos, the token right after it, and when it was made, as they are made,
+// until ctx is done or send returns false
+func (l *changeLog) follow(ctx context.Context, pos int, send func(change interface{}, token string, at time.Time) bool) {
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			l.Lock()
+			l.cond.Broadcast()
+			l.Unlock()
+		case <-stop:
+		}
+	}()
+
+	for {
+		l.Lock()
+		for pos >= len(l.changes) && ctx.Err() == nil {
+			l.cond.Wait()
+		}
+		if ctx.Err() != nil {
+			l.Unlock()
+			return
+		}
+		change, at := l.changes[pos], l.times[pos]
+		pos++
+		l.Unlock()
+
+		if !send(change, strconv.Itoa(pos), at) {
+			return
+		}
+	}
+}
@@ -2,14 +2,18 @@ package fake
 
 import (
 	"context"
+	"sort"
 	"sync"
+	"time"
 
+	"github.com/supremind/rbac/persist"
 	"github.com/supremind/rbac/types"
 )
 
 type permissionPersister struct {
 	polices map[types.Subject]map[types.Object]types.Action
 	changes chan types.PermissionPolicyChange
+	log     *changeLog // of types.PermissionPolicyChange
 	sync.RWMutex
 }
 
@@ -17,6 +21,7 @@ type permissionPersister struct {
 func NewPermissionPersister() *permissionPersister {
 	pp := &permissionPersister{
 		polices: make(map[types.Subject]map[types.Object]types.Action),
+		log:     newChangeLog(),
 	}
 
 	return pp
@@ -36,15 +41,17 @@ func (p *permissionPersister) Insert(sub types.Subject, obj types.Object, act ty
 
 	p.polices[sub][obj] = act
 
+	change := types.PermissionPolicyChange{
+		PermissionPolicy: types.PermissionPolicy{
+			Subject: sub,
+			Object:  obj,
+			Action:  act,
+		},
+		Method: types.PersistInsert,
+	}
+	p.log.append(change)
 	if p.changes != nil {
-		p.changes <- types.PermissionPolicyChange{
-			PermissionPolicy: types.PermissionPolicy{
-				Subject: sub,
-				Object:  obj,
-				Action:  act,
-			},
-			Method: types.PersistInsert,
-		}
+		p.changes <- change
 	}
 
 	return nil
@@ -64,15 +71,17 @@ func (p *permissionPersister) Update(sub types.Subject, obj types.Object, act ty
 
 	p.polices[sub][obj] = act
 
+	change := types.PermissionPolicyChange{
+		PermissionPolicy: types.PermissionPolicy{
+			Subject: sub,
+			Object:  obj,
+			Action:  act,
+		},
+		Method: types.PersistUpdate,
+	}
+	p.log.append(change)
 	if p.changes != nil {
-		p.changes <- types.PermissionPolicyChange{
-			PermissionPolicy: types.PermissionPolicy{
-				Subject: sub,
-				Object:  obj,
-				Action:  act,
-			},
-			Method: types.PersistUpdate,
-		}
+		p.changes <- change
 	}
 
 	return nil
@@ -88,14 +97,16 @@ func (p *permissionPersister) Remove(sub types.Subject, obj types.Object) error
 
 	delete(p.polices[sub], obj)
 
+	change := types.PermissionPolicyChange{
+		PermissionPolicy: types.PermissionPolicy{
+			Subject: sub,
+			Object:  obj,
+		},
+		Method: types.PersistDelete,
+	}
+	p.log.append(change)
 	if p.changes != nil {
-		p.changes <- types.PermissionPolicyChange{
-			PermissionPolicy: types.PermissionPolicy{
-				Subject: sub,
-				Object:  obj,
-			},
-			Method: types.PersistDelete,
-		}
+		p.changes <- change
 	}
 
 	return nil
@@ -105,6 +116,11 @@ func (p *permissionPersister) List() ([]types.PermissionPolicy, error) {
 	p.RLock()
 	defer p.RUnlock()
 
+	return p.list()
+}
+
+// list lists all polices, it must be called with lock held
+func (p *permissionPersister) list() ([]types.PermissionPolicy, error) {
 	polices := make([]types.PermissionPolicy, 0, len(p.polices))
 	for sub, perm := range p.polices {
 		for obj, act := range perm {
@@ -119,6 +135,66 @@ func (p *permissionPersister) List() ([]types.PermissionPolicy, error) {
 	return polices, nil
 }
 
+// Each calls fn with every policy, fn must not write to the persister
+func (p *permissionPersister) Each(ctx context.Context, fn func(types.PermissionPolicy) error) error {
+	p.RLock()
+	defer p.RUnlock()
+
+	for sub, perm := range p.polices {
+		for obj, act := range perm {
+			if e := ctx.Err(); e != nil {
+				return e
+			}
+			if e := fn(types.PermissionPolicy{Subject: sub, Object: obj, Action: act}); e != nil {
+				return e
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *permissionPersister) Get(sub types.Subject, obj types.Object) (types.Action, error) {
+	p.RLock()
+	defer p.RUnlock()
+
+	act, ok := p.polices[sub][obj]
+	if !ok {
+		return types.None, types.ErrNotFound
+	}
+	return act, nil
+}
+
+func (p *permissionPersister) ListBySubject(sub types.Subject, cursor string, limit int) ([]types.PermissionPolicy, string, error) {
+	p.RLock()
+	defer p.RUnlock()
+
+	polices := make([]types.PermissionPolicy, 0, len(p.polices[sub]))
+	for obj, act := range p.polices[sub] {
+		polices = append(polices, types.PermissionPolicy{Subject: sub, Object: obj, Action: act})
+	}
+	sort.Slice(polices, func(i, j int) bool { return polices[i].Object.String() < polices[j].Object.String() })
+
+	start, end, next := persist.Page(len(polices), func(i int) string { return polices[i].Object.String() }, cursor, limit)
+	return polices[start:end], next, nil
+}
+
+func (p *permissionPersister) ListByObject(obj types.Object, cursor string, limit int) ([]types.PermissionPolicy, string, error) {
+	p.RLock()
+	defer p.RUnlock()
+
+	var polices []types.PermissionPolicy
+	for sub, perm := range p.polices {
+		if act, ok := perm[obj]; ok {
+			polices = append(polices, types.PermissionPolicy{Subject: sub, Object: obj, Action: act})
+		}
+	}
+	sort.Slice(polices, func(i, j int) bool { return polices[i].Subject.String() < polices[j].Subject.String() })
+
+	start, end, next := persist.Page(len(polices), func(i int) string { return polices[i].Subject.String() }, cursor, limit)
+	return polices[start:end], next, nil
+}
+
 func (p *permissionPersister) Watch(context.Context) (<-chan types.PermissionPolicyChange, error) {
 	p.Lock()
 	defer p.Unlock()
@@ -126,3 +202,35 @@ func (p *permissionPersister) Watch(context.Context) (<-chan types.PermissionPol
 	p.changes = make(chan types.PermissionPolicyChange)
 	return p.changes, nil
 }
+
+// ListWithToken lists all polices, with the handshake token of the position in changes they are listed at,
+// it is valid until watched from, or ctx is done
+func (p *permissionPersister) ListWithToken(ctx context.Context) ([]types.PermissionPolicy, string, error) {
+	p.RLock()
+	defer p.RUnlock()
+
+	polices, e := p.list()
+	return polices, p.log.handshake(ctx), e
+}
+
+// WatchFrom watches changes after the position of token, all changes are kept, so any token sent along with changes is valid
+func (p *permissionPersister) WatchFrom(ctx context.Context, token string) (<-chan types.ResumablePermissionChange, error) {
+	pos, e := p.log.position(token)
+	if e != nil {
+		return nil, e
+	}
+
+	changes := make(chan types.ResumablePermissionChange)
+	go func() {
+		defer close(changes)
+		p.log.follow(ctx, pos, func(change interface{}, token string, at time.Time) bool {
+			select {
+			case changes <- types.ResumablePermissionChange{PermissionPolicyChange: change.(types.PermissionPolicyChange), Token: token, Time: at}:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}()
+	return changes, nil
+}
@@ -0,0 +1,120 @@
+// Package file persists polices in local JSON files.
+//
+// It is meant for small deployments and tools like rbacctl, where a database is overkill.
+// Every write rewrites the whole file atomically, and changes made by other processes are found by polling the file,
+// so that replicas sharing a file (on the same host) are coordinated like other persisters do.
+// Writes from different processes are not serialized, the last writer wins on conflicts.
+package file
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// DefaultPollInterval is how often the file is checked for changes made by others
+const DefaultPollInterval = time.Second
+
+type fileOption func(*file)
+
+// WithPollInterval sets how often the file is checked for changes made by others
+func WithPollInterval(d time.Duration) fileOption {
+	return func(f *file) {
+		f.interval = d
+	}
+}
+
+// WithLogger sets a logger for the file to use with
+func WithLogger(log logr.Logger) fileOption {
+	return func(f *file) {
+		f.log = log
+	}
+}
+
+// common file utilities
+type file struct {
+	path     string
+	interval time.Duration
+	content  []byte // content as last read or written
+	log      logr.Logger
+	sync.Mutex
+}
+
+func newFile(path string, opts ...fileOption) *file {
+	f := &file{path: path, interval: DefaultPollInterval, log: logr.Discard()}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// read decodes the file into v if it has been changed since last read or written,
+// a missing file is read as an empty one
+func (f *file) read(v interface{}) (changed bool, err error) {
+	content, e := ioutil.ReadFile(f.path)
+	if e != nil && !os.IsNotExist(e) {
+		return false, e
+	}
+	if f.content != nil && bytes.Equal(content, f.content) {
+		return false, nil
+	}
+	f.content = content
+
+	if len(bytes.TrimSpace(content)) == 0 {
+		return true, nil
+	}
+	return true, json.Unmarshal(content, v)
+}
+
+// write encodes v into the file, by writing to a temporary file and renaming it
+func (f *file) write(v interface{}) error {
+	content, e := json.MarshalIndent(v, "", "  ")
+	if e != nil {
+		return e
+	}
+	content = append(content, '\n')
+
+	tmp, e := ioutil.TempFile(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if e != nil {
+		return e
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, e := tmp.Write(content); e != nil {
+		tmp.Close()
+		return e
+	}
+	if e := tmp.Close(); e != nil {
+		return e
+	}
+	if e := os.Rename(tmp.Name(), f.path); e != nil {
+		return e
+	}
+
+	f.content = content
+	return nil
+}
+
+// poll calls sync every interval until ctx is done
+func (f *file) poll(ctx context.Context, sync func() error) {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if e := sync(); e != nil {
+				f.log.Error(e, "sync polices from file", "path", f.path)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package file_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/supremind/rbac/persist/file"
+	. "github.com/supremind/rbac/persist/test"
+	"github.com/supremind/rbac/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestFilePersisters(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "file persisters")
+}
+
+var dir string
+
+var _ = BeforeSuite(func() {
+	var e error
+	dir, e = ioutil.TempDir("", "rbac-file-")
+	Expect(e).To(Succeed())
+
+	gp, e := NewGrouping(filepath.Join(dir, "grouping.json"))
+	Expect(e).To(Succeed())
+	TestGroupingPersister(gp)
+
+	pp, e := NewPermission(filepath.Join(dir, "permission.json"))
+	Expect(e).To(Succeed())
+	TestPermissionPersister(pp)
+})
+
+var _ = AfterSuite(func() {
+	os.RemoveAll(dir)
+})
+
+var _ = Describe("file persisters", func() {
+	_ = GroupingCases
+	_ = PermissionCases
+
+	It("should observe changes made by others sharing the file", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		path := filepath.Join(dir, "shared.json")
+		mine, e := NewPermission(path, WithPollInterval(10*time.Millisecond))
+		Expect(e).To(Succeed())
+		others, e := NewPermission(path)
+		Expect(e).To(Succeed())
+
+		w, e := mine.Watch(ctx)
+		Expect(e).To(Succeed())
+
+		Expect(others.Insert(types.User("alan"), types.Article("apollo"), types.Read)).To(Succeed())
+		Eventually(w).Should(Receive(Equal(types.PermissionPolicyChange{
+			PermissionPolicy: types.PermissionPolicy{Subject: types.User("alan"), Object: types.Article("apollo"), Action: types.Read},
+			Method:           types.PersistInsert,
+		})))
+
+		Expect(others.Update(types.User("alan"), types.Article("apollo"), types.ReadWrite)).To(Succeed())
+		Eventually(w).Should(Receive(Equal(types.PermissionPolicyChange{
+			PermissionPolicy: types.PermissionPolicy{Subject: types.User("alan"), Object: types.Article("apollo"), Action: types.ReadWrite},
+			Method:           types.PersistUpdate,
+		})))
+
+		By("writing to the file in its own turn, without losing changes of others")
+		Expect(mine.Insert(types.Role("editor"), types.Category("news"), types.Write)).To(Succeed())
+		Expect(w).To(Receive())
+		Expect(others.List()).To(ConsistOf(
+			types.PermissionPolicy{Subject: types.User("alan"), Object: types.Article("apollo"), Action: types.ReadWrite},
+			types.PermissionPolicy{Subject: types.Role("editor"), Object: types.Category("news"), Action: types.Write},
+		))
+
+		content, e := ioutil.ReadFile(path)
+		Expect(e).To(Succeed())
+		Expect(string(content)).To(ContainSubstring(`"action": "` + types.ReadWrite.String() + `"`))
+	})
+})
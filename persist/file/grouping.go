@@ -0,0 +1,197 @@
+package file
+
+import (
+	"context"
+	"sort"
+
+	"github.com/supremind/rbac/types"
+)
+
+var _ types.GroupingPersister = (*GroupingPersister)(nil)
+
+// GroupingPersister is a GroupingPersister backed by a JSON file
+type GroupingPersister struct {
+	*file
+	polices map[types.GroupingPolicy]struct{}
+	changes chan types.GroupingPolicyChange
+	ctx     context.Context // of the watcher
+}
+
+// NewGrouping uses the file at path to persist grouping polices, the file will be created on first write
+func NewGrouping(path string, opts ...fileOption) (*GroupingPersister, error) {
+	p := &GroupingPersister{
+		file:    newFile(path, opts...),
+		polices: make(map[types.GroupingPolicy]struct{}),
+	}
+
+	p.Lock()
+	defer p.Unlock()
+	if e := p.sync(); e != nil {
+		return nil, e
+	}
+
+	return p, nil
+}
+
+type groupingRecord struct {
+	Entity string `json:"entity"`
+	Group  string `json:"group"`
+}
+
+// sync reloads polices from the file if it has been changed by others, and sends the differences to watcher
+func (p *GroupingPersister) sync() error {
+	var records []groupingRecord
+	changed, e := p.read(&records)
+	if e != nil || !changed {
+		return e
+	}
+
+	polices := make(map[types.GroupingPolicy]struct{}, len(records))
+	for _, record := range records {
+		ent, e := types.ParseEntity(record.Entity)
+		if e != nil {
+			return e
+		}
+		group, e := types.ParseGroup(record.Group)
+		if e != nil {
+			return e
+		}
+		polices[types.GroupingPolicy{Entity: ent, Group: group}] = struct{}{}
+	}
+
+	var removed, inserted []types.GroupingPolicy
+	for policy := range p.polices {
+		if _, ok := polices[policy]; !ok {
+			removed = append(removed, policy)
+		}
+	}
+	for policy := range polices {
+		if _, ok := p.polices[policy]; !ok {
+			inserted = append(inserted, policy)
+		}
+	}
+	p.polices = polices
+
+	for _, policy := range sortGrouping(removed) {
+		p.emit(policy, types.PersistDelete)
+	}
+	for _, policy := range sortGrouping(inserted) {
+		p.emit(policy, types.PersistInsert)
+	}
+	return nil
+}
+
+func (p *GroupingPersister) emit(policy types.GroupingPolicy, method types.PersistMethod) {
+	if p.changes == nil {
+		return
+	}
+	select {
+	case p.changes <- types.GroupingPolicyChange{GroupingPolicy: policy, Method: method}:
+	case <-p.ctx.Done():
+	}
+}
+
+func (p *GroupingPersister) store() error {
+	polices := make([]types.GroupingPolicy, 0, len(p.polices))
+	for policy := range p.polices {
+		polices = append(polices, policy)
+	}
+
+	records := make([]groupingRecord, 0, len(polices))
+	for _, policy := range sortGrouping(polices) {
+		records = append(records, groupingRecord{Entity: policy.Entity.String(), Group: policy.Group.String()})
+	}
+	return p.write(records)
+}
+
+// Insert inserts a policy to the persister
+func (p *GroupingPersister) Insert(ent types.Entity, group types.Group) error {
+	p.Lock()
+	defer p.Unlock()
+
+	if e := p.sync(); e != nil {
+		return e
+	}
+
+	policy := types.GroupingPolicy{Entity: ent, Group: group}
+	if _, ok := p.polices[policy]; ok {
+		return types.ErrAlreadyExists
+	}
+	p.polices[policy] = struct{}{}
+	if e := p.store(); e != nil {
+		delete(p.polices, policy)
+		return e
+	}
+
+	p.log.V(4).Info("insert grouping policy", "entity", ent, "group", group)
+	p.emit(policy, types.PersistInsert)
+	return nil
+}
+
+// Remove a policy from the persister
+func (p *GroupingPersister) Remove(ent types.Entity, group types.Group) error {
+	p.Lock()
+	defer p.Unlock()
+
+	if e := p.sync(); e != nil {
+		return e
+	}
+
+	policy := types.GroupingPolicy{Entity: ent, Group: group}
+	if _, ok := p.polices[policy]; !ok {
+		return types.ErrNotFound
+	}
+	delete(p.polices, policy)
+	if e := p.store(); e != nil {
+		p.polices[policy] = struct{}{}
+		return e
+	}
+
+	p.log.V(4).Info("remove grouping policy", "entity", ent, "group", group)
+	p.emit(policy, types.PersistDelete)
+	return nil
+}
+
+// List all policies from the persister
+func (p *GroupingPersister) List() ([]types.GroupingPolicy, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if e := p.sync(); e != nil {
+		return nil, e
+	}
+
+	polices := make([]types.GroupingPolicy, 0, len(p.polices))
+	for policy := range p.polices {
+		polices = append(polices, policy)
+	}
+	return sortGrouping(polices), nil
+}
+
+// Watch any changes occurred about the policies in the persister,
+// including changes made by other processes sharing the file
+func (p *GroupingPersister) Watch(ctx context.Context) (<-chan types.GroupingPolicyChange, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.ctx = ctx
+	p.changes = make(chan types.GroupingPolicyChange, 100)
+
+	go p.poll(ctx, func() error {
+		p.Lock()
+		defer p.Unlock()
+		return p.sync()
+	})
+
+	return p.changes, nil
+}
+
+func sortGrouping(polices []types.GroupingPolicy) []types.GroupingPolicy {
+	sort.Slice(polices, func(i, j int) bool {
+		if a, b := polices[i].Entity.String(), polices[j].Entity.String(); a != b {
+			return a < b
+		}
+		return polices[i].Group.String() < polices[j].Group.String()
+	})
+	return polices
+}
@@ -0,0 +1,256 @@
+package file
+
+import (
+	"context"
+	"sort"
+
+	"github.com/supremind/rbac/types"
+)
+
+var _ types.PermissionPersister = (*PermissionPersister)(nil)
+
+// PermissionPersister is a PermissionPersister backed by a JSON file
+type PermissionPersister struct {
+	*file
+	polices map[permissionKey]types.Action
+	changes chan types.PermissionPolicyChange
+	ctx     context.Context // of the watcher
+}
+
+type permissionKey struct {
+	sub types.Subject
+	obj types.Object
+}
+
+// NewPermission uses the file at path to persist permission polices, the file will be created on first write
+func NewPermission(path string, opts ...fileOption) (*PermissionPersister, error) {
+	p := &PermissionPersister{
+		file:    newFile(path, opts...),
+		polices: make(map[permissionKey]types.Action),
+	}
+
+	p.Lock()
+	defer p.Unlock()
+	if e := p.sync(); e != nil {
+		return nil, e
+	}
+
+	return p, nil
+}
+
+// permissionRecord keeps actions by names, so that the file is readable and survives changes of action bits
+type permissionRecord struct {
+	Subject string `json:"subject"`
+	Object  string `json:"object"`
+	Action  string `json:"action"`
+}
+
+// sync reloads polices from the file if it has been changed by others, and sends the differences to watcher
+func (p *PermissionPersister) sync() error {
+	var records []permissionRecord
+	changed, e := p.read(&records)
+	if e != nil || !changed {
+		return e
+	}
+
+	polices := make(map[permissionKey]types.Action, len(records))
+	for _, record := range records {
+		sub, e := types.ParseSubject(record.Subject)
+		if e != nil {
+			return e
+		}
+		obj, e := types.ParseObject(record.Object)
+		if e != nil {
+			return e
+		}
+		act, e := types.ParseAction(record.Action)
+		if e != nil {
+			return e
+		}
+		polices[permissionKey{sub: sub, obj: obj}] = act
+	}
+
+	var removed, inserted, updated []types.PermissionPolicy
+	for key := range p.polices {
+		if _, ok := polices[key]; !ok {
+			removed = append(removed, types.PermissionPolicy{Subject: key.sub, Object: key.obj})
+		}
+	}
+	for key, act := range polices {
+		before, ok := p.polices[key]
+		switch {
+		case !ok:
+			inserted = append(inserted, types.PermissionPolicy{Subject: key.sub, Object: key.obj, Action: act})
+		case before != act:
+			updated = append(updated, types.PermissionPolicy{Subject: key.sub, Object: key.obj, Action: act})
+		}
+	}
+	p.polices = polices
+
+	for _, policy := range sortPermission(removed) {
+		p.emit(policy, types.PersistDelete)
+	}
+	for _, policy := range sortPermission(inserted) {
+		p.emit(policy, types.PersistInsert)
+	}
+	for _, policy := range sortPermission(updated) {
+		p.emit(policy, types.PersistUpdate)
+	}
+	return nil
+}
+
+func (p *PermissionPersister) emit(policy types.PermissionPolicy, method types.PersistMethod) {
+	if p.changes == nil {
+		return
+	}
+	select {
+	case p.changes <- types.PermissionPolicyChange{PermissionPolicy: policy, Method: method}:
+	case <-p.ctx.Done():
+	}
+}
+
+func (p *PermissionPersister) list() []types.PermissionPolicy {
+	polices := make([]types.PermissionPolicy, 0, len(p.polices))
+	for key, act := range p.polices {
+		polices = append(polices, types.PermissionPolicy{Subject: key.sub, Object: key.obj, Action: act})
+	}
+	return sortPermission(polices)
+}
+
+func (p *PermissionPersister) store() error {
+	polices := p.list()
+	records := make([]permissionRecord, 0, len(polices))
+	for _, policy := range polices {
+		records = append(records, permissionRecord{
+			Subject: policy.Subject.String(),
+			Object:  policy.Object.String(),
+			Action:  policy.Action.String(),
+		})
+	}
+	return p.write(records)
+}
+
+// set changes action of the policy, and stores it, zero action removes the policy
+func (p *PermissionPersister) set(key permissionKey, act types.Action) error {
+	before, existed := p.polices[key]
+	if act == 0 {
+		delete(p.polices, key)
+	} else {
+		p.polices[key] = act
+	}
+
+	if e := p.store(); e != nil {
+		if existed {
+			p.polices[key] = before
+		} else {
+			delete(p.polices, key)
+		}
+		return e
+	}
+	return nil
+}
+
+// Insert a permission policy to the persister
+func (p *PermissionPersister) Insert(sub types.Subject, obj types.Object, act types.Action) error {
+	p.Lock()
+	defer p.Unlock()
+
+	if e := p.sync(); e != nil {
+		return e
+	}
+
+	key := permissionKey{sub: sub, obj: obj}
+	if _, ok := p.polices[key]; ok {
+		return types.ErrAlreadyExists
+	}
+	if e := p.set(key, act); e != nil {
+		return e
+	}
+
+	p.log.V(4).Info("insert permission policy", "subject", sub, "object", obj, "action", act)
+	p.emit(types.PermissionPolicy{Subject: sub, Object: obj, Action: act}, types.PersistInsert)
+	return nil
+}
+
+// Update a permission policy to the persister
+func (p *PermissionPersister) Update(sub types.Subject, obj types.Object, act types.Action) error {
+	p.Lock()
+	defer p.Unlock()
+
+	if e := p.sync(); e != nil {
+		return e
+	}
+
+	key := permissionKey{sub: sub, obj: obj}
+	if _, ok := p.polices[key]; !ok {
+		return types.ErrNotFound
+	}
+	if e := p.set(key, act); e != nil {
+		return e
+	}
+
+	p.log.V(4).Info("update permission policy", "subject", sub, "object", obj, "action", act)
+	p.emit(types.PermissionPolicy{Subject: sub, Object: obj, Action: act}, types.PersistUpdate)
+	return nil
+}
+
+// Remove a permission policy from the persister
+func (p *PermissionPersister) Remove(sub types.Subject, obj types.Object) error {
+	p.Lock()
+	defer p.Unlock()
+
+	if e := p.sync(); e != nil {
+		return e
+	}
+
+	key := permissionKey{sub: sub, obj: obj}
+	if _, ok := p.polices[key]; !ok {
+		return types.ErrNotFound
+	}
+	if e := p.set(key, 0); e != nil {
+		return e
+	}
+
+	p.log.V(4).Info("remove permission policy", "subject", sub, "object", obj)
+	p.emit(types.PermissionPolicy{Subject: sub, Object: obj}, types.PersistDelete)
+	return nil
+}
+
+// List all polices from the persister
+func (p *PermissionPersister) List() ([]types.PermissionPolicy, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if e := p.sync(); e != nil {
+		return nil, e
+	}
+	return p.list(), nil
+}
+
+// Watch any changes occurred about the polices in the persister,
+// including changes made by other processes sharing the file
+func (p *PermissionPersister) Watch(ctx context.Context) (<-chan types.PermissionPolicyChange, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.ctx = ctx
+	p.changes = make(chan types.PermissionPolicyChange, 100)
+
+	go p.poll(ctx, func() error {
+		p.Lock()
+		defer p.Unlock()
+		return p.sync()
+	})
+
+	return p.changes, nil
+}
+
+func sortPermission(polices []types.PermissionPolicy) []types.PermissionPolicy {
+	sort.Slice(polices, func(i, j int) bool {
+		if a, b := polices[i].Subject.String(), polices[j].Subject.String(); a != b {
+			return a < b
+		}
+		return polices[i].Object.String() < polices[j].Object.String()
+	})
+	return polices
+}
@@ -1,12 +1,14 @@
 package mgo
 
 import (
+	"context"
 	"errors"
 	"time"
 
 	"github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
 	"github.com/go-logr/logr"
+	"github.com/supremind/rbac/trace"
 	"github.com/supremind/rbac/types"
 )
 
@@ -15,6 +17,16 @@ type collection struct {
 	*mgo.Collection
 	log          logr.Logger
 	retryTimeout time.Duration
+	tracer       types.Tracer
+	handshakes   *handshakes
+}
+
+func newCollection(coll *mgo.Collection, opts ...collectionOption) *collection {
+	c := &collection{Collection: coll, tracer: trace.Nop, handshakes: newHandshakes()}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 func (c *collection) copySession() *collection {
@@ -22,6 +34,38 @@ func (c *collection) copySession() *collection {
 	return &collection{Collection: db.Session.Copy().DB(db.Name).C(c.Name)}
 }
 
+// do runs op with the collection, whose sessions time out at the deadline of ctx if any,
+// and returns as soon as ctx is done, while op in flight is left to finish or time out in the background,
+// so that a canceled write may still take effect
+func (c *collection) do(ctx context.Context, op func(c *collection) error) error {
+	if e := ctx.Err(); e != nil {
+		return e
+	}
+
+	cc, release := c, func() {}
+	if deadline, ok := ctx.Deadline(); ok {
+		ss := c.Database.Session.Copy()
+		ss.SetSocketTimeout(time.Until(deadline))
+		ss.SetSyncTimeout(time.Until(deadline))
+		copied := *c
+		copied.Collection = ss.DB(c.Database.Name).C(c.Name)
+		cc, release = &copied, ss.Close
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		defer release()
+		done <- op(cc)
+	}()
+
+	select {
+	case e := <-done:
+		return e
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (c *collection) closeSession() {
 	c.Database.Session.Close()
 }
@@ -44,6 +88,19 @@ func (c *collection) connectToWatch(token *bson.Raw) (*mgo.ChangeStream, func(),
 	}, nil
 }
 
+// pageQuery finds documents matching query, ordered by ids, after cursor, and up to limit documents if limit is positive,
+// one more document is found to tell if there is a next page
+func (c *collection) pageQuery(query bson.M, cursor string, limit int) *mgo.Query {
+	if cursor != "" {
+		query["_id"] = bson.M{"$gt": cursor}
+	}
+	q := c.Find(query).Sort("_id")
+	if limit > 0 {
+		q = q.Limit(limit + 1)
+	}
+	return q
+}
+
 type collectionOption func(*collection)
 
 // WithLogger set a logger for the collection to use with
@@ -60,13 +117,28 @@ func SetRetryTimeout(d time.Duration) collectionOption {
 	}
 }
 
+// WithTracer sets a tracer to start spans around round-trips to mongodb, made by the context-aware methods
+func WithTracer(t types.Tracer) collectionOption {
+	return func(coll *collection) {
+		coll.tracer = t
+	}
+}
+
+// startSpan starts a span for an operation on the collection
+func (c *collection) startSpan(ctx context.Context, name string, attrs ...types.Attribute) (context.Context, types.Span) {
+	return c.tracer.Start(ctx, name, append([]types.Attribute{
+		types.Attr("db.system", "mongodb"),
+		types.Attr("db.mongodb.collection", c.Name),
+	}, attrs...)...)
+}
+
 type changeStreamOperationType string
 
 const (
-	insert  changeStreamOperationType = "insert"
-	delete  changeStreamOperationType = "delete"
-	update  changeStreamOperationType = "update"
-	replace changeStreamOperationType = "replace"
+	opInsert  changeStreamOperationType = "insert"
+	opDelete  changeStreamOperationType = "delete"
+	opUpdate  changeStreamOperationType = "update"
+	opReplace changeStreamOperationType = "replace"
 )
 
 func parseMgoError(e error) error {
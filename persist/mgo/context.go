@@ -0,0 +1,109 @@
+package mgo
+
+import (
+	"context"
+
+	"github.com/supremind/rbac/trace"
+	"github.com/supremind/rbac/types"
+)
+
+var (
+	_ types.GroupingPersisterContext   = (*GroupingPersister)(nil)
+	_ types.PermissionPersisterContext = (*PermissionPersister)(nil)
+	_ types.GroupingQuerier            = (*GroupingPersister)(nil)
+	_ types.PermissionQuerier          = (*PermissionPersister)(nil)
+	_ types.GroupingStreamer           = (*GroupingPersister)(nil)
+	_ types.PermissionStreamer         = (*PermissionPersister)(nil)
+	_ types.GroupingResumer            = (*GroupingPersister)(nil)
+	_ types.PermissionResumer          = (*PermissionPersister)(nil)
+)
+
+// InsertContext inserts a grouping policy to the persister, as a traced operation.
+// The deadline of ctx applies to the round-trip, and it returns once ctx is done,
+// but mgo could not abort operations sent already, so a canceled write may still take effect
+func (p *GroupingPersister) InsertContext(ctx context.Context, ent types.Entity, group types.Group) error {
+	_, span := p.startSpan(ctx, "mgo.Insert", groupingAttrs(ent, group)...)
+	e := p.do(ctx, func(c *collection) error { return (&GroupingPersister{c}).Insert(ent, group) })
+	span.End(e)
+	return e
+}
+
+// RemoveContext removes a grouping policy from the persister, as a traced operation
+func (p *GroupingPersister) RemoveContext(ctx context.Context, ent types.Entity, group types.Group) error {
+	_, span := p.startSpan(ctx, "mgo.Remove", groupingAttrs(ent, group)...)
+	e := p.do(ctx, func(c *collection) error { return (&GroupingPersister{c}).Remove(ent, group) })
+	span.End(e)
+	return e
+}
+
+// ListContext lists all grouping policies from the persister, as a traced operation
+func (p *GroupingPersister) ListContext(ctx context.Context) ([]types.GroupingPolicy, error) {
+	_, span := p.startSpan(ctx, "mgo.List")
+	var polices []types.GroupingPolicy
+	e := p.do(ctx, func(c *collection) (e error) {
+		polices, e = (&GroupingPersister{c}).List()
+		return e
+	})
+	span.End(e)
+	if e != nil {
+		return nil, e
+	}
+	return polices, nil
+}
+
+// InsertContext inserts a permission policy to the persister, as a traced operation
+func (p *PermissionPersister) InsertContext(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) error {
+	_, span := p.startSpan(ctx, "mgo.Insert", permissionAttrs(sub, obj, act)...)
+	e := p.do(ctx, func(c *collection) error { return (&PermissionPersister{c}).Insert(sub, obj, act) })
+	span.End(e)
+	return e
+}
+
+// UpdateContext updates a permission policy in the persister, as a traced operation
+func (p *PermissionPersister) UpdateContext(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) error {
+	_, span := p.startSpan(ctx, "mgo.Update", permissionAttrs(sub, obj, act)...)
+	e := p.do(ctx, func(c *collection) error { return (&PermissionPersister{c}).Update(sub, obj, act) })
+	span.End(e)
+	return e
+}
+
+// RemoveContext removes a permission policy from the persister, as a traced operation
+func (p *PermissionPersister) RemoveContext(ctx context.Context, sub types.Subject, obj types.Object) error {
+	_, span := p.startSpan(ctx, "mgo.Remove",
+		types.Attr(trace.AttrSubject, sub.String()),
+		types.Attr(trace.AttrObject, obj.String()),
+	)
+	e := p.do(ctx, func(c *collection) error { return (&PermissionPersister{c}).Remove(sub, obj) })
+	span.End(e)
+	return e
+}
+
+// ListContext lists all permission policies from the persister, as a traced operation
+func (p *PermissionPersister) ListContext(ctx context.Context) ([]types.PermissionPolicy, error) {
+	_, span := p.startSpan(ctx, "mgo.List")
+	var polices []types.PermissionPolicy
+	e := p.do(ctx, func(c *collection) (e error) {
+		polices, e = (&PermissionPersister{c}).List()
+		return e
+	})
+	span.End(e)
+	if e != nil {
+		return nil, e
+	}
+	return polices, nil
+}
+
+func groupingAttrs(ent types.Entity, group types.Group) []types.Attribute {
+	return []types.Attribute{
+		types.Attr(trace.AttrEntity, ent.String()),
+		types.Attr(trace.AttrGroup, group.String()),
+	}
+}
+
+func permissionAttrs(sub types.Subject, obj types.Object, act types.Action) []types.Attribute {
+	return []types.Attribute{
+		types.Attr(trace.AttrSubject, sub.String()),
+		types.Attr(trace.AttrObject, obj.String()),
+		types.Attr(trace.AttrAction, act.String()),
+	}
+}
@@ -5,8 +5,9 @@ go 1.14
 require (
 	github.com/globalsign/mgo v0.0.0-20181015135952-eeefdecb41b8
 	github.com/go-logr/logr v1.0.0
-	github.com/go-logr/stdr v0.2.0
-	github.com/onsi/ginkgo v1.12.0
-	github.com/onsi/gomega v1.10.0
-	github.com/supremind/rbac v0.4.0
+	github.com/go-logr/stdr v1.0.0
+	github.com/onsi/ginkgo v1.16.4
+	github.com/onsi/gomega v1.14.0
+	github.com/supremind/rbac v0.5.0
 )
+
@@ -3,10 +3,12 @@ package mgo
 import (
 	"context"
 	"errors"
+	"sort"
 	"time"
 
 	"github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
+	"github.com/supremind/rbac/persist"
 	"github.com/supremind/rbac/types"
 )
 
@@ -17,10 +19,7 @@ type GroupingPersister struct {
 
 // NewGrouping uses the given mongodb collection as backend to persist grouping polices
 func NewGrouping(coll *mgo.Collection, opts ...collectionOption) (*GroupingPersister, error) {
-	c := &GroupingPersister{&collection{Collection: coll}}
-	for _, opt := range opts {
-		opt(c.collection)
-	}
+	c := &GroupingPersister{newCollection(coll, opts...)}
 
 	return c, nil
 }
@@ -260,8 +259,92 @@ func (p *GroupingPersister) List() ([]types.GroupingPolicy, error) {
 	return polices, nil
 }
 
+// Each calls fn with every policy in the persister, documents are read from a cursor batch by batch,
+// instead of being listed all at once.
+// mgo does not support cancellation, ctx is checked between documents
+func (p *GroupingPersister) Each(ctx context.Context, fn func(types.GroupingPolicy) error) error {
+	ss := p.copySession()
+	defer ss.closeSession()
+
+	iter := ss.Find(nil).Iter()
+	defer iter.Close()
+
+	var gp groups
+	for iter.Next(&gp) {
+		if e := ctx.Err(); e != nil {
+			return e
+		}
+		ent := gp.Entity.asEntity()
+		for _, group := range gp.Groups {
+			if e := fn(types.GroupingPolicy{Entity: ent, Group: group.asGroup()}); e != nil {
+				return e
+			}
+		}
+		gp = groups{}
+	}
+
+	return iter.Err()
+}
+
+// ListByEntity lists polices of groups the entity immediately belongs to, ordered by groups
+func (p *GroupingPersister) ListByEntity(ent types.Entity, cursor string, limit int) ([]types.GroupingPolicy, string, error) {
+	ss := p.copySession()
+	defer ss.closeSession()
+
+	entity := fromEntity(ent)
+	var gp groups
+	if e := ss.FindId(entity.String()).One(&gp); e != nil {
+		if errors.Is(e, mgo.ErrNotFound) {
+			return nil, "", nil
+		}
+		return nil, "", e
+	}
+
+	sort.Slice(gp.Groups, func(i, j int) bool { return gp.Groups[i].String() < gp.Groups[j].String() })
+	start, end, next := persist.Page(len(gp.Groups), func(i int) string { return gp.Groups[i].String() }, cursor, limit)
+	polices := make([]types.GroupingPolicy, 0, end-start)
+	for _, group := range gp.Groups[start:end] {
+		polices = append(polices, types.GroupingPolicy{Entity: ent, Group: group.asGroup()})
+	}
+
+	p.log.V(4).Info("list grouping policies by entity", "entity", entity, "polices", polices, "next", next)
+
+	return polices, next, nil
+}
+
+// ListByGroup lists polices of entities immediately belong to the group, ordered by entities
+func (p *GroupingPersister) ListByGroup(grp types.Group, cursor string, limit int) ([]types.GroupingPolicy, string, error) {
+	ss := p.copySession()
+	defer ss.closeSession()
+
+	group := fromGroup(grp)
+	iter := ss.pageQuery(bson.M{"groups": bson.M{"$elemMatch": group}}, cursor, limit).Iter()
+	defer iter.Close()
+
+	polices := make([]types.GroupingPolicy, 0)
+	var gp groups
+	for iter.Next(&gp) {
+		polices = append(polices, types.GroupingPolicy{Entity: gp.Entity.asEntity(), Group: grp})
+		gp = groups{}
+	}
+	if e := iter.Err(); e != nil {
+		return nil, "", e
+	}
+
+	var next string
+	if limit > 0 && len(polices) > limit {
+		polices = polices[:limit]
+		next = polices[limit-1].Entity.String()
+	}
+
+	p.log.V(4).Info("list grouping policies by group", "group", group, "polices", polices, "next", next)
+
+	return polices, next, nil
+}
+
 type groupingChangeEvent struct {
 	OperationType changeStreamOperationType `bson:"operationType,omitempty"`
+	ClusterTime   bson.MongoTimestamp       `bson:"clusterTime,omitempty"`
 	FullDocument  groups                    `bson:"fullDocument,omitempty"`
 	DocumentKey   struct {
 		ID string `bson:"_id,omitempty"`
@@ -318,47 +401,55 @@ func (p *GroupingPersister) Watch(ctx context.Context) (<-chan types.GroupingPol
 	return changes, nil
 }
 
-func (p *GroupingPersister) watch(ctx context.Context, cs *mgo.ChangeStream, changes chan<- types.GroupingPolicyChange) error {
-	for {
-		var event groupingChangeEvent
-		if cs.Next(&event) {
-			var change types.GroupingPolicyChange
-			p.log.V(6).Info("change event", "id", event.DocumentKey.ID, "event", event)
+// changeOf parses the change in event, ok is false if it is not a change of polices
+func (p *GroupingPersister) changeOf(event groupingChangeEvent) (change types.GroupingPolicyChange, ok bool) {
+	p.log.V(6).Info("change event", "id", event.DocumentKey.ID, "event", event)
 
-			entity, e := types.ParseEntity(event.DocumentKey.ID)
-			if e != nil {
-				p.log.Error(e, "parse entity in change event")
-				continue
-			}
-			change.Entity = entity
+	entity, e := types.ParseEntity(event.DocumentKey.ID)
+	if e != nil {
+		p.log.Error(e, "parse entity in change event")
+		return change, false
+	}
+	change.Entity = entity
 
-			switch event.OperationType {
-			case insert:
-				change.Method = types.PersistInsert
-				if len(event.FullDocument.Groups) > 0 {
-					change.Group = event.FullDocument.Groups[0].asGroup()
-				}
+	switch event.OperationType {
+	case opInsert:
+		change.Method = types.PersistInsert
+		if len(event.FullDocument.Groups) > 0 {
+			change.Group = event.FullDocument.Groups[0].asGroup()
+		}
 
-			case update:
-				if fields, ok := event.UpdateDescription.UpdatedFields["groups"]; ok && len(fields.([]interface{})) > 0 {
-					docs := fields.([]interface{})
-					doc := docs[len(docs)-1]
-					change.Method = types.PersistInsert
-					change.Group = groupFromDoc(doc.(bson.M)).asGroup()
-				} else if fields, ok := event.UpdateDescription.UpdatedFields["deleted"]; ok && len(fields.([]interface{})) > 0 {
-					docs := fields.([]interface{})
-					doc := docs[len(docs)-1]
-					change.Method = types.PersistDelete
-					change.Group = groupFromDoc(doc.(bson.M)).asGroup()
-				}
+	case opUpdate:
+		if fields, ok := event.UpdateDescription.UpdatedFields["groups"]; ok && len(fields.([]interface{})) > 0 {
+			docs := fields.([]interface{})
+			doc := docs[len(docs)-1]
+			change.Method = types.PersistInsert
+			change.Group = groupFromDoc(doc.(bson.M)).asGroup()
+		} else if fields, ok := event.UpdateDescription.UpdatedFields["deleted"]; ok && len(fields.([]interface{})) > 0 {
+			docs := fields.([]interface{})
+			doc := docs[len(docs)-1]
+			change.Method = types.PersistDelete
+			change.Group = groupFromDoc(doc.(bson.M)).asGroup()
+		}
 
-			default:
-				p.log.Info("unknown event", "operation type", event.OperationType)
+	default:
+		p.log.Info("unknown event", "operation type", event.OperationType)
+		return change, false
+	}
+
+	p.log.V(4).Info("got grouping change event", "change", change)
+	return change, true
+}
+
+func (p *GroupingPersister) watch(ctx context.Context, cs *mgo.ChangeStream, changes chan<- types.GroupingPolicyChange) error {
+	for {
+		var event groupingChangeEvent
+		if cs.Next(&event) {
+			change, ok := p.changeOf(event)
+			if !ok {
 				continue
 			}
 
-			p.log.V(4).Info("got grouping change event", "change", change)
-
 			select {
 			case <-ctx.Done():
 				return ctx.Err()
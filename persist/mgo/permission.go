@@ -3,12 +3,14 @@ package mgo
 import (
 	"context"
 	"errors"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
+	"github.com/supremind/rbac/persist"
 	"github.com/supremind/rbac/types"
 )
 
@@ -19,10 +21,7 @@ type PermissionPersister struct {
 
 // NewPermission uses the given mongodb collection as backend to persist grouping polices
 func NewPermission(coll *mgo.Collection, opts ...collectionOption) (*PermissionPersister, error) {
-	c := &PermissionPersister{&collection{Collection: coll}}
-	for _, opt := range opts {
-		opt(c.collection)
-	}
+	c := &PermissionPersister{newCollection(coll, opts...)}
 
 	return c, nil
 }
@@ -254,8 +253,118 @@ func (p *PermissionPersister) List() ([]types.PermissionPolicy, error) {
 	return polices, nil
 }
 
+// Each calls fn with every policy in the persister, documents are read from a cursor batch by batch,
+// instead of being listed all at once.
+// mgo does not support cancellation, ctx is checked between documents
+func (p *PermissionPersister) Each(ctx context.Context, fn func(types.PermissionPolicy) error) error {
+	ss := p.copySession()
+	defer ss.closeSession()
+
+	iter := ss.Find(nil).Iter()
+	defer iter.Close()
+
+	var mp permissions
+	for iter.Next(&mp) {
+		if e := ctx.Err(); e != nil {
+			return e
+		}
+		sub := mp.Subject.asSubject()
+		for _, perm := range mp.Permissions {
+			if e := fn(types.PermissionPolicy{Subject: sub, Object: perm.Object.asObject(), Action: perm.Action}); e != nil {
+				return e
+			}
+		}
+		mp = permissions{}
+	}
+
+	return iter.Err()
+}
+
+// Get the action permitted to the subject on the object
+func (p *PermissionPersister) Get(sub types.Subject, obj types.Object) (types.Action, error) {
+	ss := p.copySession()
+	defer ss.closeSession()
+
+	subject := fromSubject(sub)
+	object := fromObject(obj)
+	var mp permissions
+	if e := ss.FindId(subject.String()).One(&mp); e != nil {
+		return types.None, parseMgoError(e)
+	}
+	for _, perm := range mp.Permissions {
+		if perm.Object == object {
+			return perm.Action, nil
+		}
+	}
+
+	return types.None, types.ErrNotFound
+}
+
+// ListBySubject lists polices permitting the subject, ordered by objects
+func (p *PermissionPersister) ListBySubject(sub types.Subject, cursor string, limit int) ([]types.PermissionPolicy, string, error) {
+	ss := p.copySession()
+	defer ss.closeSession()
+
+	subject := fromSubject(sub)
+	var mp permissions
+	if e := ss.FindId(subject.String()).One(&mp); e != nil {
+		if errors.Is(e, mgo.ErrNotFound) {
+			return nil, "", nil
+		}
+		return nil, "", e
+	}
+
+	perms := mp.Permissions
+	sort.Slice(perms, func(i, j int) bool { return perms[i].Object.String() < perms[j].Object.String() })
+	start, end, next := persist.Page(len(perms), func(i int) string { return perms[i].Object.String() }, cursor, limit)
+	polices := make([]types.PermissionPolicy, 0, end-start)
+	for _, perm := range perms[start:end] {
+		polices = append(polices, types.PermissionPolicy{Subject: sub, Object: perm.Object.asObject(), Action: perm.Action})
+	}
+
+	p.log.V(4).Info("list permission policies by subject", "subject", subject, "polices", polices, "next", next)
+
+	return polices, next, nil
+}
+
+// ListByObject lists polices permitting on the object, ordered by subjects
+func (p *PermissionPersister) ListByObject(obj types.Object, cursor string, limit int) ([]types.PermissionPolicy, string, error) {
+	ss := p.copySession()
+	defer ss.closeSession()
+
+	object := fromObject(obj)
+	iter := ss.pageQuery(bson.M{"permissions": bson.M{"$elemMatch": bson.M{"object": object}}}, cursor, limit).Iter()
+	defer iter.Close()
+
+	polices := make([]types.PermissionPolicy, 0)
+	var mp permissions
+	for iter.Next(&mp) {
+		for _, perm := range mp.Permissions {
+			if perm.Object == object {
+				polices = append(polices, types.PermissionPolicy{Subject: mp.Subject.asSubject(), Object: obj, Action: perm.Action})
+				break
+			}
+		}
+		mp = permissions{}
+	}
+	if e := iter.Err(); e != nil {
+		return nil, "", e
+	}
+
+	var next string
+	if limit > 0 && len(polices) > limit {
+		polices = polices[:limit]
+		next = polices[limit-1].Subject.String()
+	}
+
+	p.log.V(4).Info("list permission policies by object", "object", object, "polices", polices, "next", next)
+
+	return polices, next, nil
+}
+
 type permissionChangeEvent struct {
 	OperationType changeStreamOperationType `bson:"operationType,omitempty"`
+	ClusterTime   bson.MongoTimestamp       `bson:"clusterTime,omitempty"`
 	FullDocument  permissions               `bson:"fullDocument,omitempty"`
 	DocumentKey   struct {
 		ID string `bson:"_id,omitempty"`
@@ -311,72 +420,80 @@ func (p *PermissionPersister) Watch(ctx context.Context) (<-chan types.Permissio
 	return changes, nil
 }
 
-func (p *PermissionPersister) watch(ctx context.Context, cs *mgo.ChangeStream, changes chan<- types.PermissionPolicyChange) error {
-	for {
-		var event permissionChangeEvent
-		if cs.Next(&event) {
-			var change types.PermissionPolicyChange
-			p.log.V(6).Info("change event", "event", event)
+// changeOf parses the change in event, ok is false if it is not a change of polices
+func (p *PermissionPersister) changeOf(event permissionChangeEvent) (change types.PermissionPolicyChange, ok bool) {
+	p.log.V(6).Info("change event", "event", event)
 
-			sub, e := types.ParseSubject(event.DocumentKey.ID)
-			if e != nil {
-				p.log.Error(e, "parse subjct in change event")
-				continue
-			}
-			change.Subject = sub
-
-			switch event.OperationType {
-			case insert:
-				change.Method = types.PersistInsert
-				if len(event.FullDocument.Permissions) > 0 {
-					change.Object = event.FullDocument.Permissions[0].Object.asObject()
-					change.Action = event.FullDocument.Permissions[0].Action
-				}
+	sub, e := types.ParseSubject(event.DocumentKey.ID)
+	if e != nil {
+		p.log.Error(e, "parse subjct in change event")
+		return change, false
+	}
+	change.Subject = sub
+
+	switch event.OperationType {
+	case opInsert:
+		change.Method = types.PersistInsert
+		if len(event.FullDocument.Permissions) > 0 {
+			change.Object = event.FullDocument.Permissions[0].Object.asObject()
+			change.Action = event.FullDocument.Permissions[0].Action
+		}
 
-			case update, replace:
-				if fields, ok := event.UpdateDescription.UpdatedFields["permissions"]; ok && len(fields.([]interface{})) > 0 {
-					docs := fields.([]interface{})
-					doc := docs[len(docs)-1].(bson.M)
-					change.Method = types.PersistInsert
-					change.Action = actionFromDoc(doc["action"])
-					change.Object = objectFromDoc(doc["object"].(bson.M)).asObject()
-				} else if fields, ok := event.UpdateDescription.UpdatedFields["deleted"]; ok && len(fields.([]interface{})) > 0 {
-					docs := fields.([]interface{})
-					doc := docs[len(docs)-1].(bson.M)
-					change.Method = types.PersistDelete
-					change.Object = objectFromDoc(doc).asObject()
-				} else if doc := event.UpdateDescription.UpdatedFields; len(doc) == 1 {
-					for key, val := range doc {
-						if strings.HasPrefix(key, "permissions.") {
-							index := strings.TrimSuffix(strings.TrimPrefix(key, "permissions."), ".action")
-							idx, e := strconv.Atoi(index)
-							if e != nil {
-								p.log.Error(e, "parse updated permission id", "doc", doc)
-								continue
-							}
-							if idx >= len(event.FullDocument.Permissions) {
-								// fixme: how to get correct permission updates?
-								// https://docs.mongodb.com/manual/changeStreams/#lookup-full-document-for-update-operations
-								p.log.V(2).Info("incorrect permission id in storage, content may be changed after updating")
-								continue
-							}
-							change.Object = event.FullDocument.Permissions[idx].Object.asObject()
-							change.Action = actionFromDoc(val)
-							change.Method = types.PersistUpdate
-						}
-						break
+	case opUpdate, opReplace:
+		if fields, ok := event.UpdateDescription.UpdatedFields["permissions"]; ok && len(fields.([]interface{})) > 0 {
+			docs := fields.([]interface{})
+			doc := docs[len(docs)-1].(bson.M)
+			change.Method = types.PersistInsert
+			change.Action = actionFromDoc(doc["action"])
+			change.Object = objectFromDoc(doc["object"].(bson.M)).asObject()
+		} else if fields, ok := event.UpdateDescription.UpdatedFields["deleted"]; ok && len(fields.([]interface{})) > 0 {
+			docs := fields.([]interface{})
+			doc := docs[len(docs)-1].(bson.M)
+			change.Method = types.PersistDelete
+			change.Object = objectFromDoc(doc).asObject()
+		} else if doc := event.UpdateDescription.UpdatedFields; len(doc) == 1 {
+			for key, val := range doc {
+				if strings.HasPrefix(key, "permissions.") {
+					index := strings.TrimSuffix(strings.TrimPrefix(key, "permissions."), ".action")
+					idx, e := strconv.Atoi(index)
+					if e != nil {
+						p.log.Error(e, "parse updated permission id", "doc", doc)
+						continue
 					}
-				} else {
-					continue
+					if idx >= len(event.FullDocument.Permissions) {
+						// fixme: how to get correct permission updates?
+						// https://docs.mongodb.com/manual/changeStreams/#lookup-full-document-for-update-operations
+						p.log.V(2).Info("incorrect permission id in storage, content may be changed after updating")
+						continue
+					}
+					change.Object = event.FullDocument.Permissions[idx].Object.asObject()
+					change.Action = actionFromDoc(val)
+					change.Method = types.PersistUpdate
 				}
+				break
+			}
+		} else {
+			return change, false
+		}
 
-			default:
-				p.log.Info("unknown event", "operation type")
+	default:
+		p.log.Info("unknown event", "operation type")
+		return change, false
+	}
+
+	p.log.V(4).Info("got permission change", "change", change)
+	return change, true
+}
+
+func (p *PermissionPersister) watch(ctx context.Context, cs *mgo.ChangeStream, changes chan<- types.PermissionPolicyChange) error {
+	for {
+		var event permissionChangeEvent
+		if cs.Next(&event) {
+			change, ok := p.changeOf(event)
+			if !ok {
 				continue
 			}
 
-			p.log.V(4).Info("got permission change", "change", change)
-
 			select {
 			case <-ctx.Done():
 				return ctx.Err()
@@ -0,0 +1,318 @@
+package mgo
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/supremind/rbac/types"
+)
+
+// prefixes of resume tokens
+const (
+	// handshake tokens refer to change streams opened before listing polices, they are valid only in this process,
+	// and are used if the server does not report positions of change streams
+	handshakePrefix = "handshake:"
+	// resume tokens are encoded resume tokens of change streams
+	resumePrefix = "resume:"
+)
+
+// handshakes keeps change streams opened before listing polices, until they are watched
+type handshakes struct {
+	streams map[string]handshake
+	next    int
+	sync.Mutex
+}
+
+type handshake struct {
+	cs     *mgo.ChangeStream
+	closer func()
+}
+
+func newHandshakes() *handshakes {
+	return &handshakes{streams: make(map[string]handshake)}
+}
+
+// take removes the stream of token, and returns it
+func (h *handshakes) take(token string) (handshake, bool) {
+	h.Lock()
+	defer h.Unlock()
+
+	s, ok := h.streams[token]
+	if ok {
+		delete(h.streams, token)
+	}
+	return s, ok
+}
+
+// release closes the stream of token if it is not watched yet, tokens of other kinds are ignored
+func (h *handshakes) release(token string) {
+	if s, ok := h.take(token); ok {
+		s.closer()
+	}
+}
+
+// handshake returns the token to watch changes made since now, it is taken before listing polices,
+// so that no change made while listing is lost.
+// The token is a resume token if the server reports the position of change streams opened,
+// or else a change stream is opened and kept until it is watched from its handshake token, or ctx is done.
+func (c *collection) handshake(ctx context.Context) (string, error) {
+	if raw, e := c.position(); e != nil {
+		c.log.V(2).Info("change stream position is not reported, handshake in process", "error", e)
+	} else if raw != nil {
+		return encodeToken(raw), nil
+	}
+
+	cs, closer, e := c.connectToWatch(nil)
+	if e != nil {
+		return "", e
+	}
+
+	c.handshakes.Lock()
+	c.handshakes.next++
+	token := handshakePrefix + strconv.Itoa(c.handshakes.next)
+	c.handshakes.streams[token] = handshake{cs: cs, closer: closer}
+	c.handshakes.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		c.handshakes.release(token)
+	}()
+	return token, nil
+}
+
+// position opens a change stream, and returns its post batch resume token, which is nil if the server does not report it.
+// It is the resume token of the position the stream is opened at, reported by MongoDB 4.0.7 and later.
+func (c *collection) position() (*bson.Raw, error) {
+	ss := c.copySession()
+	defer ss.closeSession()
+
+	var result struct {
+		Cursor struct {
+			ID    int64     `bson:"id"`
+			Token *bson.Raw `bson:"postBatchResumeToken"`
+		} `bson:"cursor"`
+	}
+	e := ss.Database.Run(bson.D{
+		{Name: "aggregate", Value: c.Name},
+		{Name: "pipeline", Value: []bson.M{{"$changeStream": bson.M{"fullDocument": mgo.UpdateLookup}}}},
+		{Name: "cursor", Value: bson.M{}},
+	}, &result)
+	if e != nil {
+		return nil, e
+	}
+
+	if result.Cursor.ID != 0 {
+		if e := ss.Database.Run(bson.D{
+			{Name: "killCursors", Value: c.Name},
+			{Name: "cursors", Value: []int64{result.Cursor.ID}},
+		}, nil); e != nil {
+			c.log.Error(e, "kill the cursor of change stream", "cursor", result.Cursor.ID)
+		}
+	}
+	return result.Cursor.Token, nil
+}
+
+// openStream opens a change stream after token: streams opened by handshakes are taken over,
+// the change stream is resumed after resume tokens, and an empty token watches changes made since now.
+// It returns the resume token to reconnect with before any change is watched, which is nil for handshakes or empty tokens.
+func (c *collection) openStream(token string) (*mgo.ChangeStream, func(), *bson.Raw, error) {
+	switch {
+	case token == "":
+		cs, closer, e := c.connectToWatch(nil)
+		return cs, closer, nil, e
+
+	case strings.HasPrefix(token, handshakePrefix):
+		h, ok := c.handshakes.take(token)
+		if !ok {
+			return nil, nil, nil, fmt.Errorf("%w: %s", types.ErrInvalidToken, token)
+		}
+		return h.cs, h.closer, nil, nil
+
+	case strings.HasPrefix(token, resumePrefix):
+		raw, e := decodeToken(token)
+		if e != nil {
+			return nil, nil, nil, e
+		}
+		cs, closer, e := c.connectToWatch(raw)
+		if e != nil {
+			return nil, nil, nil, fmt.Errorf("%w: %s: %v", types.ErrInvalidToken, token, e)
+		}
+		return cs, closer, raw, nil
+	}
+
+	return nil, nil, nil, fmt.Errorf("%w: %s", types.ErrInvalidToken, token)
+}
+
+// follow calls next with the change stream until it returns false, reconnects after the latest watched change
+// if the stream fails, and stops when ctx is done.
+// The stream is closed in the end.
+func (c *collection) follow(ctx context.Context, cs *mgo.ChangeStream, closer func(), token *bson.Raw, next func(*mgo.ChangeStream) bool) {
+	defer func() { closer() }()
+
+	for {
+		for next(cs) {
+			if t := cs.ResumeToken(); t != nil {
+				token = t
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		e := cs.Err()
+		if e == nil || errors.Is(e, mgo.ErrNotFound) {
+			c.log.V(2).Info("watch found nothing, retry later")
+			time.Sleep(c.retryTimeout)
+			continue
+		}
+
+		c.log.Error(e, "fetch event change failed, reconnect later", "token", token)
+		closer()
+		for {
+			time.Sleep(c.retryTimeout)
+			if ctx.Err() != nil {
+				closer = func() {}
+				return
+			}
+			if cs, closer, e = c.connectToWatch(token); e == nil {
+				break
+			}
+			c.log.Error(e, "failed to connect")
+		}
+	}
+}
+
+// changeTime returns when a change was made by its cluster time, which is in seconds, zero if it is not reported
+func changeTime(ts bson.MongoTimestamp) time.Time {
+	if ts == 0 {
+		return time.Time{}
+	}
+	return ts.Time()
+}
+
+// encodeToken encodes resume tokens of change streams into strings
+func encodeToken(raw *bson.Raw) string {
+	if raw == nil {
+		return ""
+	}
+	return resumePrefix + base64.RawURLEncoding.EncodeToString(append([]byte{raw.Kind}, raw.Data...))
+}
+
+// decodeToken decodes resume tokens encoded by encodeToken
+func decodeToken(token string) (*bson.Raw, error) {
+	b, e := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(token, resumePrefix))
+	if e != nil || len(b) == 0 {
+		return nil, fmt.Errorf("%w: %s", types.ErrInvalidToken, token)
+	}
+	return &bson.Raw{Kind: b[0], Data: b[1:]}, nil
+}
+
+// ListWithToken lists all polices, with the token to watch changes made since before listing them.
+// Tokens returned are resume tokens of change streams on MongoDB 4.0.7 and later, or else handshake tokens,
+// which are valid only in this process, until changes are watched from them or ctx is done.
+// Resume tokens of the change stream are sent along with watched changes.
+func (p *GroupingPersister) ListWithToken(ctx context.Context) ([]types.GroupingPolicy, string, error) {
+	token, e := p.handshake(ctx)
+	if e != nil {
+		return nil, "", e
+	}
+
+	polices, e := p.ListContext(ctx)
+	if e != nil {
+		p.handshakes.release(token)
+		return nil, "", e
+	}
+	return polices, token, nil
+}
+
+// WatchFrom watches changes after the position of token, along with resume tokens after them
+func (p *GroupingPersister) WatchFrom(ctx context.Context, token string) (<-chan types.ResumableGroupingChange, error) {
+	cs, closer, raw, e := p.openStream(token)
+	if e != nil {
+		return nil, e
+	}
+
+	changes := make(chan types.ResumableGroupingChange)
+
+	go func() {
+		defer close(changes)
+
+		p.follow(ctx, cs, closer, raw, func(cs *mgo.ChangeStream) bool {
+			var event groupingChangeEvent
+			if !cs.Next(&event) {
+				return false
+			}
+			change, ok := p.changeOf(event)
+			if !ok {
+				return true
+			}
+
+			select {
+			case changes <- types.ResumableGroupingChange{GroupingPolicyChange: change, Token: encodeToken(cs.ResumeToken()), Time: changeTime(event.ClusterTime)}:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}()
+
+	return changes, nil
+}
+
+// ListWithToken lists all polices, with the token to watch changes made since before listing them,
+// as GroupingPersister.ListWithToken does
+func (p *PermissionPersister) ListWithToken(ctx context.Context) ([]types.PermissionPolicy, string, error) {
+	token, e := p.handshake(ctx)
+	if e != nil {
+		return nil, "", e
+	}
+
+	polices, e := p.ListContext(ctx)
+	if e != nil {
+		p.handshakes.release(token)
+		return nil, "", e
+	}
+	return polices, token, nil
+}
+
+// WatchFrom watches changes after the position of token, along with resume tokens after them
+func (p *PermissionPersister) WatchFrom(ctx context.Context, token string) (<-chan types.ResumablePermissionChange, error) {
+	cs, closer, raw, e := p.openStream(token)
+	if e != nil {
+		return nil, e
+	}
+
+	changes := make(chan types.ResumablePermissionChange)
+
+	go func() {
+		defer close(changes)
+
+		p.follow(ctx, cs, closer, raw, func(cs *mgo.ChangeStream) bool {
+			var event permissionChangeEvent
+			if !cs.Next(&event) {
+				return false
+			}
+			change, ok := p.changeOf(event)
+			if !ok {
+				return true
+			}
+
+			select {
+			case changes <- types.ResumablePermissionChange{PermissionPolicyChange: change, Token: encodeToken(cs.ResumeToken()), Time: changeTime(event.ClusterTime)}:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}()
+
+	return changes, nil
+}
@@ -0,0 +1,20 @@
+package persist
+
+import "sort"
+
+// Page paginates n items sorted by key, as types.GroupingQuerier and types.PermissionQuerier do:
+// items in [start, end) are up to limit items after cursor,
+// and next is the cursor of the next page, which is empty after the last page
+func Page(n int, key func(i int) string, cursor string, limit int) (start, end int, next string) {
+	start = 0
+	if cursor != "" {
+		start = sort.Search(n, func(i int) bool { return key(i) > cursor })
+	}
+
+	end = n
+	if limit > 0 && start+limit < n {
+		end = start + limit
+		next = key(end - 1)
+	}
+	return start, end, next
+}
@@ -0,0 +1,11 @@
+module github.com/supremind/rbac/persist/sql
+
+go 1.14
+
+require (
+	github.com/go-logr/logr v1.0.0
+	github.com/mattn/go-sqlite3 v1.14.6
+	github.com/onsi/ginkgo v1.16.4
+	github.com/onsi/gomega v1.14.0
+	github.com/supremind/rbac v0.5.0
+)
@@ -0,0 +1,263 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/supremind/rbac/types"
+)
+
+var (
+	_ types.GroupingPersister        = (*GroupingPersister)(nil)
+	_ types.GroupingPersisterContext = (*GroupingPersister)(nil)
+	_ types.GroupingQuerier          = (*GroupingPersister)(nil)
+	_ types.GroupingStreamer         = (*GroupingPersister)(nil)
+	_ types.GroupingResumer          = (*GroupingPersister)(nil)
+)
+
+// GroupingPersister is a GroupingPersister backed by SQL databases
+type GroupingPersister struct {
+	*table
+}
+
+// NewGrouping uses the table of name in db to persist grouping polices, and the table of name_changes to log changes,
+// tables are created if they do not exist
+func NewGrouping(ctx context.Context, db *sql.DB, d Dialect, name string, opts ...tableOption) (*GroupingPersister, error) {
+	p := &GroupingPersister{newTable(db, d, name, opts...)}
+
+	e := p.create(ctx,
+		`CREATE TABLE IF NOT EXISTS {polices} (
+			entity VARCHAR(255) NOT NULL,
+			group_name VARCHAR(255) NOT NULL,
+			PRIMARY KEY (entity, group_name)
+		)`,
+		`CREATE TABLE IF NOT EXISTS {changes} (
+			id {serial},
+			entity VARCHAR(255) NOT NULL,
+			group_name VARCHAR(255) NOT NULL,
+			method VARCHAR(16) NOT NULL,
+			changed_at BIGINT NOT NULL
+		)`,
+	)
+	if e != nil {
+		return nil, e
+	}
+	return p, nil
+}
+
+// logChange logs the change in the transaction
+func (p *GroupingPersister) logChange(ctx context.Context, tx *sql.Tx, ent types.Entity, group types.Group, method types.PersistMethod) error {
+	_, e := tx.ExecContext(ctx, p.query("INSERT INTO {changes} (entity, group_name, method, changed_at) VALUES (?, ?, ?, ?)"),
+		ent.String(), group.String(), string(method), now())
+	return e
+}
+
+// Insert inserts a policy to the persister
+func (p *GroupingPersister) Insert(ent types.Entity, group types.Group) error {
+	return p.InsertContext(context.Background(), ent, group)
+}
+
+// InsertContext inserts a policy to the persister
+func (p *GroupingPersister) InsertContext(ctx context.Context, ent types.Entity, group types.Group) error {
+	e := p.write(ctx, func(tx *sql.Tx) error {
+		existed, e := p.exists(ctx, tx, "SELECT COUNT(*) FROM {polices} WHERE entity = ? AND group_name = ?", ent.String(), group.String())
+		if e != nil {
+			return e
+		}
+		if existed {
+			return types.ErrAlreadyExists
+		}
+
+		if _, e := tx.ExecContext(ctx, p.query("INSERT INTO {polices} (entity, group_name) VALUES (?, ?)"), ent.String(), group.String()); e != nil {
+			return e
+		}
+		return p.logChange(ctx, tx, ent, group, types.PersistInsert)
+	})
+	if e != nil {
+		return e
+	}
+
+	p.log.V(4).Info("insert grouping policy", "entity", ent, "group", group)
+	return nil
+}
+
+// Remove a policy from the persister
+func (p *GroupingPersister) Remove(ent types.Entity, group types.Group) error {
+	return p.RemoveContext(context.Background(), ent, group)
+}
+
+// RemoveContext removes a policy from the persister
+func (p *GroupingPersister) RemoveContext(ctx context.Context, ent types.Entity, group types.Group) error {
+	e := p.write(ctx, func(tx *sql.Tx) error {
+		res, e := tx.ExecContext(ctx, p.query("DELETE FROM {polices} WHERE entity = ? AND group_name = ?"), ent.String(), group.String())
+		if e != nil {
+			return e
+		}
+		if n, e := res.RowsAffected(); e != nil {
+			return e
+		} else if n == 0 {
+			return types.ErrNotFound
+		}
+		return p.logChange(ctx, tx, ent, group, types.PersistDelete)
+	})
+	if e != nil {
+		return e
+	}
+
+	p.log.V(4).Info("remove grouping policy", "entity", ent, "group", group)
+	return nil
+}
+
+// List all policies from the persister
+func (p *GroupingPersister) List() ([]types.GroupingPolicy, error) {
+	return p.ListContext(context.Background())
+}
+
+// ListContext lists all policies from the persister
+func (p *GroupingPersister) ListContext(ctx context.Context) ([]types.GroupingPolicy, error) {
+	var polices []types.GroupingPolicy
+	e := p.Each(ctx, func(policy types.GroupingPolicy) error {
+		polices = append(polices, policy)
+		return nil
+	})
+	if e != nil {
+		return nil, e
+	}
+	return polices, nil
+}
+
+// Each calls fn with every policy in the persister, it stops at the first error returned by fn or the persister
+func (p *GroupingPersister) Each(ctx context.Context, fn func(types.GroupingPolicy) error) error {
+	return p.each(ctx, fn, "SELECT entity, group_name FROM {polices} ORDER BY entity, group_name")
+}
+
+// each calls fn with every policy selected by the query
+func (p *GroupingPersister) each(ctx context.Context, fn func(types.GroupingPolicy) error, q string, args ...interface{}) error {
+	rows, e := p.db.QueryContext(ctx, p.query(q), args...)
+	if e != nil {
+		return e
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ent, group string
+		if e := rows.Scan(&ent, &group); e != nil {
+			return e
+		}
+		policy, e := parseGroupingPolicy(ent, group)
+		if e != nil {
+			return e
+		}
+		if e := fn(policy); e != nil {
+			return e
+		}
+	}
+	return rows.Err()
+}
+
+func parseGroupingPolicy(ent, group string) (types.GroupingPolicy, error) {
+	var policy types.GroupingPolicy
+	var e error
+	if policy.Entity, e = types.ParseEntity(ent); e != nil {
+		return policy, e
+	}
+	if policy.Group, e = types.ParseGroup(group); e != nil {
+		return policy, e
+	}
+	return policy, nil
+}
+
+// ListByEntity lists polices of groups the entity immediately belongs to, ordered by groups
+func (p *GroupingPersister) ListByEntity(ent types.Entity, cursor string, limit int) ([]types.GroupingPolicy, string, error) {
+	q, args := page("SELECT entity, group_name FROM {polices} WHERE entity = ?", "group_name", cursor, limit, []interface{}{ent.String()})
+	return p.page(q, args, limit, func(policy types.GroupingPolicy) string { return policy.Group.String() })
+}
+
+// ListByGroup lists polices of entities immediately belong to the group, ordered by entities
+func (p *GroupingPersister) ListByGroup(group types.Group, cursor string, limit int) ([]types.GroupingPolicy, string, error) {
+	q, args := page("SELECT entity, group_name FROM {polices} WHERE group_name = ?", "entity", cursor, limit, []interface{}{group.String()})
+	return p.page(q, args, limit, func(policy types.GroupingPolicy) string { return policy.Entity.String() })
+}
+
+// page lists polices selected by a query made by page, and returns the cursor of the next page by key
+func (p *GroupingPersister) page(q string, args []interface{}, limit int, key func(types.GroupingPolicy) string) ([]types.GroupingPolicy, string, error) {
+	polices := make([]types.GroupingPolicy, 0)
+	e := p.each(context.Background(), func(policy types.GroupingPolicy) error {
+		polices = append(polices, policy)
+		return nil
+	}, q, args...)
+	if e != nil {
+		return nil, "", e
+	}
+
+	var next string
+	if limit > 0 && len(polices) > limit {
+		polices = polices[:limit]
+		next = key(polices[limit-1])
+	}
+	return polices, next, nil
+}
+
+// Watch any changes occurred about the policies in the persister, including changes made by other processes
+func (p *GroupingPersister) Watch(ctx context.Context) (<-chan types.GroupingPolicyChange, error) {
+	after, e := p.position(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	changes := make(chan types.GroupingPolicyChange)
+	go func() {
+		defer close(changes)
+		p.follow(ctx, after, func(ctx context.Context, after int64) (int64, error) {
+			return p.fetch(ctx, after, func(change types.ResumableGroupingChange) bool {
+				select {
+				case changes <- change.GroupingPolicyChange:
+					return true
+				case <-ctx.Done():
+					return false
+				}
+			})
+		})
+	}()
+	return changes, nil
+}
+
+// fetch sends changes after the given id, until send returns false, and returns the id of the last change sent.
+// Changes are read before sent, so that no cursor is kept open while watchers are applying them
+func (p *GroupingPersister) fetch(ctx context.Context, after int64, send func(types.ResumableGroupingChange) bool) (int64, error) {
+	var changes []types.ResumableGroupingChange
+	var ids []int64
+	last := after // the last change scanned, including ones skipped
+	e := p.scanChanges(ctx, "entity, group_name, method", after, func(rows *sql.Rows) error {
+		var id, at int64
+		var ent, group, method string
+		if e := rows.Scan(&id, &at, &ent, &group, &method); e != nil {
+			return e
+		}
+		last = id
+		policy, e := parseGroupingPolicy(ent, group)
+		if e != nil {
+			p.log.Error(e, "parse grouping change", "id", id)
+			return nil
+		}
+
+		changes = append(changes, types.ResumableGroupingChange{
+			GroupingPolicyChange: types.GroupingPolicyChange{GroupingPolicy: policy, Method: types.PersistMethod(method)},
+			Token:                formatToken(id),
+			Time:                 changeTime(at),
+		})
+		ids = append(ids, id)
+		return nil
+	})
+
+	for i, change := range changes {
+		if !send(change) {
+			return after, nil
+		}
+		after = ids[i]
+	}
+	if e != nil {
+		return after, e
+	}
+	return last, nil
+}
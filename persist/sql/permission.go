@@ -0,0 +1,318 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/supremind/rbac/types"
+)
+
+var (
+	_ types.PermissionPersister        = (*PermissionPersister)(nil)
+	_ types.PermissionPersisterContext = (*PermissionPersister)(nil)
+	_ types.PermissionQuerier          = (*PermissionPersister)(nil)
+	_ types.PermissionStreamer         = (*PermissionPersister)(nil)
+	_ types.PermissionResumer          = (*PermissionPersister)(nil)
+)
+
+// PermissionPersister is a PermissionPersister backed by SQL databases
+type PermissionPersister struct {
+	*table
+}
+
+// NewPermission uses the table of name in db to persist permission polices, and the table of name_changes to log changes,
+// tables are created if they do not exist.
+// Actions are kept by names, so that tables are readable and survive changes of action bits
+func NewPermission(ctx context.Context, db *sql.DB, d Dialect, name string, opts ...tableOption) (*PermissionPersister, error) {
+	p := &PermissionPersister{newTable(db, d, name, opts...)}
+
+	e := p.create(ctx,
+		`CREATE TABLE IF NOT EXISTS {polices} (
+			subject VARCHAR(255) NOT NULL,
+			object VARCHAR(255) NOT NULL,
+			action VARCHAR(255) NOT NULL,
+			PRIMARY KEY (subject, object)
+		)`,
+		`CREATE TABLE IF NOT EXISTS {changes} (
+			id {serial},
+			subject VARCHAR(255) NOT NULL,
+			object VARCHAR(255) NOT NULL,
+			action VARCHAR(255) NOT NULL,
+			method VARCHAR(16) NOT NULL,
+			changed_at BIGINT NOT NULL
+		)`,
+	)
+	if e != nil {
+		return nil, e
+	}
+	return p, nil
+}
+
+// logChange logs the change in the transaction
+func (p *PermissionPersister) logChange(ctx context.Context, tx *sql.Tx, sub types.Subject, obj types.Object, act types.Action, method types.PersistMethod) error {
+	_, e := tx.ExecContext(ctx, p.query("INSERT INTO {changes} (subject, object, action, method, changed_at) VALUES (?, ?, ?, ?, ?)"),
+		sub.String(), obj.String(), act.String(), string(method), now())
+	return e
+}
+
+// Insert a permission policy to the persister
+func (p *PermissionPersister) Insert(sub types.Subject, obj types.Object, act types.Action) error {
+	return p.InsertContext(context.Background(), sub, obj, act)
+}
+
+// InsertContext inserts a permission policy to the persister
+func (p *PermissionPersister) InsertContext(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) error {
+	e := p.write(ctx, func(tx *sql.Tx) error {
+		existed, e := p.exists(ctx, tx, "SELECT COUNT(*) FROM {polices} WHERE subject = ? AND object = ?", sub.String(), obj.String())
+		if e != nil {
+			return e
+		}
+		if existed {
+			return types.ErrAlreadyExists
+		}
+
+		_, e = tx.ExecContext(ctx, p.query("INSERT INTO {polices} (subject, object, action) VALUES (?, ?, ?)"), sub.String(), obj.String(), act.String())
+		if e != nil {
+			return e
+		}
+		return p.logChange(ctx, tx, sub, obj, act, types.PersistInsert)
+	})
+	if e != nil {
+		return e
+	}
+
+	p.log.V(4).Info("insert permission policy", "subject", sub, "object", obj, "action", act)
+	return nil
+}
+
+// Update a permission policy to the persister
+func (p *PermissionPersister) Update(sub types.Subject, obj types.Object, act types.Action) error {
+	return p.UpdateContext(context.Background(), sub, obj, act)
+}
+
+// UpdateContext updates a permission policy to the persister
+func (p *PermissionPersister) UpdateContext(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) error {
+	e := p.write(ctx, func(tx *sql.Tx) error {
+		// rows affected by updates to the same value are not counted by some databases, so existence is checked first
+		existed, e := p.exists(ctx, tx, "SELECT COUNT(*) FROM {polices} WHERE subject = ? AND object = ?", sub.String(), obj.String())
+		if e != nil {
+			return e
+		}
+		if !existed {
+			return types.ErrNotFound
+		}
+
+		_, e = tx.ExecContext(ctx, p.query("UPDATE {polices} SET action = ? WHERE subject = ? AND object = ?"), act.String(), sub.String(), obj.String())
+		if e != nil {
+			return e
+		}
+		return p.logChange(ctx, tx, sub, obj, act, types.PersistUpdate)
+	})
+	if e != nil {
+		return e
+	}
+
+	p.log.V(4).Info("update permission policy", "subject", sub, "object", obj, "action", act)
+	return nil
+}
+
+// Remove a permission policy from the persister
+func (p *PermissionPersister) Remove(sub types.Subject, obj types.Object) error {
+	return p.RemoveContext(context.Background(), sub, obj)
+}
+
+// RemoveContext removes a permission policy from the persister
+func (p *PermissionPersister) RemoveContext(ctx context.Context, sub types.Subject, obj types.Object) error {
+	e := p.write(ctx, func(tx *sql.Tx) error {
+		res, e := tx.ExecContext(ctx, p.query("DELETE FROM {polices} WHERE subject = ? AND object = ?"), sub.String(), obj.String())
+		if e != nil {
+			return e
+		}
+		if n, e := res.RowsAffected(); e != nil {
+			return e
+		} else if n == 0 {
+			return types.ErrNotFound
+		}
+		return p.logChange(ctx, tx, sub, obj, 0, types.PersistDelete)
+	})
+	if e != nil {
+		return e
+	}
+
+	p.log.V(4).Info("remove permission policy", "subject", sub, "object", obj)
+	return nil
+}
+
+// List all polices from the persister
+func (p *PermissionPersister) List() ([]types.PermissionPolicy, error) {
+	return p.ListContext(context.Background())
+}
+
+// ListContext lists all polices from the persister
+func (p *PermissionPersister) ListContext(ctx context.Context) ([]types.PermissionPolicy, error) {
+	var polices []types.PermissionPolicy
+	e := p.Each(ctx, func(policy types.PermissionPolicy) error {
+		polices = append(polices, policy)
+		return nil
+	})
+	if e != nil {
+		return nil, e
+	}
+	return polices, nil
+}
+
+// Each calls fn with every policy in the persister, it stops at the first error returned by fn or the persister
+func (p *PermissionPersister) Each(ctx context.Context, fn func(types.PermissionPolicy) error) error {
+	return p.each(ctx, fn, "SELECT subject, object, action FROM {polices} ORDER BY subject, object")
+}
+
+// each calls fn with every policy selected by the query
+func (p *PermissionPersister) each(ctx context.Context, fn func(types.PermissionPolicy) error, q string, args ...interface{}) error {
+	rows, e := p.db.QueryContext(ctx, p.query(q), args...)
+	if e != nil {
+		return e
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sub, obj, act string
+		if e := rows.Scan(&sub, &obj, &act); e != nil {
+			return e
+		}
+		policy, e := parsePermissionPolicy(sub, obj, act)
+		if e != nil {
+			return e
+		}
+		if e := fn(policy); e != nil {
+			return e
+		}
+	}
+	return rows.Err()
+}
+
+func parsePermissionPolicy(sub, obj, act string) (types.PermissionPolicy, error) {
+	var policy types.PermissionPolicy
+	var e error
+	if policy.Subject, e = types.ParseSubject(sub); e != nil {
+		return policy, e
+	}
+	if policy.Object, e = types.ParseObject(obj); e != nil {
+		return policy, e
+	}
+	if act == "" {
+		// no action is logged for removals
+		return policy, nil
+	}
+	if policy.Action, e = types.ParseAction(act); e != nil {
+		return policy, e
+	}
+	return policy, nil
+}
+
+// Get the action permitted to the subject on the object, or ErrNotFound
+func (p *PermissionPersister) Get(sub types.Subject, obj types.Object) (types.Action, error) {
+	var name string
+	e := p.db.QueryRow(p.query("SELECT action FROM {polices} WHERE subject = ? AND object = ?"), sub.String(), obj.String()).Scan(&name)
+	if e == sql.ErrNoRows {
+		return 0, types.ErrNotFound
+	}
+	if e != nil {
+		return 0, e
+	}
+	return types.ParseAction(name)
+}
+
+// ListBySubject lists polices permitting the subject, ordered by objects
+func (p *PermissionPersister) ListBySubject(sub types.Subject, cursor string, limit int) ([]types.PermissionPolicy, string, error) {
+	q, args := page("SELECT subject, object, action FROM {polices} WHERE subject = ?", "object", cursor, limit, []interface{}{sub.String()})
+	return p.page(q, args, limit, func(policy types.PermissionPolicy) string { return policy.Object.String() })
+}
+
+// ListByObject lists polices permitting on the object, ordered by subjects
+func (p *PermissionPersister) ListByObject(obj types.Object, cursor string, limit int) ([]types.PermissionPolicy, string, error) {
+	q, args := page("SELECT subject, object, action FROM {polices} WHERE object = ?", "subject", cursor, limit, []interface{}{obj.String()})
+	return p.page(q, args, limit, func(policy types.PermissionPolicy) string { return policy.Subject.String() })
+}
+
+// page lists polices selected by a query made by page, and returns the cursor of the next page by key
+func (p *PermissionPersister) page(q string, args []interface{}, limit int, key func(types.PermissionPolicy) string) ([]types.PermissionPolicy, string, error) {
+	polices := make([]types.PermissionPolicy, 0)
+	e := p.each(context.Background(), func(policy types.PermissionPolicy) error {
+		polices = append(polices, policy)
+		return nil
+	}, q, args...)
+	if e != nil {
+		return nil, "", e
+	}
+
+	var next string
+	if limit > 0 && len(polices) > limit {
+		polices = polices[:limit]
+		next = key(polices[limit-1])
+	}
+	return polices, next, nil
+}
+
+// Watch any changes occurred about the polices in the persister, including changes made by other processes
+func (p *PermissionPersister) Watch(ctx context.Context) (<-chan types.PermissionPolicyChange, error) {
+	after, e := p.position(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	changes := make(chan types.PermissionPolicyChange)
+	go func() {
+		defer close(changes)
+		p.follow(ctx, after, func(ctx context.Context, after int64) (int64, error) {
+			return p.fetch(ctx, after, func(change types.ResumablePermissionChange) bool {
+				select {
+				case changes <- change.PermissionPolicyChange:
+					return true
+				case <-ctx.Done():
+					return false
+				}
+			})
+		})
+	}()
+	return changes, nil
+}
+
+// fetch sends changes after the given id, until send returns false, and returns the id of the last change sent.
+// Changes are read before sent, so that no cursor is kept open while watchers are applying them
+func (p *PermissionPersister) fetch(ctx context.Context, after int64, send func(types.ResumablePermissionChange) bool) (int64, error) {
+	var changes []types.ResumablePermissionChange
+	var ids []int64
+	last := after // the last change scanned, including ones skipped
+	e := p.scanChanges(ctx, "subject, object, action, method", after, func(rows *sql.Rows) error {
+		var id, at int64
+		var sub, obj, act, method string
+		if e := rows.Scan(&id, &at, &sub, &obj, &act, &method); e != nil {
+			return e
+		}
+		last = id
+		policy, e := parsePermissionPolicy(sub, obj, act)
+		if e != nil {
+			p.log.Error(e, "parse permission change", "id", id)
+			return nil
+		}
+
+		changes = append(changes, types.ResumablePermissionChange{
+			PermissionPolicyChange: types.PermissionPolicyChange{PermissionPolicy: policy, Method: types.PersistMethod(method)},
+			Token:                  formatToken(id),
+			Time:                   changeTime(at),
+		})
+		ids = append(ids, id)
+		return nil
+	})
+
+	for i, change := range changes {
+		if !send(change) {
+			return after, nil
+		}
+		after = ids[i]
+	}
+	if e != nil {
+		return after, e
+	}
+	return last, nil
+}
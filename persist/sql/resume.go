@@ -0,0 +1,85 @@
+package sql
+
+import (
+	"context"
+
+	"github.com/supremind/rbac/types"
+)
+
+// ListWithToken lists all polices, with the token to watch changes made since before listing them.
+// Tokens are ids of changes, changes are kept in the table of changes, so any token sent along with them is valid
+func (p *GroupingPersister) ListWithToken(ctx context.Context) ([]types.GroupingPolicy, string, error) {
+	pos, e := p.position(ctx)
+	if e != nil {
+		return nil, "", e
+	}
+
+	polices, e := p.ListContext(ctx)
+	if e != nil {
+		return nil, "", e
+	}
+	return polices, formatToken(pos), nil
+}
+
+// WatchFrom watches changes after the position of token, along with resume tokens after them
+func (p *GroupingPersister) WatchFrom(ctx context.Context, token string) (<-chan types.ResumableGroupingChange, error) {
+	after, e := parseToken(token)
+	if e != nil {
+		return nil, e
+	}
+
+	changes := make(chan types.ResumableGroupingChange)
+	go func() {
+		defer close(changes)
+		p.follow(ctx, after, func(ctx context.Context, after int64) (int64, error) {
+			return p.fetch(ctx, after, func(change types.ResumableGroupingChange) bool {
+				select {
+				case changes <- change:
+					return true
+				case <-ctx.Done():
+					return false
+				}
+			})
+		})
+	}()
+	return changes, nil
+}
+
+// ListWithToken lists all polices, with the token to watch changes made since before listing them,
+// as GroupingPersister.ListWithToken does
+func (p *PermissionPersister) ListWithToken(ctx context.Context) ([]types.PermissionPolicy, string, error) {
+	pos, e := p.position(ctx)
+	if e != nil {
+		return nil, "", e
+	}
+
+	polices, e := p.ListContext(ctx)
+	if e != nil {
+		return nil, "", e
+	}
+	return polices, formatToken(pos), nil
+}
+
+// WatchFrom watches changes after the position of token, along with resume tokens after them
+func (p *PermissionPersister) WatchFrom(ctx context.Context, token string) (<-chan types.ResumablePermissionChange, error) {
+	after, e := parseToken(token)
+	if e != nil {
+		return nil, e
+	}
+
+	changes := make(chan types.ResumablePermissionChange)
+	go func() {
+		defer close(changes)
+		p.follow(ctx, after, func(ctx context.Context, after int64) (int64, error) {
+			return p.fetch(ctx, after, func(change types.ResumablePermissionChange) bool {
+				select {
+				case changes <- change:
+					return true
+				case <-ctx.Done():
+					return false
+				}
+			})
+		})
+	}()
+	return changes, nil
+}
@@ -0,0 +1,80 @@
+package sql_test
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/supremind/rbac/persist/sql"
+	. "github.com/supremind/rbac/persist/test"
+	"github.com/supremind/rbac/types"
+)
+
+func TestPersisters(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "sql persisters")
+}
+
+var (
+	dir string
+	db  *sql.DB
+)
+
+var _ = BeforeSuite(func() {
+	var e error
+	dir, e = ioutil.TempDir("", "rbac-sql-")
+	Expect(e).To(Succeed())
+	db, e = sql.Open("sqlite3", filepath.Join(dir, "rbac.db")+"?_busy_timeout=5000&_journal_mode=WAL")
+	Expect(e).To(Succeed())
+
+	gp, e := NewGrouping(context.Background(), db, SQLite, "grouping", WithPollInterval(10*time.Millisecond))
+	Expect(e).To(Succeed())
+	TestGroupingPersister(gp)
+
+	pp, e := NewPermission(context.Background(), db, SQLite, "permission", WithPollInterval(10*time.Millisecond))
+	Expect(e).To(Succeed())
+	TestPermissionPersister(pp)
+})
+
+var _ = AfterSuite(func() {
+	db.Close()
+	os.RemoveAll(dir)
+})
+
+var _ = Describe("sql persisters", func() {
+	_ = GroupingCases
+	_ = PermissionCases
+
+	It("should observe changes made by others sharing the database", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mine, e := NewPermission(ctx, db, SQLite, "shared", WithPollInterval(10*time.Millisecond))
+		Expect(e).To(Succeed())
+		others, e := NewPermission(ctx, db, SQLite, "shared")
+		Expect(e).To(Succeed())
+
+		w, e := mine.Watch(ctx)
+		Expect(e).To(Succeed())
+
+		Expect(others.Insert(types.User("alan"), types.Article("apollo"), types.Read)).To(Succeed())
+		Eventually(w).Should(Receive(Equal(types.PermissionPolicyChange{
+			PermissionPolicy: types.PermissionPolicy{Subject: types.User("alan"), Object: types.Article("apollo"), Action: types.Read},
+			Method:           types.PersistInsert,
+		})))
+
+		Expect(others.Remove(types.User("alan"), types.Article("apollo"))).To(Succeed())
+		Eventually(w).Should(Receive(Equal(types.PermissionPolicyChange{
+			PermissionPolicy: types.PermissionPolicy{Subject: types.User("alan"), Object: types.Article("apollo")},
+			Method:           types.PersistDelete,
+		})))
+	})
+})
@@ -0,0 +1,227 @@
+// Package sql persists polices in SQL databases through database/sql.
+//
+// Polices are kept in a table, and every write is logged in a table of changes in the same transaction.
+// Changes made by any process are found by polling the table of changes, so that replicas sharing the database
+// are coordinated like other persisters do, and ids of changes are used as resume tokens.
+//
+// Tables are created if they do not exist. Drivers are up to callers, as long as the dialect of the database is given.
+// Changes are watched in the order of their ids, a change committed after another one with a greater id may be missed,
+// so writes to the same tables should not be made in long transactions.
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/supremind/rbac/types"
+)
+
+// DefaultPollInterval is how often the table of changes is checked for new changes
+const DefaultPollInterval = time.Second
+
+// Dialect tells how queries are written for a kind of databases
+type Dialect struct {
+	bind   func(i int) string // placeholder of the i-th argument, counted from 1
+	serial string             // definition of auto increment primary keys
+}
+
+// dialects of supported databases
+var (
+	SQLite   = Dialect{bind: question, serial: "INTEGER PRIMARY KEY AUTOINCREMENT"}
+	MySQL    = Dialect{bind: question, serial: "BIGINT AUTO_INCREMENT PRIMARY KEY"}
+	Postgres = Dialect{bind: dollar, serial: "BIGSERIAL PRIMARY KEY"}
+)
+
+func question(int) string { return "?" }
+func dollar(i int) string { return "$" + strconv.Itoa(i) }
+
+// DialectOf returns the dialect of databases served by the driver, by the names drivers are usually registered with
+func DialectOf(driver string) (Dialect, bool) {
+	switch driver {
+	case "sqlite3", "sqlite":
+		return SQLite, true
+	case "mysql":
+		return MySQL, true
+	case "postgres", "pgx":
+		return Postgres, true
+	}
+	return Dialect{}, false
+}
+
+type tableOption func(*table)
+
+// WithPollInterval sets how often the table of changes is checked for new changes
+func WithPollInterval(d time.Duration) tableOption {
+	return func(t *table) {
+		t.interval = d
+	}
+}
+
+// WithLogger sets a logger for the table to use with
+func WithLogger(log logr.Logger) tableOption {
+	return func(t *table) {
+		t.log = log
+	}
+}
+
+// common table utilities
+type table struct {
+	db       *sql.DB
+	dialect  Dialect
+	name     string // of polices
+	changes  string // of changes logged
+	interval time.Duration
+	log      logr.Logger
+}
+
+func newTable(db *sql.DB, d Dialect, name string, opts ...tableOption) *table {
+	t := &table{
+		db:       db,
+		dialect:  d,
+		name:     name,
+		changes:  name + "_changes",
+		interval: DefaultPollInterval,
+		log:      logr.Discard(),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// query formats the query with table names, and replaces ? with placeholders of the dialect,
+// {polices}, {changes} and {serial} are replaced by the table of polices, the table of changes,
+// and the definition of auto increment primary keys
+func (t *table) query(q string) string {
+	q = strings.NewReplacer("{polices}", t.name, "{changes}", t.changes, "{serial}", t.dialect.serial).Replace(q)
+
+	var b strings.Builder
+	n := 0
+	for _, r := range q {
+		if r == '?' {
+			n++
+			b.WriteString(t.dialect.bind(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// create creates tables by statements if they do not exist
+func (t *table) create(ctx context.Context, stmts ...string) error {
+	for _, stmt := range stmts {
+		if _, e := t.db.ExecContext(ctx, t.query(stmt)); e != nil {
+			return fmt.Errorf("create tables of %s: %w", t.name, e)
+		}
+	}
+	return nil
+}
+
+// write runs fn in a transaction, and commits it if fn succeeds
+func (t *table) write(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, e := t.db.BeginTx(ctx, nil)
+	if e != nil {
+		return e
+	}
+	if e := fn(tx); e != nil {
+		tx.Rollback()
+		return e
+	}
+	return tx.Commit()
+}
+
+// exists tells if the query selects any row
+func (t *table) exists(ctx context.Context, tx *sql.Tx, q string, args ...interface{}) (bool, error) {
+	var n int
+	if e := tx.QueryRowContext(ctx, t.query(q), args...).Scan(&n); e != nil {
+		return false, e
+	}
+	return n > 0, nil
+}
+
+// position returns the id of the last change
+func (t *table) position(ctx context.Context) (int64, error) {
+	var id int64
+	e := t.db.QueryRowContext(ctx, t.query("SELECT COALESCE(MAX(id), 0) FROM {changes}")).Scan(&id)
+	return id, e
+}
+
+// follow calls fetch with the id of the last change seen every interval, until ctx is done,
+// fetch sends changes after it, and returns the id of the last one sent
+func (t *table) follow(ctx context.Context, after int64, fetch func(ctx context.Context, after int64) (int64, error)) {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			last, e := fetch(ctx, after)
+			if e != nil && ctx.Err() == nil {
+				t.log.Error(e, "fetch changes", "table", t.changes, "after", after)
+			}
+			after = last
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// scanChanges calls fn with rows of changes after the given id in order, they have columns of id, changed_at,
+// and the given ones
+func (t *table) scanChanges(ctx context.Context, columns string, after int64, fn func(rows *sql.Rows) error) error {
+	rows, e := t.db.QueryContext(ctx, t.query("SELECT id, changed_at, "+columns+" FROM {changes} WHERE id > ? ORDER BY id"), after)
+	if e != nil {
+		return e
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if e := fn(rows); e != nil {
+			return e
+		}
+	}
+	return rows.Err()
+}
+
+// formatToken formats the id of a change as a resume token
+func formatToken(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
+
+// parseToken parses resume tokens formatted by formatToken
+func parseToken(token string) (int64, error) {
+	id, e := strconv.ParseInt(token, 10, 64)
+	if e != nil || id < 0 {
+		return 0, fmt.Errorf("%w: %s", types.ErrInvalidToken, token)
+	}
+	return id, nil
+}
+
+// now returns the time of changes made now, in unix nanoseconds, which are stored the same way by all databases
+func now() int64 {
+	return time.Now().UnixNano()
+}
+
+// changeTime returns the time of changes stored by now
+func changeTime(at int64) time.Time {
+	return time.Unix(0, at)
+}
+
+// page appends the condition of cursor on column, and the limit to the query, one more row is fetched to find the next page
+func page(q, column, cursor string, limit int, args []interface{}) (string, []interface{}) {
+	if cursor != "" {
+		q += " AND " + column + " > ?"
+		args = append(args, cursor)
+	}
+	q += " ORDER BY " + column
+	if limit > 0 {
+		q += " LIMIT " + strconv.Itoa(limit+1)
+	}
+	return q, args
+}
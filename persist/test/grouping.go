@@ -2,6 +2,7 @@ package test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/supremind/rbac/types"
@@ -43,6 +44,151 @@ var GroupingCases = Describe("grouping persister", func() {
 		})
 	}
 
+	It("should query grouping polices page by page", func() {
+		q, ok := gp.(types.GroupingQuerier)
+		if !ok {
+			Skip("grouping persister could not query polices")
+		}
+
+		polices := []types.GroupingPolicy{
+			{Entity: types.User("ada"), Group: types.Role("admins")},
+			{Entity: types.User("ada"), Group: types.Role("readers")},
+			{Entity: types.User("ada"), Group: types.Role("writers")},
+			{Entity: types.User("bob"), Group: types.Role("readers")},
+			{Entity: types.User("cid"), Group: types.Role("readers")},
+		}
+		for _, policy := range polices {
+			Expect(gp.Insert(policy.Entity, policy.Group)).To(Succeed())
+		}
+		defer func() {
+			for _, policy := range polices {
+				Expect(gp.Remove(policy.Entity, policy.Group)).To(Succeed())
+			}
+		}()
+
+		By("list all at once")
+		got, next, e := q.ListByEntity(types.User("ada"), "", 0)
+		Expect(e).To(Succeed())
+		Expect(got).To(Equal(polices[:3]))
+		Expect(next).To(BeEmpty())
+
+		By("list by entity page by page")
+		got, next, e = q.ListByEntity(types.User("ada"), "", 2)
+		Expect(e).To(Succeed())
+		Expect(got).To(Equal(polices[:2]))
+		Expect(next).NotTo(BeEmpty())
+		got, next, e = q.ListByEntity(types.User("ada"), next, 2)
+		Expect(e).To(Succeed())
+		Expect(got).To(Equal(polices[2:3]))
+		Expect(next).To(BeEmpty())
+
+		By("list by group page by page")
+		got, next, e = q.ListByGroup(types.Role("readers"), "", 2)
+		Expect(e).To(Succeed())
+		Expect(got).To(Equal([]types.GroupingPolicy{polices[1], polices[3]}))
+		Expect(next).NotTo(BeEmpty())
+		got, next, e = q.ListByGroup(types.Role("readers"), next, 2)
+		Expect(e).To(Succeed())
+		Expect(got).To(Equal(polices[4:]))
+		Expect(next).To(BeEmpty())
+
+		By("list nothing about unknown entities")
+		got, next, e = q.ListByEntity(types.User("dan"), "", 0)
+		Expect(e).To(Succeed())
+		Expect(got).To(BeEmpty())
+		Expect(next).To(BeEmpty())
+	})
+
+	It("should stream grouping polices one by one", func() {
+		s, ok := gp.(types.GroupingStreamer)
+		if !ok {
+			Skip("grouping persister could not stream polices")
+		}
+
+		polices := []types.GroupingPolicy{
+			{Entity: types.User("ada"), Group: types.Role("readers")},
+			{Entity: types.Role("readers"), Group: types.Role("staff")},
+		}
+		for _, policy := range polices {
+			Expect(gp.Insert(policy.Entity, policy.Group)).To(Succeed())
+		}
+		defer func() {
+			for _, policy := range polices {
+				Expect(gp.Remove(policy.Entity, policy.Group)).To(Succeed())
+			}
+		}()
+
+		By("stream the same polices as listed")
+		listed, e := gp.List()
+		Expect(e).To(Succeed())
+		var streamed []types.GroupingPolicy
+		Expect(s.Each(context.Background(), func(policy types.GroupingPolicy) error {
+			streamed = append(streamed, policy)
+			return nil
+		})).To(Succeed())
+		Expect(streamed).To(ConsistOf(listed))
+
+		By("stop at the first error")
+		stop := errors.New("stop")
+		n := 0
+		Expect(s.Each(context.Background(), func(types.GroupingPolicy) error {
+			n++
+			return stop
+		})).To(MatchError(stop))
+		Expect(n).To(Equal(1))
+	})
+
+	It("should resume watching grouping changes from tokens", func() {
+		r, ok := gp.(types.GroupingResumer)
+		if !ok {
+			Skip("grouping persister could not resume watching")
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		polices := []types.GroupingPolicy{
+			{Entity: types.User("ada"), Group: types.Role("readers")},
+			{Entity: types.User("bob"), Group: types.Role("readers")},
+		}
+		listed, token, e := r.ListWithToken(ctx)
+		Expect(e).To(Succeed())
+		Expect(token).NotTo(BeEmpty())
+		before, e := gp.List()
+		Expect(e).To(Succeed())
+		Expect(listed).To(ConsistOf(before))
+
+		for _, policy := range polices {
+			Expect(gp.Insert(policy.Entity, policy.Group)).To(Succeed())
+		}
+		defer func() {
+			for _, policy := range polices {
+				Expect(gp.Remove(policy.Entity, policy.Group)).To(Succeed())
+			}
+		}()
+
+		By("watch changes made since listing")
+		changes, e := r.WatchFrom(ctx, token)
+		Expect(e).To(Succeed())
+		var first types.ResumableGroupingChange
+		Eventually(changes).Should(Receive(&first))
+		Expect(first.GroupingPolicyChange).To(Equal(types.GroupingPolicyChange{GroupingPolicy: polices[0], Method: types.PersistInsert}))
+		Expect(first.Token).NotTo(BeEmpty())
+		var second types.ResumableGroupingChange
+		Eventually(changes).Should(Receive(&second))
+		Expect(second.GroupingPolicyChange).To(Equal(types.GroupingPolicyChange{GroupingPolicy: polices[1], Method: types.PersistInsert}))
+
+		By("resume after a watched change")
+		changes, e = r.WatchFrom(ctx, first.Token)
+		Expect(e).To(Succeed())
+		var resumed types.ResumableGroupingChange
+		Eventually(changes).Should(Receive(&resumed))
+		Expect(resumed).To(Equal(second))
+
+		By("refuse invalid tokens")
+		_, e = r.WatchFrom(ctx, "invalid")
+		Expect(errors.Is(e, types.ErrInvalidToken)).To(BeTrue())
+	})
+
 	It("should do grouping policy curd", func() {
 		By("insert and remove single policy only once")
 		policy := insertPolices[0]
@@ -2,6 +2,7 @@ package test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	. "github.com/onsi/ginkgo"
@@ -51,6 +52,158 @@ var PermissionCases = Describe("permission persister", func() {
 		})
 	}
 
+	It("should query permission polices page by page", func() {
+		q, ok := pp.(types.PermissionQuerier)
+		if !ok {
+			Skip("permission persister could not query polices")
+		}
+
+		polices := []types.PermissionPolicy{
+			{Subject: types.User("ada"), Object: types.Article("apollo"), Action: types.Read},
+			{Subject: types.User("ada"), Object: types.Article("gemini"), Action: types.Write},
+			{Subject: types.User("ada"), Object: types.Category("space"), Action: types.Exec},
+			{Subject: types.User("bob"), Object: types.Article("apollo"), Action: types.ReadWrite},
+			{Subject: types.User("cid"), Object: types.Article("apollo"), Action: types.Read},
+		}
+		for _, policy := range polices {
+			Expect(pp.Insert(policy.Subject, policy.Object, policy.Action)).To(Succeed())
+		}
+		defer func() {
+			for _, policy := range polices {
+				Expect(pp.Remove(policy.Subject, policy.Object)).To(Succeed())
+			}
+		}()
+
+		By("get single policy")
+		Expect(q.Get(types.User("ada"), types.Article("gemini"))).To(Equal(types.Write))
+		_, e := q.Get(types.User("ada"), types.Article("mercury"))
+		Expect(e).To(MatchError(types.ErrNotFound))
+		_, e = q.Get(types.User("dan"), types.Article("apollo"))
+		Expect(e).To(MatchError(types.ErrNotFound))
+
+		By("list all at once")
+		got, next, e := q.ListBySubject(types.User("ada"), "", 0)
+		Expect(e).To(Succeed())
+		Expect(got).To(Equal(polices[:3]))
+		Expect(next).To(BeEmpty())
+
+		By("list by subject page by page")
+		got, next, e = q.ListBySubject(types.User("ada"), "", 2)
+		Expect(e).To(Succeed())
+		Expect(got).To(Equal(polices[:2]))
+		Expect(next).NotTo(BeEmpty())
+		got, next, e = q.ListBySubject(types.User("ada"), next, 2)
+		Expect(e).To(Succeed())
+		Expect(got).To(Equal(polices[2:3]))
+		Expect(next).To(BeEmpty())
+
+		By("list by object page by page")
+		got, next, e = q.ListByObject(types.Article("apollo"), "", 2)
+		Expect(e).To(Succeed())
+		Expect(got).To(Equal([]types.PermissionPolicy{polices[0], polices[3]}))
+		Expect(next).NotTo(BeEmpty())
+		got, next, e = q.ListByObject(types.Article("apollo"), next, 2)
+		Expect(e).To(Succeed())
+		Expect(got).To(Equal(polices[4:]))
+		Expect(next).To(BeEmpty())
+
+		By("list nothing about unknown subjects")
+		got, next, e = q.ListBySubject(types.User("dan"), "", 0)
+		Expect(e).To(Succeed())
+		Expect(got).To(BeEmpty())
+		Expect(next).To(BeEmpty())
+	})
+
+	It("should stream permission polices one by one", func() {
+		s, ok := pp.(types.PermissionStreamer)
+		if !ok {
+			Skip("permission persister could not stream polices")
+		}
+
+		polices := []types.PermissionPolicy{
+			{Subject: types.User("ada"), Object: types.Article("apollo"), Action: types.Read},
+			{Subject: types.Role("staff"), Object: types.Category("space"), Action: types.ReadWrite},
+		}
+		for _, policy := range polices {
+			Expect(pp.Insert(policy.Subject, policy.Object, policy.Action)).To(Succeed())
+		}
+		defer func() {
+			for _, policy := range polices {
+				Expect(pp.Remove(policy.Subject, policy.Object)).To(Succeed())
+			}
+		}()
+
+		By("stream the same polices as listed")
+		listed, e := pp.List()
+		Expect(e).To(Succeed())
+		var streamed []types.PermissionPolicy
+		Expect(s.Each(context.Background(), func(policy types.PermissionPolicy) error {
+			streamed = append(streamed, policy)
+			return nil
+		})).To(Succeed())
+		Expect(streamed).To(ConsistOf(listed))
+
+		By("stop at the first error")
+		stop := errors.New("stop")
+		n := 0
+		Expect(s.Each(context.Background(), func(types.PermissionPolicy) error {
+			n++
+			return stop
+		})).To(MatchError(stop))
+		Expect(n).To(Equal(1))
+	})
+
+	It("should resume watching permission changes from tokens", func() {
+		r, ok := pp.(types.PermissionResumer)
+		if !ok {
+			Skip("permission persister could not resume watching")
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		polices := []types.PermissionPolicy{
+			{Subject: types.User("ada"), Object: types.Article("apollo"), Action: types.Read},
+			{Subject: types.Role("staff"), Object: types.Category("space"), Action: types.ReadWrite},
+		}
+		listed, token, e := r.ListWithToken(ctx)
+		Expect(e).To(Succeed())
+		Expect(token).NotTo(BeEmpty())
+		before, e := pp.List()
+		Expect(e).To(Succeed())
+		Expect(listed).To(ConsistOf(before))
+
+		for _, policy := range polices {
+			Expect(pp.Insert(policy.Subject, policy.Object, policy.Action)).To(Succeed())
+		}
+		defer func() {
+			for _, policy := range polices {
+				Expect(pp.Remove(policy.Subject, policy.Object)).To(Succeed())
+			}
+		}()
+
+		By("watch changes made since listing")
+		changes, e := r.WatchFrom(ctx, token)
+		Expect(e).To(Succeed())
+		var first types.ResumablePermissionChange
+		Eventually(changes).Should(Receive(&first))
+		Expect(first.PermissionPolicyChange).To(Equal(types.PermissionPolicyChange{PermissionPolicy: polices[0], Method: types.PersistInsert}))
+		Expect(first.Token).NotTo(BeEmpty())
+		var second types.ResumablePermissionChange
+		Eventually(changes).Should(Receive(&second))
+		Expect(second.PermissionPolicyChange).To(Equal(types.PermissionPolicyChange{PermissionPolicy: polices[1], Method: types.PersistInsert}))
+
+		By("resume after a watched change")
+		changes, e = r.WatchFrom(ctx, first.Token)
+		Expect(e).To(Succeed())
+		var resumed types.ResumablePermissionChange
+		Eventually(changes).Should(Receive(&resumed))
+		Expect(resumed).To(Equal(second))
+
+		By("refuse invalid tokens")
+		_, e = r.WatchFrom(ctx, "invalid")
+		Expect(errors.Is(e, types.ErrInvalidToken)).To(BeTrue())
+	})
+
 	It("should do permission policy crud", func() {
 		By("insert and remvoe single policy as expected")
 		policy := insertPolices[0]
@@ -0,0 +1,257 @@
+// Package policy exports polices to, and imports polices from, portable documents in JSON or YAML,
+// so that polices could be migrated between environments, or kept in version control.
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+
+	"github.com/supremind/rbac/types"
+	"gopkg.in/yaml.v2"
+)
+
+// Version of the document schema
+const Version = 1
+
+// Format of encoded documents
+type Format string
+
+// supported formats
+const (
+	JSON Format = "json"
+	YAML Format = "yaml"
+)
+
+// errors about documents
+var (
+	ErrUnsupportedVersion = errors.New("unsupported document version")
+	ErrUnsupportedFormat  = errors.New("unsupported document format")
+)
+
+// Document is the portable form of polices, entities are in their serialized form, and actions are named
+type Document struct {
+	Version     int          `json:"version" yaml:"version"`
+	Subjects    []Grouping   `json:"subjects" yaml:"subjects"`
+	Objects     []Grouping   `json:"objects" yaml:"objects"`
+	Permissions []Permission `json:"permissions" yaml:"permissions"`
+}
+
+// Grouping is an entity-group relationship policy, like user:alan in role:editor
+type Grouping struct {
+	Entity string `json:"entity" yaml:"entity"`
+	Group  string `json:"group" yaml:"group"`
+}
+
+// Permission is a subject-object-actions permission policy, like role:editor could read and write cat:news
+type Permission struct {
+	Subject string   `json:"subject" yaml:"subject"`
+	Object  string   `json:"object" yaml:"object"`
+	Actions []string `json:"actions" yaml:"actions"`
+}
+
+// NewDocument creates a document of the polices, polices are sorted so that documents are stable to diff
+func NewDocument(ps types.Policies) *Document {
+	doc := &Document{
+		Version:     Version,
+		Subjects:    groupingsOf(ps.Subjects),
+		Objects:     groupingsOf(ps.Objects),
+		Permissions: make([]Permission, 0, len(ps.Permissions)),
+	}
+
+	for _, policy := range ps.Permissions {
+		doc.Permissions = append(doc.Permissions, Permission{
+			Subject: policy.Subject.String(),
+			Object:  policy.Object.String(),
+			Actions: actionNames(policy.Action),
+		})
+	}
+	sort.Slice(doc.Permissions, func(i, j int) bool {
+		a, b := doc.Permissions[i], doc.Permissions[j]
+		if a.Subject != b.Subject {
+			return a.Subject < b.Subject
+		}
+		return a.Object < b.Object
+	})
+
+	return doc
+}
+
+func groupingsOf(polices []types.GroupingPolicy) []Grouping {
+	gs := make([]Grouping, 0, len(polices))
+	for _, policy := range polices {
+		gs = append(gs, Grouping{Entity: policy.Entity.String(), Group: policy.Group.String()})
+	}
+	sortGroupings(gs)
+	return gs
+}
+
+func sortGroupings(gs []Grouping) {
+	sort.Slice(gs, func(i, j int) bool {
+		if gs[i].Entity != gs[j].Entity {
+			return gs[i].Entity < gs[j].Entity
+		}
+		return gs[i].Group < gs[j].Group
+	})
+}
+
+// Policies parses polices in the document, actions permitted by duplicated permissions are merged
+func (d *Document) Policies() (types.Policies, error) {
+	if d.Version != Version {
+		return types.Policies{}, fmt.Errorf("%w: %d", ErrUnsupportedVersion, d.Version)
+	}
+
+	var ps types.Policies
+	var e error
+
+	ps.Subjects, e = parseGroupings(d.Subjects, func(ent types.Entity, group types.Group) bool {
+		_, isSubject := ent.(types.Subject)
+		_, isRole := group.(types.Role)
+		return isSubject && isRole
+	})
+	if e != nil {
+		return types.Policies{}, fmt.Errorf("subjects: %w", e)
+	}
+
+	ps.Objects, e = parseGroupings(d.Objects, func(ent types.Entity, group types.Group) bool {
+		_, isObject := ent.(types.Object)
+		_, isCategory := group.(types.Category)
+		return isObject && isCategory
+	})
+	if e != nil {
+		return types.Policies{}, fmt.Errorf("objects: %w", e)
+	}
+
+	index := make(map[permissionKey]int, len(d.Permissions))
+	for _, perm := range d.Permissions {
+		sub, e := types.ParseSubject(perm.Subject)
+		if e != nil {
+			return types.Policies{}, fmt.Errorf("permissions: %w: %s", e, perm.Subject)
+		}
+		obj, e := types.ParseObject(perm.Object)
+		if e != nil {
+			return types.Policies{}, fmt.Errorf("permissions: %w: %s", e, perm.Object)
+		}
+		act, e := parseActions(perm.Actions)
+		if e != nil {
+			return types.Policies{}, fmt.Errorf("permissions: %s on %s: %w", perm.Subject, perm.Object, e)
+		}
+
+		key := permissionKey{sub: sub, obj: obj}
+		if i, ok := index[key]; ok {
+			ps.Permissions[i].Action |= act
+			continue
+		}
+		index[key] = len(ps.Permissions)
+		ps.Permissions = append(ps.Permissions, types.PermissionPolicy{Subject: sub, Object: obj, Action: act})
+	}
+
+	return ps, nil
+}
+
+type permissionKey struct {
+	sub types.Subject
+	obj types.Object
+}
+
+func parseGroupings(gs []Grouping, valid func(types.Entity, types.Group) bool) ([]types.GroupingPolicy, error) {
+	polices := make([]types.GroupingPolicy, 0, len(gs))
+	seen := make(map[types.GroupingPolicy]struct{}, len(gs))
+
+	for _, g := range gs {
+		ent, e := types.ParseEntity(g.Entity)
+		if e != nil {
+			return nil, fmt.Errorf("%w: %s", e, g.Entity)
+		}
+		group, e := types.ParseGroup(g.Group)
+		if e != nil {
+			return nil, fmt.Errorf("%w: %s", e, g.Group)
+		}
+		if !valid(ent, group) {
+			return nil, fmt.Errorf("%w: %s in %s", types.ErrInvalidEntity, g.Entity, g.Group)
+		}
+
+		policy := types.GroupingPolicy{Entity: ent, Group: group}
+		if _, ok := seen[policy]; ok {
+			continue
+		}
+		seen[policy] = struct{}{}
+		polices = append(polices, policy)
+	}
+
+	return polices, nil
+}
+
+// actionNames splits act into names of single actions
+func actionNames(act types.Action) []string {
+	names := make([]string, 0)
+	for _, a := range act.Split() {
+		names = append(names, a.String())
+	}
+	return names
+}
+
+func parseActions(names []string) (types.Action, error) {
+	if len(names) == 0 {
+		return 0, fmt.Errorf("%w: no action", types.ErrUnknownAction)
+	}
+
+	var act types.Action
+	for _, name := range names {
+		a, e := types.ParseAction(name)
+		if e != nil {
+			return 0, e
+		}
+		act |= a
+	}
+	return act, nil
+}
+
+// Encode writes the document to w in format
+func (d *Document) Encode(w io.Writer, format Format) error {
+	switch format {
+	case JSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	case YAML:
+		content, e := yaml.Marshal(d)
+		if e != nil {
+			return e
+		}
+		_, e = w.Write(content)
+		return e
+	}
+	return fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+}
+
+// Decode reads a document in JSON or YAML from r
+func Decode(r io.Reader) (*Document, error) {
+	doc := &Document{}
+	if e := decode(r, doc); e != nil {
+		return nil, e
+	}
+	if doc.Version != Version {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, doc.Version)
+	}
+	return doc, nil
+}
+
+// decode reads v in JSON or YAML from r, unknown fields are rejected
+func decode(r io.Reader, v interface{}) error {
+	content, e := ioutil.ReadAll(r)
+	if e != nil {
+		return e
+	}
+
+	if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && trimmed[0] == '{' {
+		dec := json.NewDecoder(bytes.NewReader(content))
+		dec.DisallowUnknownFields()
+		return dec.Decode(v)
+	}
+	return yaml.UnmarshalStrict(content, v)
+}
@@ -0,0 +1,25 @@
+package policy
+
+import (
+	"io"
+
+	"github.com/supremind/rbac/types"
+)
+
+// Export writes all polices assigned directly in authz to w,
+// authz should be a types.PolicyLister, like the Authorizers created by rbac.New
+func Export(w io.Writer, authz types.Authorizer, format Format) error {
+	ps, e := policiesOf(authz)
+	if e != nil {
+		return e
+	}
+	return NewDocument(ps).Encode(w, format)
+}
+
+func policiesOf(authz types.Authorizer) (types.Policies, error) {
+	l, ok := authz.(types.PolicyLister)
+	if !ok {
+		return types.Policies{}, types.ErrNoPolicyList
+	}
+	return l.Policies()
+}
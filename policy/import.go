@@ -0,0 +1,236 @@
+package policy
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/supremind/rbac/types"
+)
+
+// Mode controls how documents are imported
+type Mode uint8
+
+// import modes, DryRun works together with Merge or Replace, like Replace|DryRun
+const (
+	// Merge adds polices in the document, and keeps others
+	Merge Mode = 0
+
+	// Replace makes polices be exactly the same as the document, polices not in it are removed
+	Replace Mode = 1 << iota
+
+	// DryRun reports what would be changed, without changing anything
+	DryRun
+)
+
+// Report tells what are changed by an import, or what would be changed in dry-run mode
+type Report struct {
+	DryRun      bool               `json:"dry_run" yaml:"dry_run"`
+	Subjects    GroupingChanges    `json:"subjects" yaml:"subjects"`
+	Objects     GroupingChanges    `json:"objects" yaml:"objects"`
+	Permissions []PermissionChange `json:"permissions" yaml:"permissions"`
+}
+
+// GroupingChanges are grouping polices joined or left
+type GroupingChanges struct {
+	Joined []Grouping `json:"joined" yaml:"joined"`
+	Left   []Grouping `json:"left" yaml:"left"`
+}
+
+// PermissionChange is a change of actions permitted to subject on object, empty actions mean no permission
+type PermissionChange struct {
+	Subject string   `json:"subject" yaml:"subject"`
+	Object  string   `json:"object" yaml:"object"`
+	Before  []string `json:"before" yaml:"before"`
+	After   []string `json:"after" yaml:"after"`
+}
+
+// Empty tells if nothing is changed
+func (r *Report) Empty() bool {
+	return len(r.Subjects.Joined) == 0 && len(r.Subjects.Left) == 0 &&
+		len(r.Objects.Joined) == 0 && len(r.Objects.Left) == 0 &&
+		len(r.Permissions) == 0
+}
+
+// Import reads a document from r, and applies it to authz in mode,
+// authz should be a types.PolicyLister, like the Authorizers created by rbac.New.
+// The document is validated before any change is made,
+// but changes applied before a failed one are kept, importing the document again finishes the rest.
+func Import(r io.Reader, authz types.Authorizer, mode Mode) (*Report, error) {
+	doc, e := Decode(r)
+	if e != nil {
+		return nil, e
+	}
+	return Apply(doc, authz, mode)
+}
+
+// Apply applies the document to authz in mode, like Import does
+func Apply(doc *Document, authz types.Authorizer, mode Mode) (*Report, error) {
+	desired, e := doc.Policies()
+	if e != nil {
+		return nil, e
+	}
+	current, e := policiesOf(authz)
+	if e != nil {
+		return nil, e
+	}
+
+	var removable types.Policies
+	if mode&Replace != 0 {
+		removable = current
+	}
+	p := plan(current, desired, removable)
+	if mode&DryRun != 0 {
+		return p.report(true), nil
+	}
+	if e := p.apply(authz); e != nil {
+		return nil, e
+	}
+	return p.report(false), nil
+}
+
+// changes to be applied
+type changes struct {
+	subjectJoins, subjectLeaves []types.GroupingPolicy
+	objectJoins, objectLeaves   []types.GroupingPolicy
+	permissions                 []permissionChange
+}
+
+type permissionChange struct {
+	sub           types.Subject
+	obj           types.Object
+	before, after types.Action
+}
+
+// plan changes current polices to desired ones, polices not desired are removed only if they are removable
+func plan(current, desired, removable types.Policies) *changes {
+	c := &changes{}
+	c.subjectJoins, c.subjectLeaves = planGroupings(current.Subjects, desired.Subjects, removable.Subjects)
+	c.objectJoins, c.objectLeaves = planGroupings(current.Objects, desired.Objects, removable.Objects)
+
+	before, after, removed := actionsByKey(current.Permissions), actionsByKey(desired.Permissions), actionsByKey(removable.Permissions)
+	keys := make(map[permissionKey]struct{}, len(before)+len(after))
+	for key := range before {
+		keys[key] = struct{}{}
+	}
+	for key := range after {
+		keys[key] = struct{}{}
+	}
+
+	for key := range keys {
+		act := after[key] | before[key].Difference(removed[key])
+		if act != before[key] {
+			c.permissions = append(c.permissions, permissionChange{sub: key.sub, obj: key.obj, before: before[key], after: act})
+		}
+	}
+	sort.Slice(c.permissions, func(i, j int) bool {
+		a, b := c.permissions[i], c.permissions[j]
+		if a.sub.String() != b.sub.String() {
+			return a.sub.String() < b.sub.String()
+		}
+		return a.obj.String() < b.obj.String()
+	})
+
+	return c
+}
+
+func actionsByKey(polices []types.PermissionPolicy) map[permissionKey]types.Action {
+	acts := make(map[permissionKey]types.Action, len(polices))
+	for _, policy := range polices {
+		acts[permissionKey{sub: policy.Subject, obj: policy.Object}] |= policy.Action
+	}
+	return acts
+}
+
+func planGroupings(current, desired, removable []types.GroupingPolicy) (joins, leaves []types.GroupingPolicy) {
+	existing := make(map[types.GroupingPolicy]struct{}, len(current))
+	for _, policy := range current {
+		existing[policy] = struct{}{}
+	}
+	wanted := make(map[types.GroupingPolicy]struct{}, len(desired))
+	for _, policy := range desired {
+		wanted[policy] = struct{}{}
+		if _, ok := existing[policy]; !ok {
+			joins = append(joins, policy)
+		}
+	}
+	for _, policy := range removable {
+		if _, ok := wanted[policy]; ok {
+			continue
+		}
+		if _, ok := existing[policy]; ok {
+			leaves = append(leaves, policy)
+		}
+	}
+	return joins, leaves
+}
+
+func (c *changes) empty() bool {
+	return len(c.subjectJoins) == 0 && len(c.subjectLeaves) == 0 &&
+		len(c.objectJoins) == 0 && len(c.objectLeaves) == 0 &&
+		len(c.permissions) == 0
+}
+
+// apply joins groupings first, then changes permissions, and leaves groupings at last
+func (c *changes) apply(authz types.Authorizer) error {
+	for _, policy := range c.subjectJoins {
+		if e := authz.SubjectJoin(policy.Entity.(types.Subject), policy.Group.(types.Role)); e != nil {
+			return fmt.Errorf("join %s to %s: %w", policy.Entity, policy.Group, e)
+		}
+	}
+	for _, policy := range c.objectJoins {
+		if e := authz.ObjectJoin(policy.Entity.(types.Object), policy.Group.(types.Category)); e != nil {
+			return fmt.Errorf("join %s to %s: %w", policy.Entity, policy.Group, e)
+		}
+	}
+
+	for _, change := range c.permissions {
+		if added := change.after.Difference(change.before); added != 0 {
+			if e := authz.Permit(change.sub, change.obj, added); e != nil {
+				return fmt.Errorf("permit %s to %s on %s: %w", change.sub, added, change.obj, e)
+			}
+		}
+		if removed := change.before.Difference(change.after); removed != 0 {
+			if e := authz.Revoke(change.sub, change.obj, removed); e != nil {
+				return fmt.Errorf("revoke %s from %s on %s: %w", removed, change.sub, change.obj, e)
+			}
+		}
+	}
+
+	for _, policy := range c.subjectLeaves {
+		if e := authz.SubjectLeave(policy.Entity.(types.Subject), policy.Group.(types.Role)); e != nil {
+			return fmt.Errorf("leave %s from %s: %w", policy.Entity, policy.Group, e)
+		}
+	}
+	for _, policy := range c.objectLeaves {
+		if e := authz.ObjectLeave(policy.Entity.(types.Object), policy.Group.(types.Category)); e != nil {
+			return fmt.Errorf("leave %s from %s: %w", policy.Entity, policy.Group, e)
+		}
+	}
+
+	return nil
+}
+
+func (c *changes) report(dryRun bool) *Report {
+	r := &Report{
+		DryRun: dryRun,
+		Subjects: GroupingChanges{
+			Joined: groupingsOf(c.subjectJoins),
+			Left:   groupingsOf(c.subjectLeaves),
+		},
+		Objects: GroupingChanges{
+			Joined: groupingsOf(c.objectJoins),
+			Left:   groupingsOf(c.objectLeaves),
+		},
+		Permissions: make([]PermissionChange, 0, len(c.permissions)),
+	}
+	for _, change := range c.permissions {
+		r.Permissions = append(r.Permissions, PermissionChange{
+			Subject: change.sub.String(),
+			Object:  change.obj.String(),
+			Before:  actionNames(change.before),
+			After:   actionNames(change.after),
+		})
+	}
+	return r
+}
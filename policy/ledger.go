@@ -0,0 +1,115 @@
+package policy
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/supremind/rbac/types"
+)
+
+// Ledger keeps polices owned by manifests
+type Ledger interface {
+	// Owned returns polices owned by owner, or empty polices if nothing is owned
+	Owned(owner string) (types.Policies, error)
+
+	// Own makes owner own exactly the polices
+	Own(owner string, ps types.Policies) error
+}
+
+// memoryLedger keeps owned polices in memory, it is useful for tests, and processes reconciling only once
+type memoryLedger struct {
+	owned map[string]types.Policies
+	sync.RWMutex
+}
+
+// NewMemoryLedger creates a Ledger in memory
+func NewMemoryLedger() Ledger {
+	return &memoryLedger{owned: make(map[string]types.Policies)}
+}
+
+func (l *memoryLedger) Owned(owner string) (types.Policies, error) {
+	l.RLock()
+	defer l.RUnlock()
+	return l.owned[owner], nil
+}
+
+func (l *memoryLedger) Own(owner string, ps types.Policies) error {
+	l.Lock()
+	defer l.Unlock()
+	l.owned[owner] = ps
+	return nil
+}
+
+// fileLedger keeps owned polices in a JSON file, as documents keyed by owners
+type fileLedger struct {
+	path string
+	sync.Mutex
+}
+
+// NewFileLedger creates a Ledger kept in the file at path, the file is created on the first Own
+func NewFileLedger(path string) Ledger {
+	return &fileLedger{path: path}
+}
+
+func (l *fileLedger) Owned(owner string) (types.Policies, error) {
+	l.Lock()
+	defer l.Unlock()
+
+	docs, e := l.load()
+	if e != nil {
+		return types.Policies{}, e
+	}
+	doc, ok := docs[owner]
+	if !ok {
+		return types.Policies{}, nil
+	}
+	return doc.Policies()
+}
+
+func (l *fileLedger) Own(owner string, ps types.Policies) error {
+	l.Lock()
+	defer l.Unlock()
+
+	docs, e := l.load()
+	if e != nil {
+		return e
+	}
+	docs[owner] = NewDocument(ps)
+
+	content, e := json.MarshalIndent(docs, "", "  ")
+	if e != nil {
+		return e
+	}
+	content = append(content, '\n')
+
+	tmp, e := ioutil.TempFile(filepath.Dir(l.path), filepath.Base(l.path)+".*")
+	if e != nil {
+		return e
+	}
+	defer os.Remove(tmp.Name())
+	if _, e := tmp.Write(content); e != nil {
+		tmp.Close()
+		return e
+	}
+	if e := tmp.Close(); e != nil {
+		return e
+	}
+	return os.Rename(tmp.Name(), l.path)
+}
+
+func (l *fileLedger) load() (map[string]*Document, error) {
+	docs := make(map[string]*Document)
+	content, e := ioutil.ReadFile(l.path)
+	if os.IsNotExist(e) {
+		return docs, nil
+	} else if e != nil {
+		return nil, e
+	}
+	if e := json.Unmarshal(content, &docs); e != nil {
+		return nil, e
+	}
+	return docs, nil
+}
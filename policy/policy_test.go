@@ -0,0 +1,261 @@
+package policy_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/supremind/rbac"
+	"github.com/supremind/rbac/persist/fake"
+	"github.com/supremind/rbac/policy"
+	. "github.com/supremind/rbac/types"
+)
+
+func TestPolicy(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "policy test suit")
+}
+
+func newAuthorizer(opts ...rbac.AuthorizerOption) Authorizer {
+	authz, e := rbac.New(context.Background(), append([]rbac.AuthorizerOption{
+		rbac.WithSubjectPersister(fake.NewGroupingPersister()),
+		rbac.WithObjectPersister(fake.NewGroupingPersister()),
+		rbac.WithPermissionPersister(fake.NewPermissionPersister()),
+	}, opts...)...)
+	Expect(e).To(Succeed())
+	return authz
+}
+
+func listPolicies(authz Authorizer) Policies {
+	ps, e := authz.(PolicyLister).Policies()
+	Expect(e).To(Succeed())
+	return ps
+}
+
+const document = `
+version: 1
+subjects:
+- entity: role:writer
+  group: role:editor
+- entity: user:alan
+  group: role:writer
+objects:
+- entity: art:apollo
+  group: cat:news
+permissions:
+- subject: role:editor
+  object: cat:news
+  actions: [read]
+- subject: user:alan
+  object: art:apollo
+  actions: [write, read]
+`
+
+var _ = Describe("policy documents", func() {
+	for name, opts := range map[string][]rbac.AuthorizerOption{
+		"locked":        nil,
+		"copy on write": {rbac.WithCopyOnWrite()},
+	} {
+		opts := opts
+
+		Describe("importing and exporting with "+name+" authorizer", func() {
+			var authz Authorizer
+
+			BeforeEach(func() {
+				authz = newAuthorizer(opts...)
+				Expect(authz.SubjectJoin(User("edison"), Role("writer"))).To(Succeed())
+				Expect(authz.Permit(User("alan"), Article("apollo"), Exec)).To(Succeed())
+			})
+
+			It("should merge polices", func() {
+				report, e := policy.Import(strings.NewReader(document), authz, policy.Merge)
+				Expect(e).To(Succeed())
+				Expect(report.DryRun).To(BeFalse())
+				Expect(report.Subjects.Joined).To(Equal([]policy.Grouping{
+					{Entity: "role:writer", Group: "role:editor"},
+					{Entity: "user:alan", Group: "role:writer"},
+				}))
+				Expect(report.Subjects.Left).To(BeEmpty())
+				Expect(report.Permissions).To(ConsistOf(
+					policy.PermissionChange{Subject: "role:editor", Object: "cat:news", Before: []string{}, After: []string{"read"}},
+					policy.PermissionChange{Subject: "user:alan", Object: "art:apollo", Before: []string{"exec"}, After: []string{"exec", "write", "read"}},
+				))
+
+				Expect(authz.Shall(User("edison"), Article("apollo"), Read)).To(BeTrue())
+				Expect(authz.Shall(User("alan"), Article("apollo"), ReadWriteExec)).To(BeTrue())
+
+				By("importing again changes nothing")
+				report, e = policy.Import(strings.NewReader(document), authz, policy.Merge)
+				Expect(e).To(Succeed())
+				Expect(report.Empty()).To(BeTrue())
+			})
+
+			It("should replace polices", func() {
+				report, e := policy.Import(strings.NewReader(document), authz, policy.Replace)
+				Expect(e).To(Succeed())
+				Expect(report.Subjects.Left).To(Equal([]policy.Grouping{{Entity: "user:edison", Group: "role:writer"}}))
+
+				Expect(authz.Shall(User("edison"), Article("apollo"), Read)).To(BeFalse())
+				Expect(authz.PermittedActions(User("alan"), Article("apollo"))).To(Equal(ReadWrite))
+
+				var exported bytes.Buffer
+				Expect(policy.Export(&exported, authz, policy.YAML)).To(Succeed())
+				Expect(exported.String()).To(MatchYAML(document))
+			})
+
+			It("should change nothing in dry-run mode", func() {
+				before := listPolicies(authz)
+
+				report, e := policy.Import(strings.NewReader(document), authz, policy.Replace|policy.DryRun)
+				Expect(e).To(Succeed())
+				Expect(report.DryRun).To(BeTrue())
+				Expect(report.Subjects.Left).To(HaveLen(1))
+				Expect(report.Permissions).To(HaveLen(2))
+
+				after := listPolicies(authz)
+				Expect(after.Subjects).To(ConsistOf(before.Subjects))
+				Expect(after.Permissions).To(ConsistOf(before.Permissions))
+			})
+		})
+	}
+
+	It("should round trip through JSON", func() {
+		src := newAuthorizer()
+		_, e := policy.Import(strings.NewReader(document), src, policy.Merge)
+		Expect(e).To(Succeed())
+
+		var exported bytes.Buffer
+		Expect(policy.Export(&exported, src, policy.JSON)).To(Succeed())
+		Expect(exported.String()).To(ContainSubstring(`"actions": [`))
+
+		dst := newAuthorizer()
+		_, e = policy.Import(&exported, dst, policy.Replace)
+		Expect(e).To(Succeed())
+
+		s, d := listPolicies(src), listPolicies(dst)
+		Expect(d.Subjects).To(ConsistOf(s.Subjects))
+		Expect(d.Objects).To(ConsistOf(s.Objects))
+		Expect(d.Permissions).To(ConsistOf(s.Permissions))
+	})
+
+	It("should reject invalid documents without changing anything", func() {
+		authz := newAuthorizer()
+		for _, doc := range []string{
+			"version: 2\n",
+			"subjects: []\n",
+			"version: 1\nsubjects:\n- entity: art:apollo\n  group: role:editor\n",
+			"version: 1\npermissions:\n- subject: user:alan\n  object: art:apollo\n  actions: [fly]\n",
+			"version: 1\npermissions:\n- subject: user:alan\n  object: art:apollo\n  actions: []\n",
+			"version: 1\nsubject: []\n",
+		} {
+			_, e := policy.Import(strings.NewReader(doc), authz, policy.Merge)
+			Expect(e).To(HaveOccurred(), doc)
+		}
+		_, e := policy.Import(strings.NewReader("version: 2\n"), authz, policy.Merge)
+		Expect(e).To(MatchError(policy.ErrUnsupportedVersion))
+
+		ps := listPolicies(authz)
+		Expect(ps.Subjects).To(BeEmpty())
+		Expect(ps.Permissions).To(BeEmpty())
+	})
+})
+
+const manifest = `
+version: 1
+owner: platform
+subjects:
+- entity: user:alan
+  group: role:editor
+permissions:
+- subject: role:editor
+  object: cat:news
+  actions: [read]
+`
+
+var _ = Describe("reconciling manifests", func() {
+	var (
+		authz  Authorizer
+		ledger policy.Ledger
+		dir    string
+	)
+
+	BeforeEach(func() {
+		var e error
+		dir, e = ioutil.TempDir("", "policy-")
+		Expect(e).To(Succeed())
+
+		authz = newAuthorizer()
+		ledger = policy.NewFileLedger(filepath.Join(dir, "ledger.json"))
+
+		// created in runtime
+		Expect(authz.SubjectJoin(User("edison"), Role("editor"))).To(Succeed())
+		Expect(authz.Permit(Role("editor"), Category("news"), Write)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	reconcile := func(content string, apply bool) *policy.Plan {
+		m, e := policy.DecodeManifest(strings.NewReader(content))
+		Expect(e).To(Succeed())
+		r := policy.NewReconciler(authz, ledger)
+		plan, e := r.Reconcile(m)
+		Expect(e).To(Succeed())
+		if apply {
+			_, e = r.Apply(plan)
+			Expect(e).To(Succeed())
+		}
+		return plan
+	}
+
+	It("should converge polices with minimal changes", func() {
+		plan := reconcile(manifest, false)
+		Expect(plan.Owner).To(Equal("platform"))
+		report := plan.Report()
+		Expect(report.Subjects.Joined).To(Equal([]policy.Grouping{{Entity: "user:alan", Group: "role:editor"}}))
+		Expect(report.Subjects.Left).To(BeEmpty())
+		Expect(report.Permissions).To(Equal([]policy.PermissionChange{
+			{Subject: "role:editor", Object: "cat:news", Before: []string{"write"}, After: []string{"write", "read"}},
+		}))
+		Expect(authz.Shall(User("alan"), Category("news"), Read)).To(BeFalse())
+
+		reconcile(manifest, true)
+		Expect(authz.Shall(User("alan"), Category("news"), ReadWrite)).To(BeTrue())
+		Expect(reconcile(manifest, false).Empty()).To(BeTrue())
+	})
+
+	It("should remove owned polices only", func() {
+		reconcile(manifest, true)
+
+		plan := reconcile("version: 1\nowner: platform\n", true)
+		Expect(plan.Report().Subjects.Left).To(Equal([]policy.Grouping{{Entity: "user:alan", Group: "role:editor"}}))
+
+		Expect(authz.Shall(User("alan"), Category("news"), Read)).To(BeFalse())
+		Expect(authz.Shall(User("edison"), Category("news"), Write)).To(BeTrue())
+		Expect(authz.Shall(User("edison"), Category("news"), Read)).To(BeFalse())
+
+		By("polices of other owners are kept")
+		reconcile(manifest, true)
+		reconcile("version: 1\nowner: others\n", true)
+		Expect(authz.Shall(User("alan"), Category("news"), ReadWrite)).To(BeTrue())
+	})
+
+	It("should take over polices created in runtime once declared", func() {
+		reconcile("version: 1\nowner: platform\nsubjects:\n- entity: user:edison\n  group: role:editor\n", true)
+		plan := reconcile("version: 1\nowner: platform\n", true)
+		Expect(plan.Report().Subjects.Left).To(Equal([]policy.Grouping{{Entity: "user:edison", Group: "role:editor"}}))
+	})
+
+	It("should reject manifests without owners", func() {
+		_, e := policy.DecodeManifest(strings.NewReader("version: 1\n"))
+		Expect(e).To(MatchError(policy.ErrNoOwner))
+	})
+})
@@ -0,0 +1,152 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/supremind/rbac/types"
+)
+
+// ErrNoOwner is returned when reconciling manifests without owners
+var ErrNoOwner = errors.New("manifest has no owner")
+
+// Manifest declares polices managed by its owner, like:
+//
+//	version: 1
+//	owner: platform
+//	subjects:
+//	- entity: user:alan
+//	  group: role:editor
+//	permissions:
+//	- subject: role:editor
+//	  object: cat:news
+//	  actions: [read, write]
+//
+// Polices in the manifest are owned by the owner once they are reconciled, even if they existed before.
+// Owned polices removed from the manifest are removed by the next reconciliation,
+// while polices created in runtime, by others, are kept.
+// Manifests of different owners should not declare the same polices.
+type Manifest struct {
+	Owner    string `json:"owner" yaml:"owner"`
+	Document `yaml:",inline"`
+}
+
+// DecodeManifest reads a manifest in JSON or YAML from r
+func DecodeManifest(r io.Reader) (*Manifest, error) {
+	m := &Manifest{}
+	if e := decode(r, m); e != nil {
+		return nil, e
+	}
+	if m.Version != Version {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, m.Version)
+	}
+	if m.Owner == "" {
+		return nil, ErrNoOwner
+	}
+	return m, nil
+}
+
+// Reconciler converges polices in an Authorizer to manifests
+type Reconciler struct {
+	authz  types.Authorizer
+	ledger Ledger
+}
+
+// NewReconciler creates a Reconciler, authz should be a types.PolicyLister, like the Authorizers created by rbac.New,
+// and the ledger keeps polices owned by manifests, it should be shared by all deployments reconciling the same polices
+func NewReconciler(authz types.Authorizer, ledger Ledger) *Reconciler {
+	return &Reconciler{authz: authz, ledger: ledger}
+}
+
+// Plan is the minimal set of changes converging polices to a manifest
+type Plan struct {
+	Owner   string
+	changes *changes
+	owned   types.Policies
+}
+
+// Empty tells if polices are already converged
+func (p *Plan) Empty() bool {
+	return p.changes.empty()
+}
+
+// Report tells what would be changed by applying the plan
+func (p *Plan) Report() *Report {
+	return p.changes.report(true)
+}
+
+// Reconcile computes the plan converging current polices to the manifest,
+// nothing is changed until the plan is applied
+func (r *Reconciler) Reconcile(m *Manifest) (*Plan, error) {
+	if m.Owner == "" {
+		return nil, ErrNoOwner
+	}
+
+	desired, e := m.Policies()
+	if e != nil {
+		return nil, e
+	}
+	current, e := policiesOf(r.authz)
+	if e != nil {
+		return nil, e
+	}
+	owned, e := r.ledger.Owned(m.Owner)
+	if e != nil {
+		return nil, fmt.Errorf("polices owned by %s: %w", m.Owner, e)
+	}
+
+	return &Plan{
+		Owner:   m.Owner,
+		changes: plan(current, desired, owned),
+		owned:   desired,
+	}, nil
+}
+
+// Apply applies the plan, and records polices in the manifest as owned.
+// Plans are computed against polices at the time, they should be applied soon after reconciled.
+// If it fails halfway, reconciling and applying again finishes the rest.
+func (r *Reconciler) Apply(p *Plan) (*Report, error) {
+	previous, e := r.ledger.Owned(p.Owner)
+	if e != nil {
+		return nil, fmt.Errorf("polices owned by %s: %w", p.Owner, e)
+	}
+	// polices about to be created are owned before hand, so they could be removed even if applying fails halfway
+	if e := r.ledger.Own(p.Owner, union(previous, p.owned)); e != nil {
+		return nil, fmt.Errorf("own polices by %s: %w", p.Owner, e)
+	}
+
+	if e := p.changes.apply(r.authz); e != nil {
+		return nil, e
+	}
+
+	if e := r.ledger.Own(p.Owner, p.owned); e != nil {
+		return nil, fmt.Errorf("own polices by %s: %w", p.Owner, e)
+	}
+	return p.changes.report(false), nil
+}
+
+// union merges polices in a and b
+func union(a, b types.Policies) types.Policies {
+	ps := types.Policies{
+		Subjects: uniqueGroupings(append(append([]types.GroupingPolicy{}, a.Subjects...), b.Subjects...)),
+		Objects:  uniqueGroupings(append(append([]types.GroupingPolicy{}, a.Objects...), b.Objects...)),
+	}
+	for key, act := range actionsByKey(append(append([]types.PermissionPolicy{}, a.Permissions...), b.Permissions...)) {
+		ps.Permissions = append(ps.Permissions, types.PermissionPolicy{Subject: key.sub, Object: key.obj, Action: act})
+	}
+	return ps
+}
+
+func uniqueGroupings(polices []types.GroupingPolicy) []types.GroupingPolicy {
+	seen := make(map[types.GroupingPolicy]struct{}, len(polices))
+	unique := polices[:0]
+	for _, policy := range polices {
+		if _, ok := seen[policy]; ok {
+			continue
+		}
+		seen[policy] = struct{}{}
+		unique = append(unique, policy)
+	}
+	return unique
+}
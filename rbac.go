@@ -6,37 +6,109 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/go-logr/stdr"
 	"github.com/supremind/rbac/internal/authorizer"
+	"github.com/supremind/rbac/internal/decision"
+	"github.com/supremind/rbac/internal/effective"
 	"github.com/supremind/rbac/internal/grouping"
+	"github.com/supremind/rbac/internal/history"
 	"github.com/supremind/rbac/internal/permission"
+	"github.com/supremind/rbac/internal/snapshot"
+	"github.com/supremind/rbac/persist"
 	"github.com/supremind/rbac/types"
 )
 
-// New creates a RBAC Authorizer
+// New creates a RBAC Authorizer, it implements types.PolicyLister as well
 func New(ctx context.Context, opts ...AuthorizerOption) (types.Authorizer, error) {
+	return newAuthorizer(ctx, opts...)
+}
+
+// NewContext creates a RBAC Authorizer, whose methods accept contexts
+func NewContext(ctx context.Context, opts ...AuthorizerOption) (types.AuthorizerContext, error) {
+	return newAuthorizer(ctx, opts...)
+}
+
+func newAuthorizer(ctx context.Context, opts ...AuthorizerOption) (authorizer.Authorizer, error) {
 	cfg := &AuthorizerConfig{}
 	for _, opt := range opts {
 		opt(cfg)
 	}
 
-	if cfg.log == logr.Discard() {
+	if cfg.log == (logr.Logger{}) {
 		cfg.log = stdr.New(log.New(os.Stderr, "", log.LstdFlags|log.Lshortfile))
 	}
 
+	// the index is told about changes before the decision cache, so that decisions are not cached from outdated cells
+	var groupingObservers []grouping.Observer
+	var permissionObservers []permission.Observer
+	var index *effective.Index
+	if cfg.index > 0 {
+		index = effective.New(cfg.index)
+		groupingObservers = append(groupingObservers, index)
+		permissionObservers = append(permissionObservers, index)
+	}
+	var decisions *decision.Cache
+	if cfg.decisions > 0 {
+		decisions = decision.New(cfg.decisions)
+		groupingObservers = append(groupingObservers, decisions)
+		permissionObservers = append(permissionObservers, decisions)
+	}
+
+	var snap *snapshot.File
+	if sc := cfg.snapshot; sc != nil {
+		var e error
+		snap, e = snapshot.Open(sc.path)
+		if errors.Is(e, snapshot.ErrFormat) {
+			cfg.log.Error(e, "ignore the snapshot, polices are listed instead", "path", sc.path)
+		} else if e != nil {
+			return nil, fmt.Errorf("open snapshot failed: %w", e)
+		}
+	}
+
+	newGroupingConfig := func(component string) grouping.Config {
+		gc := grouping.Config{CopyOnWrite: cfg.cow, Compact: cfg.compact, Shards: cfg.shards, Metrics: cfg.metrics, Component: component, Tracer: cfg.tracer, Observers: groupingObservers}
+		if h := cfg.history; h != nil {
+			gc.History = history.New(h.retention, h.limit)
+		}
+		return gc
+	}
+	newPermissionConfig := func(component string) permission.Config {
+		pc := permission.Config{CopyOnWrite: cfg.cow, Compact: cfg.compact, Metrics: cfg.metrics, Component: component, Tracer: cfg.tracer, Observers: permissionObservers}
+		if h := cfg.history; h != nil {
+			pc.History = history.New(h.retention, h.limit)
+		}
+		return pc
+	}
+
 	var sg, og types.Grouping
 	if cfg.sp != nil {
 		var e error
-		sg, e = grouping.New(ctx, cfg.sp, cfg.log.WithName("subject"))
+		gc := newGroupingConfig("subject")
+		if snap != nil {
+			gc.Snapshot = snap.Subjects
+		}
+		sg, e = grouping.New(ctx, cfg.sp, cfg.log.WithName("subject"), gc)
 		if e != nil {
 			return nil, fmt.Errorf("init subject grouping failed: %w", e)
 		}
 	}
 	if cfg.op != nil {
 		var e error
-		og, e = grouping.New(ctx, cfg.op, cfg.log.WithName("object"))
+		gc := newGroupingConfig("object")
+		if snap != nil {
+			gc.Snapshot = snap.Objects
+		}
+		if cfg.lazy > 0 {
+			q, ok := persist.GroupingQuerierOf(cfg.op)
+			if !ok {
+				return nil, errors.New("object persister could not query polices")
+			}
+			gc.Lazy, gc.LazySize = q, cfg.lazy
+		}
+		og, e = grouping.New(ctx, cfg.op, cfg.log.WithName("object"), gc)
 		if e != nil {
 			return nil, fmt.Errorf("init object grouping failed: %w", e)
 		}
@@ -45,7 +117,18 @@ func New(ctx context.Context, opts ...AuthorizerOption) (types.Authorizer, error
 	var p types.Permission
 	if cfg.pp != nil {
 		var e error
-		p, e = permission.New(ctx, cfg.pp, cfg.log.WithName("permission"))
+		pc := newPermissionConfig("permission")
+		if snap != nil {
+			pc.Snapshot = snap.Permissions
+		}
+		if cfg.lazy > 0 {
+			q, ok := persist.PermissionQuerierOf(cfg.pp)
+			if !ok {
+				return nil, errors.New("permission persister could not query polices")
+			}
+			pc.Lazy, pc.LazySize = q, cfg.lazy
+		}
+		p, e = permission.New(ctx, cfg.pp, cfg.log.WithName("permission"), pc)
 		if e != nil {
 			return nil, fmt.Errorf("init permission failed: %w", e)
 		}
@@ -53,14 +136,63 @@ func New(ctx context.Context, opts ...AuthorizerOption) (types.Authorizer, error
 		return nil, errors.New("empty permission persister")
 	}
 
-	authz := authorizer.New(sg, og, p, cfg.log.WithName("authorizer"), cfg.presets...)
+	// members are written in parallel only if groupings are sharded
+	shards := cfg.shards
+	if cfg.compact {
+		shards = 0
+	}
+
+	authz := authorizer.New(sg, og, p, cfg.log.WithName("authorizer"), authorizer.Config{
+		Presets:       cfg.presets,
+		LockFree:      cfg.cow || cfg.history != nil,
+		Shards:        shards,
+		AuditSink:     cfg.auditSink,
+		AuditDecision: cfg.auditDecision,
+		Metrics:       cfg.metrics,
+		Tracer:        cfg.tracer,
+		Index:         index,
+		Decisions:     decisions,
+	})
+
+	if snap != nil {
+		go saveSnapshots(ctx, snap, cfg.snapshot.interval, cfg.log.WithName("snapshot"))
+	}
 
 	return authz, nil
 }
 
+// saveSnapshots saves the snapshot every interval, and once more when ctx is done
+func saveSnapshots(ctx context.Context, snap *snapshot.File, interval time.Duration, l logr.Logger) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		tick = t.C
+	}
+
+	for {
+		select {
+		case <-tick:
+			if e := snap.Save(); e != nil {
+				l.Error(e, "save snapshot")
+			}
+		case <-ctx.Done():
+			if e := snap.Save(); e != nil {
+				l.Error(e, "save snapshot")
+			}
+			return
+		}
+	}
+}
+
 // WithSubjectPersister sets Persister for subject
 // could be omitted if subject grouping is not used: no roles, only users
 func WithSubjectPersister(p types.GroupingPersister) AuthorizerOption {
+	return WithSubjectPersisterContext(persist.GroupingWithContext(p))
+}
+
+// WithSubjectPersisterContext sets context-aware Persister for subject
+func WithSubjectPersisterContext(p types.GroupingPersisterContext) AuthorizerOption {
 	return func(cfg *AuthorizerConfig) {
 		cfg.sp = p
 	}
@@ -69,6 +201,11 @@ func WithSubjectPersister(p types.GroupingPersister) AuthorizerOption {
 // WithObjectPersister sets Persister for object
 // could be omitted if object grouping is not used: no rules on categories
 func WithObjectPersister(p types.GroupingPersister) AuthorizerOption {
+	return WithObjectPersisterContext(persist.GroupingWithContext(p))
+}
+
+// WithObjectPersisterContext sets context-aware Persister for object
+func WithObjectPersisterContext(p types.GroupingPersisterContext) AuthorizerOption {
 	return func(cfg *AuthorizerConfig) {
 		cfg.op = p
 	}
@@ -77,6 +214,11 @@ func WithObjectPersister(p types.GroupingPersister) AuthorizerOption {
 // WithPermissionPersister sets Persister for Permission manager
 // all permission polices will be lost after restart if not set
 func WithPermissionPersister(p types.PermissionPersister) AuthorizerOption {
+	return WithPermissionPersisterContext(persist.PermissionWithContext(p))
+}
+
+// WithPermissionPersisterContext sets context-aware Persister for Permission manager
+func WithPermissionPersisterContext(p types.PermissionPersisterContext) AuthorizerOption {
 	return func(cfg *AuthorizerConfig) {
 		cfg.pp = p
 	}
@@ -96,13 +238,157 @@ func WithLogger(l logr.Logger) AuthorizerOption {
 	}
 }
 
+// WithCopyOnWrite keeps groupings and permissions in immutable snapshots,
+// writers build and swap in new snapshots, so that readers like Shall never block
+func WithCopyOnWrite() AuthorizerOption {
+	return func(cfg *AuthorizerConfig) {
+		cfg.cow = true
+	}
+}
+
+// WithCompactPolices keeps groupings and permissions as entities interned into integer ids, in sorted slices,
+// instead of maps of entities, they take a fraction of the memory, at the cost of slower Shall and
+// listings, since groups of users and articles are expanded when queried, see BenchmarkMemory.
+// It is ignored with WithCopyOnWrite or WithHistory.
+func WithCompactPolices() AuthorizerOption {
+	return func(cfg *AuthorizerConfig) {
+		cfg.compact = true
+	}
+}
+
+// WithShards partitions polices of users and articles into shards by their hashes,
+// every shard is locked independently, so that different users or articles join and leave groups in parallel,
+// while roles and categories are shared by all shards, and changing them still blocks all others.
+// It is ignored with WithCopyOnWrite, WithHistory or WithCompactPolices, see BenchmarkJoinContention.
+func WithShards(shards int) AuthorizerOption {
+	return func(cfg *AuthorizerConfig) {
+		cfg.shards = shards
+	}
+}
+
+// WithLazyLoading loads polices of articles, categories, and permissions from persisters when they are queried,
+// instead of all of them at once, the recently used polices of up to size entities are cached,
+// and dropped when they change, locally or on other replicas. Users and roles are still loaded at once.
+// Object and permission persisters must implement types.GroupingQuerier and types.PermissionQuerier,
+// and listing all articles, categories, or polices lists them from persisters.
+// Numbers of polices loaded lazily are not reported to metrics.
+func WithLazyLoading(size int) AuthorizerOption {
+	return func(cfg *AuthorizerConfig) {
+		cfg.lazy = size
+	}
+}
+
+// WithSnapshotFile saves polices to a local file every interval, and once more when the context of the Authorizer is done,
+// together with tokens of the positions in change streams of persisters they are at,
+// non-positive interval saves them only when the context is done.
+// On startup, polices are loaded from the file, and changes after them are watched from persisters,
+// instead of listing all polices. Polices are still listed if the file is missing or not in the current format,
+// or changes after the tokens are not kept by persisters any more.
+// The file is in a compact binary format, default groupings save their closures of groups above entities as well,
+// so that they are restored as they were, instead of being built again by joining polices one by one.
+// Persisters must implement types.GroupingResumer and types.PermissionResumer to be saved in snapshots, others are listed always.
+// Polices loaded lazily are not saved, see WithLazyLoading.
+func WithSnapshotFile(path string, interval time.Duration) AuthorizerOption {
+	return func(cfg *AuthorizerConfig) {
+		cfg.snapshot = &snapshotConfig{path: path, interval: interval}
+	}
+}
+
+// DefaultHistoryLimit is the number of versions kept by WithHistory with a non-positive limit
+const DefaultHistoryLimit = 1000
+
+// WithHistory keeps versions of polices in memory for retention, but no more than limit versions,
+// non-positive retention keeps versions regardless of their ages, and non-positive limit keeps DefaultHistoryLimit versions.
+// Polices loaded on startup are recorded as a single version.
+// The created Authorizer implements types.Historian, to decide with polices in the past.
+// It implies WithCopyOnWrite, versions are snapshots sharing unchanged parts with each other.
+func WithHistory(retention time.Duration, limit int) AuthorizerOption {
+	return func(cfg *AuthorizerConfig) {
+		if limit <= 0 {
+			limit = DefaultHistoryLimit
+		}
+		cfg.history = &historyConfig{retention: retention, limit: limit}
+	}
+}
+
+// WithAuditSink sends audit events of all policy mutations to sink,
+// and decisions of Shall as well, if they are chosen by decisions, which could be nil to audit no decision.
+// Actors of operations are taken from context, see NewContext and types.WithActor
+func WithAuditSink(sink types.AuditSink, decisions func(types.AuditEvent) bool) AuthorizerOption {
+	return func(cfg *AuthorizerConfig) {
+		cfg.auditSink = sink
+		cfg.auditDecision = decisions
+	}
+}
+
+// WithMetrics reports measurements of decisions, persisters, and polices to m,
+// see package metrics for available implementations
+func WithMetrics(m types.Metrics) AuthorizerOption {
+	return func(cfg *AuthorizerConfig) {
+		cfg.metrics = m
+	}
+}
+
+// WithTracer starts spans around authorizations, groupings expansions, and persister round-trips,
+// spans are children of the span carried by contexts passed to AuthorizerContext methods,
+// see package trace for available implementations
+func WithTracer(t types.Tracer) AuthorizerOption {
+	return func(cfg *AuthorizerConfig) {
+		cfg.tracer = t
+	}
+}
+
+// WithDecisionCache caches up to size results of Shall and PermittedActions, the least recently used are evicted.
+// Cached results are dropped when polices they depend on change, locally or on other replicas,
+// other results are kept. The created Authorizer implements types.DecisionCacher, to report how the cache is used.
+func WithDecisionCache(size int) AuthorizerOption {
+	return func(cfg *AuthorizerConfig) {
+		cfg.decisions = size
+	}
+}
+
+// WithEffectiveIndex materializes effective actions of users on articles, through all their roles and categories,
+// so that Shall and PermittedActions of them take a single lookup. Users are materialized when queried for the first time,
+// and kept up to date as polices change, locally or on other replicas, until evicted as the least recently used,
+// to hold no more than budget user and article pairs with any action permitted.
+// It pays off when users have many roles and articles have many categories, see BenchmarkShallFanOut.
+func WithEffectiveIndex(budget int) AuthorizerOption {
+	return func(cfg *AuthorizerConfig) {
+		cfg.index = budget
+	}
+}
+
 // AuthorizerConfig works together with AuthorizerOption to control the initialization of authorizer
 type AuthorizerConfig struct {
-	sp      types.GroupingPersister
-	op      types.GroupingPersister
-	pp      types.PermissionPersister
-	presets []types.PresetPolicy
-	log     logr.Logger
+	sp       types.GroupingPersisterContext
+	op       types.GroupingPersisterContext
+	pp       types.PermissionPersisterContext
+	presets  []types.PresetPolicy
+	log      logr.Logger
+	cow      bool
+	compact  bool
+	shards   int
+	lazy     int
+	history  *historyConfig
+	snapshot *snapshotConfig
+
+	auditSink     types.AuditSink
+	auditDecision func(types.AuditEvent) bool
+
+	metrics   types.Metrics
+	tracer    types.Tracer
+	decisions int
+	index     int
+}
+
+type snapshotConfig struct {
+	path     string
+	interval time.Duration
+}
+
+type historyConfig struct {
+	retention time.Duration
+	limit     int
 }
 
 // AuthorizerOption controls how to init an authorizer
@@ -0,0 +1,272 @@
+package server
+
+import (
+	"context"
+	"sync"
+
+	"github.com/supremind/rbac/internal/wire"
+	"github.com/supremind/rbac/persist"
+	"github.com/supremind/rbac/policy"
+	"github.com/supremind/rbac/types"
+)
+
+// FeedBuffer is the number of changes buffered for each subscriber,
+// subscribers falling behind more than it are dropped, and should subscribe again
+const FeedBuffer = 1024
+
+// Feed follows changes of persisters, and pushes them to subscribers, like cached clients.
+// Persisters are wrapped by the feed before being used by the served Authorizer:
+//
+//	feed := server.NewFeed()
+//	authz, _ := rbac.New(ctx,
+//		rbac.WithSubjectPersisterContext(feed.Subjects(sp)),
+//		rbac.WithObjectPersisterContext(feed.Objects(op)),
+//		rbac.WithPermissionPersisterContext(feed.Permissions(pp)),
+//	)
+//	srv := server.New(authz, server.WithFeed(feed))
+//
+// It keeps a copy of polices listed and watched, so that subscribers start with a snapshot consistent with changes then.
+type Feed struct {
+	subjects    map[types.GroupingPolicy]struct{}
+	objects     map[types.GroupingPolicy]struct{}
+	permissions map[permissionKey]types.Action
+	subscribers map[chan *wire.Change]struct{}
+	closed      bool
+	sync.Mutex
+}
+
+type permissionKey struct {
+	sub types.Subject
+	obj types.Object
+}
+
+// NewFeed creates a Feed without any persisters
+func NewFeed() *Feed {
+	return &Feed{
+		subjects:    make(map[types.GroupingPolicy]struct{}),
+		objects:     make(map[types.GroupingPolicy]struct{}),
+		permissions: make(map[permissionKey]types.Action),
+		subscribers: make(map[chan *wire.Change]struct{}),
+	}
+}
+
+// Subjects wraps the persister of subject groupings
+func (f *Feed) Subjects(p types.GroupingPersister) types.GroupingPersisterContext {
+	return &feedGrouping{GroupingPersisterContext: persist.GroupingWithContext(p), feed: f, kind: wire.KindSubject}
+}
+
+// Objects wraps the persister of object groupings
+func (f *Feed) Objects(p types.GroupingPersister) types.GroupingPersisterContext {
+	return &feedGrouping{GroupingPersisterContext: persist.GroupingWithContext(p), feed: f, kind: wire.KindObject}
+}
+
+// Permissions wraps the persister of permissions
+func (f *Feed) Permissions(p types.PermissionPersister) types.PermissionPersisterContext {
+	return &feedPermission{PermissionPersisterContext: persist.PermissionWithContext(p), feed: f}
+}
+
+// subscribe returns the snapshot of polices, and the channel of changes following it,
+// the channel is closed if the subscriber falls behind, or the feed is closed
+func (f *Feed) subscribe() (*policy.Document, chan *wire.Change) {
+	f.Lock()
+	defer f.Unlock()
+
+	var ps types.Policies
+	for gp := range f.subjects {
+		ps.Subjects = append(ps.Subjects, gp)
+	}
+	for gp := range f.objects {
+		ps.Objects = append(ps.Objects, gp)
+	}
+	for key, act := range f.permissions {
+		ps.Permissions = append(ps.Permissions, types.PermissionPolicy{Subject: key.sub, Object: key.obj, Action: act})
+	}
+
+	ch := make(chan *wire.Change, FeedBuffer)
+	if f.closed {
+		close(ch)
+	} else {
+		f.subscribers[ch] = struct{}{}
+	}
+	return policy.NewDocument(ps), ch
+}
+
+func (f *Feed) unsubscribe(ch chan *wire.Change) {
+	f.Lock()
+	defer f.Unlock()
+
+	if _, ok := f.subscribers[ch]; ok {
+		delete(f.subscribers, ch)
+		close(ch)
+	}
+}
+
+// Close disconnects all subscribers, and the ones subscribing later, like when the server is shutting down
+func (f *Feed) Close() {
+	f.Lock()
+	defer f.Unlock()
+
+	f.closed = true
+	for ch := range f.subscribers {
+		delete(f.subscribers, ch)
+		close(ch)
+	}
+}
+
+// publish sends the change to all subscribers, it must be called with lock held
+func (f *Feed) publish(change *wire.Change) {
+	for ch := range f.subscribers {
+		select {
+		case ch <- change:
+		default:
+			delete(f.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+func (f *Feed) groupings(kind string) map[types.GroupingPolicy]struct{} {
+	if kind == wire.KindSubject {
+		return f.subjects
+	}
+	return f.objects
+}
+
+func (f *Feed) listGroupings(kind string, polices []types.GroupingPolicy) {
+	f.Lock()
+	defer f.Unlock()
+
+	m := f.groupings(kind)
+	for gp := range m {
+		delete(m, gp)
+	}
+	for _, gp := range polices {
+		m[gp] = struct{}{}
+	}
+}
+
+func (f *Feed) changeGrouping(kind string, change types.GroupingPolicyChange) {
+	f.Lock()
+	defer f.Unlock()
+
+	m := f.groupings(kind)
+	switch change.Method {
+	case types.PersistInsert:
+		m[change.GroupingPolicy] = struct{}{}
+	case types.PersistDelete:
+		delete(m, change.GroupingPolicy)
+	default:
+		return
+	}
+	f.publish(&wire.Change{
+		Kind:   kind,
+		Method: string(change.Method),
+		Entity: change.Entity.String(),
+		Group:  change.Group.String(),
+	})
+}
+
+func (f *Feed) listPermissions(polices []types.PermissionPolicy) {
+	f.Lock()
+	defer f.Unlock()
+
+	f.permissions = make(map[permissionKey]types.Action, len(polices))
+	for _, pp := range polices {
+		f.permissions[permissionKey{sub: pp.Subject, obj: pp.Object}] = pp.Action
+	}
+}
+
+func (f *Feed) changePermission(change types.PermissionPolicyChange) {
+	f.Lock()
+	defer f.Unlock()
+
+	key := permissionKey{sub: change.Subject, obj: change.Object}
+	switch change.Method {
+	case types.PersistInsert, types.PersistUpdate:
+		f.permissions[key] = change.Action
+	case types.PersistDelete:
+		delete(f.permissions, key)
+	default:
+		return
+	}
+	f.publish(&wire.Change{
+		Kind:    wire.KindPermission,
+		Method:  string(change.Method),
+		Subject: change.Subject.String(),
+		Object:  change.Object.String(),
+		Action:  change.Action.String(),
+	})
+}
+
+// feedGrouping copies polices listed and watched to the feed
+type feedGrouping struct {
+	types.GroupingPersisterContext
+	feed *Feed
+	kind string
+}
+
+func (g *feedGrouping) ListContext(ctx context.Context) ([]types.GroupingPolicy, error) {
+	polices, e := g.GroupingPersisterContext.ListContext(ctx)
+	if e != nil {
+		return nil, e
+	}
+	g.feed.listGroupings(g.kind, polices)
+	return polices, nil
+}
+
+func (g *feedGrouping) Watch(ctx context.Context) (<-chan types.GroupingPolicyChange, error) {
+	in, e := g.GroupingPersisterContext.Watch(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	out := make(chan types.GroupingPolicyChange)
+	go func() {
+		defer close(out)
+		for change := range in {
+			g.feed.changeGrouping(g.kind, change)
+			select {
+			case out <- change:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// feedPermission copies polices listed and watched to the feed
+type feedPermission struct {
+	types.PermissionPersisterContext
+	feed *Feed
+}
+
+func (p *feedPermission) ListContext(ctx context.Context) ([]types.PermissionPolicy, error) {
+	polices, e := p.PermissionPersisterContext.ListContext(ctx)
+	if e != nil {
+		return nil, e
+	}
+	p.feed.listPermissions(polices)
+	return polices, nil
+}
+
+func (p *feedPermission) Watch(ctx context.Context) (<-chan types.PermissionPolicyChange, error) {
+	in, e := p.PermissionPersisterContext.Watch(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	out := make(chan types.PermissionPolicyChange)
+	go func() {
+		defer close(out)
+		for change := range in {
+			p.feed.changePermission(change)
+			select {
+			case out <- change:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
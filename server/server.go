@@ -0,0 +1,582 @@
+// Package server exposes an Authorizer over HTTP/JSON, so that services not in Go could share the same decisions.
+//
+// Endpoints mirror Subjector, Objector and Permission, they are described in OpenAPI,
+// which is served at /v1/openapi.json as well. Package client implements types.Authorizer with them.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/supremind/rbac/internal/wire"
+	"github.com/supremind/rbac/policy"
+	"github.com/supremind/rbac/types"
+)
+
+// MaxBatch is the max number of checks in a batch
+const MaxBatch = 1000
+
+var errBadRequest = errors.New("bad request")
+
+// ErrNoFeed is returned for watch requests if the server is created without a Feed
+var ErrNoFeed = errors.New("no change feed")
+
+// Option configures servers
+type Option func(*Server)
+
+// WithLogger sets a logger for the server to use with
+func WithLogger(l logr.Logger) Option {
+	return func(s *Server) {
+		s.log = l
+	}
+}
+
+// WithFeed serves changes of polices at /v1/watch, the feed must wrap persisters of the served Authorizer
+func WithFeed(f *Feed) Option {
+	return func(s *Server) {
+		s.feed = f
+	}
+}
+
+// Server serves an Authorizer over HTTP/JSON, it is an http.Handler
+type Server struct {
+	authz types.Authorizer
+	w     writer
+	feed  *Feed
+	mux   *http.ServeMux
+	log   logr.Logger
+}
+
+// New creates a Server serving authz, polices are listed only if authz is a types.PolicyLister,
+// like the Authorizers created by rbac.New. Polices are changed with contexts of requests if authz is context-aware,
+// so that actors carried by them are audited.
+func New(authz types.Authorizer, opts ...Option) *Server {
+	s := &Server{authz: authz, w: writerOf(authz), mux: http.NewServeMux(), log: logr.Discard()}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.mux.Handle("/v1/check", s.handle(http.MethodPost, s.check))
+	s.mux.Handle("/v1/check/batch", s.handle(http.MethodPost, s.batch))
+
+	subjects := &grouping{
+		reader: authz.Subjects,
+		join: func(ctx context.Context, ent types.Entity, group types.Group) error {
+			sub, role, e := subjectGrouping(ent, group)
+			if e != nil {
+				return e
+			}
+			return s.w.SubjectJoinContext(ctx, sub, role)
+		},
+		leave: func(ctx context.Context, ent types.Entity, group types.Group) error {
+			sub, role, e := subjectGrouping(ent, group)
+			if e != nil {
+				return e
+			}
+			return s.w.SubjectLeaveContext(ctx, sub, role)
+		},
+	}
+	objects := &grouping{
+		reader: authz.Objects,
+		join: func(ctx context.Context, ent types.Entity, group types.Group) error {
+			obj, cat, e := objectGrouping(ent, group)
+			if e != nil {
+				return e
+			}
+			return s.w.ObjectJoinContext(ctx, obj, cat)
+		},
+		leave: func(ctx context.Context, ent types.Entity, group types.Group) error {
+			obj, cat, e := objectGrouping(ent, group)
+			if e != nil {
+				return e
+			}
+			return s.w.ObjectLeaveContext(ctx, obj, cat)
+		},
+	}
+	for prefix, g := range map[string]*grouping{"/v1/subjects/": subjects, "/v1/objects/": objects} {
+		s.mux.Handle(prefix+"join", s.handle(http.MethodPost, g.handleJoin))
+		s.mux.Handle(prefix+"leave", s.handle(http.MethodPost, g.handleLeave))
+		s.mux.Handle(prefix+"groups", s.handle(http.MethodGet, g.allGroups))
+		s.mux.Handle(prefix+"members", s.handle(http.MethodGet, g.allMembers))
+		s.mux.Handle(prefix+"members-in", s.handle(http.MethodGet, g.membersIn))
+		s.mux.Handle(prefix+"groups-of", s.handle(http.MethodGet, g.groupsOf))
+		s.mux.Handle(prefix+"is-in", s.handle(http.MethodGet, g.isIn))
+	}
+
+	s.mux.Handle("/v1/entities/", s.handle(http.MethodDelete, s.removeEntity))
+	s.mux.Handle("/v1/permissions/permit", s.handle(http.MethodPost, s.permit))
+	s.mux.Handle("/v1/permissions/revoke", s.handle(http.MethodPost, s.revoke))
+	s.mux.Handle("/v1/permissions/on", s.handle(http.MethodGet, s.permissionsOn))
+	s.mux.Handle("/v1/permissions/for", s.handle(http.MethodGet, s.permissionsFor))
+	s.mux.Handle("/v1/permissions/permitted", s.handle(http.MethodGet, s.permitted))
+	s.mux.Handle("/v1/policies", s.handle(http.MethodGet, s.policies))
+	s.mux.HandleFunc("/v1/watch", s.watch)
+	s.mux.HandleFunc("/v1/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(OpenAPI))
+	})
+
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// handle serves requests in method by fn, whose results are encoded as JSON, nil results mean no content
+func (s *Server) handle(method string, fn func(r *http.Request) (interface{}, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			s.fail(w, r, http.StatusMethodNotAllowed, &wire.Error{Code: "method_not_allowed", Message: r.Method + " is not allowed"})
+			return
+		}
+
+		result, e := fn(r)
+		if e != nil {
+			s.failWith(w, r, e)
+			return
+		}
+
+		if result == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if e := json.NewEncoder(w).Encode(result); e != nil {
+			s.log.Error(e, "write response", "method", r.Method, "path", r.URL.Path)
+		}
+	})
+}
+
+// failWith responds the error with its code
+func (s *Server) failWith(w http.ResponseWriter, r *http.Request, e error) {
+	code, status := wire.CodeOf(e)
+	switch {
+	case errors.Is(e, errBadRequest):
+		code, status = "bad_request", http.StatusBadRequest
+	case errors.Is(e, ErrNoFeed):
+		code, status = "no_feed", http.StatusNotImplemented
+	}
+	if status == http.StatusInternalServerError {
+		s.log.Error(e, "serve request", "method", r.Method, "path", r.URL.Path)
+	}
+	s.fail(w, r, status, &wire.Error{Code: code, Message: e.Error()})
+}
+
+func (s *Server) fail(w http.ResponseWriter, r *http.Request, status int, e *wire.Error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(e)
+}
+
+func decode(r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if e := dec.Decode(v); e != nil {
+		return fmt.Errorf("%w: %s", errBadRequest, e)
+	}
+	return nil
+}
+
+// query returns the required query parameter
+func query(r *http.Request, key string) (string, error) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return "", fmt.Errorf("%w: %s is required", errBadRequest, key)
+	}
+	return v, nil
+}
+
+func parsePermission(p wire.Permission) (types.Subject, types.Object, types.Action, error) {
+	sub, e := types.ParseSubject(p.Subject)
+	if e != nil {
+		return nil, nil, 0, fmt.Errorf("%w: %s", e, p.Subject)
+	}
+	obj, e := types.ParseObject(p.Object)
+	if e != nil {
+		return nil, nil, 0, fmt.Errorf("%w: %s", e, p.Object)
+	}
+	act, e := types.ParseAction(p.Action)
+	if e != nil {
+		return nil, nil, 0, e
+	}
+	return sub, obj, act, nil
+}
+
+func (s *Server) check(r *http.Request) (interface{}, error) {
+	var p wire.Permission
+	if e := decode(r, &p); e != nil {
+		return nil, e
+	}
+	sub, obj, act, e := parsePermission(p)
+	if e != nil {
+		return nil, e
+	}
+	allowed, e := s.authz.Shall(sub, obj, act)
+	if e != nil {
+		return nil, e
+	}
+	return wire.Decision{Allowed: allowed}, nil
+}
+
+// batch checks all permissions, failed checks are reported in their results, instead of failing the batch
+func (s *Server) batch(r *http.Request) (interface{}, error) {
+	var b wire.Batch
+	if e := decode(r, &b); e != nil {
+		return nil, e
+	}
+	if len(b.Checks) > MaxBatch {
+		return nil, fmt.Errorf("%w: at most %d checks in a batch", errBadRequest, MaxBatch)
+	}
+
+	result := wire.BatchResult{Results: make([]wire.Decision, len(b.Checks))}
+	for i, p := range b.Checks {
+		sub, obj, act, e := parsePermission(p)
+		if e == nil {
+			result.Results[i].Allowed, e = s.authz.Shall(sub, obj, act)
+		}
+		if e != nil {
+			code, _ := wire.CodeOf(e)
+			result.Results[i].Error = &wire.Error{Code: code, Message: e.Error()}
+		}
+	}
+	return result, nil
+}
+
+func (s *Server) permit(r *http.Request) (interface{}, error) {
+	var p wire.Permission
+	if e := decode(r, &p); e != nil {
+		return nil, e
+	}
+	sub, obj, act, e := parsePermission(p)
+	if e != nil {
+		return nil, e
+	}
+	return nil, s.w.PermitContext(r.Context(), sub, obj, act)
+}
+
+func (s *Server) revoke(r *http.Request) (interface{}, error) {
+	var p wire.Permission
+	if e := decode(r, &p); e != nil {
+		return nil, e
+	}
+	sub, obj, act, e := parsePermission(p)
+	if e != nil {
+		return nil, e
+	}
+	return nil, s.w.RevokeContext(r.Context(), sub, obj, act)
+}
+
+func (s *Server) permissionsOn(r *http.Request) (interface{}, error) {
+	q, e := query(r, "object")
+	if e != nil {
+		return nil, e
+	}
+	obj, e := types.ParseObject(q)
+	if e != nil {
+		return nil, fmt.Errorf("%w: %s", e, q)
+	}
+	perms, e := s.authz.PermissionsOn(obj)
+	if e != nil {
+		return nil, e
+	}
+	result := make(map[string]string, len(perms))
+	for sub, act := range perms {
+		result[sub.String()] = act.String()
+	}
+	return result, nil
+}
+
+func (s *Server) permissionsFor(r *http.Request) (interface{}, error) {
+	q, e := query(r, "subject")
+	if e != nil {
+		return nil, e
+	}
+	sub, e := types.ParseSubject(q)
+	if e != nil {
+		return nil, fmt.Errorf("%w: %s", e, q)
+	}
+	perms, e := s.authz.PermissionsFor(sub)
+	if e != nil {
+		return nil, e
+	}
+	result := make(map[string]string, len(perms))
+	for obj, act := range perms {
+		result[obj.String()] = act.String()
+	}
+	return result, nil
+}
+
+func (s *Server) permitted(r *http.Request) (interface{}, error) {
+	qs, e := query(r, "subject")
+	if e != nil {
+		return nil, e
+	}
+	qo, e := query(r, "object")
+	if e != nil {
+		return nil, e
+	}
+	sub, e := types.ParseSubject(qs)
+	if e != nil {
+		return nil, fmt.Errorf("%w: %s", e, qs)
+	}
+	obj, e := types.ParseObject(qo)
+	if e != nil {
+		return nil, fmt.Errorf("%w: %s", e, qo)
+	}
+	act, e := s.authz.PermittedActions(sub, obj)
+	if e != nil {
+		return nil, e
+	}
+	return wire.Actions{Action: act.String()}, nil
+}
+
+// removeEntity removes the user, role, article or category in the path, like /v1/entities/user:alan
+func (s *Server) removeEntity(r *http.Request) (interface{}, error) {
+	name := strings.TrimPrefix(r.URL.Path, "/v1/entities/")
+	ent, e := types.ParseEntity(name)
+	if e != nil {
+		return nil, fmt.Errorf("%w: %s", e, name)
+	}
+
+	switch ent := ent.(type) {
+	case types.User:
+		return nil, s.w.RemoveUserContext(r.Context(), ent)
+	case types.Role:
+		return nil, s.w.RemoveRoleContext(r.Context(), ent)
+	case types.Article:
+		return nil, s.w.RemoveArticleContext(r.Context(), ent)
+	case types.Category:
+		return nil, s.w.RemoveCategoryContext(r.Context(), ent)
+	}
+	return nil, fmt.Errorf("%w: %s", types.ErrInvalidEntity, name)
+}
+
+func (s *Server) policies(r *http.Request) (interface{}, error) {
+	l, ok := s.authz.(types.PolicyLister)
+	if !ok {
+		return nil, types.ErrNoPolicyList
+	}
+	ps, e := l.Policies()
+	if e != nil {
+		return nil, e
+	}
+	return policy.NewDocument(ps), nil
+}
+
+// watch streams the snapshot of polices and changes following it, as lines of JSON events,
+// until the client disconnects, falls behind, or the feed is closed
+func (s *Server) watch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		s.fail(w, r, http.StatusMethodNotAllowed, &wire.Error{Code: "method_not_allowed", Message: r.Method + " is not allowed"})
+		return
+	}
+	if s.feed == nil {
+		s.failWith(w, r, ErrNoFeed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.failWith(w, r, errors.New("streaming is not supported"))
+		return
+	}
+
+	snapshot, changes := s.feed.subscribe()
+	defer s.feed.unsubscribe(changes)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+	if e := enc.Encode(wire.Event{Snapshot: snapshot}); e != nil {
+		return
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(wire.Heartbeat)
+	defer heartbeat.Stop()
+	for {
+		var event wire.Event
+		select {
+		case change, ok := <-changes:
+			if !ok {
+				s.log.V(1).Info("watcher is disconnected by the feed", "remote", r.RemoteAddr)
+				return
+			}
+			event.Change = change
+		case <-heartbeat.C:
+		case <-r.Context().Done():
+			return
+		}
+
+		if e := enc.Encode(event); e != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// grouping serves subject or object groupings
+type grouping struct {
+	reader func() types.GroupingReader
+	join   func(context.Context, types.Entity, types.Group) error
+	leave  func(context.Context, types.Entity, types.Group) error
+}
+
+func subjectGrouping(ent types.Entity, group types.Group) (types.Subject, types.Role, error) {
+	sub, ok := ent.(types.Subject)
+	if !ok {
+		return nil, "", fmt.Errorf("%w: %s", types.ErrInvlaidSubject, ent)
+	}
+	role, ok := group.(types.Role)
+	if !ok {
+		return nil, "", fmt.Errorf("%w: %s", types.ErrInvalidGroup, group)
+	}
+	return sub, role, nil
+}
+
+func objectGrouping(ent types.Entity, group types.Group) (types.Object, types.Category, error) {
+	obj, ok := ent.(types.Object)
+	if !ok {
+		return nil, "", fmt.Errorf("%w: %s", types.ErrInvlaidObject, ent)
+	}
+	cat, ok := group.(types.Category)
+	if !ok {
+		return nil, "", fmt.Errorf("%w: %s", types.ErrInvalidGroup, group)
+	}
+	return obj, cat, nil
+}
+
+func parseGrouping(r *http.Request) (types.Entity, types.Group, error) {
+	var g wire.Grouping
+	if e := decode(r, &g); e != nil {
+		return nil, nil, e
+	}
+	ent, e := types.ParseEntity(g.Entity)
+	if e != nil {
+		return nil, nil, fmt.Errorf("%w: %s", e, g.Entity)
+	}
+	group, e := types.ParseGroup(g.Group)
+	if e != nil {
+		return nil, nil, fmt.Errorf("%w: %s", e, g.Group)
+	}
+	return ent, group, nil
+}
+
+func (g *grouping) handleJoin(r *http.Request) (interface{}, error) {
+	ent, group, e := parseGrouping(r)
+	if e != nil {
+		return nil, e
+	}
+	return nil, g.join(r.Context(), ent, group)
+}
+
+func (g *grouping) handleLeave(r *http.Request) (interface{}, error) {
+	ent, group, e := parseGrouping(r)
+	if e != nil {
+		return nil, e
+	}
+	return nil, g.leave(r.Context(), ent, group)
+}
+
+func (g *grouping) allGroups(r *http.Request) (interface{}, error) {
+	groups, e := g.reader().AllGroups()
+	if e != nil {
+		return nil, e
+	}
+	return groupNames(groups), nil
+}
+
+func (g *grouping) allMembers(r *http.Request) (interface{}, error) {
+	members, e := g.reader().AllMembers()
+	if e != nil {
+		return nil, e
+	}
+	return memberNames(members), nil
+}
+
+func (g *grouping) membersIn(r *http.Request) (interface{}, error) {
+	group, e := queryGroup(r, "group")
+	if e != nil {
+		return nil, e
+	}
+	members, e := g.reader().MembersIn(group)
+	if e != nil {
+		return nil, e
+	}
+	return memberNames(members), nil
+}
+
+func (g *grouping) groupsOf(r *http.Request) (interface{}, error) {
+	q, e := query(r, "entity")
+	if e != nil {
+		return nil, e
+	}
+	ent, e := types.ParseEntity(q)
+	if e != nil {
+		return nil, fmt.Errorf("%w: %s", e, q)
+	}
+	groups, e := g.reader().GroupsOf(ent)
+	if e != nil {
+		return nil, e
+	}
+	return groupNames(groups), nil
+}
+
+func (g *grouping) isIn(r *http.Request) (interface{}, error) {
+	q, e := query(r, "member")
+	if e != nil {
+		return nil, e
+	}
+	member, e := types.ParseMember(q)
+	if e != nil {
+		return nil, fmt.Errorf("%w: %s", e, q)
+	}
+	group, e := queryGroup(r, "group")
+	if e != nil {
+		return nil, e
+	}
+	in, e := g.reader().IsIn(member, group)
+	if e != nil {
+		return nil, e
+	}
+	return wire.Bool{Result: in}, nil
+}
+
+func queryGroup(r *http.Request, key string) (types.Group, error) {
+	q, e := query(r, key)
+	if e != nil {
+		return nil, e
+	}
+	group, e := types.ParseGroup(q)
+	if e != nil {
+		return nil, fmt.Errorf("%w: %s", e, q)
+	}
+	return group, nil
+}
+
+func groupNames(groups map[types.Group]struct{}) []string {
+	names := make([]string, 0, len(groups))
+	for group := range groups {
+		names = append(names, group.String())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func memberNames(members map[types.Member]struct{}) []string {
+	names := make([]string, 0, len(members))
+	for member := range members {
+		names = append(names, member.String())
+	}
+	sort.Strings(names)
+	return names
+}
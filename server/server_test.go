@@ -0,0 +1,161 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/supremind/rbac"
+	"github.com/supremind/rbac/persist/fake"
+	"github.com/supremind/rbac/server"
+	. "github.com/supremind/rbac/types"
+)
+
+func TestServer(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "server test suit")
+}
+
+type eventsSink struct {
+	events []AuditEvent
+}
+
+func (s *eventsSink) Audit(event AuditEvent) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+var _ = Describe("authorization server", func() {
+	var srv *server.Server
+
+	BeforeEach(func() {
+		authz, e := rbac.New(context.Background(),
+			rbac.WithSubjectPersister(fake.NewGroupingPersister()),
+			rbac.WithObjectPersister(fake.NewGroupingPersister()),
+			rbac.WithPermissionPersister(fake.NewPermissionPersister()),
+		)
+		Expect(e).To(Succeed())
+		Expect(authz.SubjectJoin(User("alan"), Role("editor"))).To(Succeed())
+		Expect(authz.Permit(Role("editor"), Article("apollo"), Read)).To(Succeed())
+		srv = server.New(authz)
+	})
+
+	serve := func(method, target, body string) (int, string) {
+		var r *http.Request
+		if body == "" {
+			r = httptest.NewRequest(method, target, nil)
+		} else {
+			r = httptest.NewRequest(method, target, strings.NewReader(body))
+		}
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, r)
+		content, _ := ioutil.ReadAll(w.Result().Body)
+		return w.Code, string(content)
+	}
+
+	It("should check permissions", func() {
+		code, body := serve("POST", "/v1/check", `{"subject":"user:alan","object":"art:apollo","action":"read"}`)
+		Expect(code).To(Equal(200))
+		Expect(body).To(MatchJSON(`{"allowed":true}`))
+
+		code, body = serve("POST", "/v1/check/batch", `{"checks":[
+			{"subject":"user:alan","object":"art:apollo","action":"read|write"},
+			{"subject":"user:alan","object":"art:apollo","action":"fly"}]}`)
+		Expect(code).To(Equal(200))
+		Expect(body).To(MatchJSON(`{"results":[
+			{"allowed":false},
+			{"allowed":false,"error":{"code":"unknown_action","message":"unknown action: fly"}}]}`))
+	})
+
+	It("should list groupings and permissions", func() {
+		code, body := serve("GET", "/v1/subjects/members-in?group=role:editor", "")
+		Expect(code).To(Equal(200))
+		Expect(body).To(MatchJSON(`["user:alan"]`))
+
+		code, body = serve("GET", "/v1/permissions/on?object=art:apollo", "")
+		Expect(code).To(Equal(200))
+		Expect(body).To(MatchJSON(`{"role:editor":"read"}`))
+	})
+
+	It("should report errors with codes", func() {
+		code, body := serve("POST", "/v1/subjects/join", `{"entity":"user:alan","group":"role:editor"}`)
+		Expect(code).To(Equal(409))
+		Expect(body).To(ContainSubstring(`"code":"already_exists"`))
+
+		code, body = serve("POST", "/v1/subjects/join", `{"entity":"art:apollo","group":"role:editor"}`)
+		Expect(code).To(Equal(400))
+		Expect(body).To(ContainSubstring(`"code":"invalid_subject"`))
+
+		code, body = serve("POST", "/v1/check", `{"subject":"user:alan","unknown":1}`)
+		Expect(code).To(Equal(400))
+		Expect(body).To(ContainSubstring(`"code":"bad_request"`))
+
+		code, _ = serve("GET", "/v1/permissions/on", "")
+		Expect(code).To(Equal(400))
+
+		code, body = serve("GET", "/v1/check", "")
+		Expect(code).To(Equal(405))
+		Expect(body).To(ContainSubstring(`"code":"method_not_allowed"`))
+
+		code, body = serve("POST", "/v1/subjects/leave", `{"entity":"user:bob","group":"role:editor"}`)
+		Expect(code).To(Equal(404))
+		Expect(body).To(ContainSubstring(`"code":"not_found"`))
+
+		code, body = serve("GET", "/v1/watch", "")
+		Expect(code).To(Equal(501))
+		Expect(body).To(ContainSubstring(`"code":"no_feed"`))
+	})
+
+	It("should write polices with contexts of requests", func() {
+		sink := &eventsSink{}
+		authz, e := rbac.New(context.Background(),
+			rbac.WithSubjectPersister(fake.NewGroupingPersister()),
+			rbac.WithPermissionPersister(fake.NewPermissionPersister()),
+			rbac.WithAuditSink(sink, nil),
+		)
+		Expect(e).To(Succeed())
+		srv = server.New(authz)
+
+		r := httptest.NewRequest("POST", "/v1/subjects/join", strings.NewReader(`{"entity":"user:alan","group":"role:editor"}`))
+		r = r.WithContext(WithActor(r.Context(), "admin"))
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, r)
+		Expect(w.Code).To(Equal(http.StatusNoContent))
+
+		Expect(sink.events).To(HaveLen(1))
+		Expect(sink.events[0].Operation).To(Equal(AuditSubjectJoin))
+		Expect(sink.events[0].Actor).To(Equal("admin"))
+	})
+
+	It("should describe all endpoints in openapi", func() {
+		code, body := serve("GET", "/v1/openapi.json", "")
+		Expect(code).To(Equal(200))
+
+		var doc struct {
+			OpenAPI string                     `json:"openapi"`
+			Paths   map[string]json.RawMessage `json:"paths"`
+		}
+		Expect(json.Unmarshal([]byte(body), &doc)).To(Succeed())
+		Expect(doc.OpenAPI).To(HavePrefix("3."))
+		for _, path := range []string{
+			"/v1/check", "/v1/check/batch",
+			"/v1/subjects/join", "/v1/subjects/leave", "/v1/subjects/groups", "/v1/subjects/members",
+			"/v1/subjects/members-in", "/v1/subjects/groups-of", "/v1/subjects/is-in",
+			"/v1/objects/join", "/v1/objects/leave", "/v1/objects/groups", "/v1/objects/members",
+			"/v1/objects/members-in", "/v1/objects/groups-of", "/v1/objects/is-in",
+			"/v1/entities/{entity}",
+			"/v1/permissions/permit", "/v1/permissions/revoke",
+			"/v1/permissions/on", "/v1/permissions/for", "/v1/permissions/permitted",
+			"/v1/policies", "/v1/watch",
+		} {
+			Expect(doc.Paths).To(HaveKey(path))
+		}
+	})
+})
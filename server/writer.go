@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+
+	"github.com/supremind/rbac/types"
+)
+
+// writer changes polices with contexts of requests, so that actors carried by them are audited
+type writer interface {
+	SubjectJoinContext(ctx context.Context, sub types.Subject, role types.Role) error
+	SubjectLeaveContext(ctx context.Context, sub types.Subject, role types.Role) error
+	RemoveUserContext(ctx context.Context, user types.User) error
+	RemoveRoleContext(ctx context.Context, role types.Role) error
+	ObjectJoinContext(ctx context.Context, obj types.Object, cat types.Category) error
+	ObjectLeaveContext(ctx context.Context, obj types.Object, cat types.Category) error
+	RemoveArticleContext(ctx context.Context, art types.Article) error
+	RemoveCategoryContext(ctx context.Context, cat types.Category) error
+	PermitContext(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) error
+	RevokeContext(ctx context.Context, sub types.Subject, obj types.Object, act types.Action) error
+}
+
+// writerOf returns the authorizer as a writer, context-aware authorizers are written with contexts,
+// while contexts are ignored by others
+func writerOf(authz types.Authorizer) writer {
+	if w, ok := authz.(writer); ok {
+		return w
+	}
+	return plainWriter{authz}
+}
+
+// plainWriter writes polices to authorizers without contexts
+type plainWriter struct {
+	authz types.Authorizer
+}
+
+func (w plainWriter) SubjectJoinContext(_ context.Context, sub types.Subject, role types.Role) error {
+	return w.authz.SubjectJoin(sub, role)
+}
+
+func (w plainWriter) SubjectLeaveContext(_ context.Context, sub types.Subject, role types.Role) error {
+	return w.authz.SubjectLeave(sub, role)
+}
+
+func (w plainWriter) RemoveUserContext(_ context.Context, user types.User) error {
+	return w.authz.RemoveUser(user)
+}
+
+func (w plainWriter) RemoveRoleContext(_ context.Context, role types.Role) error {
+	return w.authz.RemoveRole(role)
+}
+
+func (w plainWriter) ObjectJoinContext(_ context.Context, obj types.Object, cat types.Category) error {
+	return w.authz.ObjectJoin(obj, cat)
+}
+
+func (w plainWriter) ObjectLeaveContext(_ context.Context, obj types.Object, cat types.Category) error {
+	return w.authz.ObjectLeave(obj, cat)
+}
+
+func (w plainWriter) RemoveArticleContext(_ context.Context, art types.Article) error {
+	return w.authz.RemoveArticle(art)
+}
+
+func (w plainWriter) RemoveCategoryContext(_ context.Context, cat types.Category) error {
+	return w.authz.RemoveCategory(cat)
+}
+
+func (w plainWriter) PermitContext(_ context.Context, sub types.Subject, obj types.Object, act types.Action) error {
+	return w.authz.Permit(sub, obj, act)
+}
+
+func (w plainWriter) RevokeContext(_ context.Context, sub types.Subject, obj types.Object, act types.Action) error {
+	return w.authz.Revoke(sub, obj, act)
+}
@@ -0,0 +1,86 @@
+package rbac_test
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/supremind/rbac"
+	"github.com/supremind/rbac/persist/fake"
+	. "github.com/supremind/rbac/types"
+)
+
+var _ = Describe("authorizer with shards", func() {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+		authz  Authorizer
+	)
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		var e error
+		authz, e = rbac.New(ctx,
+			rbac.WithSubjectPersister(fake.NewGroupingPersister()),
+			rbac.WithObjectPersister(fake.NewGroupingPersister()),
+			rbac.WithPermissionPersister(fake.NewPermissionPersister()),
+			rbac.WithShards(8),
+		)
+		Expect(e).To(Succeed())
+
+		Expect(authz.SubjectJoin(Role("editor"), Role("reader"))).To(Succeed())
+		Expect(authz.ObjectJoin(Category("history"), Category("library"))).To(Succeed())
+		Expect(authz.Permit(Role("reader"), Category("library"), Read)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	It("should join members concurrently", func() {
+		const n = 100
+
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer GinkgoRecover()
+				defer wg.Done()
+
+				user, art := User(strconv.Itoa(i)), Article(strconv.Itoa(i))
+				Expect(authz.SubjectJoin(user, Role("editor"))).To(Succeed())
+				Expect(authz.ObjectJoin(art, Category("history"))).To(Succeed())
+				Expect(authz.Shall(user, art, Read)).To(BeTrue())
+				if i%2 == 0 {
+					Expect(authz.SubjectLeave(user, Role("editor"))).To(Succeed())
+				}
+			}(i)
+		}
+		wg.Add(1)
+		go func() {
+			defer GinkgoRecover()
+			defer wg.Done()
+
+			Expect(authz.SubjectJoin(Role("writer"), Role("editor"))).To(Succeed())
+			Expect(authz.Permit(Role("editor"), Category("history"), Write)).To(Succeed())
+		}()
+		wg.Wait()
+
+		Expect(authz.Subjects().MembersIn(Role("reader"))).To(HaveLen(n / 2))
+		Expect(authz.Objects().MembersIn(Category("library"))).To(HaveLen(n))
+		for i := 0; i < n; i++ {
+			expected := Read | Write
+			if i%2 == 0 {
+				expected = None
+			}
+			Expect(authz.PermittedActions(User(strconv.Itoa(i)), Article(strconv.Itoa(i)))).To(Equal(expected))
+		}
+	})
+
+	It("should decide the same as without shards", func() {
+		expectSameDecisions(ctx, authz)
+	})
+})
@@ -0,0 +1,194 @@
+package rbac_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/supremind/rbac"
+	"github.com/supremind/rbac/persist/fake"
+	. "github.com/supremind/rbac/types"
+)
+
+// resumingGroupingPersister resumes watching changes, and refuses to list polices
+type resumingGroupingPersister struct {
+	GroupingPersister
+	resumer GroupingResumer
+}
+
+func (p resumingGroupingPersister) List() ([]GroupingPolicy, error) {
+	return nil, errListed
+}
+
+func (p resumingGroupingPersister) ListWithToken(context.Context) ([]GroupingPolicy, string, error) {
+	return nil, "", errListed
+}
+
+func (p resumingGroupingPersister) WatchFrom(ctx context.Context, token string) (<-chan ResumableGroupingChange, error) {
+	return p.resumer.WatchFrom(ctx, token)
+}
+
+// resumingPermissionPersister resumes watching changes, and refuses to list polices
+type resumingPermissionPersister struct {
+	PermissionPersister
+	resumer PermissionResumer
+}
+
+func (p resumingPermissionPersister) List() ([]PermissionPolicy, error) {
+	return nil, errListed
+}
+
+func (p resumingPermissionPersister) ListWithToken(context.Context) ([]PermissionPolicy, string, error) {
+	return nil, "", errListed
+}
+
+func (p resumingPermissionPersister) WatchFrom(ctx context.Context, token string) (<-chan ResumablePermissionChange, error) {
+	return p.resumer.WatchFrom(ctx, token)
+}
+
+type resumableGroupingPersister interface {
+	GroupingPersister
+	GroupingResumer
+}
+
+type resumablePermissionPersister interface {
+	PermissionPersister
+	PermissionResumer
+}
+
+var _ = Describe("authorizer with snapshot file", func() {
+	var (
+		dir  string
+		path string
+		sp   resumableGroupingPersister
+		op   resumableGroupingPersister
+		pp   resumablePermissionPersister
+	)
+
+	BeforeEach(func() {
+		var e error
+		dir, e = ioutil.TempDir("", "rbac-snapshot")
+		Expect(e).To(Succeed())
+		path = filepath.Join(dir, "snapshot")
+
+		sp = fake.NewGroupingPersister()
+		Expect(sp.Insert(User("alan"), Role("editor"))).To(Succeed())
+		op = fake.NewGroupingPersister()
+		Expect(op.Insert(Article("apollo"), Category("history"))).To(Succeed())
+		pp = fake.NewPermissionPersister()
+		Expect(pp.Insert(Role("editor"), Category("history"), ReadWrite)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		// authorizers stopped may be saving their last snapshots
+		Eventually(func() error { return os.RemoveAll(dir) }).Should(Succeed())
+	})
+
+	// start an authorizer with the snapshot file saved every 10ms
+	start := func(ctx context.Context) Authorizer {
+		authz, e := rbac.New(ctx,
+			rbac.WithSubjectPersister(sp),
+			rbac.WithObjectPersister(op),
+			rbac.WithPermissionPersister(pp),
+			rbac.WithSnapshotFile(path, 10*time.Millisecond),
+		)
+		Expect(e).To(Succeed())
+		return authz
+	}
+
+	// snapshot returns content of the snapshot file
+	snapshot := func() (string, error) {
+		raw, e := ioutil.ReadFile(path)
+		return string(raw), e
+	}
+
+	// save starts an authorizer making changes, and waits for the snapshot saved after changes are watched,
+	// tokens of listing are handshakes, which could not be resumed after restarts, until changes are watched
+	save := func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		authz := start(ctx)
+		Expect(authz.Shall(User("alan"), Article("apollo"), Write)).To(BeTrue())
+		Expect(authz.SubjectJoin(User("dave"), Role("editor"))).To(Succeed())
+		Expect(authz.ObjectJoin(Article("gemini"), Category("history"))).To(Succeed())
+		Expect(authz.Permit(User("bob"), Article("apollo"), Read)).To(Succeed())
+
+		Eventually(snapshot).Should(SatisfyAll(ContainSubstring("user:dave"), Not(ContainSubstring("handshake:"))))
+	}
+
+	It("should restart from the snapshot without listing polices", func() {
+		save()
+
+		By("changes made after saving are watched")
+		Expect(sp.Insert(User("carol"), Role("editor"))).To(Succeed())
+		Expect(pp.Remove(User("bob"), Article("apollo"))).To(Succeed())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		authz, e := rbac.New(ctx,
+			rbac.WithSubjectPersister(resumingGroupingPersister{sp, sp}),
+			rbac.WithObjectPersister(resumingGroupingPersister{op, op}),
+			rbac.WithPermissionPersister(resumingPermissionPersister{pp, pp}),
+			rbac.WithSnapshotFile(path, time.Hour),
+		)
+		Expect(e).To(Succeed())
+		Expect(authz.Shall(User("alan"), Article("apollo"), Write)).To(BeTrue())
+		Expect(authz.Shall(User("dave"), Article("gemini"), Write)).To(BeTrue())
+		Eventually(func() bool {
+			ok, _ := authz.Shall(User("carol"), Article("apollo"), Write)
+			return ok
+		}).Should(BeTrue())
+		Eventually(func() bool {
+			ok, _ := authz.Shall(User("bob"), Article("apollo"), Read)
+			return ok
+		}).Should(BeFalse())
+	})
+
+	It("should list polices without a valid snapshot", func() {
+		By("failing to resume without a snapshot")
+		_, e := rbac.New(context.Background(),
+			rbac.WithPermissionPersister(resumingPermissionPersister{pp, pp}),
+			rbac.WithSnapshotFile(path, time.Hour),
+		)
+		Expect(e).To(MatchError(errListed))
+
+		By("listing polices if the file is not a snapshot")
+		Expect(ioutil.WriteFile(path, []byte("not a snapshot"), 0644)).To(Succeed())
+		authz, e := rbac.New(context.Background(), rbac.WithPermissionPersister(pp), rbac.WithSnapshotFile(path, time.Hour))
+		Expect(e).To(Succeed())
+		Expect(authz.Shall(User("alan"), Article("apollo"), Read)).To(BeFalse())
+		Expect(authz.Shall(Role("editor"), Category("history"), Write)).To(BeTrue())
+
+		By("listing polices if tokens of listing are saved before any change is watched")
+		ctx, cancel := context.WithCancel(context.Background())
+		start(ctx)
+		Eventually(snapshot).Should(ContainSubstring("handshake:"))
+		cancel()
+		_, e = rbac.New(context.Background(),
+			rbac.WithPermissionPersister(resumingPermissionPersister{pp, pp}),
+			rbac.WithSnapshotFile(path, time.Hour),
+		)
+		Expect(e).To(MatchError(errListed))
+
+		By("listing polices if tokens are invalid")
+		save()
+		pp = fake.NewPermissionPersister()
+
+		ctx, cancel = context.WithCancel(context.Background())
+		defer cancel()
+		authz, e = rbac.New(ctx,
+			rbac.WithSubjectPersister(sp),
+			rbac.WithObjectPersister(op),
+			rbac.WithPermissionPersister(pp),
+			rbac.WithSnapshotFile(path, time.Hour),
+		)
+		Expect(e).To(Succeed())
+		Expect(authz.Shall(User("alan"), Article("apollo"), Read)).To(BeFalse())
+		Expect(authz.Shall(User("bob"), Article("apollo"), Read)).To(BeFalse())
+	})
+})
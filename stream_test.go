@@ -0,0 +1,70 @@
+package rbac_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/supremind/rbac"
+	"github.com/supremind/rbac/persist/fake"
+	. "github.com/supremind/rbac/types"
+)
+
+var errListed = errors.New("polices should be streamed instead of listed")
+
+// streamingGroupingPersister streams polices, and refuses to list them
+type streamingGroupingPersister struct {
+	GroupingPersister
+	stream GroupingStreamer
+}
+
+func (p streamingGroupingPersister) List() ([]GroupingPolicy, error) {
+	return nil, errListed
+}
+
+func (p streamingGroupingPersister) Each(ctx context.Context, fn func(GroupingPolicy) error) error {
+	return p.stream.Each(ctx, fn)
+}
+
+// streamingPermissionPersister streams polices, and refuses to list them
+type streamingPermissionPersister struct {
+	PermissionPersister
+	stream PermissionStreamer
+}
+
+func (p streamingPermissionPersister) List() ([]PermissionPolicy, error) {
+	return nil, errListed
+}
+
+func (p streamingPermissionPersister) Each(ctx context.Context, fn func(PermissionPolicy) error) error {
+	return p.stream.Each(ctx, fn)
+}
+
+var _ = Describe("authorizer with streaming persisters", func() {
+	It("should load polices by streaming them", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		sp := fake.NewGroupingPersister()
+		Expect(sp.Insert(User("alan"), Role("editor"))).To(Succeed())
+		op := fake.NewGroupingPersister()
+		Expect(op.Insert(Article("apollo"), Category("history"))).To(Succeed())
+		pp := fake.NewPermissionPersister()
+		Expect(pp.Insert(Role("editor"), Category("history"), ReadWrite)).To(Succeed())
+
+		authz, e := rbac.New(ctx,
+			rbac.WithSubjectPersister(streamingGroupingPersister{sp, sp}),
+			rbac.WithObjectPersister(streamingGroupingPersister{op, op}),
+			rbac.WithPermissionPersister(streamingPermissionPersister{pp, pp}),
+		)
+		Expect(e).To(Succeed())
+		Expect(authz.Shall(User("alan"), Article("apollo"), Write)).To(BeTrue())
+
+		By("failing as the persister fails to stream")
+		cancel()
+		_, e = rbac.New(ctx, rbac.WithPermissionPersister(streamingPermissionPersister{pp, pp}))
+		Expect(e).To(MatchError(context.Canceled))
+	})
+})
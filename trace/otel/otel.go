@@ -0,0 +1,75 @@
+// Package otel adapts OpenTelemetry tracers to types.Tracer
+package otel
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/supremind/rbac/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+var _ types.Tracer = (*Tracer)(nil)
+
+// Tracer starts OpenTelemetry spans for rbac
+type Tracer struct {
+	t oteltrace.Tracer
+}
+
+// New creates a Tracer starting spans with t,
+// parents of spans are taken from contexts, as OpenTelemetry does
+func New(t oteltrace.Tracer) *Tracer {
+	return &Tracer{t: t}
+}
+
+// Start implements types.Tracer
+func (t *Tracer) Start(ctx context.Context, name string, attrs ...types.Attribute) (context.Context, types.Span) {
+	ctx, span := t.t.Start(ctx, name, oteltrace.WithAttributes(convert(attrs)...))
+	return ctx, spanAdapter{span}
+}
+
+type spanAdapter struct {
+	oteltrace.Span
+}
+
+func (s spanAdapter) SetAttributes(attrs ...types.Attribute) {
+	s.Span.SetAttributes(convert(attrs)...)
+}
+
+// End records err and marks the span as failed if err is not nil
+func (s spanAdapter) End(err error) {
+	if err != nil {
+		s.Span.RecordError(err)
+		s.Span.SetStatus(codes.Error, err.Error())
+	}
+	s.Span.End()
+}
+
+func convert(attrs []types.Attribute) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		kvs = append(kvs, keyValue(attr))
+	}
+	return kvs
+}
+
+func keyValue(attr types.Attribute) attribute.KeyValue {
+	key := attribute.Key(attr.Key)
+	switch v := attr.Value.(type) {
+	case string:
+		return key.String(v)
+	case bool:
+		return key.Bool(v)
+	case int:
+		return key.Int(v)
+	case int64:
+		return key.Int64(v)
+	case float64:
+		return key.Float64(v)
+	case fmt.Stringer:
+		return key.String(v.String())
+	}
+	return key.String(fmt.Sprint(attr.Value))
+}
@@ -0,0 +1,82 @@
+package otel_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/supremind/rbac"
+	"github.com/supremind/rbac/persist/fake"
+	rbactrace "github.com/supremind/rbac/trace"
+	rbacotel "github.com/supremind/rbac/trace/otel"
+	. "github.com/supremind/rbac/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestOtel(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "otel test suit")
+}
+
+var _ = Describe("otel tracer", func() {
+	var (
+		recorder *tracetest.SpanRecorder
+		tracer   *rbacotel.Tracer
+	)
+
+	BeforeEach(func() {
+		recorder = tracetest.NewSpanRecorder()
+		tracer = rbacotel.New(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("rbac"))
+	})
+
+	It("should convert attributes and errors", func() {
+		ctx, parent := tracer.Start(context.Background(), "parent")
+		_, child := tracer.Start(ctx, "child",
+			Attr(rbactrace.AttrSubject, User("alan").String()),
+			Attr(rbactrace.AttrAllowed, true),
+			Attr(rbactrace.AttrGroups, 3),
+			Attr("action", Read),
+		)
+		child.End(errors.New("boom"))
+		parent.End(nil)
+
+		spans := recorder.Ended()
+		Expect(spans).To(HaveLen(2))
+		Expect(spans[0].Name()).To(Equal("child"))
+		Expect(spans[0].Parent().SpanID()).To(Equal(spans[1].SpanContext().SpanID()))
+		Expect(spans[0].Attributes()).To(ConsistOf(
+			attribute.String(rbactrace.AttrSubject, User("alan").String()),
+			attribute.Bool(rbactrace.AttrAllowed, true),
+			attribute.Int(rbactrace.AttrGroups, 3),
+			attribute.String("action", Read.String()),
+		))
+		Expect(spans[0].Status().Code).To(Equal(codes.Error))
+		Expect(spans[0].Events()).To(HaveLen(1))
+		Expect(spans[1].Status().Code).To(Equal(codes.Unset))
+	})
+
+	It("should trace authorizations", func() {
+		authz, e := rbac.NewContext(context.Background(),
+			rbac.WithSubjectPersister(fake.NewGroupingPersister()),
+			rbac.WithObjectPersister(fake.NewGroupingPersister()),
+			rbac.WithPermissionPersister(fake.NewPermissionPersister()),
+			rbac.WithTracer(tracer),
+		)
+		Expect(e).To(Succeed())
+
+		Expect(authz.PermitContext(context.Background(), User("alan"), Article("apollo"), Read)).To(Succeed())
+		Expect(authz.ShallContext(context.Background(), User("alan"), Article("apollo"), Read)).To(BeTrue())
+
+		names := make([]string, 0)
+		for _, span := range recorder.Ended() {
+			names = append(names, span.Name())
+		}
+		Expect(names).To(ContainElements("rbac.Permit", "rbac.permission.Permit", "rbac.persister.Insert", "rbac.Shall"))
+	})
+})
@@ -0,0 +1,106 @@
+package trace
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/supremind/rbac/types"
+)
+
+var _ types.Tracer = (*Recorder)(nil)
+
+// Recorder keeps ended spans in memory, it is meant to verify spans in tests
+type Recorder struct {
+	spans  []RecordedSpan
+	nextID uint64
+	sync.Mutex
+}
+
+// RecordedSpan is an ended span
+type RecordedSpan struct {
+	ID         uint64
+	ParentID   uint64 // zero if the span has no parent
+	Name       string
+	Attributes map[string]interface{}
+	Start      time.Time
+	End        time.Time
+	Err        error
+}
+
+// NewRecorder creates a Recorder
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+type spanKey struct{}
+
+// Start implements types.Tracer
+func (r *Recorder) Start(ctx context.Context, name string, attrs ...types.Attribute) (context.Context, types.Span) {
+	r.Lock()
+	r.nextID++
+	id := r.nextID
+	r.Unlock()
+
+	s := &recordingSpan{
+		recorder: r,
+		span: RecordedSpan{
+			ID:         id,
+			Name:       name,
+			Attributes: make(map[string]interface{}, len(attrs)),
+			Start:      time.Now(),
+		},
+	}
+	if parent, ok := ctx.Value(spanKey{}).(*recordingSpan); ok {
+		s.span.ParentID = parent.span.ID
+	}
+	s.SetAttributes(attrs...)
+
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// Spans returns spans ended so far, in the order of ending
+func (r *Recorder) Spans() []RecordedSpan {
+	r.Lock()
+	defer r.Unlock()
+
+	return append([]RecordedSpan(nil), r.spans...)
+}
+
+// Reset drops all recorded spans
+func (r *Recorder) Reset() {
+	r.Lock()
+	r.spans = nil
+	r.Unlock()
+}
+
+type recordingSpan struct {
+	recorder *Recorder
+	span     RecordedSpan
+	sync.Mutex
+}
+
+func (s *recordingSpan) SetAttributes(attrs ...types.Attribute) {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, attr := range attrs {
+		s.span.Attributes[attr.Key] = attr.Value
+	}
+}
+
+func (s *recordingSpan) End(err error) {
+	s.Lock()
+	s.span.End = time.Now()
+	s.span.Err = err
+	span := s.span
+	span.Attributes = make(map[string]interface{}, len(s.span.Attributes))
+	for k, v := range s.span.Attributes {
+		span.Attributes[k] = v
+	}
+	s.Unlock()
+
+	s.recorder.Lock()
+	s.recorder.spans = append(s.recorder.spans, span)
+	s.recorder.Unlock()
+}
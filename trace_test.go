@@ -0,0 +1,97 @@
+package rbac_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/supremind/rbac"
+	"github.com/supremind/rbac/persist/fake"
+	"github.com/supremind/rbac/trace"
+	. "github.com/supremind/rbac/types"
+)
+
+var _ = Describe("authorizer with tracer", func() {
+	var (
+		recorder *trace.Recorder
+		authz    AuthorizerContext
+	)
+
+	BeforeEach(func() {
+		recorder = trace.NewRecorder()
+		var e error
+		authz, e = rbac.NewContext(context.Background(),
+			rbac.WithSubjectPersister(fake.NewGroupingPersister()),
+			rbac.WithObjectPersister(fake.NewGroupingPersister()),
+			rbac.WithPermissionPersister(fake.NewPermissionPersister()),
+			rbac.WithTracer(recorder),
+		)
+		Expect(e).To(Succeed())
+	})
+
+	// find the only span named name
+	find := func(name string) trace.RecordedSpan {
+		var found []trace.RecordedSpan
+		for _, span := range recorder.Spans() {
+			if span.Name == name {
+				found = append(found, span)
+			}
+		}
+		Expect(found).To(HaveLen(1), "span %s", name)
+		return found[0]
+	}
+
+	It("should trace writes down to persister round-trips", func() {
+		ctx, root := recorder.Start(context.Background(), "request")
+		Expect(authz.SubjectJoinContext(ctx, User("alan"), Role("editor"))).To(Succeed())
+		root.End(nil)
+
+		join := find("rbac.SubjectJoin")
+		Expect(join.ParentID).To(Equal(find("request").ID))
+		Expect(join.Attributes).To(HaveKeyWithValue(trace.AttrEntity, User("alan").String()))
+		Expect(join.Attributes).To(HaveKeyWithValue(trace.AttrGroup, Role("editor").String()))
+
+		grouping := find("rbac.grouping.Join")
+		Expect(grouping.ParentID).To(Equal(join.ID))
+		Expect(grouping.Attributes).To(HaveKeyWithValue(trace.AttrComponent, "subject"))
+
+		insert := find("rbac.persister.Insert")
+		Expect(insert.ParentID).To(Equal(grouping.ID))
+		Expect(insert.Err).To(BeNil())
+	})
+
+	It("should trace decisions and groupings expansions", func() {
+		Expect(authz.SubjectJoinContext(context.Background(), User("alan"), Role("editor"))).To(Succeed())
+		Expect(authz.ObjectJoinContext(context.Background(), Article("apollo"), Category("space"))).To(Succeed())
+		Expect(authz.PermitContext(context.Background(), Role("editor"), Category("space"), Read)).To(Succeed())
+		recorder.Reset()
+
+		Expect(authz.ShallContext(context.Background(), User("alan"), Article("apollo"), Read)).To(BeTrue())
+
+		shall := find("rbac.Shall")
+		Expect(shall.ParentID).To(BeZero())
+		Expect(shall.Attributes).To(HaveKeyWithValue(trace.AttrAction, Read.String()))
+		Expect(shall.Attributes).To(HaveKeyWithValue(trace.AttrAllowed, true))
+
+		var expansions []trace.RecordedSpan
+		for _, span := range recorder.Spans() {
+			if span.Name == "rbac.GroupsOf" {
+				expansions = append(expansions, span)
+			}
+		}
+		Expect(expansions).To(HaveLen(2))
+		for _, span := range expansions {
+			Expect(span.ParentID).To(Equal(shall.ID))
+			Expect(span.Attributes).To(HaveKeyWithValue(trace.AttrGroups, 1))
+		}
+	})
+
+	It("should record errors of failed operations", func() {
+		e := authz.SubjectLeaveContext(context.Background(), User("alan"), Role("editor"))
+		Expect(e).To(MatchError(ErrNotFound))
+
+		Expect(find("rbac.SubjectLeave").Err).To(Equal(e))
+		Expect(find("rbac.persister.Remove").Err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,182 @@
+package types
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// AuditSink receives audit events of policy mutations and authorization decisions
+type AuditSink interface {
+	// Audit records an event, it should not block for long, as it is called in line with the audited operation
+	Audit(AuditEvent) error
+}
+
+// AuditOperation names the audited operation
+type AuditOperation string
+
+// audited operations, named after methods of Authorizer
+const (
+	AuditSubjectJoin    AuditOperation = "subject_join"
+	AuditSubjectLeave   AuditOperation = "subject_leave"
+	AuditRemoveUser     AuditOperation = "remove_user"
+	AuditRemoveRole     AuditOperation = "remove_role"
+	AuditObjectJoin     AuditOperation = "object_join"
+	AuditObjectLeave    AuditOperation = "object_leave"
+	AuditRemoveArticle  AuditOperation = "remove_article"
+	AuditRemoveCategory AuditOperation = "remove_category"
+	AuditPermit         AuditOperation = "permit"
+	AuditRevoke         AuditOperation = "revoke"
+	AuditShall          AuditOperation = "shall"
+)
+
+// AuditEvent is a structured record about a policy mutation or an authorization decision,
+// only fields make sense to the operation are set
+type AuditEvent struct {
+	Time      time.Time
+	Actor     string
+	Operation AuditOperation
+
+	// Entity and Group are set for grouping operations
+	Entity Entity
+	Group  Group
+
+	// Subject, Object and Action are set for permission operations and decisions
+	Subject Subject
+	Object  Object
+	Action  Action
+
+	// Before and After are actions directly permitted to Subject on Object, around a permission change
+	Before Action
+	After  Action
+
+	// GroupsBefore and GroupsAfter are groups Entity, or Group removed, belongs to around a grouping change
+	// or a removal of users and roles, directly or not, ordered by names
+	GroupsBefore []Group
+	GroupsAfter  []Group
+
+	// Revoked are permissions directly permitted to or on the removed entity, ordered by subjects and objects,
+	// they are set for removals
+	Revoked []AuditRevocation
+
+	// Allowed is the decision of Shall
+	Allowed bool
+
+	// Error is the result of the operation, nil if succeeded
+	Error error
+}
+
+// AuditRevocation is a permission revoked by removing an entity,
+// Before and After are actions directly permitted to Subject on Object around the removal
+type AuditRevocation struct {
+	Subject Subject
+	Object  Object
+	Before  Action
+	After   Action
+}
+
+// MarshalJSON encodes entities in their serialized form, and actions by names
+func (r AuditRevocation) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Subject string `json:"subject"`
+		Object  string `json:"object"`
+		Before  string `json:"before"`
+		After   string `json:"after"`
+	}{
+		Subject: r.Subject.String(),
+		Object:  r.Object.String(),
+		Before:  r.Before.String(),
+		After:   r.After.String(),
+	})
+}
+
+// MarshalJSON encodes entities in their serialized form, and actions by names
+func (e AuditEvent) MarshalJSON() ([]byte, error) {
+	doc := struct {
+		Time         time.Time          `json:"time"`
+		Actor        string             `json:"actor,omitempty"`
+		Operation    AuditOperation     `json:"operation"`
+		Entity       string             `json:"entity,omitempty"`
+		Group        string             `json:"group,omitempty"`
+		Subject      string             `json:"subject,omitempty"`
+		Object       string             `json:"object,omitempty"`
+		Action       string             `json:"action,omitempty"`
+		Before       *string            `json:"before,omitempty"`
+		After        *string            `json:"after,omitempty"`
+		GroupsBefore *[]string          `json:"groups_before,omitempty"`
+		GroupsAfter  *[]string          `json:"groups_after,omitempty"`
+		Revoked      *[]AuditRevocation `json:"revoked,omitempty"`
+		Allowed      *bool              `json:"allowed,omitempty"`
+		Error        string             `json:"error,omitempty"`
+	}{
+		Time:      e.Time,
+		Actor:     e.Actor,
+		Operation: e.Operation,
+		Action:    e.Action.String(),
+	}
+
+	if e.Entity != nil {
+		doc.Entity = e.Entity.String()
+	}
+	if e.Group != nil {
+		doc.Group = e.Group.String()
+	}
+	if e.Subject != nil {
+		doc.Subject = e.Subject.String()
+	}
+	if e.Object != nil {
+		doc.Object = e.Object.String()
+	}
+
+	switch e.Operation {
+	case AuditPermit, AuditRevoke:
+		before, after := e.Before.String(), e.After.String()
+		doc.Before, doc.After = &before, &after
+	case AuditShall:
+		doc.Allowed = &e.Allowed
+	case AuditRemoveArticle, AuditRemoveCategory:
+		doc.Revoked = revocations(e.Revoked)
+	case AuditRemoveUser, AuditRemoveRole:
+		doc.Revoked = revocations(e.Revoked)
+		fallthrough
+	default:
+		before, after := groupNames(e.GroupsBefore), groupNames(e.GroupsAfter)
+		doc.GroupsBefore, doc.GroupsAfter = &before, &after
+	}
+
+	if e.Error != nil {
+		doc.Error = e.Error.Error()
+	}
+
+	return json.Marshal(doc)
+}
+
+// groupNames returns names of groups
+func groupNames(groups []Group) []string {
+	names := make([]string, 0, len(groups))
+	for _, group := range groups {
+		names = append(names, group.String())
+	}
+	return names
+}
+
+// revocations returns revoked, or an empty list if there is none, so that removals always tell what they revoked
+func revocations(revoked []AuditRevocation) *[]AuditRevocation {
+	if revoked == nil {
+		revoked = []AuditRevocation{}
+	}
+	return &revoked
+}
+
+type actorKey struct{}
+
+// WithActor returns a context carrying who is operating, it will be recorded in audit events
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor carried by the context, or empty if there is none
+func ActorFrom(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
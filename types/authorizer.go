@@ -1,5 +1,7 @@
 package types
 
+import "time"
+
 // Authorizer is the top level interface for end use.
 // It decides if anyone can do anthing to some object,
 // with knowledge of user groupings, article groupings, and permission polices
@@ -47,3 +49,9 @@ type Objector interface {
 
 // PresetPolicy
 type PresetPolicy func(Authorizer, Subject, Object, Action) bool
+
+// Historian knows how polices were in the past
+type Historian interface {
+	// SnapshotAt returns a read-only Authorizer, which decides with polices as they were at the given time
+	SnapshotAt(time.Time) (Authorizer, error)
+}
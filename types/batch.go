@@ -0,0 +1,30 @@
+package types
+
+import "context"
+
+// ObjectAction is an action to be performed on an object
+type ObjectAction struct {
+	Object Object
+	Action Action
+}
+
+// BatchChecker checks permissions of a subject in batches, and roles of the subject are expanded once.
+// Authorizers created by rbac.New implement it: copy-on-write ones decide a batch with one consistent snapshot of polices,
+// others block writes made through them during the batch, but not changes watched from persisters.
+// Decisions taken from caches or indexes may be newer than the rest of the batch.
+type BatchChecker interface {
+	// ShallMany tells if subject shall perform actions on objects, results are in the order of checks
+	ShallMany(sub Subject, checks []ObjectAction) ([]bool, error)
+
+	// FilterAllowed returns objects subject shall perform action on, in their given order
+	FilterAllowed(sub Subject, act Action, objs []Object) ([]Object, error)
+}
+
+// BatchCheckerContext is the context-aware variant of BatchChecker
+type BatchCheckerContext interface {
+	// ShallManyContext tells if subject shall perform actions on objects, results are in the order of checks
+	ShallManyContext(ctx context.Context, sub Subject, checks []ObjectAction) ([]bool, error)
+
+	// FilterAllowedContext returns objects subject shall perform action on, in their given order
+	FilterAllowedContext(ctx context.Context, sub Subject, act Action, objs []Object) ([]Object, error)
+}
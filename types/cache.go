@@ -0,0 +1,23 @@
+package types
+
+// DecisionCacheStats counts how the decision cache is used
+type DecisionCacheStats struct {
+	// Size is the number of decisions cached
+	Size int
+
+	// Hits and Misses count decisions found in the cache or not
+	Hits   uint64
+	Misses uint64
+
+	// Evictions counts decisions dropped as the least recently used
+	Evictions uint64
+
+	// Invalidations counts decisions dropped as polices they depend on changed
+	Invalidations uint64
+}
+
+// DecisionCacher is an authorizer caching its decisions
+type DecisionCacher interface {
+	// DecisionCacheStats returns statistics of the decision cache
+	DecisionCacheStats() (DecisionCacheStats, error)
+}
@@ -0,0 +1,116 @@
+package types
+
+import "context"
+
+// AuthorizerContext is the context-aware variant of Authorizer,
+// the context could cancel slow persister writes, carry deadlines, and tell who is operating
+type AuthorizerContext interface {
+	SubjectorContext
+	ObjectorContext
+	PermissionContext
+}
+
+// SubjectorContext is the context-aware variant of Subjector
+type SubjectorContext interface {
+	// SubjectJoinContext joins a user or a sub role to a role
+	SubjectJoinContext(ctx context.Context, sub Subject, role Role) error
+
+	// SubjectLeaveContext removes a user or a sub role from a role
+	SubjectLeaveContext(ctx context.Context, sub Subject, role Role) error
+
+	// RemoveUserContext removes a user and all policies about it
+	RemoveUserContext(ctx context.Context, user User) error
+
+	// RemoveRoleContext removes a role and all policies about it
+	RemoveRoleContext(ctx context.Context, role Role) error
+
+	// Subjects returns the GroupingReader interface for subjects
+	Subjects() GroupingReader
+}
+
+// ObjectorContext is the context-aware variant of Objector
+type ObjectorContext interface {
+	// ObjectJoinContext joins an article or a sub category to a category
+	ObjectJoinContext(ctx context.Context, obj Object, cat Category) error
+
+	// ObjectLeaveContext removes an article or a sub category from a category
+	ObjectLeaveContext(ctx context.Context, obj Object, cat Category) error
+
+	// RemoveArticleContext removes an article and all polices about it
+	RemoveArticleContext(ctx context.Context, art Article) error
+
+	// RemoveCategoryContext removes a category and all polices about it
+	RemoveCategoryContext(ctx context.Context, cat Category) error
+
+	// Objects returns the GroupingReader interface for objects
+	Objects() GroupingReader
+}
+
+// PermissionContext is the context-aware variant of Permission
+type PermissionContext interface {
+	// PermitContext permits subject to perform action on object
+	PermitContext(ctx context.Context, sub Subject, obj Object, act Action) error
+
+	// RevokeContext revokes permission for subject to perform action on object
+	RevokeContext(ctx context.Context, sub Subject, obj Object, act Action) error
+
+	// ShallContext tells if subject shall perform action on object
+	ShallContext(ctx context.Context, sub Subject, obj Object, act Action) (bool, error)
+
+	// PermissionsOnContext returns permissions on object for all subjects
+	PermissionsOnContext(ctx context.Context, obj Object) (map[Subject]Action, error)
+
+	// PermissionsForContext returns permissions for subject on all objects
+	PermissionsForContext(ctx context.Context, sub Subject) (map[Object]Action, error)
+
+	// PermittedActionsContext returns permitted actions for subject on object
+	PermittedActionsContext(ctx context.Context, sub Subject, obj Object) (Action, error)
+}
+
+// GroupingWriterContext is the context-aware variant of GroupingWriter
+type GroupingWriterContext interface {
+	// JoinContext joins an Entity to a Group
+	JoinContext(context.Context, Entity, Group) error
+
+	// LeaveContext removes an Entity from a Group
+	LeaveContext(context.Context, Entity, Group) error
+
+	// RemoveGroupContext removes a Group, and all relationships about it
+	RemoveGroupContext(context.Context, Group) error
+
+	// RemoveMemberContext removes an Member, and all relationships about it
+	RemoveMemberContext(context.Context, Member) error
+}
+
+// GroupingPersisterContext is the context-aware variant of GroupingPersister
+type GroupingPersisterContext interface {
+	// InsertContext inserts a policy to the persister
+	InsertContext(context.Context, Entity, Group) error
+
+	// RemoveContext removes a policy from the persister
+	RemoveContext(context.Context, Entity, Group) error
+
+	// ListContext lists all policies from the persister
+	ListContext(context.Context) ([]GroupingPolicy, error)
+
+	// Watch any changes occurred about the policies in the persister
+	Watch(context.Context) (<-chan GroupingPolicyChange, error)
+}
+
+// PermissionPersisterContext is the context-aware variant of PermissionPersister
+type PermissionPersisterContext interface {
+	// InsertContext inserts a permission policy to the persister
+	InsertContext(context.Context, Subject, Object, Action) error
+
+	// UpdateContext updates a permission policy to the persister
+	UpdateContext(context.Context, Subject, Object, Action) error
+
+	// RemoveContext removes a permission policy from the persister
+	RemoveContext(context.Context, Subject, Object) error
+
+	// ListContext lists all polices from the persister
+	ListContext(context.Context) ([]PermissionPolicy, error)
+
+	// Watch any changes occurred about the polices in the persister
+	Watch(context.Context) (<-chan PermissionPolicyChange, error)
+}
@@ -15,4 +15,9 @@ var (
 	ErrNoObjectGrouping  = errors.New("object grouping is not used")
 	ErrUnsupportedChange = errors.New("persister changes in a way unsupported")
 	ErrUnknownAction     = errors.New("unknown action")
+	ErrReadOnly          = errors.New("read only")
+	ErrNoHistory         = errors.New("no history kept")
+	ErrNoPolicyList      = errors.New("polices could not be listed")
+	ErrNoDecisionCache   = errors.New("decisions are not cached")
+	ErrInvalidToken      = errors.New("invalid resume token, changes after it could not be watched")
 )
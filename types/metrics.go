@@ -0,0 +1,25 @@
+package types
+
+import "time"
+
+// Metrics receives measurements of rbac components, it must be safe for concurrent use.
+// Components are named after what they manage: subject, object, or permission.
+type Metrics interface {
+	// ObserveDecision records a decision of Shall, and how long it took
+	ObserveDecision(act Action, allowed bool, err error, took time.Duration)
+
+	// ObservePersist records a write to the persister of component, and how long it took
+	ObservePersist(component string, method PersistMethod, err error, took time.Duration)
+
+	// ObserveChange records a change watched from the persister of component,
+	// filtered is true if the change was made by this replica, and so it is not applied again
+	ObserveChange(component string, method PersistMethod, filtered bool)
+
+	// ObserveSyncLag records how long it took for a change to be watched by this replica since it was made,
+	// for changes made by this replica and come back from the persister,
+	// and for changes made by others if the persister tells when they were made
+	ObserveSyncLag(component string, lag time.Duration)
+
+	// SetPolicies records the number of polices held by component
+	SetPolicies(component string, n int)
+}
@@ -1,6 +1,9 @@
 package types
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // GroupingPersister persists member-group relationship polices to an external storage
 type GroupingPersister interface {
@@ -35,6 +38,71 @@ type PermissionPersister interface {
 	Watch(context.Context) (<-chan PermissionPolicyChange, error)
 }
 
+// GroupingQuerier queries grouping polices of an entity or a group, so that polices could be loaded on demand,
+// instead of being listed all at once.
+// Queried polices are paginated: they are ordered by the other side of the polices, a page lists up to limit polices
+// after the cursor, and returns the cursor of the next page, which is empty after the last page.
+// An empty cursor starts from the first page, and a non-positive limit lists all remained polices at once.
+type GroupingQuerier interface {
+	// ListByEntity lists polices of groups the entity immediately belongs to, ordered by groups
+	ListByEntity(ent Entity, cursor string, limit int) ([]GroupingPolicy, string, error)
+
+	// ListByGroup lists polices of entities immediately belong to the group, ordered by entities
+	ListByGroup(group Group, cursor string, limit int) ([]GroupingPolicy, string, error)
+}
+
+// PermissionQuerier queries permission polices of a subject or an object, so that polices could be loaded on demand,
+// instead of being listed all at once.
+// Queried polices are paginated as GroupingQuerier does.
+type PermissionQuerier interface {
+	// Get the action permitted to the subject on the object, or ErrNotFound
+	Get(Subject, Object) (Action, error)
+
+	// ListBySubject lists polices permitting the subject, ordered by objects
+	ListBySubject(sub Subject, cursor string, limit int) ([]PermissionPolicy, string, error)
+
+	// ListByObject lists polices permitting on the object, ordered by subjects
+	ListByObject(obj Object, cursor string, limit int) ([]PermissionPolicy, string, error)
+}
+
+// GroupingStreamer streams all grouping polices one by one, so that they are not listed in memory all at once
+type GroupingStreamer interface {
+	// Each calls fn with every policy in the persister, it stops at the first error returned by fn or the persister,
+	// and returns it
+	Each(ctx context.Context, fn func(GroupingPolicy) error) error
+}
+
+// PermissionStreamer streams all permission polices one by one, so that they are not listed in memory all at once
+type PermissionStreamer interface {
+	// Each calls fn with every policy in the persister, it stops at the first error returned by fn or the persister,
+	// and returns it
+	Each(ctx context.Context, fn func(PermissionPolicy) error) error
+}
+
+// GroupingResumer lists polices together with the position of the change stream they are listed at,
+// and watches changes after positions, so that changes made between listing and watching are not lost,
+// and watching could be resumed after restarts.
+// Positions are opaque resume tokens. Changes right after the position of listed polices may be listed already,
+// they are watched again, and should be applied idempotently.
+type GroupingResumer interface {
+	// ListWithToken lists all polices, with the token of the position they are listed at
+	ListWithToken(context.Context) ([]GroupingPolicy, string, error)
+
+	// WatchFrom watches changes after the position of token, along with tokens of their own positions,
+	// it fails with ErrInvalidToken if the changes are not kept any more
+	WatchFrom(ctx context.Context, token string) (<-chan ResumableGroupingChange, error)
+}
+
+// PermissionResumer lists polices and watches changes after them with resume tokens, as GroupingResumer does
+type PermissionResumer interface {
+	// ListWithToken lists all polices, with the token of the position they are listed at
+	ListWithToken(context.Context) ([]PermissionPolicy, string, error)
+
+	// WatchFrom watches changes after the position of token, along with tokens of their own positions,
+	// it fails with ErrInvalidToken if the changes are not kept any more
+	WatchFrom(ctx context.Context, token string) (<-chan ResumablePermissionChange, error)
+}
+
 // GroupingPolicy is an entity-group releationship policy
 type GroupingPolicy struct {
 	Entity Entity
@@ -47,6 +115,15 @@ type GroupingPolicyChange struct {
 	Method PersistMethod
 }
 
+// ResumableGroupingChange is a GroupingPolicyChange, with the token to resume watching right after it
+type ResumableGroupingChange struct {
+	GroupingPolicyChange
+	Token string
+
+	// Time is when the change was made in the persister, zero if the persister does not tell it
+	Time time.Time
+}
+
 // PermissionPolicy is a subject-object-action permission policy
 type PermissionPolicy struct {
 	Subject Subject
@@ -60,6 +137,15 @@ type PermissionPolicyChange struct {
 	Method PersistMethod
 }
 
+// ResumablePermissionChange is a PermissionPolicyChange, with the token to resume watching right after it
+type ResumablePermissionChange struct {
+	PermissionPolicyChange
+	Token string
+
+	// Time is when the change was made in the persister, zero if the persister does not tell it
+	Time time.Time
+}
+
 // PersistMethod defines what happened about the policies
 type PersistMethod string
 
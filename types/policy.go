@@ -0,0 +1,14 @@
+package types
+
+// Policies are polices assigned directly, the ones derived through groupings are not included
+type Policies struct {
+	Subjects    []GroupingPolicy
+	Objects     []GroupingPolicy
+	Permissions []PermissionPolicy
+}
+
+// PolicyLister lists all polices, Authorizers created by rbac.New implement it
+type PolicyLister interface {
+	// Policies lists all polices assigned directly
+	Policies() (Policies, error)
+}
@@ -0,0 +1,31 @@
+package types
+
+import "context"
+
+// Tracer starts spans around operations, to tell where the time went
+type Tracer interface {
+	// Start a span named name, as a child of the span carried by ctx if any,
+	// the returned context carries the new span
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+// Span is a traced operation
+type Span interface {
+	// SetAttributes adds attributes describing the operation
+	SetAttributes(attrs ...Attribute)
+
+	// End the span, err is the result of the operation, nil if succeeded
+	End(err error)
+}
+
+// Attribute is a key-value pair describing a span,
+// values are expected to be strings, booleans, or numbers
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Attr creates an Attribute
+func Attr(key string, value interface{}) Attribute {
+	return Attribute{Key: key, Value: value}
+}